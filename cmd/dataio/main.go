@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/dataio"
+	"vagues-go/src/history"
+	"vagues-go/src/marketdata"
+	"vagues-go/src/recorder"
+)
+
+// 导入外部 CSV/Parquet 数据用于回测，导出本地K线
+//
+//	go run ./cmd/dataio import-klines -file BTCUSDT-1m-2025-01.csv -symbol BTC_USDC_PERP -interval 1m -no-header -columns time=0,open=1,high=2,low=3,close=4,volume=5
+//	go run ./cmd/dataio import-trades -file trades.parquet -symbol SOL_USDC_PERP -dir data/market
+//	go run ./cmd/dataio export-klines -symbol SOL_USDC_PERP -interval 1h -from 2025-01-01 -out sol_1h.parquet
+const usage = `用法:
+  dataio import-klines -file <csv|parquet> -symbol SOL_USDC_PERP -interval 1m [-dir data/klines]   导入K线到本地K线目录
+  dataio import-trades -file <csv|parquet> -symbol SOL_USDC_PERP [-dir data/market]               导入成交到录制目录（可回放）
+  dataio export-klines -symbol SOL_USDC_PERP -interval 1m -out <csv|parquet> [-from] [-to] [-fetch] 导出本地K线
+平仓订单的导出见 ORDERS_EXPORT_FILE（实盘、多交易对和回放模式退出时写入）`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	var err error
+	switch command {
+	case "import-klines":
+		err = importKlines(os.Args[2:])
+	case "import-trades":
+		err = importTrades(os.Args[2:])
+	case "export-klines":
+		err = exportKlines(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("❌ %s: %v", command, err)
+	}
+}
+
+// importFlags holds the flags shared by the import commands
+type importFlags struct {
+	file       *string
+	symbol     *string
+	format     *string
+	columns    *string
+	timeUnit   *string
+	timeLayout *string
+	timezone   *string
+	noHeader   *bool
+	comma      *string
+}
+
+func newImportFlags(flags *flag.FlagSet) importFlags {
+	return importFlags{
+		file:       flags.String("file", "", "导入的 CSV（可为 .csv.gz）或 Parquet 文件"),
+		symbol:     flags.String("symbol", "SOL_USDC_PERP", "交易对"),
+		format:     flags.String("format", "", "文件格式 csv|parquet（默认按扩展名）"),
+		columns:    flags.String("columns", "", "列映射，如 time=open_time,volume=vol；值为整数时表示列序号"),
+		timeUnit:   flags.String("time-unit", "auto", "数值时间戳单位 s|ms|us|ns|auto"),
+		timeLayout: flags.String("time-layout", "", "文本时间的 Go 格式（默认尝试 RFC3339 和常见格式）"),
+		timezone:   flags.String("tz", "UTC", "不带时区的文本时间所在时区，如 Asia/Shanghai"),
+		noHeader:   flags.Bool("no-header", false, "CSV 没有表头（需用列序号指定列）"),
+		comma:      flags.String("comma", ",", "CSV 分隔符"),
+	}
+}
+
+// options converts the flags to import options
+func (f importFlags) options() (dataio.Options, error) {
+	if *f.file == "" {
+		return dataio.Options{}, fmt.Errorf("需要指定 -file")
+	}
+	format, err := dataio.ParseFormat(*f.format)
+	if err != nil {
+		return dataio.Options{}, err
+	}
+	columns, err := dataio.ParseColumns(*f.columns)
+	if err != nil {
+		return dataio.Options{}, err
+	}
+	unit, err := dataio.ParseTimeUnit(*f.timeUnit)
+	if err != nil {
+		return dataio.Options{}, err
+	}
+	location, err := time.LoadLocation(*f.timezone)
+	if err != nil {
+		return dataio.Options{}, fmt.Errorf("无效的时区 -tz=%q: %w", *f.timezone, err)
+	}
+	comma, size := utf8.DecodeRuneInString(*f.comma)
+	if *f.comma == `\t` {
+		comma, size = '\t', 2
+	}
+	if size != len(*f.comma) {
+		return dataio.Options{}, fmt.Errorf("无效的分隔符 -comma=%q", *f.comma)
+	}
+	return dataio.Options{
+		Format:     format,
+		Columns:    columns,
+		TimeUnit:   unit,
+		TimeLayout: *f.timeLayout,
+		Location:   location,
+		Symbol:     *f.symbol,
+		NoHeader:   *f.noHeader,
+		Comma:      comma,
+	}, nil
+}
+
+// importKlines imports a K-line file into the local K-line store
+func importKlines(args []string) error {
+	flags := flag.NewFlagSet("import-klines", flag.ExitOnError)
+	importOpts := newImportFlags(flags)
+	intervalStr := flags.String("interval", "1m", "K线周期（本地K线目录的周期）")
+	dir := flags.String("dir", envOr("KLINE_DATA_DIR", "data/klines"), "本地K线目录")
+	flags.Parse(args)
+
+	opts, err := importOpts.options()
+	if err != nil {
+		return err
+	}
+	interval, err := marketdata.ParseInterval(*intervalStr)
+	if err != nil {
+		return err
+	}
+	opts.Interval = interval.Duration()
+
+	klines, err := dataio.ImportKlines(*importOpts.file, opts)
+	if err != nil {
+		return err
+	}
+
+	// 只保存与周期对齐的K线，结束时间按周期计算（月线按自然月）
+	bars := make([]history.Bar, 0, len(klines))
+	misaligned := 0
+	for _, kline := range klines {
+		if !interval.Floor(kline.StartTime).Equal(kline.StartTime) {
+			misaligned++
+			continue
+		}
+		kline.EndTime = interval.Next(kline.StartTime)
+		bars = append(bars, history.BarFromKLine(kline))
+	}
+
+	store := history.NewStore(*dir)
+	duplicates, err := store.Append(*importOpts.symbol, interval.String(), bars)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %s: 读取 %d 根, 写入 %d 根 (替换 %d 根), 丢弃未对齐 %d 根",
+		*importOpts.symbol, interval, len(klines), len(bars), duplicates, misaligned)
+	if len(bars) > 0 {
+		fmt.Printf(", %s 至 %s", formatTime(bars[0].Start), formatTime(bars[len(bars)-1].Start))
+	}
+	fmt.Println()
+	return nil
+}
+
+// importTrades writes the trades of a file as recorded trade stream messages
+func importTrades(args []string) error {
+	flags := flag.NewFlagSet("import-trades", flag.ExitOnError)
+	importOpts := newImportFlags(flags)
+	dir := flags.String("dir", envOr("MARKET_DATA_RECORD_DIR", "data/market"), "录制目录（回放时作为 REPLAY_DIR）")
+	flags.Parse(args)
+
+	opts, err := importOpts.options()
+	if err != nil {
+		return err
+	}
+	trades, err := dataio.ImportTrades(*importOpts.file, opts)
+	if err != nil {
+		return err
+	}
+
+	rec, err := recorder.New(*dir)
+	if err != nil {
+		return err
+	}
+	defer rec.Close()
+	for _, trade := range trades {
+		record, err := dataio.TradeRecord(trade)
+		if err == nil {
+			err = rec.Write(record)
+		}
+		if err != nil {
+			return fmt.Errorf("写入成交 %d 失败: %w", trade.ID, err)
+		}
+	}
+	rec.Close()
+
+	fmt.Printf("%s: 导入 %d 笔成交", *importOpts.symbol, len(trades))
+	if len(trades) > 0 {
+		fmt.Printf(", %s 至 %s", formatTime(trades[0].Time), formatTime(trades[len(trades)-1].Time))
+	}
+	fmt.Println()
+	return nil
+}
+
+// exportKlines exports local K-lines, optionally downloading missing bars first
+func exportKlines(args []string) error {
+	flags := flag.NewFlagSet("export-klines", flag.ExitOnError)
+	symbol := flags.String("symbol", "SOL_USDC_PERP", "交易对")
+	intervalStr := flags.String("interval", "1m", "K线周期")
+	fromStr := flags.String("from", "", "开始时间（YYYY-MM-DD 或 RFC3339，默认为最早的K线）")
+	toStr := flags.String("to", "", "结束时间（YYYY-MM-DD 或 RFC3339，默认为最新的K线）")
+	out := flags.String("out", "", "导出文件（.csv、.csv.gz 或 .parquet）")
+	formatStr := flags.String("format", "", "文件格式 csv|parquet（默认按扩展名）")
+	timeUnitStr := flags.String("time-unit", "", "CSV 时间列格式：为空时为 RFC3339，或 s|ms|us|ns 数值时间戳")
+	fetch := flags.Bool("fetch", false, "导出前从交易所下载缺失的K线（需要 -from）")
+	dir := flags.String("dir", envOr("KLINE_DATA_DIR", "data/klines"), "本地K线目录")
+	flags.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("需要指定 -out")
+	}
+	format, err := dataio.ParseFormat(*formatStr)
+	if err != nil {
+		return err
+	}
+	timeUnit, err := dataio.ParseTimeUnit(*timeUnitStr)
+	if err != nil {
+		return err
+	}
+	from, err := parseTime(*fromStr)
+	if err != nil {
+		return fmt.Errorf("无效的开始时间 -from=%q: %w", *fromStr, err)
+	}
+	to, err := parseTime(*toStr)
+	if err != nil {
+		return fmt.Errorf("无效的结束时间 -to=%q: %w", *toStr, err)
+	}
+
+	store := history.NewStore(*dir)
+	if *fetch {
+		if from.IsZero() {
+			return fmt.Errorf("-fetch 需要指定 -from")
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		// K线是公开端点，不需要真实密钥
+		client, err := backpack.NewClient("public", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+		if err != nil {
+			return err
+		}
+		stats, err := history.NewDownloader(client, store).Download(ctx, *symbol, *intervalStr, from, to)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s: 下载 %d 根\n", *symbol, *intervalStr, stats.Bars)
+	}
+
+	bars, err := store.Range(*symbol, *intervalStr, from, to)
+	if err != nil {
+		return err
+	}
+	klines, err := history.KLines(bars)
+	if err != nil {
+		return err
+	}
+	if err := dataio.ExportKlines(*out, klines, dataio.ExportOptions{Format: format, TimeUnit: timeUnit}); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s: 导出 %d 根到 %s\n", *symbol, *intervalStr, len(klines), *out)
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseTime parses YYYY-MM-DD (UTC) or RFC3339; empty means zero
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// envOr returns the environment variable or def when unset
+func envOr(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
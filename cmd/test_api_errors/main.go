@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vagues-go/src/backpack"
+)
+
+// 使用模拟的 HTTP 传输层校验 API 错误类型（状态码、错误代码、请求路径和 errors.Is/As 判断）、重试、限流和服务器时间同步
+
+// fakeTransport answers requests with the queued responses, then with a fixed status and body
+type fakeTransport struct {
+	status     int
+	body       string
+	retryAfter string
+	queue      []int // 依次返回的状态码（用完后返回 status）
+	requests   int
+}
+
+func (f *fakeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests++
+	status := f.status
+	if len(f.queue) > 0 {
+		status, f.queue = f.queue[0], f.queue[1:]
+	}
+	resp := &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(f.body)),
+		Request:    req,
+	}
+	if status == http.StatusTooManyRequests && f.retryAfter != "" {
+		resp.Header.Set("Retry-After", f.retryAfter)
+	}
+	return resp, nil
+}
+
+// serverTransport serves /api/v1/time with a clock ahead of local time and records the signed timestamp
+type serverTransport struct {
+	ahead     time.Duration
+	timestamp string // 最近一次签名请求的 X-Timestamp
+}
+
+func (f *serverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := "{}"
+	switch req.URL.Path {
+	case "/api/v1/time":
+		body = strconv.FormatInt(time.Now().Add(f.ahead).UnixMilli(), 10)
+	case "/api/v1/ping":
+		body = "pong"
+	case "/api/v1/status":
+		body = `{"status":"Maintenance","message":"upgrade"}`
+	}
+	if timestamp := req.Header.Get("X-Timestamp"); timestamp != "" {
+		f.timestamp = timestamp
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func main() {
+	failed := 0
+	check := func(name string, err error) {
+		if err != nil {
+			failed++
+			log.Printf("❌ %s: %v", name, err)
+			return
+		}
+		log.Printf("✅ %s", name)
+	}
+
+	client, err := backpack.NewClient("test", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		log.Fatalf("创建客户端失败: %v", err)
+	}
+	transport := &fakeTransport{}
+	client.SetTransport(transport)
+	client.SetRateLimiter(nil)
+	client.SetMaxRetries(0)
+	ctx := context.Background()
+	order := backpack.OrderRequest{Symbol: "SOL_USDC_PERP", Side: "Bid", OrderType: "Market", Quantity: "1"}
+
+	transport.status, transport.body = http.StatusBadRequest, `{"code":"INSUFFICIENT_MARGIN","message":"Insufficient margin"}`
+	_, err = client.PlaceOrder(ctx, order)
+	var apiErr *backpack.APIError
+	check("保证金不足", expect(errors.Is(err, backpack.ErrInsufficientMargin) && !errors.Is(err, backpack.ErrInvalidOrder), "%v", err))
+	check("errors.As 取得状态码、代码和路径", expect(errors.As(err, &apiErr) && apiErr.StatusCode == 400 && apiErr.Code == "INSUFFICIENT_MARGIN" &&
+		apiErr.Method == http.MethodPost && apiErr.Path == "/api/v1/order", "%+v", apiErr))
+
+	transport.status, transport.body = http.StatusBadRequest, `{"code":"INVALID_ORDER","message":"Quantity decimal too long"}`
+	_, err = client.PlaceOrder(ctx, order)
+	check("订单无效", expect(errors.Is(err, backpack.ErrInvalidOrder), "%v", err))
+
+	transport.status, transport.body = http.StatusTooManyRequests, "Too Many Requests"
+	_, err = client.GetKlines(ctx, "SOL_USDC_PERP", "1m", nil, nil, nil)
+	check("限频（非 JSON 响应）", expect(errors.Is(err, backpack.ErrRateLimited) && errors.As(err, &apiErr) && apiErr.Message == "Too Many Requests" &&
+		strings.HasPrefix(apiErr.Path, "/api/v1/klines?"), "%v", err))
+
+	transport.status, transport.body = http.StatusUnauthorized, `{"code":"INVALID_SIGNATURE","message":"Invalid signature"}`
+	_, err = client.GetBalances(ctx)
+	check("认证失败", expect(errors.Is(err, backpack.ErrAuth), "%v", err))
+
+	transport.status, transport.body = http.StatusNotFound, `{"code":"RESOURCE_NOT_FOUND","message":"Position not found"}`
+	positions, err := client.GetPositions(ctx)
+	check("没有持仓时返回空数组", expect(err == nil && len(positions) == 0, "%v", err))
+
+	transport.status, transport.body = http.StatusInternalServerError, `{"code":"INTERNAL_ERROR","message":"boom"}`
+	_, err = client.GetMarkets(ctx)
+	check("未分类的错误", expect(errors.As(err, &apiErr) && apiErr.Kind() == nil && apiErr.StatusCode == 500, "%v", err))
+
+	// 重试：GET 在 5xx 后重试，下单不重试；429 按 Retry-After 等待后重试
+	client.SetMaxRetries(2)
+	transport.requests, transport.queue = 0, []int{http.StatusBadGateway, http.StatusOK}
+	transport.body = "[]"
+	_, err = client.GetKlines(ctx, "SOL_USDC_PERP", "1m", nil, nil, nil)
+	check("GET 在 5xx 后重试", expect(err == nil && transport.requests == 2, "请求 %d 次: %v", transport.requests, err))
+
+	transport.requests, transport.queue = 0, []int{http.StatusBadGateway, http.StatusOK}
+	transport.body = `{"code":"SERVICE_UNAVAILABLE","message":"bad gateway"}`
+	_, err = client.PlaceOrder(ctx, order)
+	check("下单在 5xx 后不重试", expect(err != nil && transport.requests == 1, "请求 %d 次: %v", transport.requests, err))
+
+	transport.requests, transport.queue, transport.retryAfter = 0, []int{http.StatusTooManyRequests, http.StatusOK}, "1"
+	transport.body = `{"id":"1","status":"Filled"}`
+	limiter := backpack.NewRateLimiter(1000, 10)
+	client.SetRateLimiter(limiter)
+	started := time.Now()
+	_, err = client.PlaceOrder(ctx, order)
+	waited := time.Since(started)
+	check("429 按 Retry-After 等待后重试", expect(err == nil && transport.requests == 2 && waited >= time.Second, "请求 %d 次, 等待 %v: %v", transport.requests, waited, err))
+	stats := client.Stats()
+	check("重试和限频统计", expect(stats.Retries >= 2 && stats.RateLimited >= 1 && stats.Limiter.Pauses == 1, "%s", stats))
+
+	transport.requests, transport.queue, transport.retryAfter = 0, nil, "3600"
+	transport.status = http.StatusTooManyRequests
+	_, err = client.GetKlines(ctx, "SOL_USDC_PERP", "1m", nil, nil, nil)
+	check("Retry-After 过长时不重试", expect(errors.Is(err, backpack.ErrRateLimited) && transport.requests == 1, "请求 %d 次: %v", transport.requests, err))
+	check("Retry-After 过长时不暂停限流器", expect(limiter.Stats().Pauses == 1, "%+v", limiter.Stats()))
+
+	// 令牌桶：每秒 20 个、突发 5 个，发出 15 个请求约需 0.5 秒
+	limiter = backpack.NewRateLimiter(20, 5)
+	started = time.Now()
+	for range 15 {
+		limiter.Wait(ctx)
+	}
+	elapsed := time.Since(started)
+	check("令牌桶限流", expect(elapsed >= 450*time.Millisecond && elapsed < 800*time.Millisecond && limiter.Stats().Throttled == 10,
+		"用时 %v, %+v", elapsed, limiter.Stats()))
+
+	// 服务器时间同步：服务器比本地快 10 秒时签名时间戳按服务器时间
+	server := &serverTransport{ahead: 10 * time.Second}
+	client.SetTransport(server)
+	offset, err := client.SyncTime(ctx)
+	check("同步服务器时间", expectErr(err, offset > 9900*time.Millisecond && offset < 10100*time.Millisecond, "偏差 %v", offset))
+	client.GetBalances(ctx)
+	signed, _ := strconv.ParseInt(server.timestamp, 10, 64)
+	check("签名使用服务器时间", expect(time.UnixMilli(signed).Sub(time.Now()) > 9*time.Second, "X-Timestamp %s", server.timestamp))
+	status, err := client.GetStatus(ctx)
+	check("系统状态和 ping", expectErr(err, status != nil && !status.OK() && client.Ping(ctx) == nil, "%+v", status))
+
+	if failed > 0 {
+		log.Fatalf("❌ %d 项校验失败", failed)
+	}
+	log.Printf("✅ 全部校验通过")
+}
+
+// expectErr returns err, or the formatted message when ok is false
+func expectErr(err error, ok bool, format string, args ...any) error {
+	if err != nil {
+		return err
+	}
+	return expect(ok, format, args...)
+}
+
+// expect returns an error with the formatted message when ok is false
+func expect(ok bool, format string, args ...any) error {
+	if ok {
+		return nil
+	}
+	return fmt.Errorf(format, args...)
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"vagues-go/src/backpack"
 
@@ -45,6 +46,13 @@ func main() {
 	}
 	log.Println("✅ Backpack客户端创建成功")
 
+	// 按服务器时间签名（本地时钟偏差过大时签名请求会失败）
+	if offset, err := client.SyncTime(ctx); err != nil {
+		log.Printf("⚠️  %v", err)
+	} else {
+		log.Printf("本地时钟偏差: %v", offset.Round(time.Millisecond))
+	}
+
 	// 测试获取余额
 	log.Println("\n=== 开始测试 GetBalances ===")
 	balances, err := client.GetBalances(ctx)
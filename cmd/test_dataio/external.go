@@ -0,0 +1,31 @@
+package main
+
+import (
+	pq "github.com/parquet-go/parquet-go"
+)
+
+// externalTrade is a trade row with the layout Polars/pyarrow write by default:
+// 微秒时间戳、字典编码、可空列和 INT32 编号
+type externalTrade struct {
+	Time         int64    `parquet:"time,timestamp(microsecond)"`
+	Price        float64  `parquet:"price"`
+	Qty          *float64 `parquet:"qty,optional"`
+	IsBuyerMaker bool     `parquet:"is_buyer_maker"`
+	ID           int32    `parquet:"id"`
+	Note         *string  `parquet:"note,optional,dict"`
+}
+
+// writeExternalTrades writes a trades file with the parquet-go library instead of our writer:
+// ZSTD 压缩（Polars 默认）、v2 数据页，用于校验读取器对外部文件的兼容性
+func writeExternalTrades(path string) error {
+	const t0 = 1740787200123456
+	qty := func(v float64) *float64 { return &v }
+	note := func(s string) *string { return &s }
+	rows := []externalTrade{
+		{Time: t0, Price: 142.5, Qty: qty(1), ID: 10, Note: note("x")},
+		{Time: t0 + 1000, Price: 142.5, Qty: qty(2), IsBuyerMaker: true, ID: 11},
+		{Time: t0 + 5000, Price: 143.25, Qty: qty(3), IsBuyerMaker: true, ID: 12, Note: note("y")},
+		{Time: t0 + 5000, Price: 143.25, Qty: qty(0.5), ID: 13, Note: note("x")},
+	}
+	return pq.WriteFile(path, rows, pq.Compression(&pq.Zstd), pq.DataPageVersion(2))
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/dataio"
+	"vagues-go/src/marketdata"
+	"vagues-go/src/models"
+	"vagues-go/src/parquet"
+	"vagues-go/src/recorder"
+	"vagues-go/src/trading"
+)
+
+// 校验 CSV/Parquet 导入导出：K线往返、外部格式（无表头、毫秒时间戳、时区）、
+// 外部库写入的 Parquet（字典编码、ZSTD 压缩、v2 数据页、空值）、平仓订单导出和成交导入到录制文件
+func main() {
+	dir, err := os.MkdirTemp("", "dataio-")
+	if err != nil {
+		log.Fatalf("创建目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	failed := 0
+	check := func(name string, err error) {
+		if err != nil {
+			failed++
+			log.Printf("❌ %s: %v", name, err)
+			return
+		}
+		log.Printf("✅ %s", name)
+	}
+
+	// K线导出后再导入，数值和时间不变
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	var klines []models.KLine
+	for i := 0; i < 250_000; i++ {
+		open := 100 + math.Sin(float64(i)/50)
+		klines = append(klines, models.KLine{
+			StartTime:   start.Add(time.Duration(i) * time.Minute),
+			EndTime:     start.Add(time.Duration(i+1) * time.Minute),
+			Open:        open,
+			High:        open + 0.37,
+			Low:         open - 0.21,
+			Close:       open + 0.1,
+			Volume:      float64(i%97) * 1.5,
+			QuoteVolume: float64(i%97) * 150.25,
+		})
+	}
+	for _, name := range []string{"klines.csv", "klines.csv.gz", "klines.parquet"} {
+		path := filepath.Join(dir, name)
+		err := dataio.ExportKlines(path, klines, dataio.ExportOptions{})
+		var imported []models.KLine
+		if err == nil {
+			imported, err = dataio.ImportKlines(path, dataio.Options{})
+		}
+		check("K线往返 "+name, expectErr(err, equalKlines(klines, imported), "导入 %d 根，内容不一致", len(imported)))
+	}
+	path := filepath.Join(dir, "klines_ms.csv")
+	err = dataio.ExportKlines(path, klines[:10], dataio.ExportOptions{TimeUnit: dataio.UnitMillis})
+	if err == nil {
+		var imported []models.KLine
+		imported, err = dataio.ImportKlines(path, dataio.Options{TimeUnit: dataio.UnitMillis})
+		err = expectErr(err, equalKlines(klines[:10], imported), "毫秒时间戳不一致")
+	}
+	check("K线毫秒时间戳导出", err)
+
+	// Binance 格式：无表头，毫秒时间戳，结束时间为周期最后一毫秒
+	path = filepath.Join(dir, "BTCUSDT-1h-2025-03.csv")
+	os.WriteFile(path, []byte(
+		"1740787200000,84349.94,84500.00,84000.10,84400.00,120.5,1740790799999,10170000.1,500,60,5000000,0\n"+
+			"1740790800000,84400.00,84600.00,84300.00,84550.00,99.25,1740794399999,8390000.5,420,50,4200000,0\n"), 0o644)
+	columns, _ := dataio.ParseColumns("time=0,open=1,high=2,low=3,close=4,volume=5,end=6,quote_volume=7")
+	imported, err := dataio.ImportKlines(path, dataio.Options{NoHeader: true, Columns: columns})
+	check("无表头 Binance K线", expectErr(err, len(imported) == 2 &&
+		imported[0].StartTime.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) &&
+		imported[0].EndTime.Equal(imported[1].StartTime) && imported[1].Close == 84550 && imported[1].QuoteVolume == 8390000.5,
+		"导入结果 %+v", imported))
+
+	// 文本时间按指定时区解析，周期按相邻K线推断，列名大小写和分隔符不敏感
+	path = filepath.Join(dir, "local.csv")
+	os.WriteFile(path, []byte("Date;Open;High;Low;Close;Vol\n2025-03-01 08:05:00;1;2;0.5;1.5;10\n2025-03-01 08:00:00;1;2;0.5;1.2;10\n"), 0o644)
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	imported, err = dataio.ImportKlines(path, dataio.Options{Comma: ';', Location: shanghai})
+	check("时区和周期推断", expectErr(err, len(imported) == 2 && imported[0].StartTime.Equal(start) &&
+		imported[0].EndTime.Equal(start.Add(5*time.Minute)) && imported[1].Close == 1.5,
+		"导入结果 %+v", imported))
+
+	_, err = dataio.ImportKlines(path, dataio.Options{Comma: ';', Columns: map[string]string{"close": "last"}})
+	check("列映射错误", expect(err != nil, "未返回错误"))
+	bad := filepath.Join(dir, "bad.csv")
+	os.WriteFile(bad, []byte("time,open,high,low,close\n2025-03-01,1,2,0.5,x\n"), 0o644)
+	_, err = dataio.ImportKlines(bad, dataio.Options{Interval: time.Hour})
+	check("无效数值报告行号", expect(err != nil && bytes.Contains([]byte(err.Error()), []byte("第 1 行")), "错误 %v", err))
+
+	// 成交：side 列和 is_buyer_maker 列，没有 id 时按时间编号
+	path = filepath.Join(dir, "trades.csv")
+	os.WriteFile(path, []byte("timestamp,price,qty,side\n1740787201.5,84350.1,0.01,sell\n1740787200.25,84349.9,0.02,buy\n"), 0o644)
+	trades, err := dataio.ImportTrades(path, dataio.Options{Symbol: "BTC_USDC_PERP"})
+	check("成交 CSV 导入", expectErr(err, len(trades) == 2 && trades[0].ID == 1 && trades[0].IsTakerBuy() && !trades[1].IsTakerBuy() &&
+		trades[0].Time.Equal(time.Date(2025, 3, 1, 0, 0, 0, 250e6, time.UTC)) && trades[1].Symbol == "BTC_USDC_PERP",
+		"导入结果 %+v", trades))
+
+	// 外部库写入的成交文件：字典编码 + ZSTD + v2 数据页 + 空值
+	path = filepath.Join(dir, "trades_external.parquet")
+	err = writeExternalTrades(path)
+	if err == nil {
+		trades, err = dataio.ImportTrades(path, dataio.Options{Symbol: "SOL_USDC_PERP"})
+	}
+	check("外部 Parquet 成交导入", expectErr(err, len(trades) == 4 &&
+		trades[0].Time.Equal(time.UnixMicro(1740787200123456).UTC()) && trades[3].Time.Equal(trades[2].Time) &&
+		trades[0].Price == 142.5 && trades[1].Price == 142.5 && trades[2].Price == 143.25 &&
+		trades[0].IsTakerBuy() && !trades[1].IsTakerBuy() && trades[2].ID == 12 && trades[3].Quantity == 0.5,
+		"导入结果 %+v", trades))
+
+	// 导入的成交写入录制文件，读回后与 WebSocket 推送的解析结果一致
+	recordDir := filepath.Join(dir, "market")
+	rec, err := recorder.New(recordDir)
+	if err == nil {
+		for _, trade := range trades {
+			record, recErr := dataio.TradeRecord(trade)
+			if recErr == nil {
+				recErr = rec.Write(record)
+			}
+			if recErr != nil {
+				err = recErr
+				break
+			}
+		}
+		rec.Close()
+	}
+	var replayed []models.Trade
+	if err == nil {
+		var files []string
+		files, err = recorder.Files(recordDir, "SOL_USDC_PERP", "", "")
+		var records []recorder.Record
+		if err == nil {
+			records, err = recorder.Load(files...)
+		}
+		for _, record := range records {
+			var msg backpack.WSTradeMessage
+			if err = json.Unmarshal(record.Data, &msg); err != nil {
+				break
+			}
+			trade, tradeErr := marketdata.TradeFromWS(msg)
+			if tradeErr != nil || record.Stream != backpack.TradeStream("SOL_USDC_PERP") {
+				err = fmt.Errorf("录制消息无效: %v %s", tradeErr, record.Stream)
+				break
+			}
+			replayed = append(replayed, trade)
+		}
+	}
+	check("成交写入录制文件", expectErr(err, fmt.Sprint(replayed) == fmt.Sprint(trades), "读回 %v", replayed))
+
+	// 平仓订单导出：按开仓时间排序，未平仓订单的 exit_time 为空
+	entry := time.Date(2025, 3, 1, 1, 0, 0, 0, time.UTC)
+	orders := []*trading.LocalOrder{
+		{ID: "b", Symbol: "SOL_USDC_PERP", OrderType: trading.OrderTypeShort, Status: trading.OrderStatusOpen,
+			EntryTime: entry.Add(time.Hour), EntryPrice: 140, Quantity: 2},
+		{ID: "a", Symbol: "SOL_USDC_PERP", OrderType: trading.OrderTypeLong, Status: trading.OrderStatusClosed,
+			EntryTime: entry, ExitTime: entry.Add(30 * time.Minute), EntryPrice: 142.5, ExitPrice: 143.25,
+			Quantity: 1.5, BarsHeld: 30, PnL: 1.125, PnLPercent: 0.526, TradingFee: 0.2565},
+	}
+	path = filepath.Join(dir, "orders.parquet")
+	err = dataio.ExportOrders(path, orders, dataio.ExportOptions{})
+	var rows [][]string
+	var header []string
+	if err == nil {
+		header, rows, err = readParquet(path)
+	}
+	check("平仓订单导出 Parquet", expectErr(err, len(rows) == 2 && rows[0][0] == "a" && rows[0][2] == "LONG" &&
+		rows[0][5] == "2025-03-01T01:30:00Z" && rows[1][5] == "" && rows[0][11] == "30" && rows[0][12] == "1.125" && header[12] == "pnl",
+		"导出结果 %v", rows))
+
+	path = filepath.Join(dir, "orders.csv")
+	err = dataio.ExportOrders(path, orders, dataio.ExportOptions{TimeUnit: dataio.UnitSeconds})
+	if err == nil {
+		rows, err = readCSV(path)
+	}
+	check("平仓订单导出 CSV", expectErr(err, len(rows) == 3 && rows[1][4] == fmt.Sprint(entry.Unix()) && rows[2][5] == "" && rows[1][14] == "0.2565",
+		"导出结果 %v", rows))
+
+	// 写入类型不匹配时报错，不留下文件
+	writer, err := parquet.NewWriter(io.Discard, []parquet.Field{{Name: "x", Type: parquet.Double}})
+	if err == nil {
+		err = writer.Write([]any{int64(1)})
+	}
+	check("Parquet 类型检查", expect(err != nil, "未返回错误"))
+
+	// 截断的文件返回错误而不是 panic
+	data, _ := os.ReadFile(filepath.Join(dir, "orders.parquet"))
+	path = filepath.Join(dir, "truncated.parquet")
+	os.WriteFile(path, append(data[:len(data)/2:len(data)/2], data[len(data)-8:]...), 0o644)
+	_, _, err = readParquet(path)
+	check("截断的 Parquet 文件", expect(err != nil, "未返回错误"))
+
+	if failed > 0 {
+		log.Fatalf("❌ %d 项校验失败", failed)
+	}
+	log.Printf("✅ 全部校验通过")
+}
+
+// equalKlines compares K-lines; 价格按浮点数原样比较（导出使用最短的精确表示）
+func equalKlines(a, b []models.KLine) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].StartTime.Equal(b[i].StartTime) || !a[i].EndTime.Equal(b[i].EndTime) ||
+			a[i].Open != b[i].Open || a[i].High != b[i].High || a[i].Low != b[i].Low || a[i].Close != b[i].Close ||
+			a[i].Volume != b[i].Volume || a[i].QuoteVolume != b[i].QuoteVolume {
+			return false
+		}
+	}
+	return true
+}
+
+// readParquet reads every row of a Parquet file
+func readParquet(path string) ([]string, [][]string, error) {
+	r, err := parquet.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+	var header []string
+	for _, c := range r.Columns() {
+		header = append(header, c.Name)
+	}
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return header, rows, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readCSV reads every record of a CSV file including the header
+func readCSV(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return csv.NewReader(file).ReadAll()
+}
+
+// expect returns an error with the formatted message when ok is false
+func expect(ok bool, format string, args ...any) error {
+	if ok {
+		return nil
+	}
+	return fmt.Errorf(format, args...)
+}
+
+// expectErr returns err, or the formatted message when ok is false
+func expectErr(err error, ok bool, format string, args ...any) error {
+	if err != nil {
+		return err
+	}
+	return expect(ok, format, args...)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/history"
+)
+
+// 使用模拟的 GetKlines 校验本地K线存储：分月写入、追加、范围/最新查询、缺口和重复检测、修复和断点续传
+const (
+	symbol   = "SOL_USDC_PERP"
+	interval = "1h"
+)
+
+// fakeExchange serves hourly bars for every hour except the ones in missing
+type fakeExchange struct {
+	requests int
+	failAt   int // 第几次请求返回错误（0 表示不出错）
+	missing  map[int64]bool
+}
+
+func (f *fakeExchange) GetKlines(ctx context.Context, symbol, interval string, startTime, endTime *int64, limit *int) ([]backpack.KlineResponse, error) {
+	f.requests++
+	if f.requests == f.failAt {
+		return nil, fmt.Errorf("模拟请求失败")
+	}
+	var resp []backpack.KlineResponse
+	for t := *startTime; t < *endTime && len(resp) < *limit; t += 3600 {
+		if f.missing[t] {
+			continue
+		}
+		resp = append(resp, bar(t, "1"))
+	}
+	return resp, nil
+}
+
+// bar returns the hourly bar starting at t (秒)
+func bar(t int64, close string) backpack.KlineResponse {
+	start := time.Unix(t, 0).UTC()
+	return backpack.KlineResponse{
+		Start:       start.Format("2006-01-02 15:04:05"),
+		End:         start.Add(time.Hour).Format("2006-01-02 15:04:05"),
+		Open:        "1",
+		High:        "2",
+		Low:         "0.5",
+		Close:       close,
+		Volume:      strconv.FormatInt(t%97, 10),
+		QuoteVolume: "0",
+		Trades:      "1",
+	}
+}
+
+func main() {
+	dir, err := os.MkdirTemp("", "kline-store-")
+	if err != nil {
+		log.Fatalf("创建目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	failed := 0
+	check := func(name string, err error) {
+		if err != nil {
+			failed++
+			log.Printf("❌ %s: %v", name, err)
+			return
+		}
+		log.Printf("✅ %s", name)
+	}
+
+	from := time.Date(2025, 1, 30, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 2, 2, 0, 0, 0, 0, time.UTC)
+	hours := int(to.Sub(from) / time.Hour)
+	store := history.NewStore(dir)
+	exchange := &fakeExchange{failAt: 2, missing: map[int64]bool{from.Add(40 * time.Hour).Unix(): true}}
+
+	// 下载中途失败后重新运行，从已保存的位置继续
+	downloader := history.NewDownloader(exchange, store)
+	downloader.SetRateLimit(0)
+	downloader.SetWindowBars(24)
+	downloader.SetMaxRetries(0)
+	_, err = downloader.Download(context.Background(), symbol, interval, from, to)
+	check("第一次下载在第2个窗口失败", expect(err != nil, "未返回错误"))
+	stats, err := downloader.Download(context.Background(), symbol, interval, from, to)
+	check("重新运行后续传", err)
+	check("续传不重复下载", expect(stats.Duplicates == 0 && stats.Bars == hours-24-1, "写入 %d 根, 重复 %d 根", stats.Bars, stats.Duplicates))
+
+	months, err := store.Months(symbol, interval)
+	check("按月分区", expectErr(err, len(months) == 2 && months[0] == "2025-01" && months[1] == "2025-02", "月份 %v", months))
+
+	// 查询
+	bars, err := store.Range(symbol, interval, from.Add(10*time.Hour), from.Add(20*time.Hour))
+	check("Range 查询", expectErr(err, len(bars) == 10 && bars[0].Start.Equal(from.Add(10*time.Hour)), "返回 %d 根", len(bars)))
+	bars, err = store.Latest(symbol, interval, 30)
+	check("Latest 跨月查询", expectErr(err, len(bars) == 30 && bars[29].Start.Equal(to.Add(-time.Hour)), "返回 %d 根", len(bars)))
+	klines, err := history.KLines(bars)
+	check("转换为 KLine", expectErr(err, len(klines) == 30 && klines[0].High == 2, "转换结果 %d 根", len(klines)))
+
+	// 追加：晚于已有数据时写入末尾，重复时替换
+	next, _ := history.BarFromREST(bar(to.Unix(), "1"))
+	duplicates, err := store.Append(symbol, interval, []history.Bar{next})
+	check("追加新K线", expectErr(err, duplicates == 0, "重复 %d", duplicates))
+	replaced, _ := history.BarFromREST(bar(from.Unix(), "9"))
+	duplicates, err = store.Append(symbol, interval, []history.Bar{replaced})
+	bars, _ = store.Range(symbol, interval, from, from.Add(time.Hour))
+	check("替换重复K线", expectErr(err, duplicates == 1 && len(bars) == 1 && bars[0].Close == "9", "重复 %d", duplicates))
+
+	// 检测：缺失1根；手工写入重复行和未对齐行
+	report, err := store.Check(symbol, interval, time.Time{}, time.Time{})
+	check("检测缺失K线", expectErr(err, len(report.Gaps) == 1 && report.Missing() == 1 && report.Gaps[0].From.Equal(from.Add(40*time.Hour)), "缺口 %+v", report.Gaps))
+
+	path := filepath.Join(dir, symbol, interval, "2025-02.csv")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err == nil {
+		fmt.Fprintf(file, "2025-02-01T05:00:00Z,2025-02-01T06:00:00Z,1,2,0.5,1,1,0,1\n")
+		fmt.Fprintf(file, "2025-02-01T05:30:00Z,2025-02-01T06:30:00Z,1,2,0.5,1,1,0,1\n")
+		file.Close()
+	}
+	report, err = store.Check(symbol, interval, time.Time{}, time.Time{})
+	check("检测重复、乱序和未对齐", expectErr(err, len(report.Duplicates) == 1 && report.Unordered == 1 && len(report.Misaligned) == 1,
+		"重复 %d, 乱序 %d, 未对齐 %d", len(report.Duplicates), report.Unordered, len(report.Misaligned)))
+	report, err = store.Check(symbol, interval, from.Add(-2*time.Hour), to)
+	check("检测范围两端缺失", expectErr(err, report.Missing() == 3, "缺失 %d", report.Missing()))
+
+	// 修复：去重重写并重新下载缺口（交易所补上了缺失的K线）
+	exchange.missing = nil
+	exchange.failAt = 0
+	repair, err := store.Repair(context.Background(), exchange, symbol, interval, time.Time{}, time.Time{})
+	check("修复", expectErr(err, repair.Rewritten == 1 && repair.Before.Missing() == 1 && repair.After.OK(),
+		"重写 %d, 缺失 %d -> %d", repair.Rewritten, repair.Before.Missing(), repair.After.Missing()))
+
+	// 周期的不同写法（60m 与 1h）对应同一目录
+	report, err = store.Check(symbol, "60m", time.Time{}, time.Time{})
+	bars, _ = store.Range(symbol, "60m", from, from.Add(time.Hour))
+	check("周期写法归一", expectErr(err, report.OK() && len(bars) == 1, "缺失 %d, 返回 %d 根", report.Missing(), len(bars)))
+
+	if failed > 0 {
+		log.Fatalf("❌ %d 项校验失败", failed)
+	}
+	log.Printf("✅ 全部校验通过")
+}
+
+// expect returns an error with the formatted message when ok is false
+func expect(ok bool, format string, args ...any) error {
+	if ok {
+		return nil
+	}
+	return fmt.Errorf(format, args...)
+}
+
+// expectErr returns err, or the formatted message when ok is false
+func expectErr(err error, ok bool, format string, args ...any) error {
+	if err != nil {
+		return err
+	}
+	return expect(ok, format, args...)
+}
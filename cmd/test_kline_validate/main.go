@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/marketdata"
+	"vagues-go/src/models"
+)
+
+// 校验K线数据质量检查：价格无效、OHLC 不一致、时间乱序/未对齐、成交量异常和缺失K线
+func main() {
+	failed := 0
+	check := func(name string, err error) {
+		if err != nil {
+			failed++
+			log.Printf("❌ %s: %v", name, err)
+			return
+		}
+		log.Printf("✅ %s", name)
+	}
+
+	iv := marketdata.MustParseInterval("5m")
+	validator := marketdata.NewValidator(iv)
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	series := func(n int) []models.KLine {
+		klines := make([]models.KLine, n)
+		for i := range klines {
+			t := start.Add(time.Duration(i) * 5 * time.Minute)
+			klines[i] = models.KLine{StartTime: t, EndTime: t.Add(5 * time.Minute), Open: 100, High: 101, Low: 99, Close: 100.5, Volume: 10 + float64(i%3)}
+		}
+		return klines
+	}
+
+	check("正常序列无问题", kinds(validator.Validate(series(30))))
+
+	klines := series(30)
+	klines[29].Close = 0
+	check("收盘价为 0", kinds(validator.ValidateLast(klines), marketdata.IssueInvalidPrice))
+
+	klines = series(30)
+	klines[29].High = math.NaN()
+	check("最高价为 NaN", kinds(validator.ValidateLast(klines), marketdata.IssueInvalidPrice))
+
+	klines = series(30)
+	klines[29].High, klines[29].Low = 98, 99
+	check("最高价低于最低价", kinds(validator.ValidateLast(klines), marketdata.IssueHighBelowLow))
+
+	klines = series(30)
+	klines[29].Close = 102
+	check("收盘价超出区间", kinds(validator.ValidateLast(klines), marketdata.IssueOutOfRange))
+
+	klines = series(30)
+	klines[29].StartTime, klines[29].EndTime = klines[28].StartTime, klines[28].EndTime
+	check("开始时间重复", kinds(validator.ValidateLast(klines), marketdata.IssueNonMonotonic))
+
+	klines = series(30)
+	klines[29].StartTime = klines[29].StartTime.Add(time.Minute)
+	check("开始时间未对齐", kinds(validator.ValidateLast(klines), marketdata.IssueMisaligned))
+
+	klines = series(30)
+	klines[29].EndTime = klines[29].EndTime.Add(-time.Minute)
+	check("结束时间与周期不符", kinds(validator.ValidateLast(klines), marketdata.IssueMisaligned))
+
+	klines = series(30)
+	klines[29].EndTime = klines[29].EndTime.Add(-time.Millisecond)
+	check("结束时间为下一周期前 1ms", kinds(validator.ValidateLast(klines)))
+
+	klines = series(30)
+	klines[29].Volume = 500
+	issues := validator.ValidateLast(klines)
+	check("成交量异常", kinds(issues, marketdata.IssueVolumeSpike))
+	check("成交量异常不是致命问题", expect(!fatal(issues), "%v", issues))
+
+	validator.SetVolumeSpike(-1, 0)
+	check("关闭成交量异常检测", kinds(validator.ValidateLast(klines)))
+	validator.SetVolumeSpike(marketdata.DefaultSpikeFactor, 0)
+
+	klines = append(series(10), series(30)[13:]...)
+	issues = validator.Validate(klines)
+	check("缺失K线", kinds(issues, marketdata.IssueMissingBars))
+	check("缺失数量", expect(len(issues) == 1 && issues[0].Index == 10 && !issues[0].Fatal(), "%v", issues))
+
+	months := marketdata.NewValidator(marketdata.MustParseInterval("1month"))
+	monthly := []models.KLine{
+		monthBar(2025, time.January), monthBar(2025, time.February), monthBar(2025, time.May),
+	}
+	issues = months.Validate(monthly)
+	check("月线按自然月对齐并检测缺失", expect(len(issues) == 1 && issues[0].Kind == marketdata.IssueMissingBars && issues[0].Detail != "" && issues[0].Index == 2,
+		"%v", issues))
+
+	// REST 数值无法解析时返回错误，而不是把价格当作 0
+	_, err := marketdata.KLineFromREST(backpack.KlineResponse{
+		Start: "2025-03-01 00:00:00", End: "2025-03-01 00:05:00",
+		Open: "100", High: "", Low: "99", Close: "100", Volume: "1",
+	})
+	check("REST 价格解析失败时返回错误", expect(err != nil, "未返回错误"))
+
+	if failed > 0 {
+		log.Fatalf("❌ %d 项校验失败", failed)
+	}
+	log.Printf("✅ 全部校验通过")
+}
+
+// monthBar returns a monthly bar of year/month
+func monthBar(year int, month time.Month) models.KLine {
+	t := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return models.KLine{StartTime: t, EndTime: t.AddDate(0, 1, 0), Open: 1, High: 2, Low: 1, Close: 2, Volume: 1}
+}
+
+// kinds returns an error unless issues are exactly of the given kinds (按顺序)
+func kinds(issues []marketdata.Issue, want ...marketdata.IssueKind) error {
+	if len(issues) != len(want) {
+		return fmt.Errorf("期望 %v, 实际 %v", want, issues)
+	}
+	for i, issue := range issues {
+		if issue.Kind != want[i] {
+			return fmt.Errorf("期望 %v, 实际 %v", want, issues)
+		}
+	}
+	return nil
+}
+
+func fatal(issues []marketdata.Issue) bool {
+	for _, issue := range issues {
+		if issue.Fatal() {
+			return true
+		}
+	}
+	return false
+}
+
+// expect returns an error with the formatted message when ok is false
+func expect(ok bool, format string, args ...any) error {
+	if ok {
+		return nil
+	}
+	return fmt.Errorf(format, args...)
+}
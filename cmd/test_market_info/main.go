@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"vagues-go/src/backpack"
+)
+
+// 使用模拟的 /api/v1/markets 响应校验市场过滤器解析、tickSize/stepSize 对齐和格式化、下单限制检查以及市场信息缓存
+
+// marketsJSON 交易所返回格式（price/quantity/leverage 过滤器，数值为字符串）
+const marketsJSON = `[
+  {"symbol":"SOL_USDC_PERP","baseSymbol":"SOL","quoteSymbol":"USDC","marketType":"PERP","fundingInterval":28800000,
+   "filters":{"price":{"tickSize":"0.01","minPrice":"0.01","maxPrice":"10000"},
+              "quantity":{"stepSize":"0.01","minQuantity":"0.01","maxQuantity":"5000"},
+              "leverage":{"minLeverage":"1","maxLeverage":"20","stepSize":"1"}}},
+  {"symbol":"BTC_USDC_PERP","baseSymbol":"BTC","quoteSymbol":"USDC","marketType":"PERP","fundingInterval":"28800000",
+   "filters":{"price":{"tickSize":"0.1"},
+              "quantity":{"stepSize":"0.00001","minQuantity":"0.00001","minNotional":"5"}}},
+  {"symbol":"ETH_USDC","baseSymbol":"ETH","quoteSymbol":"USDC","marketType":"SPOT",
+   "filters":{"priceFilter":{"tickSize":"0.05"},"quantityFilter":{"stepSize":"1","minQuantity":"1"}}}
+]`
+
+// marketsTransport serves marketsJSON and counts requests; fail makes it answer 503
+type marketsTransport struct {
+	requests int
+	fail     bool
+	block    chan struct{} // 非 nil 时等待关闭后再应答（模拟慢请求）
+}
+
+func (f *marketsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if f.block != nil {
+		<-f.block
+	}
+	f.requests++
+	status, body := http.StatusOK, marketsJSON
+	if f.fail {
+		status, body = http.StatusServiceUnavailable, `{"code":"SERVICE_UNAVAILABLE","message":"maintenance"}`
+	}
+	return &http.Response{StatusCode: status, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func main() {
+	failed := 0
+	check := func(name string, err error) {
+		if err != nil {
+			failed++
+			log.Printf("❌ %s: %v", name, err)
+			return
+		}
+		log.Printf("✅ %s", name)
+	}
+
+	// 步长格式化：按十进制精确输出，不出现浮点误差
+	tenth, _ := backpack.ParseIncrement("0.1")
+	check("0.3 按 0.1 对齐", expect(tenth.Format(0.3, backpack.RoundDown) == "0.3" && tenth.Format(0.1+0.2, backpack.RoundDown) == "0.3",
+		"%s / %s", tenth.Format(0.3, backpack.RoundDown), tenth.Format(0.1+0.2, backpack.RoundDown)))
+	check("向下、四舍五入、向上取整", expect(tenth.Format(1.26, backpack.RoundDown) == "1.2" && tenth.Format(1.26, backpack.RoundNearest) == "1.3" &&
+		tenth.Format(1.21, backpack.RoundUp) == "1.3", "%s %s %s",
+		tenth.Format(1.26, backpack.RoundDown), tenth.Format(1.26, backpack.RoundNearest), tenth.Format(1.21, backpack.RoundUp)))
+	fine, _ := backpack.ParseIncrement("0.00001000")
+	check("小数步长和末尾零", expect(fine.Decimals() == 5 && fine.Format(0.000123456, backpack.RoundDown) == "0.00012" &&
+		fine.Format(2, backpack.RoundDown) == "2", "%d %s %s", fine.Decimals(), fine.Format(0.000123456, backpack.RoundDown), fine.Format(2, backpack.RoundDown)))
+	five, _ := backpack.ParseIncrement("5")
+	check("整数步长", expect(five.Format(123, backpack.RoundDown) == "120" && five.Round(123, backpack.RoundUp) == 125,
+		"%s %v", five.Format(123, backpack.RoundDown), five.Round(123, backpack.RoundUp)))
+	_, err := backpack.ParseIncrement("0")
+	_, err2 := backpack.ParseIncrement("abc")
+	check("无效步长", expect(err != nil && err2 != nil, "%v / %v", err, err2))
+
+	// 过滤器解析
+	client, err := backpack.NewClient("test", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		log.Fatalf("创建客户端失败: %v", err)
+	}
+	transport := &marketsTransport{}
+	client.SetTransport(transport)
+	client.SetRateLimiter(nil)
+	client.SetMaxRetries(0)
+	ctx := context.Background()
+
+	sol, err := client.GetMarketInfo(ctx, "SOL_USDC_PERP")
+	check("解析 price/quantity/leverage 过滤器", expectErr(err, sol != nil && sol.TickSize.Float() == 0.01 && sol.StepSize.Float() == 0.01 &&
+		sol.MinQuantity == 0.01 && sol.MaxQuantity == 5000 && sol.MaxPrice == 10000 && sol.MaxLeverage == 20 &&
+		sol.FundingInterval == 8*time.Hour, "%+v", sol))
+	btc, err := client.GetMarketInfo(ctx, "BTC_USDC_PERP")
+	check("资金费间隔为字符串和最小名义价值", expectErr(err, btc != nil && btc.FundingInterval == 8*time.Hour && btc.MinNotional == 5 &&
+		btc.MaxQuantity == 0, "%+v", btc))
+	eth, err := client.GetMarketInfo(ctx, "ETH_USDC")
+	check("旧格式 priceFilter/quantityFilter", expectErr(err, eth != nil && eth.FormatPrice(2345.67, backpack.RoundDown) == "2345.65" &&
+		eth.FormatQuantity(3.7, backpack.RoundDown) == "3" && eth.FundingInterval == 0, "%+v", eth))
+	check("价格和数量格式化", expect(sol.FormatPrice(142.3456, backpack.RoundDown) == "142.34" && sol.FormatQuantity(1.006, backpack.RoundNearest) == "1.01" &&
+		btc.FormatQuantity(0.0123456, backpack.RoundDown) == "0.01234", "%s %s %s",
+		sol.FormatPrice(142.3456, backpack.RoundDown), sol.FormatQuantity(1.006, backpack.RoundNearest), btc.FormatQuantity(0.0123456, backpack.RoundDown)))
+	_, err = client.GetMarketInfo(ctx, "DOGE_USDC_PERP")
+	check("未知交易对", expect(err != nil, "应返回错误"))
+	check("市场列表只请求一次", expect(transport.requests == 1, "请求 %d 次", transport.requests))
+
+	// 下单限制
+	check("限制内的订单", sol.CheckOrder(150, 1))
+	check("数量过小", expect(sol.CheckOrder(150, 0.001) != nil, "应返回错误"))
+	check("数量过大", expect(sol.CheckOrder(150, 6000) != nil, "应返回错误"))
+	check("价格超出范围", expect(sol.CheckOrder(20000, 1) != nil, "应返回错误"))
+	check("名义价值过小", expect(btc.CheckOrder(60000, 0.00005) != nil && btc.CheckOrder(60000, 0.0001) == nil, "%v", btc.CheckOrder(60000, 0.0001)))
+
+	// 缓存：过期后刷新；刷新失败时继续使用过期数据，没有数据时返回错误
+	cache := backpack.NewMarketCache(client, 50*time.Millisecond)
+	transport.requests = 0
+	cache.Get(ctx, "SOL_USDC_PERP")
+	cache.Get(ctx, "BTC_USDC_PERP")
+	time.Sleep(60 * time.Millisecond)
+	cache.Get(ctx, "SOL_USDC_PERP")
+	check("缓存过期后刷新", expect(transport.requests == 2, "请求 %d 次", transport.requests))
+
+	time.Sleep(60 * time.Millisecond)
+	transport.fail = true
+	stale, err := cache.Get(ctx, "SOL_USDC_PERP")
+	check("刷新失败时使用过期缓存", expectErr(err, stale != nil && stale.Symbol == "SOL_USDC_PERP" && transport.requests == 3, "请求 %d 次", transport.requests))
+	cache.Get(ctx, "SOL_USDC_PERP")
+	check("刷新失败后暂缓重试", expect(transport.requests == 3, "请求 %d 次", transport.requests))
+
+	cache.Invalidate()
+	transport.fail = false
+	cache.Get(ctx, "SOL_USDC_PERP")
+	check("Invalidate 后立即刷新", expect(transport.requests == 4, "请求 %d 次", transport.requests))
+
+	// 刷新在锁外进行：一个协程刷新期间，其他调用方直接使用已有缓存，不重复请求
+	transport.block = make(chan struct{})
+	cache.Invalidate()
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(ctx, "SOL_USDC_PERP")
+		refreshed <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	started := time.Now()
+	cached, err := cache.Get(ctx, "BTC_USDC_PERP")
+	waited := time.Since(started)
+	close(transport.block)
+	if refreshErr := <-refreshed; err == nil {
+		err = refreshErr
+	}
+	check("刷新期间不阻塞其他调用方", expectErr(err, cached != nil && waited < 10*time.Millisecond && transport.requests == 5,
+		"等待 %v, 请求 %d 次", waited, transport.requests))
+
+	transport.fail = true
+	_, err = backpack.NewMarketCache(client, time.Hour).Get(ctx, "SOL_USDC_PERP")
+	check("没有缓存时返回错误", expect(err != nil, "应返回错误"))
+
+	if failed > 0 {
+		log.Fatalf("❌ %d 项校验失败", failed)
+	}
+	log.Printf("✅ 全部校验通过")
+}
+
+// expectErr returns err, or the formatted message when ok is false
+func expectErr(err error, ok bool, format string, args ...any) error {
+	if err != nil {
+		return err
+	}
+	return expect(ok, format, args...)
+}
+
+// expect returns an error with the formatted message when ok is false
+func expect(ok bool, format string, args ...any) error {
+	if ok {
+		return nil
+	}
+	return fmt.Errorf(format, args...)
+}
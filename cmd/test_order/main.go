@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -30,6 +29,13 @@ func main() {
 
 	ctx := context.Background()
 
+	// 按服务器时间签名（本地时钟偏差过大时签名请求会失败）
+	if offset, err := client.SyncTime(ctx); err != nil {
+		log.Printf("⚠️  %v", err)
+	} else {
+		log.Printf("本地时钟偏差: %v", offset.Round(time.Millisecond))
+	}
+
 	// 测试交易对（可以从命令行参数获取，默认使用 SOL_USDC_PERP）
 	symbol := "SOL_USDC_PERP"
 	if len(os.Args) > 1 {
@@ -75,24 +81,22 @@ func main() {
 	}
 	fmt.Println()
 
-	// 3. 获取市场信息（stepSize）
-	fmt.Println("3. 获取市场信息（stepSize）...")
-	markets, err := client.GetMarkets(ctx)
+	// 3. 获取市场信息（精度和交易限制）
+	fmt.Println("3. 获取市场信息（tickSize、stepSize 和交易限制）...")
+	market, err := client.GetMarketInfo(ctx, symbol)
 	if err != nil {
 		log.Printf("获取市场信息失败: %v", err)
 	} else {
-		for _, market := range markets {
-			if market.Symbol == symbol {
-				qf, err := market.GetQuantityFilter()
-				if err == nil {
-					fmt.Printf("   最小数量: %s\n", qf.MinQuantity)
-					fmt.Printf("   步长: %s\n", qf.StepSize)
-					if qf.MaxQuantity != "" {
-						fmt.Printf("   最大数量: %s\n", qf.MaxQuantity)
-					}
-				}
-				break
-			}
+		fmt.Printf("   价格步长: %.*f (最低 %v, 最高 %v)\n", market.TickSize.Decimals(), market.TickSize.Float(), market.MinPrice, market.MaxPrice)
+		fmt.Printf("   数量步长: %.*f (最小 %v, 最大 %v)\n", market.StepSize.Decimals(), market.StepSize.Float(), market.MinQuantity, market.MaxQuantity)
+		if market.MaxLeverage > 0 {
+			fmt.Printf("   最大杠杆: %vx\n", market.MaxLeverage)
+		}
+		if market.MinNotional > 0 {
+			fmt.Printf("   最小名义价值: %v\n", market.MinNotional)
+		}
+		if market.FundingInterval > 0 {
+			fmt.Printf("   资金费间隔: %v\n", market.FundingInterval)
 		}
 	}
 	fmt.Println()
@@ -113,13 +117,10 @@ func main() {
 
 		// 获取当前价格（用于计算止损止盈）
 		var currentPrice float64
-		for _, market := range markets {
-			if market.Symbol == symbol {
-				// 尝试从市场信息获取最新价格，如果没有则使用默认值
-				// 这里简化处理，实际应该从ticker或最新K线获取
-				currentPrice = 100.0 // 默认价格，实际应该从API获取
-				break
-			}
+		if market != nil {
+			// 尝试从市场信息获取最新价格，如果没有则使用默认值
+			// 这里简化处理，实际应该从ticker或最新K线获取
+			currentPrice = 100.0 // 默认价格，实际应该从API获取
 		}
 
 		// 如果无法获取价格，提示用户输入
@@ -143,12 +144,12 @@ func main() {
 		takeProfit := currentPrice * (1 + takeProfitPct/100)
 
 		// 格式化数量
-		quantityStr := formatQuantity(quantity, markets, symbol)
+		quantityStr := formatQuantity(quantity, market)
 		fmt.Printf("   格式化后的数量: %s\n", quantityStr)
 
 		// 格式化止损止盈价格
-		stopLossStr := formatPrice(stopLoss, markets, symbol)
-		takeProfitStr := formatPrice(takeProfit, markets, symbol)
+		stopLossStr := formatPrice(stopLoss, market)
+		takeProfitStr := formatPrice(takeProfit, market)
 		fmt.Printf("   当前价格: %.4f\n", currentPrice)
 		fmt.Printf("   止损价格: %s (%.2f%%)\n", stopLossStr, stopLossPct)
 		fmt.Printf("   止盈价格: %s (%.2f%%)\n", takeProfitStr, takeProfitPct)
@@ -240,90 +241,28 @@ func main() {
 	fmt.Println("\n=== 测试完成 ===")
 }
 
-// formatQuantity 格式化数量（根据 stepSize）
-func formatQuantity(quantity float64, markets []backpack.Market, symbol string) string {
-	for _, market := range markets {
-		if market.Symbol == symbol {
-			qf, err := market.GetQuantityFilter()
-			if err == nil && qf.StepSize != "" {
-				stepSize, err := strconv.ParseFloat(qf.StepSize, 64)
-				if err == nil && stepSize > 0 {
-					// 对齐到 stepSize
-					alignedQuantity := float64(int(quantity/stepSize)) * stepSize
-					// 确保不小于最小数量
-					if qf.MinQuantity != "" {
-						minQty, err := strconv.ParseFloat(qf.MinQuantity, 64)
-						if err == nil && alignedQuantity < minQty {
-							alignedQuantity = minQty
-						}
-					}
-					// 计算小数位数
-					decimals := countDecimals(stepSize)
-					quantityStr := fmt.Sprintf("%."+fmt.Sprintf("%d", decimals)+"f", alignedQuantity)
-					// 移除尾部的0
-					quantityStr = strings.TrimRight(quantityStr, "0")
-					quantityStr = strings.TrimSuffix(quantityStr, ".")
-					return quantityStr
-				}
-			}
-			break
-		}
+// formatQuantity 格式化数量（对齐 stepSize，不小于最小数量）
+func formatQuantity(quantity float64, market *backpack.MarketInfo) string {
+	if market == nil || market.StepSize.IsZero() {
+		// 默认2位小数
+		return fmt.Sprintf("%.2f", quantity)
 	}
-	// 默认2位小数
-	return fmt.Sprintf("%.2f", quantity)
-}
-
-// formatPrice 格式化价格（根据 tickSize）
-func formatPrice(price float64, markets []backpack.Market, symbol string) string {
-	for _, market := range markets {
-		if market.Symbol == symbol {
-			// 尝试获取 priceFilter
-			if priceFilterData, ok := market.Filters["priceFilter"]; ok {
-				// 将 priceFilterData 转换为 JSON 再解析
-				filterJSON, err := json.Marshal(priceFilterData)
-				if err == nil {
-					var priceFilter struct {
-						TickSize string `json:"tickSize"`
-					}
-					if err := json.Unmarshal(filterJSON, &priceFilter); err == nil && priceFilter.TickSize != "" {
-						tickSize, err := strconv.ParseFloat(priceFilter.TickSize, 64)
-						if err == nil && tickSize > 0 {
-							// 将价格对齐到 tickSize 的倍数（向下取整）
-							alignedPrice := float64(int(price/tickSize)) * tickSize
-							// 计算小数位数
-							decimals := countDecimals(tickSize)
-							// 格式化
-							priceStr := fmt.Sprintf("%."+fmt.Sprintf("%d", decimals)+"f", alignedPrice)
-							// 移除尾部的0和小数点
-							priceStr = strings.TrimRight(priceStr, "0")
-							priceStr = strings.TrimSuffix(priceStr, ".")
-							return priceStr
-						}
-					}
-				}
-			}
-			break
-		}
+	aligned := market.RoundQuantity(quantity, backpack.RoundDown)
+	if market.MinQuantity > 0 && aligned < market.MinQuantity {
+		aligned = market.MinQuantity
 	}
-	// 如果无法获取 tickSize，使用4位小数
-	priceStr := fmt.Sprintf("%.4f", price)
-	priceStr = strings.TrimRight(priceStr, "0")
-	priceStr = strings.TrimSuffix(priceStr, ".")
-	return priceStr
+	return market.FormatQuantity(aligned, backpack.RoundNearest)
 }
 
-// countDecimals 计算小数位数
-func countDecimals(value float64) int {
-	str := fmt.Sprintf("%g", value)
-	if !strings.Contains(str, ".") {
-		return 0
-	}
-	parts := strings.Split(str, ".")
-	if len(parts) != 2 {
-		return 0
+// formatPrice 格式化价格（向下对齐 tickSize）
+func formatPrice(price float64, market *backpack.MarketInfo) string {
+	if market == nil || market.TickSize.IsZero() {
+		// 如果无法获取 tickSize，使用4位小数
+		priceStr := fmt.Sprintf("%.4f", price)
+		priceStr = strings.TrimRight(priceStr, "0")
+		return strings.TrimSuffix(priceStr, ".")
 	}
-	decimals := strings.TrimRight(parts[1], "0")
-	return len(decimals)
+	return market.FormatPrice(price, backpack.RoundDown)
 }
 
 // loadEnvFile 加载 .env 文件
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/recorder"
+	"vagues-go/src/replay"
+	"vagues-go/src/trading"
+)
+
+// 合成录制数据：3 小时逐笔成交（约每 2 秒一笔）和标记价格（每 10 秒），前 2 小时作为预热
+const (
+	symbol   = "SOL_USDC_PERP"
+	duration = 3 * time.Hour
+	warmup   = 2 * time.Hour
+)
+
+// writeRecording writes a seeded random-walk recording of symbol under dir
+func writeRecording(dir string, start time.Time) error {
+	rec, err := recorder.New(dir)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go rec.Run(ctx)
+
+	rng := rand.New(rand.NewSource(42))
+	price := 150.0
+	drift := 0.0
+	tradeID := int64(0)
+	for t := start; t.Before(start.Add(duration)); t = t.Add(time.Duration(1000+rng.Intn(2000)) * time.Millisecond) {
+		// 每 15 分钟切换一次趋势，使策略有机会产生信号
+		if t.Sub(start)%(15*time.Minute) < 3*time.Second {
+			drift = (rng.Float64() - 0.5) * 0.0008
+		}
+		price *= 1 + drift + rng.NormFloat64()*0.0006
+		quantity := 0.1 + rng.Float64()*5
+		if rng.Intn(30) == 0 {
+			quantity *= 20 // 偶发放量，使 Volume 过滤可以通过
+		}
+		tradeID++
+		data, _ := json.Marshal(backpack.WSTradeMessage{
+			EventType:    "trade",
+			EventTime:    t.UnixMicro(),
+			Symbol:       symbol,
+			Price:        strconv.FormatFloat(price, 'f', 2, 64),
+			Quantity:     strconv.FormatFloat(quantity, 'f', 2, 64),
+			TradeID:      tradeID,
+			TradeTime:    t.UnixMicro(),
+			BuyerIsMaker: rng.Intn(2) == 0,
+		})
+		rec.Record(recorder.Record{Received: t.Add(5 * time.Millisecond), Stream: backpack.TradeStream(symbol), Data: data})
+
+		if tradeID%5 == 0 {
+			data, _ := json.Marshal(backpack.WSMarkPriceMessage{
+				EventType:  "markPrice",
+				EventTime:  t.UnixMicro(),
+				Symbol:     symbol,
+				MarkPrice:  strconv.FormatFloat(price, 'f', 2, 64),
+				EngineTime: t.UnixMicro(),
+			})
+			rec.Record(recorder.Record{Received: t.Add(6 * time.Millisecond), Stream: backpack.MarkPriceStream(symbol), Data: data})
+		}
+	}
+
+	cancel()
+	<-rec.Done()
+	if _, dropped := rec.Stats(); dropped > 0 {
+		return fmt.Errorf("录制丢弃了 %d 条消息", dropped)
+	}
+	return nil
+}
+
+// runOnce replays dir through a trading system, returning the log and fills
+func runOnce(dir string, config trading.Config) ([]byte, []replay.Fill, error) {
+	session, err := replay.NewSession(replay.Options{Dir: dir, Symbol: symbol, Warmup: warmup})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var logs bytes.Buffer
+	log.SetFlags(0)
+	log.SetOutput(replay.NewLogWriter(session.Clock(), &logs))
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+
+	config.WSClient = session.WSClient()
+	config.Clock = session.Clock()
+	ts, err := trading.NewTradingSystem(session.Client(), config)
+	if err != nil {
+		return logs.Bytes(), nil, err
+	}
+	if err := session.Run(context.Background(), ts); err != nil {
+		return logs.Bytes(), nil, err
+	}
+	return logs.Bytes(), session.Fills(), nil
+}
+
+func main() {
+	dir, err := os.MkdirTemp("", "replay-")
+	if err != nil {
+		log.Fatalf("创建录制目录失败: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := writeRecording(dir, start); err != nil {
+		log.Fatalf("生成录制数据失败: %v", err)
+	}
+
+	config := trading.Config{
+		Symbol:        symbol,
+		Interval:      "1m",
+		StopLossPct:   0.25,
+		TakeProfitPct: 0.6,
+		Leverage:      1,
+		MaxPosPct:     0.02,
+	}
+
+	var digests []string
+	fillCount := 0
+	for run := 1; run <= 2; run++ {
+		logs, fills, err := runOnce(dir, config)
+		if err != nil {
+			log.Fatalf("第 %d 次回放失败: %v", run, err)
+		}
+		digest := sha256.New()
+		digest.Write(logs)
+		encoder := json.NewEncoder(digest)
+		for _, fill := range fills {
+			encoder.Encode(fill)
+		}
+		digests = append(digests, fmt.Sprintf("%x", digest.Sum(nil)))
+		fillCount = len(fills)
+		log.Printf("第 %d 次回放: 日志 %d 行, 成交 %d 笔, 摘要 %s", run, bytes.Count(logs, []byte("\n")), len(fills), digests[run-1])
+		if run == 1 && os.Getenv("REPLAY_VERBOSE") != "" {
+			io.Copy(os.Stderr, bytes.NewReader(logs))
+		}
+	}
+
+	if fillCount == 0 {
+		log.Fatalf("❌ 回放没有产生成交，未覆盖下单路径")
+	}
+	if digests[0] != digests[1] {
+		log.Fatalf("❌ 两次回放结果不一致")
+	}
+	log.Printf("✅ 两次回放的日志和成交逐字节一致")
+}
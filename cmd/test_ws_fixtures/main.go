@@ -0,0 +1,577 @@
+package main
+
+// 使用录制的 WebSocket 推送数据校验 WSClient 的协议实现（本地回放服务器，不连接交易所）
+// 运行: go run ./cmd/test_ws_fixtures -fixtures cmd/test_ws_fixtures/fixtures
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/marketdata"
+	"vagues-go/src/recorder"
+
+	"github.com/gorilla/websocket"
+)
+
+// replayServer 回放录制数据的 WebSocket 服务器：收到 SUBSCRIBE 后按请求ID确认并推送该 stream 的录制消息
+type replayServer struct {
+	frames map[string][][]byte // stream -> 录制的原始消息
+
+	mu         sync.Mutex
+	conns      []*websocket.Conn
+	subscribes map[string]int // stream -> 收到的订阅次数
+}
+
+// loadFixtures 读取目录下所有 .jsonl 录制文件（每行一条原始推送）
+func loadFixtures(dir string) (map[string][][]byte, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("目录 %s 中没有 .jsonl 录制文件", dir)
+	}
+
+	frames := make(map[string][][]byte)
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var envelope struct {
+				Stream string `json:"stream"`
+			}
+			if err := json.Unmarshal([]byte(line), &envelope); err != nil || envelope.Stream == "" {
+				f.Close()
+				return nil, fmt.Errorf("%s: 无效的录制消息: %s", file, line)
+			}
+			frames[envelope.Stream] = append(frames[envelope.Stream], []byte(line))
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return frames, nil
+}
+
+func (s *replayServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, conn)
+	s.mu.Unlock()
+	defer conn.Close()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req struct {
+			Method    string   `json:"method"`
+			Params    []string `json:"params"`
+			Signature []string `json:"signature"`
+			ID        int      `json:"id"`
+		}
+		if err := json.Unmarshal(data, &req); err != nil || req.Method != "SUBSCRIBE" {
+			continue
+		}
+
+		for _, stream := range req.Params {
+			s.mu.Lock()
+			s.subscribes[stream]++
+			s.mu.Unlock()
+
+			if strings.HasPrefix(stream, "account.") {
+				if err := verifySignature(req.Signature); err != nil {
+					conn.WriteJSON(map[string]any{"id": req.ID, "error": map[string]any{"code": 4006, "message": err.Error()}})
+					continue
+				}
+			}
+
+			frames, ok := s.frames[stream]
+			if !ok {
+				conn.WriteJSON(map[string]any{"id": req.ID, "error": map[string]any{"code": 4005, "message": "Invalid stream: " + stream}})
+				continue
+			}
+			conn.WriteJSON(map[string]any{"id": req.ID, "result": nil})
+			for _, frame := range frames {
+				conn.WriteMessage(websocket.TextMessage, frame)
+			}
+		}
+	}
+}
+
+// verifySignature 校验私有 stream 订阅签名: [验证公钥, 签名, 时间戳, 时间窗口]
+func verifySignature(signature []string) error {
+	if len(signature) != 4 {
+		return fmt.Errorf("私有 stream 缺少签名")
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(signature[0])
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("无效的验证公钥")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature[1])
+	if err != nil {
+		return fmt.Errorf("无效的签名编码")
+	}
+	message := "instruction=subscribe&timestamp=" + signature[2] + "&window=" + signature[3]
+	if !ed25519.Verify(publicKey, []byte(message), sig) {
+		return fmt.Errorf("签名校验失败")
+	}
+	return nil
+}
+
+// dropAll 关闭所有连接（模拟断线）
+func (s *replayServer) dropAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// subscribeCount 返回 stream 收到的订阅次数
+func (s *replayServer) subscribeCount(stream string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscribes[stream]
+}
+
+// receive 在超时前从 ch 读取 n 条消息
+func receive[T any](ch <-chan T, n int, timeout time.Duration) ([]T, error) {
+	var items []T
+	deadline := time.After(timeout)
+	for len(items) < n {
+		select {
+		case item := <-ch:
+			items = append(items, item)
+		case <-deadline:
+			return items, fmt.Errorf("超时: 收到 %d/%d 条", len(items), n)
+		}
+	}
+	return items, nil
+}
+
+func main() {
+	fixturesDir := flag.String("fixtures", "cmd/test_ws_fixtures/fixtures", "录制数据目录")
+	flag.Parse()
+
+	frames, err := loadFixtures(*fixturesDir)
+	if err != nil {
+		log.Fatalf("加载录制数据失败: %v", err)
+	}
+
+	server := &replayServer{frames: frames, subscribes: make(map[string]int)}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	// 回放不需要真实密钥
+	client, err := backpack.NewWSClient("fixture", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		log.Fatalf("创建 WebSocket 客户端失败: %v", err)
+	}
+	client.SetURL("ws" + strings.TrimPrefix(httpServer.URL, "http"))
+	if err := client.Connect(context.Background()); err != nil {
+		log.Fatalf("连接回放服务器失败: %v", err)
+	}
+	defer client.Disconnect()
+
+	// 录制所有推送，结束时读回校验
+	recordDir, err := os.MkdirTemp("", "ws-record-")
+	if err != nil {
+		log.Fatalf("创建录制目录失败: %v", err)
+	}
+	defer os.RemoveAll(recordDir)
+	rec, err := recorder.New(recordDir)
+	if err != nil {
+		log.Fatalf("创建录制器失败: %v", err)
+	}
+	rec.Attach(client)
+	recordCtx, stopRecording := context.WithCancel(context.Background())
+	go rec.Run(recordCtx)
+
+	failed := 0
+	check := func(name string, err error) {
+		if err != nil {
+			failed++
+			log.Printf("❌ %s: %v", name, err)
+			return
+		}
+		log.Printf("✅ %s", name)
+	}
+
+	// K线推送: kline.<interval>.<symbol>
+	klines := make(chan backpack.WSKlineMessage, 16)
+	err = client.SubscribeKlines("SOL_USDC", "1m", func(msg backpack.WSKlineMessage) { klines <- msg })
+	check("订阅 "+backpack.KlineStream("SOL_USDC", "1m"), err)
+	check("解析K线推送", checkKlines(klines, len(frames[backpack.KlineStream("SOL_USDC", "1m")])))
+
+	// 逐笔成交: trade.<symbol>
+	trades := make(chan backpack.WSTradeMessage, 16)
+	err = client.SubscribeTrades("SOL_USDC", func(msg backpack.WSTradeMessage) { trades <- msg })
+	check("订阅 "+backpack.TradeStream("SOL_USDC"), err)
+	check("解析成交推送", checkTrades(trades, len(frames[backpack.TradeStream("SOL_USDC")])))
+
+	// 私有 stream: account.orderUpdate.<symbol> / account.positionUpdate.<symbol>（订阅需签名）
+	orders := make(chan backpack.WSOrderUpdate, 16)
+	err = client.SubscribeOrderUpdates("SOL_USDC_PERP", func(update backpack.WSOrderUpdate) { orders <- update })
+	check("订阅 "+backpack.OrderUpdateStream("SOL_USDC_PERP"), err)
+	check("解析订单更新推送", checkOrderUpdates(orders, len(frames[backpack.OrderUpdateStream("SOL_USDC_PERP")])))
+
+	positions := make(chan backpack.WSPositionUpdate, 16)
+	err = client.SubscribePositionUpdates("SOL_USDC_PERP", func(update backpack.WSPositionUpdate) { positions <- update })
+	check("订阅 "+backpack.PositionUpdateStream("SOL_USDC_PERP"), err)
+	check("解析持仓更新推送", checkPositionUpdates(positions, len(frames[backpack.PositionUpdateStream("SOL_USDC_PERP")])))
+
+	// 增量深度: depth.<symbol>（快照 + 增量，更新ID不连续时重新获取快照）
+	depth := &fixtureDepth{snapshots: []backpack.DepthResponse{
+		{
+			Bids:         [][]string{{"169.20", "8.0"}, {"169.10", "20.0"}},
+			Asks:         [][]string{{"169.30", "10.0"}, {"169.40", "15.0"}},
+			LastUpdateID: "100",
+			Timestamp:    1730296800100000,
+		},
+		{
+			Bids:         [][]string{{"169.22", "6.5"}, {"169.21", "4.0"}, {"169.10", "20.0"}},
+			Asks:         [][]string{{"169.27", "1.0"}, {"169.30", "12.5"}, {"169.40", "15.0"}},
+			LastUpdateID: "107",
+			Timestamp:    1730296801000000,
+		},
+	}}
+	bookSync := marketdata.NewBookSync("SOL_USDC", depth)
+	err = bookSync.Start(context.Background(), client, "")
+	check("订阅 "+backpack.DepthStream("SOL_USDC", ""), err)
+	check("订单簿快照与增量同步", checkOrderBook(bookSync))
+
+	// 订阅确认按请求ID匹配
+	time.Sleep(200 * time.Millisecond)
+	check("订阅确认按请求ID匹配", func() error {
+		if n := client.PendingRequests(); n != 0 {
+			return fmt.Errorf("仍有 %d 个请求未确认", n)
+		}
+		return nil
+	}())
+
+	// 被拒绝的订阅不再跟踪（可再次订阅，重连时不会重新订阅）
+	check("订阅错误按请求ID处理", func() error {
+		if err := client.SubscribeTrades("INVALID", func(backpack.WSTradeMessage) {}); err != nil {
+			return err
+		}
+		time.Sleep(200 * time.Millisecond)
+		if err := client.SubscribeTrades("INVALID", func(backpack.WSTradeMessage) {}); err != nil {
+			return fmt.Errorf("被拒绝的 stream 仍在跟踪: %w", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+		return nil
+	}())
+
+	// 断线后重连并重新订阅
+	gaps := make(chan backpack.WSGap, 1)
+	client.OnGap(func(gap backpack.WSGap) { gaps <- gap })
+	server.dropAll()
+	check("断线重连并重新订阅", func() error {
+		got, err := receive(gaps, 1, backpack.WSReconnectInterval+10*time.Second)
+		if err != nil {
+			return err
+		}
+		// 重新订阅后服务端会再次推送录制消息
+		if _, err := receive(trades, len(frames[backpack.TradeStream("SOL_USDC")]), 5*time.Second); err != nil {
+			return err
+		}
+		if _, err := receive(klines, len(frames[backpack.KlineStream("SOL_USDC", "1m")]), 5*time.Second); err != nil {
+			return err
+		}
+		for _, stream := range []string{backpack.KlineStream("SOL_USDC", "1m"), backpack.TradeStream("SOL_USDC")} {
+			if !slices.Contains(got[0].Streams, stream) {
+				return fmt.Errorf("缺口通知未包含 %s: %v", stream, got[0].Streams)
+			}
+			if n := server.subscribeCount(stream); n != 2 {
+				return fmt.Errorf("%s 收到 %d 次订阅，应为 2", stream, n)
+			}
+		}
+		if slices.Contains(got[0].Streams, backpack.TradeStream("INVALID")) {
+			return fmt.Errorf("重新订阅了被拒绝的 stream")
+		}
+		return nil
+	}())
+
+	stopRecording()
+	<-rec.Done()
+	check("录制推送并读回", checkRecording(recordDir, frames))
+
+	if failed > 0 {
+		log.Printf("共 %d 项校验失败", failed)
+		os.Exit(1)
+	}
+	log.Println("全部校验通过")
+}
+
+// checkKlines 校验K线推送的字段解析及收盘检测
+func checkKlines(ch <-chan backpack.WSKlineMessage, n int) error {
+	msgs, err := receive(ch, n, 5*time.Second)
+	if err != nil {
+		return err
+	}
+
+	closer := marketdata.NewKlineCloser(marketdata.MustParseInterval("1m"), 0)
+	for _, msg := range msgs {
+		if msg.EventType != "kline" || msg.Symbol == "" || msg.EventTime == 0 {
+			return fmt.Errorf("事件字段缺失: %+v", msg)
+		}
+		kline, err := marketdata.KLineFromWS(msg)
+		if err != nil {
+			return err
+		}
+		if kline.EndTime.Sub(kline.StartTime) != time.Minute {
+			return fmt.Errorf("开始/结束时间不是1分钟: %s ~ %s", kline.StartTime, kline.EndTime)
+		}
+		if kline.High < kline.Low || kline.Close <= 0 || kline.Volume <= 0 {
+			return fmt.Errorf("K线数值无效: %+v", kline)
+		}
+		closer.Update(kline, msg.Closed)
+	}
+
+	last := msgs[len(msgs)-1]
+	if !last.Closed {
+		return fmt.Errorf("最后一条推送应为已收盘 (X=true)")
+	}
+	select {
+	case bar := <-closer.Bars():
+		want, _ := marketdata.KLineFromWS(last)
+		if bar.Close != want.Close || bar.Volume != want.Volume {
+			return fmt.Errorf("收盘K线应使用最终推送: %+v", bar)
+		}
+	default:
+		return fmt.Errorf("X=true 的推送未触发收盘")
+	}
+	return nil
+}
+
+// checkTrades 校验成交推送的字段解析（时间戳为微秒）
+func checkTrades(ch <-chan backpack.WSTradeMessage, n int) error {
+	msgs, err := receive(ch, n, 5*time.Second)
+	if err != nil {
+		return err
+	}
+
+	var lastID int64
+	for _, msg := range msgs {
+		if msg.EventType != "trade" {
+			return fmt.Errorf("事件类型应为 trade: %+v", msg)
+		}
+		trade, err := marketdata.TradeFromWS(msg)
+		if err != nil {
+			return err
+		}
+		if trade.ID <= lastID || trade.Price <= 0 || trade.Quantity <= 0 {
+			return fmt.Errorf("成交数据无效: %+v", trade)
+		}
+		if eventTime := time.UnixMicro(msg.EventTime); trade.Time.After(eventTime) || eventTime.Sub(trade.Time) > time.Minute {
+			return fmt.Errorf("成交时间 %s 与事件时间 %s 不一致（时间戳单位应为微秒）", trade.Time, eventTime)
+		}
+		if trade.IsTakerBuy() == msg.BuyerIsMaker {
+			return fmt.Errorf("主动方向解析错误: %+v", trade)
+		}
+		lastID = trade.ID
+	}
+	return nil
+}
+
+// checkOrderUpdates 校验订单更新推送：开仓成交、触发单挂出和触发单成交
+func checkOrderUpdates(ch <-chan backpack.WSOrderUpdate, n int) error {
+	msgs, err := receive(ch, n, 5*time.Second)
+	if err != nil {
+		return err
+	}
+
+	var entryFilled, triggerFilled bool
+	for _, msg := range msgs {
+		if msg.OrderID == "" || msg.EventTime == 0 {
+			return fmt.Errorf("事件字段缺失: %+v", msg)
+		}
+		price, quantity, fee, ok := msg.Fill()
+		if ok != (msg.EventType == backpack.OrderEventFill) {
+			return fmt.Errorf("%s 事件的成交解析结果错误", msg.EventType)
+		}
+		if !ok {
+			continue
+		}
+		if price <= 0 || quantity <= 0 || fee <= 0 {
+			return fmt.Errorf("成交数值无效: %+v", msg)
+		}
+		avgPrice, ok := msg.AveragePrice()
+		if !ok || math.Abs(avgPrice-price) > 1e-9 {
+			return fmt.Errorf("单笔成交的均价应等于成交价: %.4f != %.4f", avgPrice, price)
+		}
+		if msg.IsTrigger() {
+			triggerFilled = msg.RelatedOrderID != ""
+		} else {
+			entryFilled = true
+		}
+	}
+	if !entryFilled || !triggerFilled {
+		return fmt.Errorf("应包含开仓成交和关联原订单的触发单成交")
+	}
+	return nil
+}
+
+// checkPositionUpdates 校验持仓更新推送：订阅快照（无事件类型）、开仓和平仓，数值字段兼容字符串和数字
+func checkPositionUpdates(ch <-chan backpack.WSPositionUpdate, n int) error {
+	msgs, err := receive(ch, n, 5*time.Second)
+	if err != nil {
+		return err
+	}
+
+	events := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Symbol == "" || msg.MarkPrice <= 0 {
+			return fmt.Errorf("持仓字段缺失: %+v", msg)
+		}
+		events = append(events, msg.EventType)
+	}
+	want := []string{"", backpack.PositionEventOpened, backpack.PositionEventClosed}
+	if !slices.Equal(events, want) {
+		return fmt.Errorf("事件顺序 %q，应为 %q", events, want)
+	}
+	if msgs[1].NetQuantity != 2.5 || msgs[2].NetQuantity != 0 || msgs[2].RealizedPnL != -7.75 {
+		return fmt.Errorf("持仓数值解析错误: %+v / %+v", msgs[1], msgs[2])
+	}
+	return nil
+}
+
+// fixtureDepth 按顺序返回录制的深度快照（之后重复最后一个）
+type fixtureDepth struct {
+	mu        sync.Mutex
+	snapshots []backpack.DepthResponse
+	calls     int
+}
+
+func (f *fixtureDepth) GetDepth(ctx context.Context, symbol string, limit int) (*backpack.DepthResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	snapshot := f.snapshots[min(f.calls, len(f.snapshots)-1)]
+	f.calls++
+	return &snapshot, nil
+}
+
+// checkOrderBook 校验订单簿：跨越快照ID的首条增量、连续增量、更新ID缺口触发重新获取快照
+func checkOrderBook(bookSync *marketdata.BookSync) error {
+	book := bookSync.Book()
+	deadline := time.Now().Add(5 * time.Second)
+	for book.LastUpdateID() < 107 || !book.Synced() {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("订单簿未同步到更新ID 107（当前 %d）", book.LastUpdateID())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if n := bookSync.Snapshots(); n != 2 {
+		return fmt.Errorf("获取快照 %d 次，应为 2 次（首次 + 缺口后重新获取）", n)
+	}
+	bid, _ := book.BestBid()
+	ask, _ := book.BestAsk()
+	if bid.Price != 169.22 || bid.Quantity != 6.5 || ask.Price != 169.27 || ask.Quantity != 1.0 {
+		return fmt.Errorf("最优买卖价错误: 买 %+v 卖 %+v", bid, ask)
+	}
+	stats, ok := book.Stats(10)
+	if !ok {
+		return fmt.Errorf("订单簿统计不可用")
+	}
+	// 中间价 169.245，10bps 范围约 169.076~169.414
+	if math.Abs(stats.Spread-0.05) > 1e-9 || stats.BidDepth != 30.5 || stats.AskDepth != 28.5 {
+		return fmt.Errorf("价差/深度错误: %+v", stats)
+	}
+	if want := (30.5 - 28.5) / 59.0; math.Abs(stats.Imbalance-want) > 1e-9 {
+		return fmt.Errorf("失衡度 %.4f，应为 %.4f", stats.Imbalance, want)
+	}
+
+	// 市价买入 5 个：1.0 @ 169.27 + 4.0 @ 169.30
+	estimate, ok := book.EstimateFill(true, 5)
+	if !ok || !estimate.Complete() || math.Abs(estimate.AvgPrice-169.294) > 1e-9 || estimate.WorstPrice != 169.30 {
+		return fmt.Errorf("成交估算错误: %+v", estimate)
+	}
+	if want := (169.294 - 169.245) / 169.245 * 10000; math.Abs(estimate.SlippageBps-want) > 1e-6 {
+		return fmt.Errorf("滑点 %.4fbps，应为 %.4fbps", estimate.SlippageBps, want)
+	}
+	if estimate, _ := book.EstimateFill(false, 100); estimate.Complete() {
+		return fmt.Errorf("深度不足时不应完全成交: %+v", estimate)
+	}
+	return nil
+}
+
+// checkRecording 校验录制文件：按交易对分目录、每个 stream 的推送都已写入、截断的文件仍可读取
+func checkRecording(dir string, frames map[string][][]byte) error {
+	files, err := recorder.Files(dir, "", "", "")
+	if err != nil {
+		return err
+	}
+	records, err := recorder.Load(files...)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for i, rec := range records {
+		if i > 0 && rec.Received.Before(records[i-1].Received) {
+			return fmt.Errorf("读回的消息未按接收时间排序")
+		}
+		counts[rec.Stream]++
+	}
+	for stream, want := range frames {
+		if counts[stream] < len(want) {
+			return fmt.Errorf("%s 录制了 %d 条，至少应为 %d 条", stream, counts[stream], len(want))
+		}
+	}
+	for _, symbol := range []string{"SOL_USDC", "SOL_USDC_PERP"} {
+		if perSymbol, _ := recorder.Files(dir, symbol, "", ""); len(perSymbol) == 0 {
+			return fmt.Errorf("缺少 %s 的录制文件", symbol)
+		}
+	}
+
+	// 模拟异常退出：截断文件末尾后仍能读出前面的消息
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return err
+	}
+	truncated := filepath.Join(dir, "truncated.jsonl.gz")
+	if err := os.WriteFile(truncated, data[:len(data)-8], 0o644); err != nil {
+		return err
+	}
+	n := 0
+	if err := recorder.ReadFile(truncated, func(recorder.Record) error { n++; return nil }); err != nil {
+		return fmt.Errorf("读取截断文件失败: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("截断文件未读出任何消息")
+	}
+	return nil
+}
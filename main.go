@@ -8,9 +8,15 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"vagues-go/src/backpack"
+	"vagues-go/src/dataio"
+	"vagues-go/src/indicators"
+	"vagues-go/src/recorder"
+	"vagues-go/src/strategy"
 	"vagues-go/src/trading"
 
 	"github.com/joho/godotenv"
@@ -35,6 +41,17 @@ func main() {
 		cancel()
 	}()
 
+	// Load trading system configuration from environment variables
+	config := loadConfigFromEnv()
+
+	// 回放录制的行情（REPLAY_DIR，不连接交易所）
+	if replayDir := os.Getenv("REPLAY_DIR"); replayDir != "" {
+		if err := runReplay(ctx, replayDir, config); err != nil {
+			log.Fatalf("回放失败: %v", err)
+		}
+		return
+	}
+
 	// Create Backpack client from environment variables
 	client, err := backpack.NewClientFromEnv()
 	if err != nil {
@@ -42,8 +59,55 @@ func main() {
 	}
 	log.Println("Backpack客户端创建成功")
 
-	// Load trading system configuration from environment variables
-	config := loadConfigFromEnv()
+	// 检查交易所状态并同步服务器时间（签名时间戳按服务器时间校正，之后定期重新同步）
+	checkExchange(ctx, client)
+	timeSyncInterval := backpack.DefaultTimeSyncInterval
+	if intervalStr := os.Getenv("BACKPACK_TIME_SYNC_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil && interval > 0 {
+			timeSyncInterval = interval
+		} else {
+			log.Printf("警告: 无法解析 BACKPACK_TIME_SYNC_INTERVAL=%s, 使用默认值 %v", intervalStr, timeSyncInterval)
+		}
+	}
+	go client.RunTimeSync(ctx, timeSyncInterval)
+
+	// 行情录制目录（MARKET_DATA_RECORD_DIR，为空时不录制）
+	recordDir := os.Getenv("MARKET_DATA_RECORD_DIR")
+
+	// 连接 WebSocket（K线/逐笔成交/账户/深度推送），失败时使用 REST 轮询和回补
+	if !config.EstimateDelta || config.BarSource != trading.BarSourcePoll || config.OrderBook || recordDir != "" {
+		wsClient, err := backpack.NewWSClientFromEnv()
+		if err == nil {
+			wsClient.SetSignClock(client.Now) // 私有 stream 订阅签名同样按服务器时间
+			err = wsClient.Connect(ctx)
+		}
+		if err != nil {
+			log.Printf("警告: 连接 WebSocket 失败: %v (将使用 REST 轮询)", err)
+		} else {
+			defer wsClient.Disconnect()
+			wsClient.OnStateChange(func(state backpack.WSState) {
+				log.Printf("WebSocket 状态: %s", state)
+			})
+			config.WSClient = wsClient
+		}
+	}
+
+	// 录制所有 WebSocket 推送（按交易对/日期写入压缩文件，用于回放和逐笔回测）
+	if recordDir != "" && config.WSClient != nil {
+		rec, err := recorder.New(recordDir)
+		if err != nil {
+			log.Printf("警告: %v (将不录制行情)", err)
+		} else {
+			rec.Attach(config.WSClient)
+			config.RecordMarkPrice = true
+			go rec.Run(ctx)
+			defer func() {
+				cancel()
+				<-rec.Done()
+			}()
+			log.Printf("✅ 行情录制已启用: %s", recordDir)
+		}
+	}
 
 	// 检查是否启用多交易对模式
 	multiSymbolMode := os.Getenv("MULTI_SYMBOL_MODE")
@@ -77,10 +141,14 @@ func main() {
 				}
 			}
 		}
+		exportClosedOrders(monitor.GetAllClosedOrders())
 	} else {
 		// 单交易对模式：原有的单交易对监控
 		log.Println("=== 启用单交易对模式 ===")
-		tradingSystem := trading.NewTradingSystem(client, config)
+		tradingSystem, err := trading.NewTradingSystem(client, config)
+		if err != nil {
+			log.Fatalf("创建交易系统失败: %v", err)
+		}
 
 		// Run trading system
 		log.Printf("启动交易系统 - 配置: %+v", config)
@@ -99,11 +167,48 @@ func main() {
 		fmt.Printf("胜率: %.2f%%\n", performance.WinRate)
 		fmt.Printf("平均盈利: %.4f USDC\n", performance.AverageWin)
 		fmt.Printf("平均亏损: %.4f USDC\n", performance.AverageLoss)
+		exportClosedOrders(tradingSystem.GetClosedOrders())
 	}
 
+	log.Printf("API 请求统计: %s", client.Stats())
 	log.Println("交易系统已停止")
 }
 
+// checkExchange checks that the exchange is reachable and not under maintenance, then synchronizes the clock
+// 检查失败只告警，不阻止启动
+func checkExchange(ctx context.Context, client *backpack.Client) {
+	if err := client.Ping(ctx); err != nil {
+		log.Printf("警告: 交易所 API 不可达: %v", err)
+	}
+	if status, err := client.GetStatus(ctx); err != nil {
+		log.Printf("警告: 获取交易所状态失败: %v", err)
+	} else if !status.OK() {
+		message := ""
+		if status.Message != nil {
+			message = *status.Message
+		}
+		log.Printf("⚠️  交易所状态: %s %s", status.Status, message)
+	}
+	if offset, err := client.SyncTime(ctx); err != nil {
+		log.Printf("警告: %v (将使用本地时间签名)", err)
+	} else {
+		log.Printf("✅ 已同步服务器时间，本地时钟偏差 %v", offset.Round(time.Millisecond))
+	}
+}
+
+// exportClosedOrders writes the closed orders to ORDERS_EXPORT_FILE (.csv 或 .parquet) when set
+func exportClosedOrders(orders []*trading.LocalOrder) {
+	path := os.Getenv("ORDERS_EXPORT_FILE")
+	if path == "" {
+		return
+	}
+	if err := dataio.ExportOrders(path, orders, dataio.ExportOptions{}); err != nil {
+		log.Printf("警告: %v", err)
+		return
+	}
+	log.Printf("已导出 %d 笔平仓订单: %s", len(orders), path)
+}
+
 // loadEnvFile loads .env file from project root
 func loadEnvFile() error {
 	// Get project root directory (where go.mod is located)
@@ -189,5 +294,143 @@ func loadConfigFromEnv() trading.Config {
 		config.MaxTradingSymbols = 20
 	}
 
+	// 读取 ATR 相关配置（ATR 倍数止损止盈、波动率归一化突破）
+	if atrPeriodStr := os.Getenv("TRADING_ATR_PERIOD"); atrPeriodStr != "" {
+		if atrPeriod, err := strconv.Atoi(atrPeriodStr); err == nil && atrPeriod > 0 {
+			config.ATRPeriod = atrPeriod
+		} else {
+			log.Printf("警告: 无法解析 TRADING_ATR_PERIOD=%s, 使用默认值 14", atrPeriodStr)
+		}
+	}
+	for envName, target := range map[string]*float64{
+		"TRADING_STOP_LOSS_ATR_MULT":   &config.StopLossATRMult,
+		"TRADING_TAKE_PROFIT_ATR_MULT": &config.TakeProfitATRMult,
+		"TRADING_BREAKOUT_ATR_MULT":    &config.BreakoutATRMult,
+	} {
+		if valueStr := os.Getenv(envName); valueStr != "" {
+			if value, err := strconv.ParseFloat(valueStr, 64); err == nil && value >= 0 {
+				*target = value
+			} else {
+				log.Printf("警告: 无法解析 %s=%s, 已忽略", envName, valueStr)
+			}
+		}
+	}
+
+	// 读取趋势过滤器（如 "adx(14,20), supertrend(10,3)" 表示 ADX > 20 且 Supertrend 方向一致）
+	if filtersStr := os.Getenv("TRADING_TREND_FILTERS"); filtersStr != "" {
+		if filters, err := strategy.ParseTrendFilters(filtersStr); err == nil {
+			var specs []indicators.Spec
+			for _, filter := range filters {
+				specs = append(specs, filter.RequiredIndicators()...)
+			}
+			if err := indicators.Validate(specs...); err == nil {
+				config.TrendFilters = filters
+			} else {
+				log.Printf("警告: TRADING_TREND_FILTERS=%s 无效: %v, 已忽略", filtersStr, err)
+			}
+		} else {
+			log.Printf("警告: 无法解析 TRADING_TREND_FILTERS=%s: %v", filtersStr, err)
+		}
+	}
+
+	// 读取背离配置（TRADING_DIVERGENCE_SOURCES 如 "macd,rsi,cvd"）
+	if v := os.Getenv("TRADING_DIVERGENCE_FILTER"); v == "true" || v == "1" {
+		config.DivergenceFilter = true
+	}
+	if v := os.Getenv("TRADING_DIVERGENCE_EXIT"); v == "true" || v == "1" {
+		config.DivergenceExit = true
+	}
+	if sourcesStr := os.Getenv("TRADING_DIVERGENCE_SOURCES"); sourcesStr != "" {
+		for _, source := range strings.Split(sourcesStr, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				config.DivergenceSources = append(config.DivergenceSources, source)
+			}
+		}
+	}
+
+	// 读取 Delta 配置（DELTA_LOOKBACK_TICKS 为逐笔窗口；TRADING_DELTA_WINDOW 为 bar 或 ticks）
+	if ticksStr := os.Getenv("DELTA_LOOKBACK_TICKS"); ticksStr != "" {
+		if ticks, err := strconv.Atoi(ticksStr); err == nil && ticks > 0 {
+			config.DeltaLookbackTicks = ticks
+		} else {
+			log.Printf("警告: 无法解析 DELTA_LOOKBACK_TICKS=%s, 使用默认值 40", ticksStr)
+		}
+	}
+	if window := os.Getenv("TRADING_DELTA_WINDOW"); window != "" {
+		config.DeltaWindow = window
+	}
+	if v := os.Getenv("TRADING_ESTIMATE_DELTA"); v == "true" || v == "1" {
+		config.EstimateDelta = true
+	}
+
+	// 读取K线来源（trades: 逐笔聚合, klines: K线推送, poll: REST 轮询；为空时自动选择）
+	if source := os.Getenv("TRADING_BAR_SOURCE"); source != "" {
+		config.BarSource = strings.ToLower(strings.TrimSpace(source))
+	}
+
+	// 读取订单簿配置（TRADING_ORDER_BOOK 启用本地订单簿；TRADING_BOOK_DEPTH_BPS 为深度/失衡统计范围）
+	if v := os.Getenv("TRADING_ORDER_BOOK"); v == "true" || v == "1" {
+		config.OrderBook = true
+	}
+	if bpsStr := os.Getenv("TRADING_BOOK_DEPTH_BPS"); bpsStr != "" {
+		if bps, err := strconv.ParseFloat(bpsStr, 64); err == nil && bps > 0 {
+			config.BookDepthBps = bps
+		} else {
+			log.Printf("警告: 无法解析 TRADING_BOOK_DEPTH_BPS=%s, 使用默认值 25", bpsStr)
+		}
+	}
+
+	// 读取流动性过滤配置（TRADING_MAX_SLIPPAGE_BPS 为市价开仓滑点上限；TRADING_BOOK_IMBALANCE 为订单簿失衡确认阈值）
+	for envName, target := range map[string]*float64{
+		"TRADING_MAX_SLIPPAGE_BPS": &config.MaxSlippageBps,
+		"TRADING_BOOK_IMBALANCE":   &config.BookImbalanceMin,
+	} {
+		if valueStr := os.Getenv(envName); valueStr != "" {
+			if value, err := strconv.ParseFloat(valueStr, 64); err == nil && value >= 0 {
+				*target = value
+			} else {
+				log.Printf("警告: 无法解析 %s=%s, 已忽略", envName, valueStr)
+			}
+		}
+	}
+	if config.BookImbalanceMin > 0 && !config.OrderBook {
+		log.Printf("警告: TRADING_BOOK_IMBALANCE 需要本地订单簿，已启用 TRADING_ORDER_BOOK")
+		config.OrderBook = true
+	}
+
+	// 读取K线数据质量配置（TRADING_SKIP_INVALID_BARS 跳过未通过校验的K线；TRADING_VOLUME_SPIKE_FACTOR 为成交量异常倍数，负数关闭）
+	if v := os.Getenv("TRADING_SKIP_INVALID_BARS"); v == "true" || v == "1" {
+		config.SkipInvalidBars = true
+	}
+	if factorStr := os.Getenv("TRADING_VOLUME_SPIKE_FACTOR"); factorStr != "" {
+		if factor, err := strconv.ParseFloat(factorStr, 64); err == nil {
+			config.VolumeSpikeFactor = factor
+		} else {
+			log.Printf("警告: 无法解析 TRADING_VOLUME_SPIKE_FACTOR=%s, 使用默认值 20", factorStr)
+		}
+	}
+
+	// 读取多周期确认配置（如 "15m,1h" 表示 15 分钟和 1 小时趋势方向需与信号一致）
+	if timeframesStr := os.Getenv("TRADING_CONFIRM_TIMEFRAMES"); timeframesStr != "" {
+		for _, interval := range strings.Split(timeframesStr, ",") {
+			if interval = strings.TrimSpace(interval); interval != "" {
+				config.ConfirmTimeframes = append(config.ConfirmTimeframes, interval)
+			}
+		}
+	}
+
+	// 读取策略额外需要的指标声明（如 "rsi(7), bbands(20,2)"）
+	if specsStr := os.Getenv("TRADING_INDICATORS"); specsStr != "" {
+		if specs, err := indicators.ParseSpecs(specsStr); err == nil {
+			if err := indicators.Validate(specs...); err == nil {
+				config.Indicators = specs
+			} else {
+				log.Printf("警告: TRADING_INDICATORS=%s 无效: %v, 已忽略", specsStr, err)
+			}
+		} else {
+			log.Printf("警告: 无法解析 TRADING_INDICATORS=%s: %v", specsStr, err)
+		}
+	}
+
 	return config
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"vagues-go/src/replay"
+	"vagues-go/src/trading"
+)
+
+// runReplay replays the market data recorded in dir through the trading system (REPLAY_DIR)
+// 使用模拟时钟、回放 WebSocket 和模拟交易所，不连接真实交易所；日志、成交和摘要可用于逐字节对比两次回放
+func runReplay(ctx context.Context, dir string, config trading.Config) error {
+	opts := replay.Options{
+		Dir:      dir,
+		Symbol:   config.Symbol,
+		TickSize: os.Getenv("REPLAY_TICK_SIZE"),
+		StepSize: os.Getenv("REPLAY_STEP_SIZE"),
+	}
+	for envName, target := range map[string]*time.Time{
+		"REPLAY_FROM": &opts.From,
+		"REPLAY_TO":   &opts.To,
+	} {
+		if valueStr := os.Getenv(envName); valueStr != "" {
+			value, err := time.Parse(time.RFC3339, valueStr)
+			if err != nil {
+				return fmt.Errorf("无法解析 %s=%s: %w", envName, valueStr, err)
+			}
+			*target = value
+		}
+	}
+	if warmupStr := os.Getenv("REPLAY_WARMUP"); warmupStr != "" {
+		warmup, err := time.ParseDuration(warmupStr)
+		if err != nil {
+			return fmt.Errorf("无法解析 REPLAY_WARMUP=%s: %w", warmupStr, err)
+		}
+		opts.Warmup = warmup
+	}
+	for envName, target := range map[string]*float64{
+		"REPLAY_BALANCE":   &opts.Balance,
+		"REPLAY_TAKER_FEE": &opts.TakerFee,
+	} {
+		if valueStr := os.Getenv(envName); valueStr != "" {
+			value, err := strconv.ParseFloat(valueStr, 64)
+			if err != nil {
+				return fmt.Errorf("无法解析 %s=%s: %w", envName, valueStr, err)
+			}
+			*target = value
+		}
+	}
+
+	session, err := replay.NewSession(opts)
+	if err != nil {
+		return err
+	}
+
+	// 日志时间使用模拟时钟，同时计算摘要
+	digest := sha256.New()
+	log.SetFlags(0)
+	log.SetOutput(replay.NewLogWriter(session.Clock(), io.MultiWriter(os.Stderr, digest)))
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+	log.Printf("=== 回放 %s: %s 起 (目录 %s) ===", config.Symbol, session.From().UTC().Format(time.RFC3339), dir)
+
+	// 回放不发送通知、不录制
+	config.WSClient = session.WSClient()
+	config.Clock = session.Clock()
+	config.TelegramBotToken = ""
+	config.TelegramChatID = ""
+	config.RecordMarkPrice = false
+
+	tradingSystem, err := trading.NewTradingSystem(session.Client(), config)
+	if err != nil {
+		return err
+	}
+	if err := session.Run(ctx, tradingSystem); err != nil {
+		return err
+	}
+
+	// 输出模拟成交（JSON Lines），一并计入摘要
+	out := io.Writer(digest)
+	if path := os.Getenv("REPLAY_ORDERS_FILE"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("创建成交文件失败: %w", err)
+		}
+		defer file.Close()
+		out = io.MultiWriter(file, digest)
+	}
+	encoder := json.NewEncoder(out)
+	for _, fill := range session.Fills() {
+		if err := encoder.Encode(fill); err != nil {
+			return fmt.Errorf("写入成交失败: %w", err)
+		}
+	}
+
+	performance := tradingSystem.GetPerformance()
+	fmt.Println("\n=== 回放性能统计 ===")
+	fmt.Printf("成交笔数: %d\n", len(session.Fills()))
+	fmt.Printf("总订单数: %d\n", performance.TotalOrders)
+	fmt.Printf("已平仓订单: %d\n", performance.ClosedOrders)
+	fmt.Printf("未平仓订单: %d\n", performance.OpenOrders)
+	fmt.Printf("总盈亏: %.4f USDC\n", performance.TotalPnL)
+	fmt.Printf("胜率: %.2f%%\n", performance.WinRate)
+	fmt.Printf("回放摘要 (日志+成交 SHA-256): %x\n", digest.Sum(nil))
+	exportClosedOrders(tradingSystem.GetClosedOrders())
+	return nil
+}
@@ -6,8 +6,10 @@ import (
 	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,6 +17,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -35,7 +38,15 @@ type Client struct {
 	privateKey ed25519.PrivateKey
 	httpClient *http.Client
 	baseURL    string
-	window     int64 // 时间窗口（毫秒）
+	window     int64        // 时间窗口（毫秒）
+	limiter    *RateLimiter // 请求限流（默认与其他客户端共享，nil 表示不限流）
+	maxRetries int          // 可重试错误的最大重试次数
+
+	requests    atomic.Int64 // 发出的请求数（含重试）
+	retries     atomic.Int64 // 重试次数
+	rateLimited atomic.Int64 // 收到 429 的次数
+	clockOffset atomic.Int64 // 服务器时间减本地时间（纳秒，见 SyncTime）
+	markets     *MarketCache // 市场信息缓存
 }
 
 // loadEnvFile 加载 .env 文件
@@ -84,21 +95,30 @@ func NewClient(apiKey, privateKeySeed string) (*Client, error) {
 
 	privateKey := ed25519.NewKeyFromSeed(seed)
 
-	return &Client{
+	client := &Client{
 		apiKey:     apiKey,
 		privateKey: privateKey,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		baseURL: BaseURL,
-		window:  DefaultWindow,
-	}, nil
+		baseURL:    BaseURL,
+		window:     DefaultWindow,
+		limiter:    sharedLimiter,
+		maxRetries: DefaultMaxRetries,
+	}
+	client.markets = NewMarketCache(client, DefaultMarketCacheTTL)
+	return client, nil
 }
 
 // NewClientFromEnv 从 .env 文件创建新的 Backpack 客户端
 // 需要 .env 文件中包含以下变量：
 //   - BACKPACK_API_KEY: Base64 编码的公钥
 //   - BACKPACK_PRIVATE_KEY: Base64 编码的私钥种子（32字节）
+//
+// 可选变量：
+//   - BACKPACK_RATE_LIMIT: 每秒请求数（默认20，0 表示不限流），所有客户端共享
+//   - BACKPACK_RATE_BURST: 允许的突发请求数（默认40）
+//   - BACKPACK_MAX_RETRIES: 可重试错误的最大重试次数（默认3）
 func NewClientFromEnv() (*Client, error) {
 	// 加载 .env 文件
 	if err := loadEnvFile(); err != nil {
@@ -116,7 +136,33 @@ func NewClientFromEnv() (*Client, error) {
 		return nil, fmt.Errorf("未找到 BACKPACK_PRIVATE_KEY，请在 .env 文件中设置或使用环境变量")
 	}
 
-	return NewClient(apiKey, privateKeySeed)
+	client, err := NewClient(apiKey, privateKeySeed)
+	if err != nil {
+		return nil, err
+	}
+
+	// 限流和重试配置
+	rate, burst := DefaultRateLimit, DefaultRateBurst
+	if value := os.Getenv("BACKPACK_RATE_LIMIT"); value != "" {
+		if rate, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("无效的 BACKPACK_RATE_LIMIT=%q: %w", value, err)
+		}
+	}
+	if value := os.Getenv("BACKPACK_RATE_BURST"); value != "" {
+		if burst, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("无效的 BACKPACK_RATE_BURST=%q: %w", value, err)
+		}
+	}
+	sharedLimiter.SetRate(rate, burst)
+	if value := os.Getenv("BACKPACK_MAX_RETRIES"); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return nil, fmt.Errorf("无效的 BACKPACK_MAX_RETRIES=%q", value)
+		}
+		client.SetMaxRetries(retries)
+	}
+
+	return client, nil
 }
 
 // SetWindow 设置请求时间窗口（毫秒）
@@ -128,12 +174,42 @@ func (c *Client) SetWindow(window int64) error {
 	return nil
 }
 
+// SetRateLimiter 设置请求限流器（nil 表示不限流，回放时使用）
+func (c *Client) SetRateLimiter(limiter *RateLimiter) {
+	c.limiter = limiter
+}
+
+// SetMaxRetries 设置可重试错误（429，以及 GET 请求的 5xx 和网络错误）的最大重试次数
+func (c *Client) SetMaxRetries(n int) {
+	if n >= 0 {
+		c.maxRetries = n
+	}
+}
+
+// Stats 返回请求统计（限流器统计为所有共享该限流器的客户端合计）
+func (c *Client) Stats() ClientStats {
+	stats := ClientStats{
+		Requests:    c.requests.Load(),
+		Retries:     c.retries.Load(),
+		RateLimited: c.rateLimited.Load(),
+	}
+	if c.limiter != nil {
+		stats.Limiter = c.limiter.Stats()
+	}
+	return stats
+}
+
+// SetTransport 设置 HTTP 传输层（回放时由模拟交易所应答所有 REST 请求）
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.httpClient.Transport = transport
+}
+
 // signRequest 生成请求签名
 // instruction: 指令类型（如 "orderExecute", "orderCancel" 等）
 // params: 请求参数（会被按字母序排序）
 func (c *Client) signRequest(instruction string, params map[string]string) (string, string, string, error) {
-	// 生成时间戳（毫秒）
-	timestamp := time.Now().UnixMilli()
+	// 生成时间戳（毫秒，按服务器时间校正）
+	timestamp := c.Now().UnixMilli()
 	timestampStr := strconv.FormatInt(timestamp, 10)
 	windowStr := strconv.FormatInt(c.window, 10)
 
@@ -199,6 +275,46 @@ func (c *Client) doRequest(ctx context.Context, method, path, instruction string
 		}
 	}
 
+	for attempt := 0; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		respBody, err := c.send(ctx, method, path, instruction, params, reqBody)
+		if err == nil {
+			return respBody, nil
+		}
+
+		var apiErr *APIError
+		rateLimited := errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+		if rateLimited {
+			c.rateLimited.Add(1)
+		}
+		delay, retry := retryDelay(method, attempt, err)
+		// 429 时暂停所有共享限流器的请求，避免其他交易对继续触发限频（重试次数用尽时同样暂停）
+		// Retry-After 超过 maxRetryAfter 时不暂停，避免一次限频阻塞所有交易对的平仓和撤单
+		if rateLimited && retry && c.limiter != nil {
+			c.limiter.Pause(time.Now().Add(delay))
+		}
+		if !retry || attempt >= c.maxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+		c.retries.Add(1)
+		log.Printf("⚠️  %s %s 失败: %v，%v 后重试 (%d/%d)", method, requestPath(path), err, delay.Round(time.Millisecond), attempt+1, c.maxRetries)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// send 签名并发送一次请求（每次重试重新签名，避免时间戳超出时间窗口）
+func (c *Client) send(ctx context.Context, method, path, instruction string, params map[string]string, reqBody []byte) ([]byte, error) {
 	// 生成签名（如果 instruction 不为空）
 	var timestamp, window, signature string
 	if instruction != "" {
@@ -230,6 +346,7 @@ func (c *Client) doRequest(ctx context.Context, method, path, instruction string
 	}
 
 	// 发送请求
+	c.requests.Add(1)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %w", err)
@@ -244,12 +361,22 @@ func (c *Client) doRequest(ctx context.Context, method, path, instruction string
 
 	// 检查状态码
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("API 请求失败: 状态码 %d, 响应: %s", resp.StatusCode, string(respBody))
+		apiErr := newAPIError(method, path, resp.StatusCode, respBody)
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return nil, apiErr
 	}
 
 	return respBody, nil
 }
 
+// requestPath returns path without the query string (用于日志)
+func requestPath(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
 // KlineResponse K线数据响应
 type KlineResponse struct {
 	Start       string `json:"start"`       // 开始时间（字符串格式）
@@ -338,12 +465,19 @@ type OrderResponse struct {
 	PostOnly      bool        `json:"postOnly,omitempty"`
 	ReduceOnly    bool        `json:"reduceOnly,omitempty"`
 	CreatedAt     interface{} `json:"createdAt"` // 可能是字符串或数字（时间戳）
+
+	ExecutedQuantity      string `json:"executedQuantity,omitempty"`      // 累计成交数量
+	ExecutedQuoteQuantity string `json:"executedQuoteQuantity,omitempty"` // 累计成交额
 }
 
-// APIError API 错误响应
-type APIError struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+// AveragePrice 返回累计成交均价（累计成交额 / 累计成交数量）
+func (r OrderResponse) AveragePrice() (float64, bool) {
+	quantity, err1 := strconv.ParseFloat(r.ExecutedQuantity, 64)
+	quote, err2 := strconv.ParseFloat(r.ExecutedQuoteQuantity, 64)
+	if err1 != nil || err2 != nil || quantity <= 0 {
+		return 0, false
+	}
+	return quote / quantity, true
 }
 
 // PlaceOrder 下单（开多/空仓）
@@ -352,21 +486,7 @@ func (c *Client) PlaceOrder(ctx context.Context, req OrderRequest) (*OrderRespon
 
 	respBody, err := c.doRequest(ctx, http.MethodPost, path, "orderExecute", req)
 	if err != nil {
-		// 尝试解析错误响应
-		var apiErr APIError
-		if jsonErr := json.Unmarshal([]byte(err.Error()), &apiErr); jsonErr == nil {
-			// 如果成功解析错误，返回更友好的错误信息
-			switch apiErr.Code {
-			case "INSUFFICIENT_MARGIN":
-				return nil, fmt.Errorf("保证金不足: %s。请检查账户余额或减少下单数量", apiErr.Message)
-			case "INVALID_ORDER":
-				return nil, fmt.Errorf("订单无效: %s", apiErr.Message)
-			case "INSUFFICIENT_BALANCE":
-				return nil, fmt.Errorf("余额不足: %s。请检查账户余额", apiErr.Message)
-			default:
-				return nil, fmt.Errorf("下单失败 [%s]: %s", apiErr.Code, apiErr.Message)
-			}
-		}
+		// 返回 *APIError，调用方用 errors.Is 判断保证金不足、订单无效等
 		return nil, err
 	}
 
@@ -413,6 +533,35 @@ func (c *Client) CancelAllOrders(ctx context.Context, symbol string) error {
 	return err
 }
 
+// OpenOrder 挂单（包括开仓时附带、尚未触发的止损/止盈触发单）
+type OpenOrder struct {
+	ID           string `json:"id"`
+	Symbol       string `json:"symbol"`
+	Side         string `json:"side"`
+	OrderType    string `json:"orderType"`
+	Quantity     string `json:"quantity"`
+	Price        string `json:"price,omitempty"`
+	TriggerPrice string `json:"triggerPrice,omitempty"` // 触发价（仅触发单）
+	ReduceOnly   bool   `json:"reduceOnly,omitempty"`
+	Status       string `json:"status"`
+}
+
+// GetOpenOrders 获取指定交易对的挂单
+func (c *Client) GetOpenOrders(ctx context.Context, symbol string) ([]OpenOrder, error) {
+	path := "/api/v1/orders?symbol=" + url.QueryEscape(symbol)
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, path, "orderQueryAll", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []OpenOrder
+	if err := json.Unmarshal(respBody, &orders); err != nil {
+		return nil, fmt.Errorf("解析挂单失败: %w", err)
+	}
+	return orders, nil
+}
+
 // PositionResponse 持仓信息（根据 FuturePositionWithMargin 结构）
 type PositionResponse struct {
 	Symbol                   string `json:"symbol"`                   // 交易对
@@ -446,7 +595,7 @@ func (c *Client) GetPositions(ctx context.Context, symbol ...string) ([]Position
 	respBody, err := c.doRequest(ctx, http.MethodGet, path, "positionQuery", nil)
 	if err != nil {
 		// 如果是 404 错误，可能表示没有持仓，返回空数组
-		if strings.Contains(err.Error(), "404") || strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, ErrNotFound) {
 			return []PositionResponse{}, nil
 		}
 		return nil, err
@@ -525,14 +674,20 @@ func (c *Client) ClosePosition(ctx context.Context, symbol string) (*OrderRespon
 		positionSize = -positionSize // 转为正数
 	}
 
+	// 平仓数量按 stepSize 对齐（持仓本身就是步长的整数倍，只需消除浮点误差）
+	quantity := fmt.Sprintf("%.8f", positionSize)
+	if info, err := c.GetMarketInfo(ctx, symbol); err == nil && !info.StepSize.IsZero() {
+		quantity = info.FormatQuantity(positionSize, RoundNearest)
+	}
+
 	// 5. 下平仓订单（使用 ReduceOnly 标志）
 	orderReq := OrderRequest{
 		Symbol:      symbol,
 		Side:        side,
-		OrderType:   "Market",                          // 使用市价单快速平仓
-		Quantity:    fmt.Sprintf("%.8f", positionSize), // 平仓数量等于持仓数量
-		ReduceOnly:  true,                              // 仅减仓标志
-		TimeInForce: "IOC",                             // 立即成交或取消
+		OrderType:   "Market", // 使用市价单快速平仓
+		Quantity:    quantity, // 平仓数量等于持仓数量
+		ReduceOnly:  true,     // 仅减仓标志
+		TimeInForce: "IOC",    // 立即成交或取消
 	}
 
 	return c.PlaceOrder(ctx, orderReq)
@@ -649,66 +804,49 @@ type QuantityFilter struct {
 	MinQuantity string `json:"minQuantity"`
 	MaxQuantity string `json:"maxQuantity,omitempty"`
 	StepSize    string `json:"stepSize"`
+	MinNotional string `json:"minNotional,omitempty"`
+	MaxNotional string `json:"maxNotional,omitempty"`
 }
 
 // Market 市场信息
 type Market struct {
-	BaseSymbol     string                 `json:"baseSymbol"`
-	QuoteSymbol    string                 `json:"quoteSymbol"`
-	Symbol         string                 `json:"symbol"`
-	MarketType     string                 `json:"marketType"` // SPOT, PERP
-	Visible        bool                   `json:"visible"`
-	OrderBookState string                 `json:"orderBookState"`
-	Filters        map[string]interface{} `json:"filters"`
+	BaseSymbol      string                 `json:"baseSymbol"`
+	QuoteSymbol     string                 `json:"quoteSymbol"`
+	Symbol          string                 `json:"symbol"`
+	MarketType      string                 `json:"marketType"` // SPOT, PERP
+	Visible         bool                   `json:"visible"`
+	OrderBookState  string                 `json:"orderBookState"`
+	Filters         map[string]interface{} `json:"filters"`
+	FundingInterval millis                 `json:"fundingInterval,omitempty"` // 资金费结算间隔（毫秒，仅合约市场）
 }
 
-// GetQuantityFilter 获取数量过滤器
+// GetQuantityFilter 获取数量过滤器（交易所返回的 quantity 或旧格式 quantityFilter）
 func (m *Market) GetQuantityFilter() (*QuantityFilter, error) {
-	filterData, ok := m.Filters["quantityFilter"]
-	if !ok {
-		return nil, fmt.Errorf("未找到 quantityFilter")
-	}
-
-	// 将 filterData 转换为 JSON 再解析
-	filterJSON, err := json.Marshal(filterData)
+	var qf QuantityFilter
+	ok, err := m.filter(&qf, "quantity", "quantityFilter")
 	if err != nil {
-		return nil, fmt.Errorf("序列化 quantityFilter 失败: %w", err)
+		return nil, err
 	}
-
-	var qf QuantityFilter
-	if err := json.Unmarshal(filterJSON, &qf); err != nil {
-		return nil, fmt.Errorf("解析 quantityFilter 失败: %w", err)
+	if !ok {
+		return nil, fmt.Errorf("未找到 quantityFilter")
 	}
-
 	return &qf, nil
 }
 
+// GetMarketInfo 获取交易对的精度和交易限制（缓存 DefaultMarketCacheTTL，所有使用该客户端的交易系统共享）
+func (c *Client) GetMarketInfo(ctx context.Context, symbol string) (*MarketInfo, error) {
+	return c.markets.Get(ctx, symbol)
+}
+
 // GetMarkets 获取所有市场信息
 // API 文档：https://api.backpack.exchange/api/v1/markets
 func (c *Client) GetMarkets(ctx context.Context) ([]Market, error) {
 	path := "/api/v1/markets"
 
 	// 这是一个公开接口，不需要签名
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
+	respBody, err := c.doRequest(ctx, http.MethodGet, path, "", nil)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API 请求失败: 状态码 %d, 响应: %s", resp.StatusCode, string(respBody))
+		return nil, err
 	}
 
 	var markets []Market
@@ -0,0 +1,92 @@
+package backpack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DepthLimits 深度快照支持的档位数量
+var DepthLimits = []int{5, 10, 20, 50, 100, 500, 1000}
+
+// DepthResponse 订单簿深度快照
+type DepthResponse struct {
+	Asks         [][]string `json:"asks"`         // 卖单 [价格, 数量]
+	Bids         [][]string `json:"bids"`         // 买单 [价格, 数量]
+	LastUpdateID string     `json:"lastUpdateId"` // 快照对应的最后更新ID
+	Timestamp    int64      `json:"timestamp"`    // 撮合引擎时间（微秒）
+}
+
+// GetDepth 获取订单簿深度快照（公开端点，不需要认证）
+// limit: 每侧档位数量，向上取到支持的档位（<=0 时使用交易所默认值）
+func (c *Client) GetDepth(ctx context.Context, symbol string, limit int) (*DepthResponse, error) {
+	queryParams := url.Values{}
+	queryParams.Set("symbol", symbol)
+	if limit > 0 {
+		queryParams.Set("limit", strconv.Itoa(depthLimit(limit)))
+	}
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/api/v1/depth?"+queryParams.Encode(), "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var depth DepthResponse
+	if err := json.Unmarshal(respBody, &depth); err != nil {
+		return nil, fmt.Errorf("解析深度数据失败: %w", err)
+	}
+	return &depth, nil
+}
+
+// depthLimit 返回不小于 limit 的最小支持档位
+func depthLimit(limit int) int {
+	for _, supported := range DepthLimits {
+		if limit <= supported {
+			return supported
+		}
+	}
+	return DepthLimits[len(DepthLimits)-1]
+}
+
+// WSDepthMessage 增量深度推送（stream: depth[.<聚合周期>].<symbol>）
+// 每档为该价格的最新绝对数量（数量为0表示删除）；FirstUpdateID 应等于上一条的 LastUpdateID+1
+type WSDepthMessage struct {
+	EventType     string     `json:"e"` // 事件类型
+	EventTime     int64      `json:"E"` // 事件时间（微秒）
+	Symbol        string     `json:"s"` // 交易对
+	Asks          [][]string `json:"a"` // 卖单变化 [价格, 数量]
+	Bids          [][]string `json:"b"` // 买单变化 [价格, 数量]
+	FirstUpdateID int64      `json:"U"` // 本条推送的第一个更新ID
+	LastUpdateID  int64      `json:"u"` // 本条推送的最后一个更新ID
+	EngineTime    int64      `json:"T"` // 撮合引擎时间（微秒）
+}
+
+// DepthStream 返回深度推送的 stream 名称
+// aggregation 为空时实时推送（depth.<symbol>），否则按 200ms、600ms 或 1000ms 聚合（depth.<aggregation>.<symbol>）
+func DepthStream(symbol, aggregation string) string {
+	if aggregation == "" {
+		return "depth." + symbol
+	}
+	return "depth." + aggregation + "." + symbol
+}
+
+// SubscribeDepth 订阅增量深度（初始快照需通过 GetDepth 获取）
+func (ws *WSClient) SubscribeDepth(symbol, aggregation string, handler func(WSDepthMessage)) error {
+	return ws.subscribe(DepthStream(symbol, aggregation), func(data []byte) {
+		var depthMsg WSDepthMessage
+		if err := json.Unmarshal(data, &depthMsg); err == nil {
+			handler(depthMsg)
+		} else {
+			log.Printf("解析深度推送失败: %v, 数据: %s", err, string(data))
+		}
+	})
+}
+
+// UnsubscribeDepth 取消订阅增量深度
+func (ws *WSClient) UnsubscribeDepth(symbol, aggregation string) error {
+	return ws.unsubscribe(DepthStream(symbol, aggregation))
+}
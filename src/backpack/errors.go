@@ -0,0 +1,93 @@
+package backpack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API 错误类别，配合 errors.Is 判断 *APIError 的类型
+var (
+	ErrRateLimited        = errors.New("请求频率超限")
+	ErrInsufficientMargin = errors.New("保证金或余额不足")
+	ErrInvalidOrder       = errors.New("订单无效")
+	ErrAuth               = errors.New("认证失败")
+	ErrNotFound           = errors.New("资源不存在")
+)
+
+// errorCodes 交易所错误代码对应的错误类别
+var errorCodes = map[string]error{
+	"TOO_MANY_REQUESTS":    ErrRateLimited,
+	"RATE_LIMIT_EXCEEDED":  ErrRateLimited,
+	"INSUFFICIENT_MARGIN":  ErrInsufficientMargin,
+	"INSUFFICIENT_FUNDS":   ErrInsufficientMargin,
+	"INSUFFICIENT_BALANCE": ErrInsufficientMargin,
+	"INVALID_ORDER":        ErrInvalidOrder,
+	"INVALID_PRICE":        ErrInvalidOrder,
+	"INVALID_QUANTITY":     ErrInvalidOrder,
+	"INVALID_MARKET":       ErrInvalidOrder,
+	"ORDER_LIMIT_REACHED":  ErrInvalidOrder,
+	"UNAUTHORIZED":         ErrAuth,
+	"INVALID_SIGNATURE":    ErrAuth,
+	"INVALID_API_KEY":      ErrAuth,
+	"EXPIRED_REQUEST":      ErrAuth, // 时间戳超出时间窗口
+	"RESOURCE_NOT_FOUND":   ErrNotFound,
+}
+
+// maxErrorBody 错误信息中保留的非 JSON 响应长度
+const maxErrorBody = 500
+
+// APIError API 错误响应（非 2xx 状态码）
+type APIError struct {
+	StatusCode int           `json:"-"`       // HTTP 状态码
+	Code       string        `json:"code"`    // 交易所错误代码（如 INSUFFICIENT_MARGIN，响应不是 JSON 时为空）
+	Message    string        `json:"message"` // 错误信息（响应不是 JSON 时为原始响应）
+	Method     string        `json:"-"`
+	Path       string        `json:"-"` // 请求路径（含查询参数）
+	RetryAfter time.Duration `json:"-"` // 响应头 Retry-After（没有时为 0）
+}
+
+// newAPIError parses an error response body
+func newAPIError(method, path string, statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Method: method, Path: path}
+	if err := json.Unmarshal(body, apiErr); err != nil || (apiErr.Code == "" && apiErr.Message == "") {
+		message := strings.TrimSpace(string(body))
+		if len(message) > maxErrorBody {
+			message = message[:maxErrorBody] + "..."
+		}
+		apiErr.Code, apiErr.Message = "", message
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("API 请求失败: %s %s 状态码 %d, 响应: %s", e.Method, e.Path, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("API 请求失败: %s %s 状态码 %d [%s] %s", e.Method, e.Path, e.StatusCode, e.Code, e.Message)
+}
+
+// Is reports whether the error belongs to the category target (ErrRateLimited 等)
+func (e *APIError) Is(target error) bool {
+	return target != nil && e.Kind() == target
+}
+
+// Kind returns the error category, or nil when the error is not categorized
+// 优先按错误代码判断，其次按 HTTP 状态码
+func (e *APIError) Kind() error {
+	if kind, ok := errorCodes[e.Code]; ok {
+		return kind
+	}
+	switch e.StatusCode {
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuth
+	case http.StatusNotFound:
+		return ErrNotFound
+	}
+	return nil
+}
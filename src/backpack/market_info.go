@@ -0,0 +1,379 @@
+package backpack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 市场信息缓存参数
+const (
+	DefaultMarketCacheTTL = time.Hour   // 缓存时间（精度和限制很少变化）
+	marketRetryDelay      = time.Minute // 刷新失败后使用过期缓存，间隔多久再重试
+)
+
+// millis is a millisecond count encoded as a JSON number or string
+type millis int64
+
+func (m *millis) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		*m = 0
+		return nil
+	}
+	value, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("无效的毫秒数: %s", data)
+	}
+	*m = millis(value)
+	return nil
+}
+
+// PriceFilter 价格过滤器
+type PriceFilter struct {
+	MinPrice string `json:"minPrice,omitempty"`
+	MaxPrice string `json:"maxPrice,omitempty"`
+	TickSize string `json:"tickSize"`
+}
+
+// LeverageFilter 杠杆过滤器（仅合约市场）
+type LeverageFilter struct {
+	MinLeverage string `json:"minLeverage,omitempty"`
+	MaxLeverage string `json:"maxLeverage,omitempty"`
+	StepSize    string `json:"stepSize,omitempty"`
+}
+
+// NotionalFilter 名义价值过滤器（价格 × 数量）
+type NotionalFilter struct {
+	MinNotional string `json:"minNotional,omitempty"`
+	MaxNotional string `json:"maxNotional,omitempty"`
+}
+
+// Rounding 价格和数量对齐到步长的方向
+type Rounding int
+
+const (
+	RoundDown    Rounding = iota // 向下取整（默认，数量不超过计算值）
+	RoundNearest                 // 四舍五入到最近的步长
+	RoundUp                      // 向上取整
+)
+
+// Increment is a price or quantity increment kept as an exact decimal (units / 10^decimals)
+// 按字符串解析，避免 0.1 之类的步长在浮点运算后格式化出多余的小数位
+type Increment struct {
+	units    int64
+	decimals int
+}
+
+// ParseIncrement parses a decimal increment such as "0.01" or "1"
+func ParseIncrement(value string) (Increment, error) {
+	value = strings.TrimSpace(value)
+	whole, frac, _ := strings.Cut(value, ".")
+	frac = strings.TrimRight(frac, "0")
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || units <= 0 || len(frac) > 18 {
+		return Increment{}, fmt.Errorf("无效的步长: %q", value)
+	}
+	return Increment{units: units, decimals: len(frac)}, nil
+}
+
+// IsZero reports whether the increment is unset
+func (inc Increment) IsZero() bool {
+	return inc.units == 0
+}
+
+// Float returns the increment as a float
+func (inc Increment) Float() float64 {
+	return float64(inc.units) / math.Pow10(inc.decimals)
+}
+
+// Decimals returns the number of decimals of the increment
+func (inc Increment) Decimals() int {
+	return inc.decimals
+}
+
+// steps returns value in increments, rounded in mode
+// 加上相对误差容限，避免 0.3/0.1 = 2.9999999999999996 之类的浮点误差被向下取整
+func (inc Increment) steps(value float64, mode Rounding) int64 {
+	n := value / inc.Float()
+	const epsilon = 1e-9
+	switch mode {
+	case RoundUp:
+		return int64(math.Ceil(n - epsilon*math.Max(1, math.Abs(n))))
+	case RoundNearest:
+		return int64(math.Round(n))
+	default:
+		return int64(math.Floor(n + epsilon*math.Max(1, math.Abs(n))))
+	}
+}
+
+// Round aligns value to a multiple of the increment
+func (inc Increment) Round(value float64, mode Rounding) float64 {
+	if inc.IsZero() {
+		return value
+	}
+	return float64(inc.steps(value, mode)*inc.units) / math.Pow10(inc.decimals)
+}
+
+// Format aligns value to a multiple of the increment and formats it without trailing zeros
+func (inc Increment) Format(value float64, mode Rounding) string {
+	if inc.IsZero() {
+		return trimZeros(strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	total := inc.steps(value, mode) * inc.units
+	sign := ""
+	if total < 0 {
+		sign, total = "-", -total
+	}
+	digits := strconv.FormatInt(total, 10)
+	if inc.decimals == 0 {
+		return sign + digits
+	}
+	if len(digits) <= inc.decimals {
+		digits = strings.Repeat("0", inc.decimals-len(digits)+1) + digits
+	}
+	point := len(digits) - inc.decimals
+	return sign + trimZeros(digits[:point]+"."+digits[point:])
+}
+
+func trimZeros(value string) string {
+	if strings.Contains(value, ".") {
+		value = strings.TrimSuffix(strings.TrimRight(value, "0"), ".")
+	}
+	return value
+}
+
+// MarketInfo 解析后的市场精度和交易限制（未提供的限制为 0）
+type MarketInfo struct {
+	Symbol          string
+	BaseSymbol      string
+	QuoteSymbol     string
+	MarketType      string // SPOT, PERP
+	TickSize        Increment
+	MinPrice        float64
+	MaxPrice        float64
+	StepSize        Increment
+	MinQuantity     float64
+	MaxQuantity     float64
+	MinLeverage     float64
+	MaxLeverage     float64
+	MinNotional     float64
+	MaxNotional     float64
+	FundingInterval time.Duration // 资金费结算间隔（仅合约市场）
+}
+
+// Info parses the filters of a market
+// 过滤器名称兼容 price/quantity/leverage（交易所返回）和 priceFilter/quantityFilter（旧格式）
+func (m *Market) Info() (*MarketInfo, error) {
+	info := &MarketInfo{
+		Symbol:          m.Symbol,
+		BaseSymbol:      m.BaseSymbol,
+		QuoteSymbol:     m.QuoteSymbol,
+		MarketType:      m.MarketType,
+		FundingInterval: time.Duration(m.FundingInterval) * time.Millisecond,
+	}
+
+	var price PriceFilter
+	if ok, err := m.filter(&price, "price", "priceFilter"); err != nil {
+		return nil, err
+	} else if ok {
+		if info.TickSize, err = ParseIncrement(price.TickSize); err != nil {
+			return nil, fmt.Errorf("%s tickSize: %w", m.Symbol, err)
+		}
+		info.MinPrice, info.MaxPrice = parseLimit(price.MinPrice), parseLimit(price.MaxPrice)
+	}
+
+	quantity, err := m.GetQuantityFilter()
+	if err == nil {
+		if info.StepSize, err = ParseIncrement(quantity.StepSize); err != nil {
+			return nil, fmt.Errorf("%s stepSize: %w", m.Symbol, err)
+		}
+		info.MinQuantity, info.MaxQuantity = parseLimit(quantity.MinQuantity), parseLimit(quantity.MaxQuantity)
+		info.MinNotional, info.MaxNotional = parseLimit(quantity.MinNotional), parseLimit(quantity.MaxNotional)
+	}
+
+	var leverage LeverageFilter
+	if _, err := m.filter(&leverage, "leverage", "leverageFilter"); err != nil {
+		return nil, err
+	}
+	info.MinLeverage, info.MaxLeverage = parseLimit(leverage.MinLeverage), parseLimit(leverage.MaxLeverage)
+
+	var notional NotionalFilter
+	if ok, err := m.filter(&notional, "notional", "notionalFilter"); err != nil {
+		return nil, err
+	} else if ok {
+		info.MinNotional, info.MaxNotional = parseLimit(notional.MinNotional), parseLimit(notional.MaxNotional)
+	}
+	return info, nil
+}
+
+// filter decodes the first filter present under one of names into target
+func (m *Market) filter(target any, names ...string) (bool, error) {
+	for _, name := range names {
+		data, ok := m.Filters[name]
+		if !ok || data == nil {
+			continue
+		}
+		filterJSON, err := json.Marshal(data)
+		if err == nil {
+			err = json.Unmarshal(filterJSON, target)
+		}
+		if err != nil {
+			return false, fmt.Errorf("解析 %s %s 失败: %w", m.Symbol, name, err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// parseLimit parses an optional limit; empty or invalid means no limit (0)
+func parseLimit(value string) float64 {
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil || limit < 0 || math.IsInf(limit, 0) || math.IsNaN(limit) {
+		return 0
+	}
+	return limit
+}
+
+// RoundPrice aligns price to the tick size
+func (m *MarketInfo) RoundPrice(price float64, mode Rounding) float64 {
+	return m.TickSize.Round(price, mode)
+}
+
+// FormatPrice aligns price to the tick size and formats it for an order
+func (m *MarketInfo) FormatPrice(price float64, mode Rounding) string {
+	return m.TickSize.Format(price, mode)
+}
+
+// RoundQuantity aligns quantity to the step size
+func (m *MarketInfo) RoundQuantity(quantity float64, mode Rounding) float64 {
+	return m.StepSize.Round(quantity, mode)
+}
+
+// FormatQuantity aligns quantity to the step size and formats it for an order
+func (m *MarketInfo) FormatQuantity(quantity float64, mode Rounding) string {
+	return m.StepSize.Format(quantity, mode)
+}
+
+// CheckOrder checks an order's price and quantity against the market limits
+func (m *MarketInfo) CheckOrder(price, quantity float64) error {
+	switch {
+	case m.MinQuantity > 0 && quantity < m.MinQuantity:
+		return fmt.Errorf("%s 数量 %v 小于最小数量 %v", m.Symbol, quantity, m.MinQuantity)
+	case m.MaxQuantity > 0 && quantity > m.MaxQuantity:
+		return fmt.Errorf("%s 数量 %v 大于最大数量 %v", m.Symbol, quantity, m.MaxQuantity)
+	case m.MinPrice > 0 && price < m.MinPrice:
+		return fmt.Errorf("%s 价格 %v 小于最低价格 %v", m.Symbol, price, m.MinPrice)
+	case m.MaxPrice > 0 && price > m.MaxPrice:
+		return fmt.Errorf("%s 价格 %v 大于最高价格 %v", m.Symbol, price, m.MaxPrice)
+	case m.MinNotional > 0 && price*quantity < m.MinNotional:
+		return fmt.Errorf("%s 名义价值 %v 小于最小名义价值 %v", m.Symbol, price*quantity, m.MinNotional)
+	case m.MaxNotional > 0 && price*quantity > m.MaxNotional:
+		return fmt.Errorf("%s 名义价值 %v 大于最大名义价值 %v", m.Symbol, price*quantity, m.MaxNotional)
+	}
+	return nil
+}
+
+// MarketCache caches the parsed market list of a client for a TTL
+// 刷新失败时继续使用过期的数据（精度很少变化），没有数据时返回错误
+type MarketCache struct {
+	client  *Client
+	ttl     time.Duration
+	mu      sync.Mutex
+	markets map[string]*MarketInfo
+	fetched time.Time // 上次成功刷新的时间
+	retryAt time.Time // 刷新失败后下次重试的时间
+
+	refreshing chan struct{} // 正在进行的刷新（完成时关闭，nil 表示没有）
+}
+
+// NewMarketCache creates a market cache refreshing through client every ttl
+func NewMarketCache(client *Client, ttl time.Duration) *MarketCache {
+	if ttl <= 0 {
+		ttl = DefaultMarketCacheTTL
+	}
+	return &MarketCache{client: client, ttl: ttl}
+}
+
+// Get returns the market info of symbol, refreshing the cache when it has expired
+// 刷新在锁外进行且同一时间只有一个：已有缓存时其他调用方继续使用旧数据，首次加载时等待刷新完成
+func (c *MarketCache) Get(ctx context.Context, symbol string) (*MarketInfo, error) {
+	c.mu.Lock()
+	for {
+		now := time.Now()
+		if c.markets != nil && (now.Sub(c.fetched) <= c.ttl || now.Before(c.retryAt)) {
+			break
+		}
+		if c.refreshing != nil {
+			if c.markets != nil {
+				break
+			}
+			done := c.refreshing
+			c.mu.Unlock()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			c.mu.Lock()
+			continue
+		}
+
+		done := make(chan struct{})
+		c.refreshing = done
+		c.mu.Unlock()
+		markets, err := c.fetch(ctx)
+		c.mu.Lock()
+		c.refreshing = nil
+		close(done)
+
+		if err == nil {
+			c.markets, c.fetched = markets, time.Now()
+			break
+		}
+		if c.markets == nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+		log.Printf("⚠️  刷新市场信息失败: %v（继续使用 %s 前的缓存）", err, now.Sub(c.fetched).Round(time.Second))
+		c.retryAt = now.Add(marketRetryDelay) // 避免每次下单都请求
+		break
+	}
+	info, ok := c.markets[symbol]
+	c.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("未找到交易对 %s 的市场信息", symbol)
+	}
+	return info, nil
+}
+
+// Invalidate forces the next Get to refresh
+func (c *MarketCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetched, c.retryAt = time.Time{}, time.Time{}
+}
+
+// fetch requests and parses all markets (调用方不持有锁)
+func (c *MarketCache) fetch(ctx context.Context) (map[string]*MarketInfo, error) {
+	markets, err := c.client.GetMarkets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parsed := make(map[string]*MarketInfo, len(markets))
+	for i := range markets {
+		info, err := markets[i].Info()
+		if err != nil {
+			log.Printf("⚠️  %v", err)
+			continue
+		}
+		parsed[info.Symbol] = info
+	}
+	return parsed, nil
+}
@@ -0,0 +1,172 @@
+package backpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 限流和重试参数
+const (
+	DefaultRateLimit  = 20.0 // 每秒请求数（所有客户端共享）
+	DefaultRateBurst  = 40   // 允许的突发请求数
+	DefaultMaxRetries = 3    // 可重试错误的最大重试次数
+	retryBaseDelay    = 500 * time.Millisecond
+	retryMaxDelay     = 10 * time.Second
+	maxRetryAfter     = time.Minute // Retry-After 超过此值时不再重试，直接返回错误
+)
+
+// sharedLimiter 所有客户端默认共享的限流器（多交易对模式下各 TradingSystem 共用同一个 IP 的额度）
+var sharedLimiter = NewRateLimiter(DefaultRateLimit, DefaultRateBurst)
+
+// SharedRateLimiter returns the limiter shared by clients created with NewClient
+func SharedRateLimiter() *RateLimiter {
+	return sharedLimiter
+}
+
+// RateLimiter is a token bucket limiting the request rate
+// 令牌不足时预约下一个令牌并等待；收到 429 时暂停所有共享该限流器的请求
+type RateLimiter struct {
+	mu          sync.Mutex
+	rate        float64 // 每秒令牌数（<= 0 表示不限流）
+	burst       float64
+	tokens      float64 // 可为负数（已预约的令牌）
+	last        time.Time
+	pausedUntil time.Time
+	stats       LimiterStats
+}
+
+// LimiterStats counts the requests delayed by a limiter
+type LimiterStats struct {
+	Throttled int64         // 被限流等待的请求数
+	Waited    time.Duration // 累计等待时间
+	Pauses    int64         // 因 429 暂停的次数
+}
+
+// NewRateLimiter creates a limiter allowing rate requests per second with bursts of burst
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetRate(rate, burst)
+	return l
+}
+
+// SetRate changes the rate and burst; rate <= 0 disables limiting
+func (l *RateLimiter) SetRate(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.burst = float64(max(burst, 1))
+	l.tokens = l.burst
+	l.last = time.Now()
+}
+
+// Wait blocks until a request may be sent or ctx is cancelled
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	var wait time.Duration
+	if l.rate > 0 {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		l.tokens--
+		if l.tokens < 0 {
+			wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+	}
+	if paused := l.pausedUntil.Sub(now); paused > wait {
+		wait = paused
+	}
+	if wait > 0 {
+		l.stats.Throttled++
+		l.stats.Waited += wait
+	}
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// 归还预约的令牌
+		l.mu.Lock()
+		if l.rate > 0 {
+			l.tokens = min(l.burst, l.tokens+1)
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Pause delays every request until t (收到 429 时按 Retry-After 暂停)
+func (l *RateLimiter) Pause(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.pausedUntil) {
+		l.pausedUntil = t
+		l.stats.Pauses++
+	}
+}
+
+// Stats returns the throttling statistics
+func (l *RateLimiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// ClientStats summarizes the requests of a client
+type ClientStats struct {
+	Requests    int64 // 发出的请求数（含重试）
+	Retries     int64 // 重试次数
+	RateLimited int64 // 收到 429 的次数
+	Limiter     LimiterStats
+}
+
+func (s ClientStats) String() string {
+	return fmt.Sprintf("请求 %d 次, 重试 %d 次, 429 %d 次, 限流等待 %d 次 (共 %s), 暂停 %d 次",
+		s.Requests, s.Retries, s.RateLimited, s.Limiter.Throttled, s.Limiter.Waited.Round(time.Millisecond), s.Limiter.Pauses)
+}
+
+// retryDelay returns how long to wait before retrying a failed request, or false when it must not be retried
+// 429 表示请求未被处理，任何方法都可重试；5xx 和网络错误只重试 GET（下单等请求可能已执行）
+func retryDelay(method string, attempt int, err error) (time.Duration, bool) {
+	var apiErr *APIError
+	isAPIErr := errors.As(err, &apiErr)
+	switch {
+	case isAPIErr && apiErr.StatusCode == http.StatusTooManyRequests:
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, apiErr.RetryAfter <= maxRetryAfter
+		}
+	case method != http.MethodGet:
+		return 0, false
+	case isAPIErr && apiErr.StatusCode < 500:
+		return 0, false
+	}
+
+	// 指数退避，在 [delay/2, delay) 内随机抖动，避免多个交易对同时重试
+	delay := min(retryBaseDelay<<min(attempt, 5), retryMaxDelay) // 限制移位，避免重试次数较大时溢出
+	return delay/2 + rand.N(delay/2), true
+}
+
+// parseRetryAfter parses a Retry-After header (秒数或 HTTP 日期)
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
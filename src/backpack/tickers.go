@@ -0,0 +1,36 @@
+package backpack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// TickerResponse 交易对行情统计（默认24小时）
+type TickerResponse struct {
+	Symbol             string `json:"symbol"`             // 交易对
+	FirstPrice         string `json:"firstPrice"`         // 区间首个成交价
+	LastPrice          string `json:"lastPrice"`          // 最新成交价
+	PriceChange        string `json:"priceChange"`        // 价格变化
+	PriceChangePercent string `json:"priceChangePercent"` // 价格变化百分比
+	High               string `json:"high"`               // 最高价
+	Low                string `json:"low"`                // 最低价
+	Volume             string `json:"volume"`             // 成交量（基础资产）
+	QuoteVolume        string `json:"quoteVolume"`        // 成交额（计价资产）
+	Trades             string `json:"trades"`             // 成交笔数
+}
+
+// GetTickers 获取所有交易对的24小时行情统计（公开端点，不需要认证）
+func (c *Client) GetTickers(ctx context.Context) ([]TickerResponse, error) {
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/api/v1/tickers", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var tickers []TickerResponse
+	if err := json.Unmarshal(respBody, &tickers); err != nil {
+		return nil, fmt.Errorf("解析行情统计失败: %w", err)
+	}
+	return tickers, nil
+}
@@ -0,0 +1,136 @@
+package backpack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 服务器时间同步参数
+const (
+	DefaultTimeSyncInterval = 10 * time.Minute // 定期同步间隔
+	DriftWarningThreshold   = time.Second      // 本地时钟偏差超过此值时告警（签名时间窗口默认5秒）
+	maxSyncRoundTrip        = 5 * time.Second  // 往返时间超过此值的测量误差过大，丢弃
+)
+
+// SystemStatus 交易所系统状态
+type SystemStatus struct {
+	Status  string  `json:"status"`  // "Ok" 或 "Maintenance"
+	Message *string `json:"message"` // 维护说明（可能为空）
+}
+
+// OK 交易所是否正常运行
+func (s SystemStatus) OK() bool {
+	return strings.EqualFold(s.Status, "Ok")
+}
+
+// GetServerTime 获取服务器时间（公开端点 /api/v1/time，返回毫秒时间戳）
+func (c *Client) GetServerTime(ctx context.Context) (time.Time, error) {
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/api/v1/time", "", nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseServerTime(respBody)
+}
+
+// parseServerTime 解析 /api/v1/time 的响应（毫秒时间戳）
+func parseServerTime(respBody []byte) (time.Time, error) {
+	millis, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(string(respBody)), `"`), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("解析服务器时间失败: %w (原始响应: %s)", err, string(respBody))
+	}
+	return time.UnixMilli(millis), nil
+}
+
+// Ping 检查 API 是否可达（/api/v1/ping 返回 pong）
+func (c *Client) Ping(ctx context.Context) error {
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/api/v1/ping", "", nil)
+	if err != nil {
+		return err
+	}
+	if reply := strings.Trim(strings.TrimSpace(string(respBody)), `"`); !strings.EqualFold(reply, "pong") {
+		return fmt.Errorf("ping 响应异常: %s", reply)
+	}
+	return nil
+}
+
+// GetStatus 获取交易所系统状态（/api/v1/status）
+func (c *Client) GetStatus(ctx context.Context) (*SystemStatus, error) {
+	respBody, err := c.doRequest(ctx, http.MethodGet, "/api/v1/status", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var status SystemStatus
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return nil, fmt.Errorf("解析系统状态失败: %w (原始响应: %s)", err, string(respBody))
+	}
+	return &status, nil
+}
+
+// Now 返回按服务器时间校正后的当前时间（用于请求签名）
+func (c *Client) Now() time.Time {
+	return time.Now().Add(c.ClockOffset())
+}
+
+// ClockOffset 返回服务器时间减去本地时间的偏差（未同步时为 0）
+func (c *Client) ClockOffset() time.Duration {
+	return time.Duration(c.clockOffset.Load())
+}
+
+// SyncTime 查询服务器时间并更新时钟偏差，偏差超过 DriftWarningThreshold 时告警
+// 服务器时间按请求往返的中点估算；只发送一次请求（不重试），计时不包含限流等待
+func (c *Client) SyncTime(ctx context.Context) (time.Duration, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return c.ClockOffset(), fmt.Errorf("同步服务器时间失败: %w", err)
+		}
+	}
+	sent := time.Now()
+	respBody, err := c.send(ctx, http.MethodGet, "/api/v1/time", "", nil, nil)
+	received := time.Now()
+	if err != nil {
+		return c.ClockOffset(), fmt.Errorf("同步服务器时间失败: %w", err)
+	}
+	serverTime, err := parseServerTime(respBody)
+	if err != nil {
+		return c.ClockOffset(), fmt.Errorf("同步服务器时间失败: %w", err)
+	}
+	roundTrip := received.Sub(sent)
+	if roundTrip > maxSyncRoundTrip {
+		return c.ClockOffset(), fmt.Errorf("同步服务器时间失败: 往返时间 %v 过长", roundTrip.Round(time.Millisecond))
+	}
+
+	offset := serverTime.Sub(sent.Add(roundTrip / 2))
+	previous := time.Duration(c.clockOffset.Swap(int64(offset)))
+	if offset > DriftWarningThreshold || offset < -DriftWarningThreshold {
+		log.Printf("⚠️  本地时钟与服务器相差 %v（往返 %v），已按服务器时间签名，建议开启 NTP 同步",
+			offset.Round(time.Millisecond), roundTrip.Round(time.Millisecond))
+	} else if change := offset - previous; change > DriftWarningThreshold || change < -DriftWarningThreshold {
+		log.Printf("⚠️  本地时钟偏差变化 %v（当前 %v）", change.Round(time.Millisecond), offset.Round(time.Millisecond))
+	}
+	return offset, nil
+}
+
+// RunTimeSync 每隔 interval 同步一次服务器时间，直到 ctx 取消（启动时应先调用 SyncTime）
+func (c *Client) RunTimeSync(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultTimeSyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.SyncTime(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("⚠️  %v（沿用上次的时钟偏差 %v）", err, c.ClockOffset().Round(time.Millisecond))
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package backpack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// MaxTradesLimit 单次查询成交记录的最大条数
+const MaxTradesLimit = 1000
+
+// TradeResponse 公开成交记录
+type TradeResponse struct {
+	ID            int64  `json:"id"`            // 成交ID（按交易对递增）
+	Price         string `json:"price"`         // 成交价
+	Quantity      string `json:"quantity"`      // 成交数量（基础资产）
+	QuoteQuantity string `json:"quoteQuantity"` // 成交额（计价资产）
+	Timestamp     int64  `json:"timestamp"`     // 成交时间（毫秒）
+	IsBuyerMaker  bool   `json:"isBuyerMaker"`  // 买方是否为挂单方（true 表示主动卖出）
+}
+
+// GetRecentTrades 获取最近成交记录（公开端点，不需要认证）
+// limit: 返回条数（默认100，最大1000）
+func (c *Client) GetRecentTrades(ctx context.Context, symbol string, limit int) ([]TradeResponse, error) {
+	queryParams := url.Values{}
+	queryParams.Set("symbol", symbol)
+	if limit > 0 {
+		queryParams.Set("limit", strconv.Itoa(min(limit, MaxTradesLimit)))
+	}
+
+	return c.getTrades(ctx, "/api/v1/trades?"+queryParams.Encode())
+}
+
+// GetHistoricalTrades 获取历史成交记录（公开端点，不需要认证）
+// offset: 从最新成交往前偏移的条数，用于分页回补
+func (c *Client) GetHistoricalTrades(ctx context.Context, symbol string, limit, offset int) ([]TradeResponse, error) {
+	queryParams := url.Values{}
+	queryParams.Set("symbol", symbol)
+	if limit > 0 {
+		queryParams.Set("limit", strconv.Itoa(min(limit, MaxTradesLimit)))
+	}
+	if offset > 0 {
+		queryParams.Set("offset", strconv.Itoa(offset))
+	}
+
+	return c.getTrades(ctx, "/api/v1/trades/history?"+queryParams.Encode())
+}
+
+// getTrades 请求并解析成交记录
+func (c *Client) getTrades(ctx context.Context, path string) ([]TradeResponse, error) {
+	respBody, err := c.doRequest(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var trades []TradeResponse
+	if err := json.Unmarshal(respBody, &trades); err != nil {
+		return nil, fmt.Errorf("解析成交记录失败: %w", err)
+	}
+
+	return trades, nil
+}
@@ -0,0 +1,216 @@
+package backpack
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WSSignatureWindow 私有 stream 订阅签名的有效时间窗口（毫秒）
+const WSSignatureWindow = 5000
+
+// 订单更新事件类型（account.orderUpdate）
+const (
+	OrderEventAccepted      = "orderAccepted"
+	OrderEventCancelled     = "orderCancelled"
+	OrderEventExpired       = "orderExpired"
+	OrderEventFill          = "orderFill"
+	OrderEventModified      = "orderModified"
+	OrderEventTriggerPlaced = "triggerPlaced"
+	OrderEventTriggerFailed = "triggerFailed"
+)
+
+// 持仓更新事件类型（account.positionUpdate；订阅时推送的当前持仓快照没有事件类型）
+const (
+	PositionEventAdjusted = "positionAdjusted"
+	PositionEventOpened   = "positionOpened"
+	PositionEventClosed   = "positionClosed"
+)
+
+// WSOrderUpdate 订单更新推送（stream: account.orderUpdate[.<symbol>]）
+// 部分字段仅在特定订单类型或事件中出现（如成交字段只在 orderFill 中出现）
+type WSOrderUpdate struct {
+	EventType             string `json:"e"` // 事件类型
+	EventTime             int64  `json:"E"` // 事件时间（微秒）
+	Symbol                string `json:"s"` // 交易对
+	ClientID              int64  `json:"c"` // 客户端订单ID
+	Side                  string `json:"S"` // 方向（Bid/Ask）
+	OrderType             string `json:"o"` // 订单类型（LIMIT/MARKET）
+	TimeInForce           string `json:"f"` // 有效方式
+	Quantity              string `json:"q"` // 数量
+	QuoteQuantity         string `json:"Q"` // 计价资产数量
+	Price                 string `json:"p"` // 限价
+	TriggerPrice          string `json:"P"` // 触发价（触发单）
+	TriggerBy             string `json:"B"` // 触发价类型
+	TakeProfitTrigger     string `json:"a"` // 止盈触发价
+	StopLossTrigger       string `json:"b"` // 止损触发价
+	TakeProfitLimit       string `json:"j"` // 止盈限价
+	StopLossLimit         string `json:"k"` // 止损限价
+	TakeProfitTriggerBy   string `json:"d"` // 止盈触发价类型
+	StopLossTriggerBy     string `json:"g"` // 止损触发价类型
+	TriggerQuantity       string `json:"Y"` // 触发数量
+	Status                string `json:"X"` // 订单状态
+	ExpiryReason          string `json:"R"` // 过期原因（orderExpired）
+	OrderID               string `json:"i"` // 订单ID
+	TradeID               int64  `json:"t"` // 成交ID（orderFill）
+	FillQuantity          string `json:"l"` // 本次成交数量（orderFill）
+	ExecutedQuantity      string `json:"z"` // 累计成交数量
+	ExecutedQuoteQuantity string `json:"Z"` // 累计成交额
+	FillPrice             string `json:"L"` // 本次成交价（orderFill）
+	IsMaker               bool   `json:"m"` // 是否为挂单方（orderFill）
+	Fee                   string `json:"n"` // 手续费（orderFill）
+	FeeSymbol             string `json:"N"` // 手续费币种（orderFill）
+	SelfTradePrevention   string `json:"V"` // 自成交保护
+	EngineTime            int64  `json:"T"` // 撮合引擎时间（微秒）
+	Origin                string `json:"O"` // 更新来源（USER、LIQUIDATION_AUTOCLOSE 等）
+	RelatedOrderID        string `json:"I"` // 关联订单ID（触发单对应的原订单）
+	StrategyID            int64  `json:"H"` // 策略ID
+	PostOnly              bool   `json:"y"` // 是否只做 Maker
+}
+
+// IsTrigger 是否为触发单（止损/止盈等）
+func (u WSOrderUpdate) IsTrigger() bool {
+	return u.TriggerPrice != ""
+}
+
+// Fill 返回本次成交的价格、数量和手续费（非 orderFill 事件返回 false）
+func (u WSOrderUpdate) Fill() (price, quantity, fee float64, ok bool) {
+	if u.EventType != OrderEventFill {
+		return 0, 0, 0, false
+	}
+	price, err1 := strconv.ParseFloat(u.FillPrice, 64)
+	quantity, err2 := strconv.ParseFloat(u.FillQuantity, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, 0, false
+	}
+	fee, _ = strconv.ParseFloat(u.Fee, 64)
+	return price, quantity, fee, true
+}
+
+// AveragePrice 返回累计成交均价（累计成交额 / 累计成交数量）
+func (u WSOrderUpdate) AveragePrice() (float64, bool) {
+	executed, err1 := strconv.ParseFloat(u.ExecutedQuantity, 64)
+	quote, err2 := strconv.ParseFloat(u.ExecutedQuoteQuantity, 64)
+	if err1 != nil || err2 != nil || executed <= 0 {
+		return 0, false
+	}
+	return quote / executed, true
+}
+
+// WSPositionUpdate 持仓更新推送（stream: account.positionUpdate[.<symbol>]）
+type WSPositionUpdate struct {
+	EventType                 string    `json:"e"` // 事件类型（订阅时的持仓快照为空）
+	EventTime                 int64     `json:"E"` // 事件时间（微秒）
+	Symbol                    string    `json:"s"` // 交易对
+	BreakEvenPrice            FlexFloat `json:"b"` // 盈亏平衡价
+	EntryPrice                FlexFloat `json:"B"` // 开仓均价
+	InitialMarginFraction     FlexFloat `json:"f"` // 初始保证金率
+	MarkPrice                 FlexFloat `json:"M"` // 标记价格
+	MaintenanceMarginFraction FlexFloat `json:"m"` // 维持保证金率
+	NetQuantity               FlexFloat `json:"q"` // 净持仓数量（多为正，空为负）
+	NetExposureQuantity       FlexFloat `json:"Q"` // 净敞口数量（含挂单）
+	NetExposureNotional       FlexFloat `json:"n"` // 净敞口名义价值
+	PositionID                string    `json:"i"` // 持仓ID
+	RealizedPnL               FlexFloat `json:"p"` // 已实现盈亏
+	UnrealizedPnL             FlexFloat `json:"P"` // 未实现盈亏
+	EngineTime                int64     `json:"T"` // 撮合引擎时间（微秒）
+}
+
+// FlexFloat 兼容字符串和数字两种 JSON 表示的浮点数（文档示例中持仓字段两种形式均有）
+type FlexFloat float64
+
+// UnmarshalJSON 解析 "1.23" 或 1.23
+func (f *FlexFloat) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		*f = 0
+		return nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("无效的数值: %s", string(data))
+	}
+	*f = FlexFloat(value)
+	return nil
+}
+
+// OrderUpdateStream 返回订单更新 stream 名称（symbol 为空时订阅所有交易对）
+func OrderUpdateStream(symbol string) string {
+	if symbol == "" {
+		return "account.orderUpdate"
+	}
+	return "account.orderUpdate." + symbol
+}
+
+// PositionUpdateStream 返回持仓更新 stream 名称（symbol 为空时订阅所有交易对）
+func PositionUpdateStream(symbol string) string {
+	if symbol == "" {
+		return "account.positionUpdate"
+	}
+	return "account.positionUpdate." + symbol
+}
+
+// SubscribeOrderUpdates 订阅订单更新（私有 stream，需签名）
+func (ws *WSClient) SubscribeOrderUpdates(symbol string, handler func(WSOrderUpdate)) error {
+	return ws.subscribe(OrderUpdateStream(symbol), func(data []byte) {
+		var update WSOrderUpdate
+		if err := json.Unmarshal(data, &update); err == nil {
+			handler(update)
+		} else {
+			log.Printf("解析订单更新失败: %v, 数据: %s", err, string(data))
+		}
+	})
+}
+
+// SubscribePositionUpdates 订阅持仓更新（私有 stream，需签名）
+func (ws *WSClient) SubscribePositionUpdates(symbol string, handler func(WSPositionUpdate)) error {
+	return ws.subscribe(PositionUpdateStream(symbol), func(data []byte) {
+		var update WSPositionUpdate
+		if err := json.Unmarshal(data, &update); err == nil {
+			handler(update)
+		} else {
+			log.Printf("解析持仓更新失败: %v, 数据: %s", err, string(data))
+		}
+	})
+}
+
+// isPrivateStream 私有 stream 以 account. 开头
+func isPrivateStream(stream string) bool {
+	return strings.HasPrefix(stream, "account.")
+}
+
+// SetSignClock 设置签名使用的时间（如 Client.Now，按服务器时间校正），需在 Connect 之前调用
+func (ws *WSClient) SetSignClock(now func() time.Time) {
+	ws.signNow = now
+}
+
+// signTime 返回签名使用的当前时间
+func (ws *WSClient) signTime() time.Time {
+	if ws.signNow != nil {
+		return ws.signNow()
+	}
+	return time.Now()
+}
+
+// signSubscription 生成私有 stream 订阅签名: [验证公钥, 签名, 时间戳, 时间窗口]
+// 签名字符串: instruction=subscribe&timestamp=<毫秒>&window=<毫秒>
+func (ws *WSClient) signSubscription(now time.Time) []string {
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	window := strconv.Itoa(WSSignatureWindow)
+	message := "instruction=subscribe&timestamp=" + timestamp + "&window=" + window
+
+	signature := ed25519.Sign(ws.privateKey, []byte(message))
+	verifyingKey := ws.privateKey.Public().(ed25519.PublicKey)
+
+	return []string{
+		base64.StdEncoding.EncodeToString(verifyingKey),
+		base64.StdEncoding.EncodeToString(signature),
+		timestamp,
+		window,
+	}
+}
@@ -9,20 +9,25 @@ import (
 	"log"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 const (
-	// WSBaseURL WebSocket 基础地址
-	WSBaseURL = "wss://api.backpack.exchange"
-	// WSReconnectInterval WebSocket 重连间隔
+	// WSBaseURL WebSocket 地址（openapi.json: wss://ws.backpack.exchange）
+	WSBaseURL = "wss://ws.backpack.exchange"
+	// WSReconnectInterval WebSocket 首次重连等待时间（之后指数退避）
 	WSReconnectInterval = 5 * time.Second
-	// WSPingInterval WebSocket Ping 间隔
+	// WSMaxReconnectInterval WebSocket 重连等待时间上限
+	WSMaxReconnectInterval = 2 * time.Minute
+	// WSPingInterval WebSocket Ping 间隔（服务端每60秒 Ping 一次，120秒内未收到 Pong 会断开）
 	WSPingInterval = 30 * time.Second
-	// WSReadTimeout WebSocket 读取超时
-	WSReadTimeout = 60 * time.Second
+	// WSReadTimeout WebSocket 读取超时（收到数据、Ping 或 Pong 时延长）
+	WSReadTimeout = 90 * time.Second
+	// WSAckTimeout 订阅请求等待确认的时间，超时后不再跟踪
+	WSAckTimeout = 30 * time.Second
 	// WSWriteTimeout WebSocket 写入超时
 	WSWriteTimeout = 10 * time.Second
 )
@@ -31,6 +36,8 @@ const (
 type WSClient struct {
 	apiKey        string
 	privateKey    ed25519.PrivateKey
+	signNow       func() time.Time // 签名时间（为空时使用本地时间）
+	url           string
 	conn          *websocket.Conn
 	connMutex     sync.RWMutex
 	ctx           context.Context
@@ -40,15 +47,39 @@ type WSClient struct {
 	handlers      map[string]func([]byte) // 消息处理器
 	handlersMutex sync.RWMutex
 	reconnectChan chan struct{}
+	writeMutex    sync.Mutex // 同一连接同时只允许一个写入方
+	startOnce     sync.Once  // 重连监控和 Ping 协程只启动一次
+	replay        bool       // 回放模式：不建立连接，推送由 Deliver 注入
+
+	lastMessage    atomic.Int64 // 最近一次收到消息的时间（UnixNano），用于计算断线缺口
+	callbacksMutex sync.RWMutex
+	stateCallbacks []func(WSState)
+	gapCallbacks   []func(WSGap)
+	msgCallbacks   []func(WSRawMessage)
+
+	nextID       atomic.Int64 // 请求ID（单调递增，避免同一秒内的订阅冲突）
+	pendingMutex sync.Mutex
+	pending      map[int]wsRequest // 等待确认的请求
+}
+
+// wsRequest 已发送、等待确认的订阅/取消订阅请求
+type wsRequest struct {
+	method  string
+	streams []string
+	sentAt  time.Time
 }
 
 // WSMessage WebSocket 消息
+// 发送: {"method","params","id"}；确认: {"id","result"} 或 {"id","error"}；推送: {"stream","data"}
 type WSMessage struct {
-	Method string          `json:"method"`
-	Params json.RawMessage `json:"params,omitempty"`
-	ID     int             `json:"id,omitempty"`
-	Result json.RawMessage `json:"result,omitempty"`
-	Error  *WSError        `json:"error,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Signature []string        `json:"signature,omitempty"`
+	ID        int             `json:"id,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *WSError        `json:"error,omitempty"`
+	Stream    string          `json:"stream,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
 }
 
 // WSError WebSocket 错误
@@ -57,24 +88,54 @@ type WSError struct {
 	Message string `json:"message"`
 }
 
-// WSKlineMessage K线数据消息
+// WSKlineMessage K线推送（stream: kline.<interval>.<symbol>）
+// 同一根K线在收盘前会多次推送，Closed 为 true 时为最终数据
 type WSKlineMessage struct {
-	Symbol   string    `json:"symbol"`
-	Interval string    `json:"interval"`
-	Kline    KlineData `json:"kline"`
+	EventType string `json:"e"` // 事件类型 "kline"
+	EventTime int64  `json:"E"` // 事件时间（微秒）
+	Symbol    string `json:"s"` // 交易对
+	Start     string `json:"t"` // 开始时间（ISO 8601，UTC）
+	End       string `json:"T"` // 结束时间（ISO 8601，UTC）
+	Open      string `json:"o"` // 开盘价
+	Close     string `json:"c"` // 收盘价
+	High      string `json:"h"` // 最高价
+	Low       string `json:"l"` // 最低价
+	Volume    string `json:"v"` // 成交量（基础资产）
+	Trades    int64  `json:"n"` // 成交笔数
+	Closed    bool   `json:"X"` // 是否已收盘
+}
+
+// WSMarkPriceMessage 标记价格推送（stream: markPrice.<symbol>）
+type WSMarkPriceMessage struct {
+	EventType       string `json:"e"` // 事件类型 "markPrice"
+	EventTime       int64  `json:"E"` // 事件时间（微秒）
+	Symbol          string `json:"s"` // 交易对
+	MarkPrice       string `json:"p"` // 标记价格
+	FundingRate     string `json:"f"` // 预估资金费率
+	IndexPrice      string `json:"i"` // 指数价格
+	NextFundingTime int64  `json:"n"` // 下次资金费时间（毫秒）
+	EngineTime      int64  `json:"T"` // 撮合引擎时间（微秒）
 }
 
-// KlineData K线数据
-type KlineData struct {
-	Start       string `json:"start"`
-	End         string `json:"end"`
-	Open        string `json:"open"`
-	High        string `json:"high"`
-	Low         string `json:"low"`
-	Close       string `json:"close"`
-	Volume      string `json:"volume"`
-	QuoteVolume string `json:"quoteVolume"`
-	Trades      string `json:"trades"`
+// WSRawMessage 收到的原始推送（用于录制行情）
+type WSRawMessage struct {
+	Received time.Time       // 本地接收时间
+	Stream   string          // stream 名称
+	Data     json.RawMessage // 推送的 data 字段
+}
+
+// WSTradeMessage 逐笔成交推送（stream: trade.<symbol>）
+type WSTradeMessage struct {
+	EventType     string `json:"e"` // 事件类型 "trade"
+	EventTime     int64  `json:"E"` // 事件时间（微秒）
+	Symbol        string `json:"s"` // 交易对
+	Price         string `json:"p"` // 成交价
+	Quantity      string `json:"q"` // 成交数量
+	BuyerOrderID  string `json:"b"` // 买方订单ID
+	SellerOrderID string `json:"a"` // 卖方订单ID
+	TradeID       int64  `json:"t"` // 成交ID
+	TradeTime     int64  `json:"T"` // 撮合引擎时间（微秒）
+	BuyerIsMaker  bool   `json:"m"` // 买方是否为挂单方（true 表示主动卖出）
 }
 
 // NewWSClient 创建新的 WebSocket 客户端
@@ -98,9 +159,11 @@ func NewWSClient(apiKey, privateKeySeed string) (*WSClient, error) {
 		privateKey:    privateKey,
 		ctx:           ctx,
 		cancel:        cancel,
+		url:           WSBaseURL,
 		subscribed:    make(map[string]bool),
 		handlers:      make(map[string]func([]byte)),
 		reconnectChan: make(chan struct{}, 1),
+		pending:       make(map[int]wsRequest),
 	}, nil
 }
 
@@ -116,8 +179,20 @@ func NewWSClientFromEnv() (*WSClient, error) {
 	return NewWSClient(apiKey, privateKeySeed)
 }
 
+// SetURL 设置 WebSocket 地址（需在 Connect 之前调用，用于录制数据回放等）
+func (ws *WSClient) SetURL(url string) {
+	ws.connMutex.Lock()
+	defer ws.connMutex.Unlock()
+	ws.url = url
+}
+
 // Connect 连接到 WebSocket 服务器
 func (ws *WSClient) Connect(ctx context.Context) error {
+	if ws.replay {
+		ws.notifyState(WSStateConnected)
+		return nil
+	}
+
 	ws.connMutex.Lock()
 	defer ws.connMutex.Unlock()
 
@@ -125,26 +200,37 @@ func (ws *WSClient) Connect(ctx context.Context) error {
 		return fmt.Errorf("WebSocket 已连接")
 	}
 
-	// 构建 WebSocket URL
-	wsURL := WSBaseURL + "/ws"
+	conn, err := ws.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	ws.conn = conn
+	ws.lastMessage.Store(time.Now().UnixNano())
+
+	// 启动消息处理 goroutine；断线后由 supervise 重连
+	go ws.readLoop(conn)
+	ws.startOnce.Do(func() {
+		go ws.supervise()
+		go ws.pingLoop()
+	})
+
+	ws.notifyState(WSStateConnected)
+	return nil
+}
 
+// dial 建立 WebSocket 连接
+func (ws *WSClient) dial(ctx context.Context) (*websocket.Conn, error) {
 	// 创建 WebSocket 连接
 	dialer := websocket.Dialer{
 		HandshakeTimeout: 10 * time.Second,
 	}
 
-	conn, _, err := dialer.DialContext(ctx, wsURL, nil)
+	conn, _, err := dialer.DialContext(ctx, ws.url, nil)
 	if err != nil {
-		return fmt.Errorf("连接 WebSocket 失败: %w", err)
+		return nil, fmt.Errorf("连接 WebSocket 失败: %w", err)
 	}
-
-	ws.conn = conn
-
-	// 启动消息处理 goroutine
-	go ws.readLoop()
-	go ws.pingLoop()
-
-	return nil
+	return conn, nil
 }
 
 // Disconnect 断开 WebSocket 连接
@@ -157,16 +243,83 @@ func (ws *WSClient) Disconnect() error {
 	if ws.conn != nil {
 		err := ws.conn.Close()
 		ws.conn = nil
+		ws.notifyState(WSStateDisconnected)
 		return err
 	}
 
 	return nil
 }
 
-// SubscribeKlines 订阅 K线数据
+// KlineStream 返回K线推送的 stream 名称（kline.<interval>.<symbol>）
+func KlineStream(symbol, interval string) string {
+	return "kline." + interval + "." + symbol
+}
+
+// TradeStream 返回逐笔成交推送的 stream 名称（trade.<symbol>）
+func TradeStream(symbol string) string {
+	return "trade." + symbol
+}
+
+// MarkPriceStream 返回标记价格推送的 stream 名称（markPrice.<symbol>）
+func MarkPriceStream(symbol string) string {
+	return "markPrice." + symbol
+}
+
+// SubscribeKlines 订阅 K线数据（stream: kline.<interval>.<symbol>）
 func (ws *WSClient) SubscribeKlines(symbol, interval string, handler func(WSKlineMessage)) error {
-	stream := fmt.Sprintf("%s@kline_%s", symbol, interval)
+	return ws.subscribe(KlineStream(symbol, interval), func(data []byte) {
+		var klineMsg WSKlineMessage
+		if err := json.Unmarshal(data, &klineMsg); err == nil {
+			handler(klineMsg)
+		} else {
+			log.Printf("解析K线推送失败: %v, 数据: %s", err, string(data))
+		}
+	})
+}
+
+// UnsubscribeKlines 取消订阅 K线数据
+func (ws *WSClient) UnsubscribeKlines(symbol, interval string) error {
+	return ws.unsubscribe(KlineStream(symbol, interval))
+}
+
+// SubscribeTrades 订阅逐笔成交（stream: trade.<symbol>）
+func (ws *WSClient) SubscribeTrades(symbol string, handler func(WSTradeMessage)) error {
+	return ws.subscribe(TradeStream(symbol), func(data []byte) {
+		var tradeMsg WSTradeMessage
+		if err := json.Unmarshal(data, &tradeMsg); err == nil {
+			handler(tradeMsg)
+		} else {
+			log.Printf("解析成交推送失败: %v, 数据: %s", err, string(data))
+		}
+	})
+}
+
+// UnsubscribeTrades 取消订阅逐笔成交
+func (ws *WSClient) UnsubscribeTrades(symbol string) error {
+	return ws.unsubscribe(TradeStream(symbol))
+}
+
+// SubscribeMarkPrice 订阅标记价格（stream: markPrice.<symbol>）
+func (ws *WSClient) SubscribeMarkPrice(symbol string, handler func(WSMarkPriceMessage)) error {
+	return ws.subscribe(MarkPriceStream(symbol), func(data []byte) {
+		var markMsg WSMarkPriceMessage
+		if err := json.Unmarshal(data, &markMsg); err == nil {
+			handler(markMsg)
+		} else {
+			log.Printf("解析标记价格推送失败: %v, 数据: %s", err, string(data))
+		}
+	})
+}
 
+// OnMessage 注册原始推送回调，每条推送在交给 stream 处理器之前调用（在 WebSocket 协程中调用，不应阻塞）
+func (ws *WSClient) OnMessage(callback func(WSRawMessage)) {
+	ws.callbacksMutex.Lock()
+	defer ws.callbacksMutex.Unlock()
+	ws.msgCallbacks = append(ws.msgCallbacks, callback)
+}
+
+// subscribe 发送订阅消息并注册 stream 的消息处理器
+func (ws *WSClient) subscribe(stream string, handler func([]byte)) error {
 	ws.subMutex.Lock()
 	if ws.subscribed[stream] {
 		ws.subMutex.Unlock()
@@ -175,38 +328,27 @@ func (ws *WSClient) SubscribeKlines(symbol, interval string, handler func(WSKlin
 	ws.subscribed[stream] = true
 	ws.subMutex.Unlock()
 
-	// 构建订阅消息
-	subscribeMsg := WSMessage{
-		Method: "SUBSCRIBE",
-		Params: json.RawMessage(fmt.Sprintf(`["%s"]`, stream)),
-		ID:     int(time.Now().Unix()),
-	}
+	// 先注册处理器，避免订阅后第一条推送丢失
+	ws.handlersMutex.Lock()
+	ws.handlers[stream] = handler
+	ws.handlersMutex.Unlock()
 
-	// 发送订阅消息
-	if err := ws.sendMessage(subscribeMsg); err != nil {
+	// 发送订阅消息（成功后加入跟踪，断线重连时重新订阅）
+	if err := ws.sendRequest("SUBSCRIBE", stream); err != nil {
 		ws.subMutex.Lock()
 		delete(ws.subscribed, stream)
 		ws.subMutex.Unlock()
+		ws.handlersMutex.Lock()
+		delete(ws.handlers, stream)
+		ws.handlersMutex.Unlock()
 		return fmt.Errorf("发送订阅消息失败: %w", err)
 	}
 
-	// 注册消息处理器
-	ws.handlersMutex.Lock()
-	ws.handlers[stream] = func(data []byte) {
-		var klineMsg WSKlineMessage
-		if err := json.Unmarshal(data, &klineMsg); err == nil {
-			handler(klineMsg)
-		}
-	}
-	ws.handlersMutex.Unlock()
-
 	return nil
 }
 
-// UnsubscribeKlines 取消订阅 K线数据
-func (ws *WSClient) UnsubscribeKlines(symbol, interval string) error {
-	stream := fmt.Sprintf("%s@kline_%s", symbol, interval)
-
+// unsubscribe 发送取消订阅消息并移除 stream 的消息处理器
+func (ws *WSClient) unsubscribe(stream string) error {
 	ws.subMutex.Lock()
 	if !ws.subscribed[stream] {
 		ws.subMutex.Unlock()
@@ -215,15 +357,8 @@ func (ws *WSClient) UnsubscribeKlines(symbol, interval string) error {
 	delete(ws.subscribed, stream)
 	ws.subMutex.Unlock()
 
-	// 构建取消订阅消息
-	unsubscribeMsg := WSMessage{
-		Method: "UNSUBSCRIBE",
-		Params: json.RawMessage(fmt.Sprintf(`["%s"]`, stream)),
-		ID:     int(time.Now().Unix()),
-	}
-
 	// 发送取消订阅消息
-	if err := ws.sendMessage(unsubscribeMsg); err != nil {
+	if err := ws.sendRequest("UNSUBSCRIBE", stream); err != nil {
 		return fmt.Errorf("发送取消订阅消息失败: %w", err)
 	}
 
@@ -245,47 +380,64 @@ func (ws *WSClient) sendMessage(msg WSMessage) error {
 		return fmt.Errorf("WebSocket 未连接")
 	}
 
-	// 设置写入超时
-	conn.SetWriteDeadline(time.Now().Add(WSWriteTimeout))
-
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("序列化消息失败: %w", err)
 	}
 
-	return conn.WriteMessage(websocket.TextMessage, data)
+	return ws.write(conn, websocket.TextMessage, data)
 }
 
-// readLoop 读取消息循环
-func (ws *WSClient) readLoop() {
+// write 写入一帧消息（带写入超时）
+func (ws *WSClient) write(conn *websocket.Conn, messageType int, data []byte) error {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+
+	// 设置写入超时
+	conn.SetWriteDeadline(time.Now().Add(WSWriteTimeout))
+	return conn.WriteMessage(messageType, data)
+}
+
+// readLoop 读取消息循环（每个连接一个）
+func (ws *WSClient) readLoop(conn *websocket.Conn) {
+	// 收到 Pong 说明连接仍然可用，延长读取超时
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(WSReadTimeout))
+	})
+	// 服务端每60秒发送 Ping，需回复 Pong
+	conn.SetPingHandler(func(appData string) error {
+		conn.SetReadDeadline(time.Now().Add(WSReadTimeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(WSWriteTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
 	for {
 		select {
 		case <-ws.ctx.Done():
 			return
 		default:
-			ws.connMutex.RLock()
-			conn := ws.conn
-			ws.connMutex.RUnlock()
-
-			if conn == nil {
-				return
-			}
-
 			// 设置读取超时
 			conn.SetReadDeadline(time.Now().Add(WSReadTimeout))
 
 			_, data, err := conn.ReadMessage()
 			if err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Printf("WebSocket 读取错误: %v", err)
-					// 触发重连
-					select {
-					case ws.reconnectChan <- struct{}{}:
-					default:
-					}
+				// 主动断开时不重连
+				if ws.ctx.Err() != nil {
+					return
+				}
+				log.Printf("WebSocket 读取错误: %v", err)
+				ws.dropConn(conn)
+				// 触发重连
+				select {
+				case ws.reconnectChan <- struct{}{}:
+				default:
 				}
 				return
 			}
+			ws.lastMessage.Store(time.Now().UnixNano())
 
 			// 处理消息
 			ws.handleMessage(data)
@@ -293,6 +445,17 @@ func (ws *WSClient) readLoop() {
 	}
 }
 
+// dropConn closes conn and marks the client disconnected (conn 已被替换时不处理)
+func (ws *WSClient) dropConn(conn *websocket.Conn) {
+	ws.connMutex.Lock()
+	defer ws.connMutex.Unlock()
+
+	if ws.conn == conn {
+		conn.Close()
+		ws.conn = nil
+	}
+}
+
 // pingLoop Ping 循环
 func (ws *WSClient) pingLoop() {
 	ticker := time.NewTicker(WSPingInterval)
@@ -307,9 +470,9 @@ func (ws *WSClient) pingLoop() {
 			conn := ws.conn
 			ws.connMutex.RUnlock()
 
+			ws.prunePending(time.Now())
 			if conn != nil {
-				conn.SetWriteDeadline(time.Now().Add(WSWriteTimeout))
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				if err := ws.write(conn, websocket.PingMessage, nil); err != nil {
 					log.Printf("WebSocket Ping 失败: %v", err)
 				}
 			}
@@ -319,45 +482,46 @@ func (ws *WSClient) pingLoop() {
 
 // handleMessage 处理接收到的消息
 func (ws *WSClient) handleMessage(data []byte) {
+	received := time.Now()
 	var msg WSMessage
 	if err := json.Unmarshal(data, &msg); err != nil {
 		log.Printf("解析 WebSocket 消息失败: %v, 数据: %s", err, string(data))
 		return
 	}
 
-	// 处理订阅响应
-	if msg.Result != nil {
-		// 订阅成功，不需要特殊处理
+	// 推送数据: {"stream": "<stream>", "data": {...}}
+	if msg.Stream != "" {
+		ws.dispatch(received, msg.Stream, msg.Data)
 		return
 	}
 
-	// 处理错误
-	if msg.Error != nil {
-		log.Printf("WebSocket 错误: %s (代码: %d)", msg.Error.Message, msg.Error.Code)
+	// 订阅确认或错误（按请求ID匹配）
+	if msg.ID != 0 || msg.Result != nil || msg.Error != nil {
+		ws.handleAck(msg)
 		return
 	}
 
-	// 处理 K线数据推送
-	// Backpack WebSocket 消息格式可能不同，需要根据实际 API 调整
-	// 这里假设消息格式为: {"stream": "symbol@kline_interval", "data": {...}}
-	var streamMsg struct {
-		Stream string          `json:"stream"`
-		Data   json.RawMessage `json:"data"`
-	}
+	log.Printf("未识别的 WebSocket 消息: %s", string(data))
+}
 
-	if err := json.Unmarshal(data, &streamMsg); err == nil && streamMsg.Stream != "" {
-		ws.handlersMutex.RLock()
-		handler, ok := ws.handlers[streamMsg.Stream]
-		ws.handlersMutex.RUnlock()
+// dispatch 将推送交给原始推送回调和 stream 的处理器
+func (ws *WSClient) dispatch(received time.Time, stream string, data json.RawMessage) {
+	ws.notifyMessage(WSRawMessage{Received: received, Stream: stream, Data: data})
 
-		if ok && handler != nil {
-			handler(streamMsg.Data)
-		}
+	ws.handlersMutex.RLock()
+	handler, ok := ws.handlers[stream]
+	ws.handlersMutex.RUnlock()
+
+	if ok && handler != nil {
+		handler(data)
 	}
 }
 
-// IsConnected 检查是否已连接
+// IsConnected 检查是否已连接（回放模式在 Disconnect 之前始终视为已连接）
 func (ws *WSClient) IsConnected() bool {
+	if ws.replay {
+		return ws.ctx.Err() == nil
+	}
 	ws.connMutex.RLock()
 	defer ws.connMutex.RUnlock()
 	return ws.conn != nil
@@ -0,0 +1,168 @@
+package backpack
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"time"
+)
+
+// WSState WebSocket 连接状态
+type WSState int
+
+const (
+	WSStateDisconnected WSState = iota // 已断开
+	WSStateReconnecting                // 正在重连
+	WSStateConnected                   // 已连接
+)
+
+// String 返回连接状态名称
+func (s WSState) String() string {
+	switch s {
+	case WSStateConnected:
+		return "已连接"
+	case WSStateReconnecting:
+		return "重连中"
+	default:
+		return "已断开"
+	}
+}
+
+// WSGap 断线缺口：From 到 Until 之间的推送可能丢失，订阅方需通过 REST 回补
+type WSGap struct {
+	From    time.Time // 断线前最后一次收到消息的时间
+	Until   time.Time // 重连并重新订阅完成的时间
+	Streams []string  // 已重新订阅的 stream
+}
+
+// Duration 返回缺口时长
+func (g WSGap) Duration() time.Duration {
+	return g.Until.Sub(g.From)
+}
+
+// OnStateChange 注册连接状态变化回调（在 WebSocket 协程中调用，不应阻塞）
+func (ws *WSClient) OnStateChange(callback func(WSState)) {
+	ws.callbacksMutex.Lock()
+	defer ws.callbacksMutex.Unlock()
+	ws.stateCallbacks = append(ws.stateCallbacks, callback)
+}
+
+// OnGap 注册断线缺口回调，重连成功后调用（在 WebSocket 协程中调用，不应阻塞）
+func (ws *WSClient) OnGap(callback func(WSGap)) {
+	ws.callbacksMutex.Lock()
+	defer ws.callbacksMutex.Unlock()
+	ws.gapCallbacks = append(ws.gapCallbacks, callback)
+}
+
+// notifyState 通知连接状态变化
+func (ws *WSClient) notifyState(state WSState) {
+	ws.callbacksMutex.RLock()
+	callbacks := slices.Clone(ws.stateCallbacks)
+	ws.callbacksMutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(state)
+	}
+}
+
+// notifyGap 通知断线缺口
+func (ws *WSClient) notifyGap(gap WSGap) {
+	ws.callbacksMutex.RLock()
+	callbacks := slices.Clone(ws.gapCallbacks)
+	ws.callbacksMutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(gap)
+	}
+}
+
+// notifyMessage 通知原始推送（每条推送都会调用，回调列表只追加，读取时无需复制）
+func (ws *WSClient) notifyMessage(msg WSRawMessage) {
+	ws.callbacksMutex.RLock()
+	callbacks := ws.msgCallbacks
+	ws.callbacksMutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(msg)
+	}
+}
+
+// supervise 监控连接，断线后按指数退避重连并重新订阅
+func (ws *WSClient) supervise() {
+	for {
+		select {
+		case <-ws.ctx.Done():
+			return
+		case <-ws.reconnectChan:
+		}
+
+		from := time.Unix(0, ws.lastMessage.Load())
+		ws.notifyState(WSStateDisconnected)
+
+		if !ws.reconnect() {
+			return
+		}
+
+		ws.clearPending() // 旧连接上的请求不会再收到确认
+		streams := ws.resubscribe()
+		gap := WSGap{From: from, Until: time.Now(), Streams: streams}
+		log.Printf("✅ WebSocket 已重连，重新订阅 %d 个 stream（缺口 %s）", len(streams), gap.Duration().Round(time.Second))
+
+		ws.notifyState(WSStateConnected)
+		ws.notifyGap(gap)
+	}
+}
+
+// reconnect 按指数退避重连直到成功；客户端已关闭时返回 false
+func (ws *WSClient) reconnect() bool {
+	backoff := WSReconnectInterval
+	for attempt := 1; ; attempt++ {
+		ws.notifyState(WSStateReconnecting)
+		log.Printf("WebSocket 第 %d 次重连（等待 %s）...", attempt, backoff)
+
+		select {
+		case <-ws.ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+
+		conn, err := ws.dial(ws.ctx)
+		if err == nil {
+			ws.connMutex.Lock()
+			if ws.ctx.Err() != nil {
+				ws.connMutex.Unlock()
+				conn.Close()
+				return false
+			}
+			ws.conn = conn
+			ws.connMutex.Unlock()
+
+			ws.lastMessage.Store(time.Now().UnixNano())
+			go ws.readLoop(conn)
+			return true
+		}
+
+		log.Printf("⚠️  WebSocket 重连失败: %v", err)
+		if backoff *= 2; backoff > WSMaxReconnectInterval {
+			backoff = WSMaxReconnectInterval
+		}
+	}
+}
+
+// resubscribe 重新发送所有已跟踪 stream 的订阅消息，返回 stream 列表
+func (ws *WSClient) resubscribe() []string {
+	ws.subMutex.RLock()
+	streams := make([]string, 0, len(ws.subscribed))
+	for stream := range ws.subscribed {
+		streams = append(streams, stream)
+	}
+	ws.subMutex.RUnlock()
+	sort.Strings(streams)
+
+	for _, stream := range streams {
+		if err := ws.sendRequest("SUBSCRIBE", stream); err != nil {
+			log.Printf("⚠️  重新订阅 %s 失败: %v", stream, err)
+		}
+	}
+	return streams
+}
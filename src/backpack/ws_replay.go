@@ -0,0 +1,30 @@
+package backpack
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// NewReplayWSClient 创建回放用的 WebSocket 客户端
+// 不建立网络连接，订阅只登记处理器；推送由 Deliver 按录制顺序注入，处理器在调用方协程中同步执行
+func NewReplayWSClient() *WSClient {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &WSClient{
+		ctx:           ctx,
+		cancel:        cancel,
+		url:           WSBaseURL,
+		replay:        true,
+		subscribed:    make(map[string]bool),
+		handlers:      make(map[string]func([]byte)),
+		reconnectChan: make(chan struct{}, 1),
+		pending:       make(map[int]wsRequest),
+	}
+}
+
+// Deliver 注入一条推送，如同在 received 时刻从服务端收到（仅回放模式）
+func (ws *WSClient) Deliver(received time.Time, stream string, data json.RawMessage) {
+	ws.lastMessage.Store(received.UnixNano())
+	ws.dispatch(received, stream, data)
+}
@@ -0,0 +1,100 @@
+package backpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// sendRequest 发送订阅/取消订阅请求，并按请求ID跟踪确认
+// 同一请求中包含私有 stream 时整体签名
+func (ws *WSClient) sendRequest(method string, streams ...string) error {
+	if ws.replay {
+		return nil
+	}
+
+	params, err := json.Marshal(streams)
+	if err != nil {
+		return fmt.Errorf("序列化订阅参数失败: %w", err)
+	}
+
+	id := int(ws.nextID.Add(1))
+	ws.pendingMutex.Lock()
+	ws.pending[id] = wsRequest{method: method, streams: streams, sentAt: time.Now()}
+	ws.pendingMutex.Unlock()
+
+	msg := WSMessage{
+		Method: method,
+		Params: params,
+		ID:     id,
+	}
+	// 私有 stream 订阅需签名（重连时重新签名，避免时间窗口过期）
+	if method == "SUBSCRIBE" && slices.ContainsFunc(streams, isPrivateStream) {
+		msg.Signature = ws.signSubscription(ws.signTime())
+	}
+
+	err = ws.sendMessage(msg)
+	if err != nil {
+		ws.pendingMutex.Lock()
+		delete(ws.pending, id)
+		ws.pendingMutex.Unlock()
+	}
+	return err
+}
+
+// handleAck 处理请求确认；订阅被拒绝时不再跟踪对应 stream（重连时不会重新订阅）
+func (ws *WSClient) handleAck(msg WSMessage) {
+	ws.pendingMutex.Lock()
+	req, ok := ws.pending[msg.ID]
+	delete(ws.pending, msg.ID)
+	ws.pendingMutex.Unlock()
+
+	if msg.Error == nil {
+		return
+	}
+	if !ok {
+		log.Printf("WebSocket 错误: %s (代码: %d, 请求ID: %d)", msg.Error.Message, msg.Error.Code, msg.ID)
+		return
+	}
+
+	log.Printf("WebSocket %s %v 失败: %s (代码: %d)", req.method, req.streams, msg.Error.Message, msg.Error.Code)
+	if req.method != "SUBSCRIBE" {
+		return
+	}
+	for _, stream := range req.streams {
+		ws.subMutex.Lock()
+		delete(ws.subscribed, stream)
+		ws.subMutex.Unlock()
+		ws.handlersMutex.Lock()
+		delete(ws.handlers, stream)
+		ws.handlersMutex.Unlock()
+	}
+}
+
+// PendingRequests 返回尚未收到确认的请求数
+func (ws *WSClient) PendingRequests() int {
+	ws.pendingMutex.Lock()
+	defer ws.pendingMutex.Unlock()
+	return len(ws.pending)
+}
+
+// prunePending 丢弃超过 WSAckTimeout 未确认的请求（服务端不一定回复成功确认）
+func (ws *WSClient) prunePending(now time.Time) {
+	ws.pendingMutex.Lock()
+	defer ws.pendingMutex.Unlock()
+
+	for id, req := range ws.pending {
+		if now.Sub(req.sentAt) > WSAckTimeout {
+			delete(ws.pending, id)
+		}
+	}
+}
+
+// clearPending 丢弃所有等待确认的请求（连接已替换）
+func (ws *WSClient) clearPending() {
+	ws.pendingMutex.Lock()
+	defer ws.pendingMutex.Unlock()
+	ws.pending = make(map[int]wsRequest)
+}
@@ -0,0 +1,162 @@
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when Set is called (用于确定性回放)
+// 定时器按到期时间（相同时按创建顺序）依次触发；AfterFunc 的回调在调用 Set 的协程中同步执行
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    int64
+	timers []*fakeTimer // 未到期的定时器
+}
+
+// fakeTimer is a timer, ticker or AfterFunc of a Fake clock
+type fakeTimer struct {
+	clock    *Fake
+	c        chan time.Time
+	fn       func()
+	deadline time.Time
+	period   time.Duration // >0 表示 Ticker
+	seq      int64
+}
+
+// NewFake creates a fake clock set to start
+func NewFake(start time.Time) *Fake {
+	return &Fake{now: start}
+}
+
+// Now returns the current fake time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer creates a timer firing d after the current fake time
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// NewTicker creates a ticker firing every d of fake time
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1), period: d}
+	t.Reset(d)
+	return fakeTicker{t}
+}
+
+// AfterFunc calls f in the goroutine calling Set once d of fake time has passed
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{clock: f, fn: fn}
+	t.Reset(d)
+	return t
+}
+
+// Next returns the deadline of the earliest pending timer
+func (f *Fake) Next() (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.timers) == 0 {
+		return time.Time{}, false
+	}
+	return f.timers[0].deadline, true
+}
+
+// Set moves the clock to t, firing every timer due by then in order (时间不会倒退)
+// 通道已满时丢弃本次触发（与 time.Ticker 相同）
+func (f *Fake) Set(t time.Time) {
+	for {
+		f.mu.Lock()
+		if len(f.timers) == 0 || f.timers[0].deadline.After(t) {
+			if t.After(f.now) {
+				f.now = t
+			}
+			f.mu.Unlock()
+			return
+		}
+
+		timer := f.timers[0]
+		f.timers = f.timers[1:]
+		if timer.deadline.After(f.now) {
+			f.now = timer.deadline
+		}
+		now := f.now
+		if timer.period > 0 {
+			timer.deadline = timer.deadline.Add(timer.period)
+			f.insert(timer)
+		}
+		f.mu.Unlock()
+
+		if timer.fn != nil {
+			timer.fn()
+			continue
+		}
+		select {
+		case timer.c <- now:
+		default:
+		}
+	}
+}
+
+// insert adds a timer keeping the list ordered by deadline, then creation (caller holds the lock)
+func (f *Fake) insert(timer *fakeTimer) {
+	i, _ := slices.BinarySearchFunc(f.timers, timer, func(a, b *fakeTimer) int {
+		if c := a.deadline.Compare(b.deadline); c != 0 {
+			return c
+		}
+		return int(a.seq - b.seq)
+	})
+	f.timers = slices.Insert(f.timers, i, timer)
+}
+
+// remove drops a pending timer; false when it was not pending (caller holds the lock)
+func (f *Fake) remove(timer *fakeTimer) bool {
+	i := slices.Index(f.timers, timer)
+	if i < 0 {
+		return false
+	}
+	f.timers = slices.Delete(f.timers, i, i+1)
+	return true
+}
+
+// fakeTicker adapts a periodic fakeTimer to Ticker
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	active := f.remove(t)
+	f.seq++
+	t.seq = f.seq
+	t.deadline = f.now.Add(d)
+	f.insert(t)
+	return active
+}
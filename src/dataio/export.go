@@ -0,0 +1,133 @@
+package dataio
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/models"
+	"vagues-go/src/parquet"
+	"vagues-go/src/recorder"
+	"vagues-go/src/trading"
+)
+
+// ExportOptions configures an export
+type ExportOptions struct {
+	Format   Format   // 文件格式，为空时按扩展名判断
+	TimeUnit TimeUnit // CSV 时间列的格式：为空时为 RFC3339（UTC），否则为该单位的数值时间戳；Parquet 始终为毫秒时间戳类型
+}
+
+// K线导出列，与导入时的默认列名一致
+var klineColumns = []parquet.Field{
+	{Name: "time", Type: parquet.Timestamp},
+	{Name: "end", Type: parquet.Timestamp},
+	{Name: "open", Type: parquet.Double},
+	{Name: "high", Type: parquet.Double},
+	{Name: "low", Type: parquet.Double},
+	{Name: "close", Type: parquet.Double},
+	{Name: "volume", Type: parquet.Double},
+	{Name: "quote_volume", Type: parquet.Double},
+}
+
+// 平仓订单导出列
+var orderColumns = []parquet.Field{
+	{Name: "id", Type: parquet.String},
+	{Name: "symbol", Type: parquet.String},
+	{Name: "side", Type: parquet.String},
+	{Name: "status", Type: parquet.String},
+	{Name: "entry_time", Type: parquet.Timestamp},
+	{Name: "exit_time", Type: parquet.Timestamp, Optional: true},
+	{Name: "entry_price", Type: parquet.Double},
+	{Name: "exit_price", Type: parquet.Double},
+	{Name: "quantity", Type: parquet.Double},
+	{Name: "stop_loss", Type: parquet.Double},
+	{Name: "take_profit", Type: parquet.Double},
+	{Name: "bars_held", Type: parquet.Int64},
+	{Name: "pnl", Type: parquet.Double},
+	{Name: "pnl_percent", Type: parquet.Double},
+	{Name: "trading_fee", Type: parquet.Double},
+	{Name: "funding_fee", Type: parquet.Double},
+}
+
+// ExportKlines writes K-lines to a CSV or Parquet file
+func ExportKlines(path string, klines []models.KLine, opts ExportOptions) error {
+	rows := make([][]any, len(klines))
+	for i, k := range klines {
+		rows[i] = []any{k.StartTime.UTC(), k.EndTime.UTC(), k.Open, k.High, k.Low, k.Close, k.Volume, k.QuoteVolume}
+	}
+	return export(path, klineColumns, rows, opts)
+}
+
+// ExportOrders writes orders sorted by entry time to a CSV or Parquet file
+// 通常传入已平仓订单（TradingSystem.GetClosedOrders）；未平仓订单的 exit_time 为空
+func ExportOrders(path string, orders []*trading.LocalOrder, opts ExportOptions) error {
+	sorted := append([]*trading.LocalOrder(nil), orders...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].EntryTime.Equal(sorted[j].EntryTime) {
+			return sorted[i].EntryTime.Before(sorted[j].EntryTime)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	rows := make([][]any, len(sorted))
+	for i, o := range sorted {
+		var exitTime any
+		if !o.ExitTime.IsZero() {
+			exitTime = o.ExitTime.UTC()
+		}
+		rows[i] = []any{
+			o.ID, o.Symbol, string(o.OrderType), string(o.Status),
+			o.EntryTime.UTC(), exitTime,
+			o.EntryPrice, o.ExitPrice, o.Quantity, o.StopLoss, o.TakeProfit,
+			int64(o.BarsHeld), o.PnL, o.PnLPercent, o.TradingFee, o.FundingFee,
+		}
+	}
+	return export(path, orderColumns, rows, opts)
+}
+
+// export writes rows to path; 出错时删除写了一半的文件
+func export(path string, columns []parquet.Field, rows [][]any, opts ExportOptions) error {
+	w, err := createTable(path, opts.Format, columns, opts.TimeUnit)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err = w.write(row); err != nil {
+			break
+		}
+	}
+	if closeErr := w.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("导出 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// TradeRecord converts an imported trade to a recorded trade stream message
+// 写入录制目录后可以像实时录制的数据一样回放（接收时间为成交时间）
+func TradeRecord(trade models.Trade) (recorder.Record, error) {
+	data, err := json.Marshal(backpack.WSTradeMessage{
+		EventType:    "trade",
+		EventTime:    trade.Time.UnixMicro(),
+		Symbol:       trade.Symbol,
+		Price:        strconv.FormatFloat(trade.Price, 'f', -1, 64),
+		Quantity:     strconv.FormatFloat(trade.Quantity, 'f', -1, 64),
+		TradeID:      trade.ID,
+		TradeTime:    trade.Time.UnixMicro(),
+		BuyerIsMaker: trade.BuyerIsMaker,
+	})
+	if err != nil {
+		return recorder.Record{}, err
+	}
+	return recorder.Record{
+		Received: trade.Time,
+		Stream:   backpack.TradeStream(trade.Symbol),
+		Data:     data,
+	}, nil
+}
@@ -0,0 +1,409 @@
+package dataio
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"vagues-go/src/models"
+)
+
+// Options configures how a CSV or Parquet file is imported
+type Options struct {
+	Format Format // 文件格式，为空时按扩展名判断
+
+	// Columns 字段 -> 文件中的列名，覆盖默认的列名匹配；值为整数时表示列序号（从 0 开始）
+	// K线字段: time, end, open, high, low, close, volume, quote_volume
+	// 成交字段: id, time, price, quantity, is_buyer_maker, side, symbol
+	Columns map[string]string
+
+	TimeUnit   TimeUnit       // 数值时间戳的单位，默认按数值大小判断
+	TimeLayout string         // 文本时间的 Go 格式，为空时尝试 RFC3339 和常见格式
+	Location   *time.Location // 不带时区的文本时间所在时区，默认 UTC
+
+	Interval time.Duration // K线周期，文件中没有结束时间列时用于计算结束时间；为 0 时按相邻K线推断
+	Symbol   string        // 文件中没有交易对列时使用的交易对
+
+	NoHeader bool // CSV 没有表头（列通过 Columns 中的序号指定）
+	Comma    rune // CSV 分隔符，默认逗号
+}
+
+// field is an importable field and the column names it matches by default
+type field struct {
+	name     string
+	aliases  []string
+	required bool
+}
+
+var klineFields = []field{
+	{"time", []string{"time", "timestamp", "start", "start_time", "open_time", "datetime", "date", "ts"}, true},
+	{"end", []string{"end", "end_time", "close_time"}, false},
+	{"open", []string{"open", "o"}, true},
+	{"high", []string{"high", "h"}, true},
+	{"low", []string{"low", "l"}, true},
+	{"close", []string{"close", "c"}, true},
+	{"volume", []string{"volume", "vol", "v", "base_volume"}, false},
+	{"quote_volume", []string{"quote_volume", "quote_asset_volume", "quote_vol", "turnover"}, false},
+}
+
+var tradeFields = []field{
+	{"id", []string{"id", "trade_id", "agg_trade_id", "tid"}, false},
+	{"time", []string{"time", "timestamp", "trade_time", "transact_time", "datetime", "date", "ts"}, true},
+	{"price", []string{"price", "px", "p"}, true},
+	{"quantity", []string{"quantity", "qty", "size", "amount", "q"}, true},
+	{"is_buyer_maker", []string{"is_buyer_maker", "buyer_is_maker", "m"}, false},
+	{"side", []string{"side", "taker_side"}, false},
+	{"symbol", []string{"symbol"}, false},
+}
+
+// ParseColumns parses a column mapping like "time=open_time,volume=vol"
+func ParseColumns(spec string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, column, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("无效的列映射 %q（格式为 字段=列名）", item)
+		}
+		columns[strings.TrimSpace(name)] = strings.TrimSpace(column)
+	}
+	return columns, nil
+}
+
+// normalize lowercases a column name and drops separators (isBuyerMaker 与 is_buyer_maker 相同)
+func normalize(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// resolveColumns maps each field to a column index (-1 when absent)
+func resolveColumns(header []string, fields []field, overrides map[string]string) (map[string]int, error) {
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.name] = true
+	}
+	for name := range overrides {
+		if !known[name] {
+			return nil, fmt.Errorf("未知的字段 %q", name)
+		}
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := index[normalize(name)]; !ok {
+			index[normalize(name)] = i
+		}
+	}
+
+	columns := make(map[string]int, len(fields))
+	for _, f := range fields {
+		columns[f.name] = -1
+		if column, ok := overrides[f.name]; ok {
+			if n, err := strconv.Atoi(column); err == nil {
+				if n < 0 {
+					return nil, fmt.Errorf("字段 %s 的列序号 %d 无效", f.name, n)
+				}
+				columns[f.name] = n
+				continue
+			}
+			i, ok := index[normalize(column)]
+			if !ok {
+				return nil, fmt.Errorf("字段 %s 对应的列 %q 不存在", f.name, column)
+			}
+			columns[f.name] = i
+			continue
+		}
+		for _, alias := range f.aliases {
+			if i, ok := index[normalize(alias)]; ok {
+				columns[f.name] = i
+				break
+			}
+		}
+		if columns[f.name] < 0 && f.required {
+			if header == nil {
+				return nil, fmt.Errorf("文件没有表头，请用列序号指定字段 %s", f.name)
+			}
+			return nil, fmt.Errorf("找不到字段 %s 对应的列（表头: %s），请指定列映射", f.name, strings.Join(header, ","))
+		}
+	}
+	return columns, nil
+}
+
+// rowReader reads the mapped fields of each row
+type rowReader struct {
+	t       table
+	columns map[string]int
+	times   timeParser
+	row     []string
+	line    int // 数据行号（从 1 开始）
+}
+
+func newRowReader(path string, fields []field, opts Options) (*rowReader, error) {
+	t, err := openTable(path, opts.Format, opts)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := resolveColumns(t.header(), fields, opts.Columns)
+	if err != nil {
+		t.close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	location := opts.Location
+	if location == nil {
+		location = time.UTC
+	}
+	return &rowReader{
+		t:       t,
+		columns: columns,
+		times:   timeParser{unit: opts.TimeUnit, layout: opts.TimeLayout, location: location},
+	}, nil
+}
+
+// next advances to the next row; returns io.EOF after the last row
+func (r *rowReader) next() error {
+	row, err := r.t.next()
+	if err != nil {
+		return err
+	}
+	r.row = row
+	r.line++
+	return nil
+}
+
+// has reports whether the field is mapped to a column
+func (r *rowReader) has(name string) bool {
+	return r.columns[name] >= 0
+}
+
+// value returns the cell of a field; 未映射或超出行长度时为空
+func (r *rowReader) value(name string) string {
+	i := r.columns[name]
+	if i < 0 || i >= len(r.row) {
+		return ""
+	}
+	return strings.TrimSpace(r.row[i])
+}
+
+func (r *rowReader) float(name string) (float64, error) {
+	value := r.value(name)
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, r.errorf("解析 %s=%q 失败", name, value)
+	}
+	return v, nil
+}
+
+// optionalFloat parses a field that may be absent or empty (为 0)
+func (r *rowReader) optionalFloat(name string) (float64, error) {
+	if r.value(name) == "" {
+		return 0, nil
+	}
+	return r.float(name)
+}
+
+func (r *rowReader) time(name string) (time.Time, error) {
+	t, err := r.times.parse(r.value(name))
+	if err != nil {
+		return time.Time{}, r.errorf("解析 %s 失败: %v", name, err)
+	}
+	return t, nil
+}
+
+func (r *rowReader) errorf(format string, args ...any) error {
+	return fmt.Errorf("第 %d 行: %s", r.line, fmt.Sprintf(format, args...))
+}
+
+func (r *rowReader) close() error {
+	return r.t.close()
+}
+
+// ImportKlines reads OHLCV bars from a CSV or Parquet file, sorted by start time
+// 开始时间重复的K线保留最后一根；没有结束时间列时结束时间为开始时间加周期
+func ImportKlines(path string, opts Options) ([]models.KLine, error) {
+	r, err := newRowReader(path, klineFields, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close()
+
+	var klines []models.KLine
+	for {
+		if err := r.next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		kline, err := readKline(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", path, err)
+		}
+		klines = append(klines, kline)
+	}
+
+	klines = sortKlines(klines)
+	if !r.has("end") {
+		interval := opts.Interval
+		if interval <= 0 {
+			if interval = inferInterval(klines); interval <= 0 && len(klines) > 0 {
+				return nil, fmt.Errorf("%s: 无法推断K线周期，请指定周期", path)
+			}
+		}
+		for i := range klines {
+			klines[i].EndTime = klines[i].StartTime.Add(interval)
+		}
+	}
+	return klines, nil
+}
+
+// readKline parses the current row as a K-line
+func readKline(r *rowReader) (models.KLine, error) {
+	var k models.KLine
+	var err error
+	if k.StartTime, err = r.time("time"); err != nil {
+		return k, err
+	}
+	if r.has("end") {
+		if k.EndTime, err = r.time("end"); err != nil {
+			return k, err
+		}
+		// Binance 等数据的结束时间为周期最后一毫秒，换算为下一周期的开始
+		if k.EndTime.Sub(k.StartTime)%time.Second == time.Second-time.Millisecond {
+			k.EndTime = k.EndTime.Add(time.Millisecond)
+		}
+	}
+	if k.Open, err = r.float("open"); err != nil {
+		return k, err
+	}
+	if k.High, err = r.float("high"); err != nil {
+		return k, err
+	}
+	if k.Low, err = r.float("low"); err != nil {
+		return k, err
+	}
+	if k.Close, err = r.float("close"); err != nil {
+		return k, err
+	}
+	if k.Volume, err = r.optionalFloat("volume"); err != nil {
+		return k, err
+	}
+	if k.QuoteVolume, err = r.optionalFloat("quote_volume"); err != nil {
+		return k, err
+	}
+	return k, nil
+}
+
+// sortKlines sorts by start time and keeps the last bar of each start time
+func sortKlines(klines []models.KLine) []models.KLine {
+	sort.SliceStable(klines, func(i, j int) bool {
+		return klines[i].StartTime.Before(klines[j].StartTime)
+	})
+	out := klines[:0]
+	for _, k := range klines {
+		if n := len(out); n > 0 && out[n-1].StartTime.Equal(k.StartTime) {
+			out[n-1] = k
+			continue
+		}
+		out = append(out, k)
+	}
+	return out
+}
+
+// inferInterval returns the smallest spacing between consecutive bars
+func inferInterval(klines []models.KLine) time.Duration {
+	var interval time.Duration
+	for i := 1; i < len(klines); i++ {
+		if d := klines[i].StartTime.Sub(klines[i-1].StartTime); interval == 0 || d < interval {
+			interval = d
+		}
+	}
+	return interval
+}
+
+// ImportTrades reads public trades from a CSV or Parquet file, sorted by time
+// 主动方来自 is_buyer_maker 列或 side 列（buy 表示买方主动）；没有 id 列时按时间顺序从 1 编号
+func ImportTrades(path string, opts Options) ([]models.Trade, error) {
+	r, err := newRowReader(path, tradeFields, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close()
+
+	var trades []models.Trade
+	for {
+		if err := r.next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		trade, err := readTrade(r, opts.Symbol)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", path, err)
+		}
+		trades = append(trades, trade)
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool {
+		if !trades[i].Time.Equal(trades[j].Time) {
+			return trades[i].Time.Before(trades[j].Time)
+		}
+		return trades[i].ID < trades[j].ID
+	})
+	if !r.has("id") {
+		for i := range trades {
+			trades[i].ID = int64(i + 1)
+		}
+	}
+	return trades, nil
+}
+
+// readTrade parses the current row as a trade
+func readTrade(r *rowReader, symbol string) (models.Trade, error) {
+	t := models.Trade{Symbol: symbol}
+	var err error
+	if r.has("id") {
+		if t.ID, err = strconv.ParseInt(r.value("id"), 10, 64); err != nil {
+			return t, r.errorf("解析 id=%q 失败", r.value("id"))
+		}
+	}
+	if r.has("symbol") && r.value("symbol") != "" {
+		t.Symbol = r.value("symbol")
+	}
+	if t.Time, err = r.time("time"); err != nil {
+		return t, err
+	}
+	if t.Price, err = r.float("price"); err != nil {
+		return t, err
+	}
+	if t.Quantity, err = r.float("quantity"); err != nil {
+		return t, err
+	}
+
+	switch {
+	case r.has("is_buyer_maker"):
+		value := r.value("is_buyer_maker")
+		if t.BuyerIsMaker, err = strconv.ParseBool(value); err != nil {
+			return t, r.errorf("解析 is_buyer_maker=%q 失败", value)
+		}
+	case r.has("side"):
+		switch side := strings.ToLower(r.value("side")); side {
+		case "buy", "b", "bid":
+			t.BuyerIsMaker = false
+		case "sell", "s", "ask":
+			t.BuyerIsMaker = true
+		default:
+			return t, r.errorf("无效的 side=%q（buy 或 sell）", side)
+		}
+	}
+	return t, nil
+}
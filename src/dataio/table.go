@@ -0,0 +1,260 @@
+package dataio
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"vagues-go/src/parquet"
+)
+
+// Format is a file format for import and export
+type Format string
+
+const (
+	CSV     Format = "csv"     // 逗号分隔文本，.csv.gz 自动解压
+	Parquet Format = "parquet" // 平面 schema 的 Parquet 文件
+)
+
+// ParseFormat parses a format name; empty means detect from the file extension
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "":
+		return "", nil
+	case CSV:
+		return CSV, nil
+	case Parquet, "pq":
+		return Parquet, nil
+	}
+	return "", fmt.Errorf("不支持的文件格式 %q（csv 或 parquet）", name)
+}
+
+// formatOf returns format, or the format implied by the extension of path
+func formatOf(path string, format Format) (Format, error) {
+	if format != "" {
+		return format, nil
+	}
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(name, ".csv"), strings.HasSuffix(name, ".csv.gz"):
+		return CSV, nil
+	case strings.HasSuffix(name, ".parquet"), strings.HasSuffix(name, ".pq"):
+		return Parquet, nil
+	}
+	return "", fmt.Errorf("无法根据扩展名判断 %s 的格式，请指定 csv 或 parquet", path)
+}
+
+// table is a source of rows of string values
+type table interface {
+	header() []string // 列名；CSV 无表头时为 nil
+	next() ([]string, error)
+	close() error
+}
+
+// openTable opens a CSV or Parquet file for reading
+func openTable(path string, format Format, opts Options) (table, error) {
+	format, err := formatOf(path, format)
+	if err != nil {
+		return nil, err
+	}
+	if format == Parquet {
+		r, err := parquet.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		return &parquetTable{r: r}, nil
+	}
+	return openCSV(path, opts)
+}
+
+// csvTable reads a CSV file
+type csvTable struct {
+	file  *os.File
+	gz    *gzip.Reader
+	r     *csv.Reader
+	names []string
+}
+
+func openCSV(path string, opts Options) (*csvTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &csvTable{file: file}
+	var src io.Reader = file
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		if t.gz, err = gzip.NewReader(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		src = t.gz
+	}
+	t.r = csv.NewReader(src)
+	t.r.FieldsPerRecord = -1
+	t.r.TrimLeadingSpace = true
+	if opts.Comma != 0 {
+		t.r.Comma = opts.Comma
+	}
+	if !opts.NoHeader {
+		names, err := t.r.Read()
+		if err != nil {
+			t.close()
+			return nil, fmt.Errorf("读取 %s 表头失败: %w", path, err)
+		}
+		names[0] = strings.TrimPrefix(names[0], "\ufeff") // Excel 导出的 UTF-8 BOM
+		t.names = names
+	}
+	return t, nil
+}
+
+func (t *csvTable) header() []string {
+	return t.names
+}
+
+func (t *csvTable) next() ([]string, error) {
+	return t.r.Read()
+}
+
+func (t *csvTable) close() error {
+	if t.gz != nil {
+		t.gz.Close()
+	}
+	return t.file.Close()
+}
+
+// parquetTable reads a Parquet file
+type parquetTable struct {
+	r *parquet.Reader
+}
+
+func (t *parquetTable) header() []string {
+	var names []string
+	for _, c := range t.r.Columns() {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func (t *parquetTable) next() ([]string, error) {
+	return t.r.Read()
+}
+
+func (t *parquetTable) close() error {
+	return t.r.Close()
+}
+
+// tableWriter writes typed rows (int64, float64, string, bool, time.Time 或 nil)
+type tableWriter interface {
+	write(row []any) error
+	close() error
+}
+
+// createTable creates a CSV or Parquet file with the given columns
+func createTable(path string, format Format, fields []parquet.Field, timeUnit TimeUnit) (tableWriter, error) {
+	format, err := formatOf(path, format)
+	if err != nil {
+		return nil, err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if format == Parquet {
+		w, err := parquet.NewWriter(file, fields)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &parquetTableWriter{file: file, w: w}, nil
+	}
+
+	t := &csvTableWriter{file: file, timeUnit: timeUnit}
+	var dst io.Writer = file
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		t.gz = gzip.NewWriter(file)
+		dst = t.gz
+	}
+	t.w = csv.NewWriter(dst)
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.Name
+	}
+	if err := t.w.Write(names); err != nil {
+		t.close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// csvTableWriter writes a CSV file; 时间为 RFC3339（UTC），指定单位时为数值时间戳
+type csvTableWriter struct {
+	file     *os.File
+	gz       *gzip.Writer
+	w        *csv.Writer
+	timeUnit TimeUnit
+	record   []string
+}
+
+func (t *csvTableWriter) write(row []any) error {
+	t.record = t.record[:0]
+	for _, value := range row {
+		var s string
+		switch v := value.(type) {
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			s = v
+		case bool:
+			s = strconv.FormatBool(v)
+		case time.Time:
+			s = formatTime(v, t.timeUnit)
+		}
+		t.record = append(t.record, s)
+	}
+	return t.w.Write(t.record)
+}
+
+func (t *csvTableWriter) close() error {
+	t.w.Flush()
+	err := t.w.Error()
+	if t.gz != nil {
+		if gzErr := t.gz.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if closeErr := t.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// parquetTableWriter writes a Parquet file
+type parquetTableWriter struct {
+	file *os.File
+	w    *parquet.Writer
+}
+
+func (t *parquetTableWriter) write(row []any) error {
+	return t.w.Write(row)
+}
+
+func (t *parquetTableWriter) close() error {
+	err := t.w.Close()
+	if closeErr := t.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
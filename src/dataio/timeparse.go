@@ -0,0 +1,135 @@
+package dataio
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeUnit is the unit of numeric timestamps
+type TimeUnit string
+
+const (
+	UnitAuto    TimeUnit = ""   // 按数值大小判断
+	UnitSeconds TimeUnit = "s"  // 秒
+	UnitMillis  TimeUnit = "ms" // 毫秒
+	UnitMicros  TimeUnit = "us" // 微秒
+	UnitNanos   TimeUnit = "ns" // 纳秒
+)
+
+// ParseTimeUnit parses s, ms, us, ns or auto
+func ParseTimeUnit(name string) (TimeUnit, error) {
+	switch unit := TimeUnit(strings.ToLower(name)); unit {
+	case "auto":
+		return UnitAuto, nil
+	case UnitAuto, UnitSeconds, UnitMillis, UnitMicros, UnitNanos:
+		return unit, nil
+	case "µs":
+		return UnitMicros, nil
+	}
+	return "", fmt.Errorf("无效的时间单位 %q（s、ms、us、ns 或 auto）", name)
+}
+
+// perSecond returns how many units make one second
+func (u TimeUnit) perSecond() float64 {
+	switch u {
+	case UnitMillis:
+		return 1e3
+	case UnitMicros:
+		return 1e6
+	case UnitNanos:
+		return 1e9
+	}
+	return 1
+}
+
+// autoUnit guesses the unit of an epoch timestamp from its magnitude
+// 1e11 秒约为公元5138年，1e11 毫秒约为1973年，可以区分 1973 年之后的时间
+func autoUnit(v float64) TimeUnit {
+	switch v = math.Abs(v); {
+	case v < 1e11:
+		return UnitSeconds
+	case v < 1e14:
+		return UnitMillis
+	case v < 1e17:
+		return UnitMicros
+	}
+	return UnitNanos
+}
+
+// 未指定格式时依次尝试的文本时间格式；不带时区的按 Options.Location 解析
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02",
+}
+
+// timeParser parses timestamp cells
+type timeParser struct {
+	unit     TimeUnit
+	layout   string
+	location *time.Location
+}
+
+// parse parses an epoch number or a text time
+func (p timeParser) parse(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("时间为空")
+	}
+	if p.layout != "" {
+		t, err := time.ParseInLocation(p.layout, value, p.location)
+		return t.UTC(), err
+	}
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return p.epoch(value, number), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, p.location); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q", value)
+}
+
+// epoch converts an epoch timestamp; 整数按整数换算以免损失纳秒精度
+func (p timeParser) epoch(value string, number float64) time.Time {
+	unit := p.unit
+	if unit == UnitAuto {
+		unit = autoUnit(number)
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		switch unit {
+		case UnitMillis:
+			return time.UnixMilli(n).UTC()
+		case UnitMicros:
+			return time.UnixMicro(n).UTC()
+		case UnitNanos:
+			return time.Unix(0, n).UTC()
+		}
+		return time.Unix(n, 0).UTC()
+	}
+	seconds := number / unit.perSecond()
+	whole := math.Floor(seconds)
+	return time.Unix(int64(whole), int64(math.Round((seconds-whole)*1e9))).UTC()
+}
+
+// formatTime formats a time for CSV export: RFC3339（UTC），或指定单位的数值时间戳
+func formatTime(t time.Time, unit TimeUnit) string {
+	switch unit {
+	case UnitSeconds:
+		return strconv.FormatInt(t.Unix(), 10)
+	case UnitMillis:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case UnitMicros:
+		return strconv.FormatInt(t.UnixMicro(), 10)
+	case UnitNanos:
+		return strconv.FormatInt(t.UnixNano(), 10)
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,249 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/marketdata"
+)
+
+// KlineFetcher fetches K-lines over REST (由 backpack.Client 实现)
+type KlineFetcher interface {
+	GetKlines(ctx context.Context, symbol, interval string, startTime, endTime *int64, limit *int) ([]backpack.KlineResponse, error)
+}
+
+// 下载参数
+const (
+	DefaultWindowBars = 1000                   // 每个请求窗口的K线数量
+	DefaultRateLimit  = 200 * time.Millisecond // 相邻请求的最小间隔
+	DefaultMaxRetries = 5                      // 单个窗口的最大重试次数
+	retryBaseDelay    = time.Second            // 重试等待（每次翻倍）
+	retryMaxDelay     = 30 * time.Second
+	flushWindows      = 20 // 每下载多少个窗口写一次文件（中断时最多重新下载这么多窗口）
+)
+
+// DownloadStats summarizes a download
+type DownloadStats struct {
+	Requests   int // 请求次数（含重试）
+	Retries    int // 重试次数
+	Windows    int // 完成的时间窗口数
+	Bars       int // 写入的K线数（含替换的重复K线）
+	Duplicates int // 与本地已有数据重复的K线数
+	Skipped    int // 丢弃的K线数（不在窗口内或尚未收盘）
+}
+
+// Downloader fetches long K-line histories window by window into local files
+// 按 startTime/endTime 分页请求，限制请求频率并在失败时退避重试；
+// 重新运行时从本地最后一根K线之后继续（早于本地数据的部分单独补齐），写入 Store 时按开始时间去重
+type Downloader struct {
+	fetcher    KlineFetcher
+	store      *Store
+	windowBars int
+	rateLimit  time.Duration
+	maxRetries int
+	last       time.Time // 上次请求时间
+}
+
+// NewDownloader creates a downloader writing to store
+func NewDownloader(fetcher KlineFetcher, store *Store) *Downloader {
+	return &Downloader{
+		fetcher:    fetcher,
+		store:      store,
+		windowBars: DefaultWindowBars,
+		rateLimit:  DefaultRateLimit,
+		maxRetries: DefaultMaxRetries,
+	}
+}
+
+// SetWindowBars sets how many bars each request covers
+func (d *Downloader) SetWindowBars(n int) {
+	if n > 0 {
+		d.windowBars = n
+	}
+}
+
+// SetRateLimit sets the minimum interval between requests
+func (d *Downloader) SetRateLimit(interval time.Duration) {
+	if interval >= 0 {
+		d.rateLimit = interval
+	}
+}
+
+// SetMaxRetries sets how many times a failed window is retried
+func (d *Downloader) SetMaxRetries(n int) {
+	if n >= 0 {
+		d.maxRetries = n
+	}
+}
+
+// Download fetches the closed bars of symbol/interval starting within [from, to) (to 为零时到当前时间)
+// 只支持交易所原生周期；其他周期请下载可整除的原生周期后重采样
+func (d *Downloader) Download(ctx context.Context, symbol, interval string, from, to time.Time) (DownloadStats, error) {
+	var stats DownloadStats
+
+	iv, err := marketdata.ParseInterval(interval)
+	if err != nil {
+		return stats, err
+	}
+	if !iv.IsExchangeInterval() {
+		return stats, fmt.Errorf("%s 不是交易所原生周期，请下载 %s 后重采样", interval, iv.SourceInterval())
+	}
+	now := time.Now()
+	if to.IsZero() || to.After(now) {
+		to = now
+	}
+	from = iv.Floor(from)
+	if !from.Before(to) {
+		return stats, fmt.Errorf("开始时间 %s 不早于结束时间 %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	ranges, err := d.missingRanges(symbol, iv, from, to)
+	if err != nil {
+		return stats, err
+	}
+	for _, r := range ranges {
+		log.Printf("下载 %s %s K线: %s 至 %s", symbol, interval, r[0].Format(time.RFC3339), r[1].Format(time.RFC3339))
+		if err := d.downloadRange(ctx, symbol, iv, r[0], r[1], &stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// missingRanges returns the parts of [from, to) to download given the bars already stored
+// 本地已有数据时跳过 [首根, 末根] 之间的部分（其中的缺口由修复命令处理），只下载之前和之后的部分
+func (d *Downloader) missingRanges(symbol string, iv marketdata.Interval, from, to time.Time) ([][2]time.Time, error) {
+	first, ok, err := d.store.firstBar(symbol, iv.String())
+	if err != nil || !ok {
+		return [][2]time.Time{{from, to}}, err
+	}
+	last, _, err := d.store.lastBar(symbol, iv.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges [][2]time.Time
+	if from.Before(first.Start) {
+		ranges = append(ranges, [2]time.Time{from, minTime(first.Start, to)})
+	}
+	if resume := maxTime(from, iv.Next(last.Start)); resume.Before(to) {
+		ranges = append(ranges, [2]time.Time{resume, to})
+	}
+	return ranges, nil
+}
+
+// downloadRange fetches [from, to) window by window, writing to the store as it goes
+func (d *Downloader) downloadRange(ctx context.Context, symbol string, iv marketdata.Interval, from, to time.Time, stats *DownloadStats) error {
+	var pending []Bar
+	flush := func() error {
+		duplicates, err := d.store.Append(symbol, iv.String(), pending)
+		if err != nil {
+			return err
+		}
+		stats.Bars += len(pending)
+		stats.Duplicates += duplicates
+		pending = nil
+		return nil
+	}
+
+	windows := 0
+	for start := from; start.Before(to); {
+		end := start
+		for i := 0; i < d.windowBars && end.Before(to); i++ {
+			end = iv.Next(end)
+		}
+		end = minTime(end, to)
+
+		resp, err := d.fetch(ctx, symbol, iv.String(), start, end, stats)
+		if err != nil {
+			// 保存已下载的部分，重新运行时从这里继续
+			if flushErr := flush(); flushErr != nil {
+				log.Printf("⚠️  保存已下载的K线失败: %v", flushErr)
+			}
+			return fmt.Errorf("下载 %s %s 至 %s 失败: %w", symbol, start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+		}
+
+		now := time.Now()
+		for _, r := range resp {
+			bar, err := BarFromREST(r)
+			if err != nil {
+				return err
+			}
+			if bar.Start.Before(start) || !bar.Start.Before(end) || bar.End.After(now) {
+				stats.Skipped++
+				continue
+			}
+			pending = append(pending, bar)
+		}
+		stats.Windows++
+
+		if windows++; windows%flushWindows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			log.Printf("已下载 %s %s K线至 %s (%d 根)", symbol, iv, end.Format(time.RFC3339), stats.Bars)
+		}
+		start = end
+	}
+	return flush()
+}
+
+// fetch requests one window, waiting for the rate limit and retrying with backoff on failure
+func (d *Downloader) fetch(ctx context.Context, symbol, interval string, start, end time.Time, stats *DownloadStats) ([]backpack.KlineResponse, error) {
+	startTime, endTime, limit := start.Unix(), end.Unix(), d.windowBars
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, time.Until(d.last.Add(d.rateLimit))); err != nil {
+			return nil, err
+		}
+		d.last = time.Now()
+		stats.Requests++
+
+		resp, err := d.fetcher.GetKlines(ctx, symbol, interval, &startTime, &endTime, &limit)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= d.maxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		stats.Retries++
+		log.Printf("⚠️  获取 %s K线失败: %v，%v 后重试 (%d/%d)", symbol, err, delay, attempt+1, d.maxRetries)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// sleep waits for d or until ctx is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
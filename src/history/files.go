@@ -0,0 +1,236 @@
+package history
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/marketdata"
+	"vagues-go/src/models"
+)
+
+// 本地K线文件布局：<dir>/<symbol>/<interval>/<YYYY-MM>.csv，按K线开始时间（UTC）的月份分区
+// 每个文件带表头、按开始时间升序且无重复；数值保留交易所返回的原始字符串
+const (
+	monthFormat = "2006-01"
+	fileSuffix  = ".csv"
+	timeLayout  = time.RFC3339
+)
+
+// header CSV 表头
+var header = []string{"start", "end", "open", "high", "low", "close", "volume", "quote_volume", "trades"}
+
+// Bar is a stored K-line
+type Bar struct {
+	Start       time.Time
+	End         time.Time
+	Open        string
+	High        string
+	Low         string
+	Close       string
+	Volume      string
+	QuoteVolume string
+	Trades      string
+}
+
+// BarFromREST converts a REST K-line to a Bar
+func BarFromREST(resp backpack.KlineResponse) (Bar, error) {
+	kline, err := marketdata.KLineFromREST(resp)
+	if err != nil {
+		return Bar{}, err
+	}
+	return Bar{
+		Start:       kline.StartTime,
+		End:         kline.EndTime,
+		Open:        resp.Open,
+		High:        resp.High,
+		Low:         resp.Low,
+		Close:       resp.Close,
+		Volume:      resp.Volume,
+		QuoteVolume: resp.QuoteVolume,
+		Trades:      resp.Trades,
+	}, nil
+}
+
+// BarFromKLine converts an imported models.KLine to a Bar (成交笔数未知时为空)
+func BarFromKLine(kline models.KLine) Bar {
+	return Bar{
+		Start:       kline.StartTime.UTC(),
+		End:         kline.EndTime.UTC(),
+		Open:        formatFloat(kline.Open),
+		High:        formatFloat(kline.High),
+		Low:         formatFloat(kline.Low),
+		Close:       formatFloat(kline.Close),
+		Volume:      formatFloat(kline.Volume),
+		QuoteVolume: formatFloat(kline.QuoteVolume),
+	}
+}
+
+// formatFloat formats a value with the fewest digits that parse back to it
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// KLine converts the bar to models.KLine
+func (b Bar) KLine() (models.KLine, error) {
+	return marketdata.KLineFromREST(backpack.KlineResponse{
+		Start:       b.Start.Format(timeLayout),
+		End:         b.End.Format(timeLayout),
+		Open:        b.Open,
+		High:        b.High,
+		Low:         b.Low,
+		Close:       b.Close,
+		Volume:      b.Volume,
+		QuoteVolume: b.QuoteVolume,
+		Trades:      b.Trades,
+	})
+}
+
+// seriesDir returns the directory holding the files of symbol/interval
+func seriesDir(dir, symbol, interval string) string {
+	return filepath.Join(dir, symbol, interval)
+}
+
+// monthPath returns the file holding the bars of the month containing t
+func monthPath(dir, symbol, interval string, t time.Time) string {
+	return filepath.Join(seriesDir(dir, symbol, interval), t.UTC().Format(monthFormat)+fileSuffix)
+}
+
+// readMonth reads a month file; a missing file has no bars
+func readMonth(path string) ([]Bar, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = len(header)
+	var bars []Bar
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return bars, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if line == 1 && record[0] == header[0] {
+			continue
+		}
+
+		start, err1 := time.Parse(timeLayout, record[0])
+		end, err2 := time.Parse(timeLayout, record[1])
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行: %w", path, line, err)
+		}
+		bars = append(bars, Bar{
+			Start:       start.UTC(),
+			End:         end.UTC(),
+			Open:        record[2],
+			High:        record[3],
+			Low:         record[4],
+			Close:       record[5],
+			Volume:      record[6],
+			QuoteVolume: record[7],
+			Trades:      record[8],
+		})
+	}
+}
+
+// writeMonth replaces a month file with bars (先写临时文件再重命名，中断时不会留下半个文件)
+func writeMonth(path string, bars []Bar) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := csv.NewWriter(tmp)
+	writer.Write(header)
+	for _, bar := range bars {
+		writer.Write(bar.record())
+	}
+	writer.Flush()
+	if err := errors.Join(writer.Error(), tmp.Close()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// appendMonth appends bars after the last row of a month file (调用方保证 bars 晚于文件中的所有K线)
+func appendMonth(path string, bars []Bar) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 {
+		writer.Write(header)
+	}
+	for _, bar := range bars {
+		writer.Write(bar.record())
+	}
+	writer.Flush()
+	return errors.Join(writer.Error(), file.Close())
+}
+
+// record returns the CSV row of the bar
+func (b Bar) record() []string {
+	return []string{
+		b.Start.UTC().Format(timeLayout),
+		b.End.UTC().Format(timeLayout),
+		b.Open,
+		b.High,
+		b.Low,
+		b.Close,
+		b.Volume,
+		b.QuoteVolume,
+		b.Trades,
+	}
+}
+
+// mergeBars merges added into existing by start time; added replaces bars with the same start
+// 返回合并结果和被替换的重复K线数量
+func mergeBars(existing, added []Bar) ([]Bar, int) {
+	byStart := make(map[int64]Bar, len(existing)+len(added))
+	for _, bar := range existing {
+		byStart[bar.Start.Unix()] = bar
+	}
+	duplicates := 0
+	for _, bar := range added {
+		if _, ok := byStart[bar.Start.Unix()]; ok {
+			duplicates++
+		}
+		byStart[bar.Start.Unix()] = bar
+	}
+
+	merged := make([]Bar, 0, len(byStart))
+	for _, bar := range byStart {
+		merged = append(merged, bar)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Start.Before(merged[j].Start) })
+	return merged, duplicates
+}
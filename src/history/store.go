@@ -0,0 +1,448 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vagues-go/src/marketdata"
+	"vagues-go/src/models"
+)
+
+// Store is a file-based K-line store, one CSV partition per symbol/interval/month
+// 追加晚于已有数据的K线时直接写入文件末尾，否则按开始时间合并去重后重写所在月份；可在多个协程中使用
+type Store struct {
+	dir  string
+	mu   sync.Mutex
+	last map[string]time.Time // symbol/interval -> 最新K线开始时间（缓存）
+}
+
+// NewStore creates a store rooted at dir
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, last: make(map[string]time.Time)}
+}
+
+// Dir returns the root directory of the store
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// normalizeInterval returns the canonical name of interval (如 60m -> 1h、1M -> 1month)，同一周期只对应一个目录
+// 分钟/小时/天都按 Unix 纪元对齐，整倍数的写法等价；无法解析的周期原样返回
+func normalizeInterval(interval string) string {
+	iv, err := marketdata.ParseInterval(interval)
+	if err != nil {
+		return interval
+	}
+	if iv.Unit == marketdata.UnitMinute && iv.Count%60 == 0 {
+		iv = marketdata.Interval{Count: iv.Count / 60, Unit: marketdata.UnitHour}
+	}
+	if iv.Unit == marketdata.UnitHour && iv.Count%24 == 0 {
+		iv = marketdata.Interval{Count: iv.Count / 24, Unit: marketdata.UnitDay}
+	}
+	return iv.String()
+}
+
+// Months returns the months (YYYY-MM) stored for symbol/interval in ascending order
+func (s *Store) Months(symbol, interval string) ([]string, error) {
+	interval = normalizeInterval(interval)
+	paths, err := filepath.Glob(filepath.Join(seriesDir(s.dir, symbol, interval), "*"+fileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	months := make([]string, 0, len(paths))
+	for _, path := range paths {
+		month := strings.TrimSuffix(filepath.Base(path), fileSuffix)
+		if _, err := time.Parse(monthFormat, month); err == nil {
+			months = append(months, month)
+		}
+	}
+	sort.Strings(months)
+	return months, nil
+}
+
+// readMonthOf reads the stored bars of one month
+func (s *Store) readMonthOf(symbol, interval, month string) ([]Bar, error) {
+	return readMonth(filepath.Join(seriesDir(s.dir, symbol, interval), month+fileSuffix))
+}
+
+// Append stores bars of symbol/interval, replacing stored bars with the same start
+// 返回被替换的重复K线数量
+func (s *Store) Append(symbol, interval string, bars []Bar) (int, error) {
+	interval = normalizeInterval(interval)
+	if len(bars) == 0 {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := symbol + "/" + interval
+	last, ok := s.last[key]
+	if !ok {
+		bar, found, err := s.lastBar(symbol, interval)
+		if err != nil {
+			return 0, err
+		}
+		if found {
+			last = bar.Start
+		}
+	}
+
+	sorted := append([]Bar(nil), bars...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	duplicates := 0
+	for len(sorted) > 0 {
+		month := sorted[0].Start.UTC().Format(monthFormat)
+		n := 0
+		for n < len(sorted) && sorted[n].Start.UTC().Format(monthFormat) == month {
+			n++
+		}
+		group := sorted[:n]
+		sorted = sorted[n:]
+		path := monthPath(s.dir, symbol, interval, group[0].Start)
+
+		if group[0].Start.After(last) && strictlyIncreasing(group) {
+			if err := appendMonth(path, group); err != nil {
+				return duplicates, fmt.Errorf("写入 %s 失败: %w", path, err)
+			}
+		} else {
+			existing, err := readMonth(path)
+			if err != nil {
+				return duplicates, err
+			}
+			merged, replaced := mergeBars(existing, group)
+			if err := writeMonth(path, merged); err != nil {
+				return duplicates, fmt.Errorf("写入 %s 失败: %w", path, err)
+			}
+			duplicates += replaced
+		}
+		if end := group[len(group)-1].Start; end.After(last) {
+			last = end
+		}
+	}
+	s.last[key] = last
+	return duplicates, nil
+}
+
+// strictlyIncreasing reports whether the (sorted) bars have no repeated start
+func strictlyIncreasing(bars []Bar) bool {
+	for i := 1; i < len(bars); i++ {
+		if !bars[i].Start.After(bars[i-1].Start) {
+			return false
+		}
+	}
+	return true
+}
+
+// Range returns the stored bars of symbol/interval starting within [from, to), sorted and without duplicates
+// from/to 为零时不限制
+func (s *Store) Range(symbol, interval string, from, to time.Time) ([]Bar, error) {
+	interval = normalizeInterval(interval)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	months, err := s.Months(symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	var bars []Bar
+	for _, month := range months {
+		if !from.IsZero() && month < from.UTC().Format(monthFormat) {
+			continue
+		}
+		if !to.IsZero() && month > to.UTC().Format(monthFormat) {
+			break
+		}
+		monthBars, err := s.readMonthOf(symbol, interval, month)
+		if err != nil {
+			return nil, err
+		}
+		for _, bar := range monthBars {
+			if (from.IsZero() || !bar.Start.Before(from)) && (to.IsZero() || bar.Start.Before(to)) {
+				bars = append(bars, bar)
+			}
+		}
+	}
+	bars, _ = mergeBars(nil, bars)
+	return bars, nil
+}
+
+// Latest returns the last n stored bars of symbol/interval (用于指标预热)
+func (s *Store) Latest(symbol, interval string, n int) ([]Bar, error) {
+	interval = normalizeInterval(interval)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	months, err := s.Months(symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	var bars []Bar
+	for i := len(months) - 1; i >= 0 && len(bars) < n; i-- {
+		monthBars, err := s.readMonthOf(symbol, interval, months[i])
+		if err != nil {
+			return nil, err
+		}
+		bars, _ = mergeBars(monthBars, bars)
+	}
+	if len(bars) > n {
+		bars = bars[len(bars)-n:]
+	}
+	return bars, nil
+}
+
+// firstBar returns the earliest stored bar of symbol/interval
+func (s *Store) firstBar(symbol, interval string) (Bar, bool, error) {
+	months, err := s.Months(symbol, interval)
+	if err != nil {
+		return Bar{}, false, err
+	}
+	for _, month := range months {
+		bars, err := s.readMonthOf(symbol, interval, month)
+		if err != nil {
+			return Bar{}, false, err
+		}
+		if bars, _ = mergeBars(nil, bars); len(bars) > 0 {
+			return bars[0], true, nil
+		}
+	}
+	return Bar{}, false, nil
+}
+
+// lastBar returns the latest stored bar of symbol/interval
+func (s *Store) lastBar(symbol, interval string) (Bar, bool, error) {
+	months, err := s.Months(symbol, interval)
+	if err != nil {
+		return Bar{}, false, err
+	}
+	for i := len(months) - 1; i >= 0; i-- {
+		bars, err := s.readMonthOf(symbol, interval, months[i])
+		if err != nil {
+			return Bar{}, false, err
+		}
+		if bars, _ = mergeBars(nil, bars); len(bars) > 0 {
+			return bars[len(bars)-1], true, nil
+		}
+	}
+	return Bar{}, false, nil
+}
+
+// KLines converts stored bars to models.KLine
+func KLines(bars []Bar) ([]models.KLine, error) {
+	klines := make([]models.KLine, len(bars))
+	for i, bar := range bars {
+		kline, err := bar.KLine()
+		if err != nil {
+			return nil, err
+		}
+		klines[i] = kline
+	}
+	return klines, nil
+}
+
+// Gap is a run of missing bars starting within [From, To)
+type Gap struct {
+	From    time.Time
+	To      time.Time
+	Missing int // 缺失的K线数量
+}
+
+// Report is the result of checking a stored series
+type Report struct {
+	Symbol     string
+	Interval   string
+	Bars       int         // 文件中的K线行数
+	First      time.Time   // 最早K线
+	Last       time.Time   // 最新K线
+	Gaps       []Gap       // 缺失的K线
+	Duplicates []time.Time // 重复出现的开始时间
+	Unordered  int         // 未按开始时间升序的行数
+	Misaligned []time.Time // 未对齐周期边界的开始时间
+}
+
+// Missing returns the total number of missing bars
+func (r Report) Missing() int {
+	total := 0
+	for _, gap := range r.Gaps {
+		total += gap.Missing
+	}
+	return total
+}
+
+// OK reports whether the series has no gaps, duplicates, unordered or misaligned bars
+func (r Report) OK() bool {
+	return len(r.Gaps) == 0 && len(r.Duplicates) == 0 && r.Unordered == 0 && len(r.Misaligned) == 0
+}
+
+// Check scans the stored bars of symbol/interval within [from, to) for missing bars, duplicate and misaligned timestamps
+// from/to 为零时从最早的K线检查到最新的K线；指定时范围两端的缺失也计入
+// 注意：没有成交的周期交易所可能不返回K线，修复后仍缺失的部分需结合行情判断
+func (s *Store) Check(symbol, interval string, from, to time.Time) (Report, error) {
+	interval = normalizeInterval(interval)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := Report{Symbol: symbol, Interval: interval}
+	iv, err := marketdata.ParseInterval(interval)
+	if err != nil {
+		return report, err
+	}
+	months, err := s.Months(symbol, interval)
+	if err != nil {
+		return report, err
+	}
+
+	seen := make(map[int64]bool)
+	var starts []time.Time
+	var previous time.Time
+	for _, month := range months {
+		if (!from.IsZero() && month < from.UTC().Format(monthFormat)) || (!to.IsZero() && month > to.UTC().Format(monthFormat)) {
+			continue
+		}
+		bars, err := s.readMonthOf(symbol, interval, month)
+		if err != nil {
+			return report, err
+		}
+		for _, bar := range bars {
+			if (!from.IsZero() && bar.Start.Before(from)) || (!to.IsZero() && !bar.Start.Before(to)) {
+				continue
+			}
+			report.Bars++
+			if !previous.IsZero() && bar.Start.Before(previous) {
+				report.Unordered++
+			}
+			previous = bar.Start
+			if !iv.Floor(bar.Start).Equal(bar.Start) {
+				report.Misaligned = append(report.Misaligned, bar.Start)
+			}
+			if seen[bar.Start.Unix()] {
+				report.Duplicates = append(report.Duplicates, bar.Start)
+				continue
+			}
+			seen[bar.Start.Unix()] = true
+			starts = append(starts, bar.Start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	if len(starts) > 0 {
+		report.First, report.Last = starts[0], starts[len(starts)-1]
+	}
+	expected := iv.Floor(from)
+	if expected.Before(from) {
+		expected = iv.Next(expected)
+	}
+	if from.IsZero() {
+		if len(starts) == 0 {
+			return report, nil
+		}
+		expected = starts[0]
+	}
+	for _, start := range starts {
+		if start.Before(expected) {
+			continue // 未对齐的K线
+		}
+		if gap := gapBetween(iv, expected, start); gap.Missing > 0 {
+			report.Gaps = append(report.Gaps, gap)
+		}
+		expected = iv.Next(iv.Floor(start))
+	}
+	if !to.IsZero() {
+		if gap := gapBetween(iv, expected, to); gap.Missing > 0 {
+			report.Gaps = append(report.Gaps, gap)
+		}
+	}
+	return report, nil
+}
+
+// gapBetween returns the bars of iv starting within [from, to)
+func gapBetween(iv marketdata.Interval, from, to time.Time) Gap {
+	gap := Gap{From: from, To: to}
+	for t := from; t.Before(to); t = iv.Next(t) {
+		gap.Missing++
+	}
+	return gap
+}
+
+// RepairStats summarizes a repair
+type RepairStats struct {
+	Rewritten int           // 去重/重排后重写的月份文件数
+	Download  DownloadStats // 重新下载缺口的统计
+	Before    Report        // 修复前的检查结果
+	After     Report        // 修复后的检查结果（仍缺失的通常是交易所没有数据的周期）
+}
+
+// Repair rewrites months holding duplicate, unordered or misaligned rows and refetches missing bars through fetcher
+func (s *Store) Repair(ctx context.Context, fetcher KlineFetcher, symbol, interval string, from, to time.Time) (RepairStats, error) {
+	interval = normalizeInterval(interval)
+	var stats RepairStats
+	report, err := s.Check(symbol, interval, from, to)
+	if err != nil {
+		return stats, err
+	}
+	stats.Before = report
+
+	iv, err := marketdata.ParseInterval(interval)
+	if err != nil {
+		return stats, err
+	}
+
+	// 重复、乱序和未对齐的行：丢弃未对齐的K线，按开始时间合并（保留最后写入的一行）后重写
+	if len(report.Duplicates) > 0 || report.Unordered > 0 || len(report.Misaligned) > 0 {
+		if err := s.rewriteSorted(symbol, iv, &stats); err != nil {
+			return stats, err
+		}
+	}
+	downloader := NewDownloader(fetcher, s)
+	for _, gap := range report.Gaps {
+		end := minTime(gap.To, time.Now())
+		log.Printf("修复 %s %s 缺失K线: %s 至 %s (%d 根)", symbol, interval, gap.From.Format(time.RFC3339), gap.To.Format(time.RFC3339), gap.Missing)
+		if err := downloader.downloadRange(ctx, symbol, iv, gap.From, end, &stats.Download); err != nil {
+			return stats, err
+		}
+	}
+
+	stats.After, err = s.Check(symbol, interval, from, to)
+	return stats, err
+}
+
+// rewriteSorted rewrites the months of symbol/interval holding duplicate, unordered or misaligned rows
+func (s *Store) rewriteSorted(symbol string, iv marketdata.Interval, stats *RepairStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	interval := iv.String()
+	months, err := s.Months(symbol, interval)
+	if err != nil {
+		return err
+	}
+	for _, month := range months {
+		bars, err := s.readMonthOf(symbol, interval, month)
+		if err != nil {
+			return err
+		}
+		aligned := make([]Bar, 0, len(bars))
+		for _, bar := range bars {
+			if iv.Floor(bar.Start).Equal(bar.Start) {
+				aligned = append(aligned, bar)
+			}
+		}
+		merged, duplicates := mergeBars(nil, aligned)
+		if duplicates == 0 && len(aligned) == len(bars) && sort.SliceIsSorted(bars, func(i, j int) bool { return bars[i].Start.Before(bars[j].Start) }) {
+			continue
+		}
+		if err := writeMonth(filepath.Join(seriesDir(s.dir, symbol, interval), month+fileSuffix), merged); err != nil {
+			return err
+		}
+		stats.Rewritten++
+	}
+	return nil
+}
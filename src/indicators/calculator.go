@@ -2,91 +2,99 @@ package indicators
 
 import (
 	"vagues-go/src/models"
-
-	"github.com/markcheno/go-talib"
 )
 
 // Calculator handles technical indicator calculations
-type Calculator struct {
-	periods int // Number of periods to keep for calculations
-}
+// 计算使用传入的全部K线，少于 minPeriods 根时不输出
+type Calculator struct{}
+
+// minPeriods is the minimum number of K-lines required (EMA30, the minimum requirement for our strategy)
+const minPeriods = 30
 
 // NewCalculator creates a new indicator calculator
-func NewCalculator(periods int) *Calculator {
-	return &Calculator{
-		periods: periods,
-	}
+func NewCalculator() *Calculator {
+	return &Calculator{}
 }
 
-// CalculateIndicators calculates all technical indicators from K-line data
-func (c *Calculator) CalculateIndicators(klines []models.KLine) []models.Indicators {
-	// Need at least 30 periods for EMA30 (minimum requirement for our strategy)
-	if len(klines) < 30 {
-		return nil
-	}
-
-	indicators := make([]models.Indicators, len(klines))
+// DefaultSpecs are the indicators that populate the fixed fields of models.Indicators
+var DefaultSpecs = []Spec{
+	EMA(8),
+	EMA(30),
+	EMA(55),
+	EMA(144),
+	EMA(169),
+	MACD(12, 26, 9),
+	RSI(14),
+}
 
-	// Extract price data
-	closes := make([]float64, len(klines))
-	highs := make([]float64, len(klines))
-	lows := make([]float64, len(klines))
-	volumes := make([]float64, len(klines))
+// Calculate computes the given indicator specs and returns a keyed series map
+func (c *Calculator) Calculate(klines []models.KLine, specs ...Spec) (Series, error) {
+	return c.CalculateInput(NewInput(klines), specs...)
+}
 
-	for i, kline := range klines {
-		closes[i] = kline.Close
-		highs[i] = kline.High
-		lows[i] = kline.Low
-		volumes[i] = kline.Volume
+// CalculateInput computes the given indicator specs from a prepared input
+func (c *Calculator) CalculateInput(in Input, specs ...Spec) (Series, error) {
+	series := make(Series)
+	for _, spec := range specs {
+		if _, exists := series[spec.Key()]; exists {
+			continue
+		}
+		if err := compute(in, spec, series); err != nil {
+			return nil, err
+		}
 	}
+	return series, nil
+}
 
-	// Calculate EMAs (only calculate what we have enough data for)
-	ema8 := talib.Ema(closes, 8)
-	ema30 := talib.Ema(closes, 30)
+// CalculateIndicators calculates the default indicators plus any extra specs declared by a strategy
+// Extra spec values are stored in Indicators.Values keyed by Spec.Key() / Spec.Output()
+// Returns nil without error when there are fewer than 30 K-lines
+func (c *Calculator) CalculateIndicators(klines []models.KLine, extra ...Spec) ([]models.Indicators, error) {
+	return c.CalculateIndicatorsInput(NewInput(klines), extra...)
+}
 
-	var ema55, ema144, ema169 []float64
-	if len(klines) >= 55 {
-		ema55 = talib.Ema(closes, 55)
-	}
-	if len(klines) >= 144 {
-		ema144 = talib.Ema(closes, 144)
-	}
-	if len(klines) >= 169 {
-		ema169 = talib.Ema(closes, 169)
+// CalculateIndicatorsInput is CalculateIndicators over a prepared input (e.g. one carrying order flow deltas)
+func (c *Calculator) CalculateIndicatorsInput(in Input, extra ...Spec) ([]models.Indicators, error) {
+	if in.Len() < minPeriods {
+		return nil, nil
 	}
 
-	// Calculate MACD (needs at least 26 periods)
-	var macd, macdSignal, macdHistogram []float64
-	if len(klines) >= 26 {
-		macd, macdSignal, macdHistogram = talib.Macd(closes, 12, 26, 9)
+	specs := append(append([]Spec{}, DefaultSpecs...), extra...)
+	series, err := c.CalculateInput(in, specs...)
+	if err != nil {
+		return nil, err
 	}
 
-	// Calculate RSI (needs at least 14 periods)
-	var rsi []float64
-	if len(klines) >= 14 {
-		rsi = talib.Rsi(closes, 14)
-	}
+	macd := MACD(12, 26, 9)
+	indicators := make([]models.Indicators, in.Len())
 
-	// Populate indicators
-	// For EMA30, we need at least 30 periods, so start from index 29
-	minStartIdx := 29 // EMA30 needs 30 periods
+	// Populate indicators, starting once enough periods are available
+	minStartIdx := minPeriods - 1
 	for i := range indicators {
-		if i >= minStartIdx {
-			indicators[i] = models.Indicators{
-				EMA8:          getValueAt(ema8, i),
-				EMA30:         getValueAt(ema30, i),
-				EMA55:         getValueAt(ema55, i),
-				EMA144:        getValueAt(ema144, i),
-				EMA169:        getValueAt(ema169, i),
-				MACD:          getValueAt(macd, i),
-				MACDSignal:    getValueAt(macdSignal, i),
-				MACDHistogram: getValueAt(macdHistogram, i),
-				RSI:           getValueAt(rsi, i),
-			}
+		if i < minStartIdx {
+			continue
+		}
+
+		values := make(map[string]float64, len(series))
+		for key, s := range series {
+			values[key] = getValueAt(s, i)
+		}
+
+		indicators[i] = models.Indicators{
+			EMA8:          series.At(EMA(8).Key(), i),
+			EMA30:         series.At(EMA(30).Key(), i),
+			EMA55:         series.At(EMA(55).Key(), i),
+			EMA144:        series.At(EMA(144).Key(), i),
+			EMA169:        series.At(EMA(169).Key(), i),
+			MACD:          series.At(macd.Key(), i),
+			MACDSignal:    series.At(macd.Output("signal"), i),
+			MACDHistogram: series.At(macd.Output("hist"), i),
+			RSI:           series.At(RSI(14).Key(), i),
+			Values:        values,
 		}
 	}
 
-	return indicators
+	return indicators, nil
 }
 
 // getValueAt safely gets a value from a slice, returning 0 if index is out of bounds
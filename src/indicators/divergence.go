@@ -0,0 +1,265 @@
+package indicators
+
+import (
+	"fmt"
+
+	"vagues-go/src/models"
+)
+
+// PivotKind distinguishes swing highs from swing lows
+type PivotKind int
+
+const (
+	PivotHigh PivotKind = iota
+	PivotLow
+)
+
+// Pivot represents a confirmed swing point
+type Pivot struct {
+	Index int       // K线索引
+	Value float64   // 价格（最高价或最低价）
+	Kind  PivotKind // 高点或低点
+}
+
+// DivergenceType represents the kind of divergence between price and an oscillator
+type DivergenceType int
+
+const (
+	DivergenceRegularBullish DivergenceType = iota // 常规看涨：价格更低的低点，指标更高的低点
+	DivergenceRegularBearish                       // 常规看跌：价格更高的高点，指标更低的高点
+	DivergenceHiddenBullish                        // 隐藏看涨：价格更高的低点，指标更低的低点
+	DivergenceHiddenBearish                        // 隐藏看跌：价格更低的高点，指标更高的高点
+)
+
+// String returns a readable name for the divergence type
+func (t DivergenceType) String() string {
+	switch t {
+	case DivergenceRegularBullish:
+		return "常规看涨背离"
+	case DivergenceRegularBearish:
+		return "常规看跌背离"
+	case DivergenceHiddenBullish:
+		return "隐藏看涨背离"
+	case DivergenceHiddenBearish:
+		return "隐藏看跌背离"
+	default:
+		return "未知背离"
+	}
+}
+
+// Divergence sources
+const (
+	DivergenceSourceMACD = "macd" // MACD 柱状图
+	DivergenceSourceRSI  = "rsi"  // RSI(14)
+	DivergenceSourceCVD  = "cvd"  // 累计成交量 Delta
+)
+
+// Divergence describes a divergence between two price pivots and the oscillator at those bars
+type Divergence struct {
+	Type      DivergenceType
+	Source    string  // 指标来源（macd / rsi / cvd）
+	From      Pivot   // 较早的价格拐点
+	To        Pivot   // 较晚的价格拐点
+	OscFrom   float64 // 较早拐点处的指标值
+	OscTo     float64 // 较晚拐点处的指标值
+	Confirmed int     // 确认该背离的K线索引（To.Index + 右侧确认根数）
+}
+
+// IsBullish reports whether the divergence is bullish (regular or hidden)
+func (d Divergence) IsBullish() bool {
+	return d.Type == DivergenceRegularBullish || d.Type == DivergenceHiddenBullish
+}
+
+// IsRegular reports whether the divergence is a regular (reversal) divergence
+func (d Divergence) IsRegular() bool {
+	return d.Type == DivergenceRegularBullish || d.Type == DivergenceRegularBearish
+}
+
+// String implements fmt.Stringer
+func (d Divergence) String() string {
+	return fmt.Sprintf("%s %s (K线 %d→%d, 价格 %.4f→%.4f, 指标 %.4f→%.4f)",
+		d.Source, d.Type, d.From.Index, d.To.Index, d.From.Value, d.To.Value, d.OscFrom, d.OscTo)
+}
+
+// DivergenceConfig configures pivot detection and divergence pairing
+type DivergenceConfig struct {
+	PivotLeft      int  // 拐点左侧确认根数（默认3）
+	PivotRight     int  // 拐点右侧确认根数（默认3）
+	MinBarsBetween int  // 两个拐点之间最少间隔（默认5）
+	MaxBarsBetween int  // 两个拐点之间最多间隔（默认60）
+	IncludeHidden  bool // 是否检测隐藏背离
+}
+
+// DefaultDivergenceConfig returns the default divergence configuration
+func DefaultDivergenceConfig() DivergenceConfig {
+	return DivergenceConfig{
+		PivotLeft:      3,
+		PivotRight:     3,
+		MinBarsBetween: 5,
+		MaxBarsBetween: 60,
+		IncludeHidden:  true,
+	}
+}
+
+// FindPivots finds swing points: a high pivot is strictly above the left bars and not below the right bars
+// (mirrored for lows). The last `right` bars cannot be confirmed yet.
+func FindPivots(values []float64, left, right int, kind PivotKind) []Pivot {
+	var pivots []Pivot
+	for i := left; i < len(values)-right; i++ {
+		isPivot := true
+		for j := i - left; j <= i+right && isPivot; j++ {
+			if j == i {
+				continue
+			}
+			switch kind {
+			case PivotHigh:
+				if (j < i && values[j] >= values[i]) || (j > i && values[j] > values[i]) {
+					isPivot = false
+				}
+			case PivotLow:
+				if (j < i && values[j] <= values[i]) || (j > i && values[j] < values[i]) {
+					isPivot = false
+				}
+			}
+		}
+		if isPivot {
+			pivots = append(pivots, Pivot{Index: i, Value: values[i], Kind: kind})
+		}
+	}
+	return pivots
+}
+
+// DetectDivergences compares consecutive price pivots with the oscillator values at the same bars
+func DetectDivergences(highs, lows, osc []float64, source string, cfg DivergenceConfig) []Divergence {
+	if len(highs) != len(osc) || len(lows) != len(osc) {
+		return nil
+	}
+
+	var divergences []Divergence
+
+	// 低点：看涨背离
+	lowPivots := FindPivots(lows, cfg.PivotLeft, cfg.PivotRight, PivotLow)
+	for i := 1; i < len(lowPivots); i++ {
+		from, to := lowPivots[i-1], lowPivots[i]
+		if !cfg.spacingOK(from, to) {
+			continue
+		}
+		div := Divergence{Source: source, From: from, To: to, OscFrom: osc[from.Index], OscTo: osc[to.Index], Confirmed: to.Index + cfg.PivotRight}
+		switch {
+		case to.Value < from.Value && div.OscTo > div.OscFrom:
+			div.Type = DivergenceRegularBullish
+		case cfg.IncludeHidden && to.Value > from.Value && div.OscTo < div.OscFrom:
+			div.Type = DivergenceHiddenBullish
+		default:
+			continue
+		}
+		divergences = append(divergences, div)
+	}
+
+	// 高点：看跌背离
+	highPivots := FindPivots(highs, cfg.PivotLeft, cfg.PivotRight, PivotHigh)
+	for i := 1; i < len(highPivots); i++ {
+		from, to := highPivots[i-1], highPivots[i]
+		if !cfg.spacingOK(from, to) {
+			continue
+		}
+		div := Divergence{Source: source, From: from, To: to, OscFrom: osc[from.Index], OscTo: osc[to.Index], Confirmed: to.Index + cfg.PivotRight}
+		switch {
+		case to.Value > from.Value && div.OscTo < div.OscFrom:
+			div.Type = DivergenceRegularBearish
+		case cfg.IncludeHidden && to.Value < from.Value && div.OscTo > div.OscFrom:
+			div.Type = DivergenceHiddenBearish
+		default:
+			continue
+		}
+		divergences = append(divergences, div)
+	}
+
+	return divergences
+}
+
+// spacingOK checks the distance between two pivots
+func (cfg DivergenceConfig) spacingOK(from, to Pivot) bool {
+	gap := to.Index - from.Index
+	if gap < cfg.MinBarsBetween {
+		return false
+	}
+	return cfg.MaxBarsBetween <= 0 || gap <= cfg.MaxBarsBetween
+}
+
+// DetectMarketDivergences detects divergences over MarketData history for the given sources
+// deltas 为与 history 末尾对齐的每根K线 Delta 值（仅 cvd 需要，可为 nil）；返回的索引均基于 history
+func DetectMarketDivergences(history []models.MarketData, deltas []float64, cfg DivergenceConfig, sources ...string) []Divergence {
+	var divergences []Divergence
+	for _, source := range sources {
+		osc, start := oscillatorSeries(history, deltas, source)
+		if start >= len(history) {
+			continue
+		}
+
+		// 只在指标有效的区间内寻找拐点
+		window := history[start:]
+		highs := make([]float64, len(window))
+		lows := make([]float64, len(window))
+		for i, data := range window {
+			highs[i] = data.KLine.High
+			lows[i] = data.KLine.Low
+		}
+
+		for _, div := range DetectDivergences(highs, lows, osc[start:], source, cfg) {
+			div.From.Index += start
+			div.To.Index += start
+			div.Confirmed += start
+			divergences = append(divergences, div)
+		}
+	}
+	return divergences
+}
+
+// oscillatorSeries extracts the oscillator values for a divergence source
+// together with the first index at which the values are valid
+func oscillatorSeries(history []models.MarketData, deltas []float64, source string) ([]float64, int) {
+	n := len(history)
+	osc := make([]float64, n)
+	switch source {
+	case DivergenceSourceMACD:
+		for i, data := range history {
+			osc[i] = data.Indicators.MACDHistogram
+		}
+	case DivergenceSourceRSI:
+		for i, data := range history {
+			osc[i] = data.Indicators.RSI
+		}
+	case DivergenceSourceCVD:
+		// Delta 与 history 末尾对齐，只使用两者重叠的部分
+		if len(deltas) > n {
+			deltas = deltas[len(deltas)-n:]
+		}
+		start := n - len(deltas)
+		copy(osc[start:], cumulativeSum(deltas))
+		return osc, start
+	default:
+		// 其他来源按指标键读取（如 "rsi(7)"、"mfi(14)"）
+		for i, data := range history {
+			osc[i] = data.Indicators.Get(source)
+		}
+	}
+
+	// 跳过指标预热期（值为0）
+	start := 0
+	for start < n && osc[start] == 0 {
+		start++
+	}
+	return osc, start
+}
+
+// RecentDivergences returns divergences confirmed within the last `within` bars of a series of length n
+func RecentDivergences(divergences []Divergence, n, within int) []Divergence {
+	var recent []Divergence
+	for _, div := range divergences {
+		if div.Confirmed >= n-1-within {
+			recent = append(recent, div)
+		}
+	}
+	return recent
+}
@@ -0,0 +1,435 @@
+package indicators
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vagues-go/src/models"
+
+	"github.com/markcheno/go-talib"
+)
+
+// Spec declares an indicator and its parameters, e.g. ema(21), macd(12,26,9), bbands(20,2)
+type Spec struct {
+	Name   string    // 指标名称（小写，如 "ema"）
+	Params []float64 // 指标参数
+}
+
+// NewSpec creates an indicator spec
+func NewSpec(name string, params ...float64) Spec {
+	return Spec{
+		Name:   strings.ToLower(strings.TrimSpace(name)),
+		Params: params,
+	}
+}
+
+// SMA returns the spec for a simple moving average
+func SMA(period int) Spec {
+	return NewSpec("sma", float64(period))
+}
+
+// EMA returns the spec for an exponential moving average
+func EMA(period int) Spec {
+	return NewSpec("ema", float64(period))
+}
+
+// MACD returns the spec for MACD (outputs: main, .signal, .hist)
+func MACD(fast, slow, signal int) Spec {
+	return NewSpec("macd", float64(fast), float64(slow), float64(signal))
+}
+
+// RSI returns the spec for the relative strength index
+func RSI(period int) Spec {
+	return NewSpec("rsi", float64(period))
+}
+
+// Key returns the canonical key of the spec, e.g. "ema(21)"
+// 无参数指标的键为名称本身（如 "obv"）
+func (s Spec) Key() string {
+	if len(s.Params) == 0 {
+		return s.Name
+	}
+	parts := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		parts[i] = strconv.FormatFloat(p, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%s(%s)", s.Name, strings.Join(parts, ","))
+}
+
+// Output returns the key of a named output of a multi-output indicator, e.g. "macd(12,26,9).hist"
+func (s Spec) Output(name string) string {
+	if name == "" {
+		return s.Key()
+	}
+	return s.Key() + "." + name
+}
+
+// String implements fmt.Stringer
+func (s Spec) String() string {
+	return s.Key()
+}
+
+// ParseSpec parses a single spec such as "ema(21)" or "obv"
+func ParseSpec(text string) (Spec, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Spec{}, fmt.Errorf("指标声明为空")
+	}
+
+	open := strings.Index(text, "(")
+	if open < 0 {
+		return NewSpec(text), nil
+	}
+	if !strings.HasSuffix(text, ")") {
+		return Spec{}, fmt.Errorf("指标声明格式错误: %s", text)
+	}
+
+	name := text[:open]
+	body := strings.TrimSpace(text[open+1 : len(text)-1])
+	if strings.TrimSpace(name) == "" {
+		return Spec{}, fmt.Errorf("指标声明缺少名称: %s", text)
+	}
+
+	var params []float64
+	if body != "" {
+		for _, field := range strings.Split(body, ",") {
+			value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return Spec{}, fmt.Errorf("解析指标参数失败: %s: %w", text, err)
+			}
+			params = append(params, value)
+		}
+	}
+
+	return NewSpec(name, params...), nil
+}
+
+// ParseSpecs parses a comma separated list such as "ema(21), macd(12,26,9), rsi(7)"
+func ParseSpecs(text string) ([]Spec, error) {
+	var specs []Spec
+	depth := 0
+	start := 0
+	for i, r := range text {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				spec, err := ParseSpec(text[start:i])
+				if err != nil {
+					return nil, err
+				}
+				specs = append(specs, spec)
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(text[start:]) != "" {
+		spec, err := ParseSpec(text[start:])
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// Input holds the price series an indicator is computed from
+type Input struct {
+	Times       []time.Time
+	Open        []float64
+	High        []float64
+	Low         []float64
+	Close       []float64
+	Volume      []float64
+	QuoteVolume []float64
+	Delta       []float64 // 每根K线的订单流 Delta（可选，见 WithDeltas）
+}
+
+// NewInput extracts the price series from K-line data
+func NewInput(klines []models.KLine) Input {
+	in := Input{
+		Times:       make([]time.Time, len(klines)),
+		Open:        make([]float64, len(klines)),
+		High:        make([]float64, len(klines)),
+		Low:         make([]float64, len(klines)),
+		Close:       make([]float64, len(klines)),
+		Volume:      make([]float64, len(klines)),
+		QuoteVolume: make([]float64, len(klines)),
+	}
+	for i, kline := range klines {
+		in.Times[i] = kline.StartTime
+		in.Open[i] = kline.Open
+		in.High[i] = kline.High
+		in.Low[i] = kline.Low
+		in.Close[i] = kline.Close
+		in.Volume[i] = kline.Volume
+		in.QuoteVolume[i] = kline.QuoteVolume
+	}
+	return in
+}
+
+// Len returns the number of bars in the input
+func (in Input) Len() int {
+	return len(in.Close)
+}
+
+// Series maps indicator keys to their value series (aligned with the input bars)
+type Series map[string][]float64
+
+// At returns the value of key at bar index i, or 0 if unavailable
+func (s Series) At(key string, i int) float64 {
+	return getValueAt(s[key], i)
+}
+
+// Last returns the most recent value of key, or 0 if unavailable
+func (s Series) Last(key string) float64 {
+	values := s[key]
+	return getValueAt(values, len(values)-1)
+}
+
+// ComputeFunc computes an indicator; the returned map is keyed by output name ("" = main output)
+// 缺少的输出不会写入结果（如 cvd 没有 Delta 数据时）
+type ComputeFunc func(in Input, params []float64) (map[string][]float64, error)
+
+// Definition describes a registered indicator
+type Definition struct {
+	Name      string                     // 指标名称
+	MinParams int                        // 最少参数个数
+	MaxParams int                        // 最多参数个数
+	Outputs   []string                   // 输出名称（"" 表示主输出）
+	IntParams []int                      // 必须为正整数的参数下标（周期类参数，由 Validate 检查）
+	Lookback  func(params []float64) int // 计算所需的最少K线数量（可选）
+	Compute   ComputeFunc
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Definition)
+)
+
+// Register adds an indicator definition to the registry
+// 重复注册同名指标会 panic（与 database/sql 驱动注册一致）
+func Register(def Definition) {
+	name := strings.ToLower(def.Name)
+	if name == "" || def.Compute == nil {
+		panic("indicators: 注册指标缺少名称或计算函数")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic("indicators: 重复注册指标 " + name)
+	}
+	def.Name = name
+	registry[name] = def
+}
+
+// Lookup returns the definition registered under name
+func Lookup(name string) (Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := registry[strings.ToLower(name)]
+	return def, ok
+}
+
+// Names returns the names of all registered indicators (sorted)
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that every spec refers to a registered indicator with valid parameters
+func Validate(specs ...Spec) error {
+	for _, spec := range specs {
+		def, err := lookupSpec(spec)
+		if err != nil {
+			return err
+		}
+		for _, i := range def.IntParams {
+			if _, err := intParam(spec.Params, i, 1); err != nil {
+				return fmt.Errorf("指标 %s 参数无效: %w", spec.Key(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// RequiredBars returns the number of K-lines needed before every spec produces values
+func RequiredBars(specs ...Spec) int {
+	required := 0
+	for _, spec := range specs {
+		def, err := lookupSpec(spec)
+		if err != nil || def.Lookback == nil {
+			continue
+		}
+		if bars := def.Lookback(spec.Params); bars > required {
+			required = bars
+		}
+	}
+	return required
+}
+
+// Keys returns the output keys a spec will produce
+func Keys(spec Spec) ([]string, error) {
+	def, err := lookupSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(def.Outputs))
+	for i, out := range def.Outputs {
+		keys[i] = spec.Output(out)
+	}
+	return keys, nil
+}
+
+// lookupSpec finds the definition for a spec and validates its parameters
+func lookupSpec(spec Spec) (Definition, error) {
+	def, ok := Lookup(spec.Name)
+	if !ok {
+		return Definition{}, fmt.Errorf("未知指标: %s", spec.Name)
+	}
+	if len(spec.Params) < def.MinParams || len(spec.Params) > def.MaxParams {
+		return Definition{}, fmt.Errorf("指标 %s 参数个数错误: 需要 %d-%d 个, 实际 %d 个",
+			spec.Key(), def.MinParams, def.MaxParams, len(spec.Params))
+	}
+	return def, nil
+}
+
+// compute runs a single spec against the input and stores its outputs in series
+func compute(in Input, spec Spec, series Series) error {
+	def, err := lookupSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	// 数据不足时输出全0序列（talib 在数据不足时会越界）
+	if def.Lookback != nil && in.Len() < def.Lookback(spec.Params) {
+		for _, out := range def.Outputs {
+			series[spec.Output(out)] = make([]float64, in.Len())
+		}
+		return nil
+	}
+
+	outputs, err := def.Compute(in, spec.Params)
+	if err != nil {
+		return fmt.Errorf("计算指标 %s 失败: %w", spec.Key(), err)
+	}
+	for _, out := range def.Outputs {
+		values, ok := outputs[out]
+		if !ok {
+			continue // 缺少所需输入（如 cvd 没有 Delta）时不输出该键
+		}
+		if len(values) != in.Len() {
+			values = make([]float64, in.Len())
+		}
+		series[spec.Output(out)] = values
+	}
+	return nil
+}
+
+// intParam returns params[i] as a positive integer period
+func intParam(params []float64, i int, fallback int) (int, error) {
+	if i >= len(params) {
+		return fallback, nil
+	}
+	value := int(params[i])
+	if float64(value) != params[i] || value <= 0 {
+		return 0, fmt.Errorf("参数 %v 必须为正整数", params[i])
+	}
+	return value, nil
+}
+
+// floatParam returns params[i] or fallback if absent
+func floatParam(params []float64, i int, fallback float64) float64 {
+	if i >= len(params) {
+		return fallback
+	}
+	return params[i]
+}
+
+// periodLookback returns a Lookback that requires params[i] bars (plus extra)
+func periodLookback(i, fallback, extra int) func(params []float64) int {
+	return func(params []float64) int {
+		period, err := intParam(params, i, fallback)
+		if err != nil {
+			return 0
+		}
+		return period + extra
+	}
+}
+
+func init() {
+	Register(Definition{
+		Name: "sma", MinParams: 1, MaxParams: 1, Outputs: []string{""},
+		IntParams: []int{0},
+		Lookback:  periodLookback(0, 0, 0),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 0)
+			if err != nil {
+				return nil, err
+			}
+			return map[string][]float64{"": talib.Sma(in.Close, period)}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "ema", MinParams: 1, MaxParams: 1, Outputs: []string{""},
+		IntParams: []int{0},
+		Lookback:  periodLookback(0, 0, 0),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 0)
+			if err != nil {
+				return nil, err
+			}
+			return map[string][]float64{"": talib.Ema(in.Close, period)}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "macd", MinParams: 0, MaxParams: 3, Outputs: []string{"", "signal", "hist"},
+		IntParams: []int{0, 1, 2},
+		Lookback:  periodLookback(1, 26, 0),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			fast, err := intParam(params, 0, 12)
+			if err != nil {
+				return nil, err
+			}
+			slow, err := intParam(params, 1, 26)
+			if err != nil {
+				return nil, err
+			}
+			signal, err := intParam(params, 2, 9)
+			if err != nil {
+				return nil, err
+			}
+			macd, macdSignal, macdHist := talib.Macd(in.Close, fast, slow, signal)
+			return map[string][]float64{"": macd, "signal": macdSignal, "hist": macdHist}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "rsi", MinParams: 1, MaxParams: 1, Outputs: []string{""},
+		IntParams: []int{0},
+		Lookback:  periodLookback(0, 0, 0),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 0)
+			if err != nil {
+				return nil, err
+			}
+			return map[string][]float64{"": talib.Rsi(in.Close, period)}, nil
+		},
+	})
+}
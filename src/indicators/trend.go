@@ -0,0 +1,218 @@
+package indicators
+
+import (
+	"github.com/markcheno/go-talib"
+)
+
+// ADX returns the spec for ADX with directional movement (outputs: main, .plusdi, .minusdi)
+func ADX(period int) Spec {
+	return NewSpec("adx", float64(period))
+}
+
+// Supertrend returns the spec for Supertrend (outputs: main line, .direction = +1 up / -1 down)
+func Supertrend(period int, mult float64) Spec {
+	return NewSpec("supertrend", float64(period), mult)
+}
+
+// Ichimoku returns the spec for Ichimoku Kinko Hyo (outputs: .tenkan, .kijun, .spana, .spanb)
+// spana/spanb 为当前K线所对应的云层（即 kijun 根之前计算并前移的值）
+func Ichimoku(tenkan, kijun, senkouB int) Spec {
+	return NewSpec("ichimoku", float64(tenkan), float64(kijun), float64(senkouB))
+}
+
+// DMI holds ADX and directional indicator series
+type DMI struct {
+	ADX     []float64
+	PlusDI  []float64
+	MinusDI []float64
+}
+
+// CalculateDMI calculates ADX, +DI and -DI
+func CalculateDMI(highs, lows, closes []float64, period int) DMI {
+	n := len(closes)
+	if n < 2*period {
+		return DMI{
+			ADX:     make([]float64, n),
+			PlusDI:  make([]float64, n),
+			MinusDI: make([]float64, n),
+		}
+	}
+	return DMI{
+		ADX:     talib.Adx(highs, lows, closes, period),
+		PlusDI:  talib.PlusDI(highs, lows, closes, period),
+		MinusDI: talib.MinusDI(highs, lows, closes, period),
+	}
+}
+
+// SupertrendSeries holds the Supertrend line and its direction (+1 up, -1 down, 0 warming up)
+type SupertrendSeries struct {
+	Line      []float64
+	Direction []float64
+}
+
+// CalculateSupertrend calculates Supertrend using hl2 ± mult × ATR(period)
+func CalculateSupertrend(highs, lows, closes []float64, period int, mult float64) SupertrendSeries {
+	n := len(closes)
+	st := SupertrendSeries{
+		Line:      make([]float64, n),
+		Direction: make([]float64, n),
+	}
+	if n <= period {
+		return st
+	}
+
+	atr := talib.Atr(highs, lows, closes, period)
+
+	var finalUpper, finalLower float64
+	direction := 1.0
+	for i := period; i < n; i++ {
+		hl2 := (highs[i] + lows[i]) / 2
+		basicUpper := hl2 + mult*atr[i]
+		basicLower := hl2 - mult*atr[i]
+
+		if i == period {
+			finalUpper, finalLower = basicUpper, basicLower
+		} else {
+			// 上轨只允许下移（除非价格已突破），下轨只允许上移
+			if basicUpper < finalUpper || closes[i-1] > finalUpper {
+				finalUpper = basicUpper
+			}
+			if basicLower > finalLower || closes[i-1] < finalLower {
+				finalLower = basicLower
+			}
+		}
+
+		if direction > 0 && closes[i] < finalLower {
+			direction = -1
+		} else if direction < 0 && closes[i] > finalUpper {
+			direction = 1
+		}
+
+		st.Direction[i] = direction
+		if direction > 0 {
+			st.Line[i] = finalLower
+		} else {
+			st.Line[i] = finalUpper
+		}
+	}
+	return st
+}
+
+// IchimokuSeries holds Ichimoku lines aligned to each bar
+type IchimokuSeries struct {
+	Tenkan []float64 // 转换线
+	Kijun  []float64 // 基准线
+	SpanA  []float64 // 先行带A（当前K线对应的云层）
+	SpanB  []float64 // 先行带B（当前K线对应的云层）
+}
+
+// CalculateIchimoku calculates Ichimoku lines; the cloud is displaced forward by kijun bars
+func CalculateIchimoku(highs, lows []float64, tenkanPeriod, kijunPeriod, senkouBPeriod int) IchimokuSeries {
+	n := len(highs)
+	ich := IchimokuSeries{
+		Tenkan: midpoint(highs, lows, tenkanPeriod),
+		Kijun:  midpoint(highs, lows, kijunPeriod),
+		SpanA:  make([]float64, n),
+		SpanB:  make([]float64, n),
+	}
+	spanB := midpoint(highs, lows, senkouBPeriod)
+
+	for i := kijunPeriod; i < n; i++ {
+		src := i - kijunPeriod
+		if src >= kijunPeriod-1 && src >= tenkanPeriod-1 {
+			ich.SpanA[i] = (ich.Tenkan[src] + ich.Kijun[src]) / 2
+		}
+		if src >= senkouBPeriod-1 {
+			ich.SpanB[i] = spanB[src]
+		}
+	}
+	return ich
+}
+
+// midpoint returns (highest high + lowest low) / 2 over period bars
+func midpoint(highs, lows []float64, period int) []float64 {
+	n := len(highs)
+	out := make([]float64, n)
+	if n < period {
+		return out
+	}
+	upper := talib.Max(highs, period)
+	lower := talib.Min(lows, period)
+	for i := period - 1; i < n; i++ {
+		out[i] = (upper[i] + lower[i]) / 2
+	}
+	return out
+}
+
+func init() {
+	Register(Definition{
+		Name: "adx", MinParams: 1, MaxParams: 1, Outputs: []string{"", "plusdi", "minusdi"},
+		IntParams: []int{0},
+		Lookback: func(params []float64) int {
+			period, err := intParam(params, 0, 14)
+			if err != nil {
+				return 0
+			}
+			return 2 * period
+		},
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 14)
+			if err != nil {
+				return nil, err
+			}
+			dmi := CalculateDMI(in.High, in.Low, in.Close, period)
+			return map[string][]float64{"": dmi.ADX, "plusdi": dmi.PlusDI, "minusdi": dmi.MinusDI}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "supertrend", MinParams: 0, MaxParams: 2, Outputs: []string{"", "direction"},
+		IntParams: []int{0},
+		Lookback:  periodLookback(0, 10, 1),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 10)
+			if err != nil {
+				return nil, err
+			}
+			st := CalculateSupertrend(in.High, in.Low, in.Close, period, floatParam(params, 1, 3))
+			return map[string][]float64{"": st.Line, "direction": st.Direction}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "ichimoku", MinParams: 0, MaxParams: 3, Outputs: []string{"tenkan", "kijun", "spana", "spanb"},
+		IntParams: []int{0, 1, 2},
+		Lookback: func(params []float64) int {
+			kijun, err := intParam(params, 1, 26)
+			if err != nil {
+				return 0
+			}
+			senkouB, err := intParam(params, 2, 52)
+			if err != nil {
+				return 0
+			}
+			return kijun + senkouB
+		},
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			tenkan, err := intParam(params, 0, 9)
+			if err != nil {
+				return nil, err
+			}
+			kijun, err := intParam(params, 1, 26)
+			if err != nil {
+				return nil, err
+			}
+			senkouB, err := intParam(params, 2, 52)
+			if err != nil {
+				return nil, err
+			}
+			ich := CalculateIchimoku(in.High, in.Low, tenkan, kijun, senkouB)
+			return map[string][]float64{
+				"tenkan": ich.Tenkan,
+				"kijun":  ich.Kijun,
+				"spana":  ich.SpanA,
+				"spanb":  ich.SpanB,
+			}, nil
+		},
+	})
+}
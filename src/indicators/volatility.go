@@ -0,0 +1,190 @@
+package indicators
+
+import (
+	"github.com/markcheno/go-talib"
+)
+
+// ATR returns the spec for the average true range
+func ATR(period int) Spec {
+	return NewSpec("atr", float64(period))
+}
+
+// BBands returns the spec for Bollinger Bands (outputs: .upper, .middle, .lower, .width, .pctb)
+func BBands(period int, k float64) Spec {
+	return NewSpec("bbands", float64(period), k)
+}
+
+// Keltner returns the spec for Keltner Channels (outputs: .upper, .middle, .lower)
+func Keltner(emaPeriod, atrPeriod int, mult float64) Spec {
+	return NewSpec("keltner", float64(emaPeriod), float64(atrPeriod), mult)
+}
+
+// Donchian returns the spec for Donchian Channels (outputs: .upper, .middle, .lower)
+func Donchian(period int) Spec {
+	return NewSpec("donchian", float64(period))
+}
+
+// CalculateATR calculates the average true range
+func CalculateATR(highs, lows, closes []float64, period int) []float64 {
+	if len(closes) <= period {
+		return make([]float64, len(closes))
+	}
+	return talib.Atr(highs, lows, closes, period)
+}
+
+// BollingerBands holds Bollinger Band series
+type BollingerBands struct {
+	Upper    []float64
+	Middle   []float64
+	Lower    []float64
+	Width    []float64 // 带宽: (upper - lower) / middle
+	PercentB []float64 // %B: (close - lower) / (upper - lower)
+}
+
+// CalculateBollingerBands calculates Bollinger Bands with a k standard deviation envelope
+func CalculateBollingerBands(closes []float64, period int, k float64) BollingerBands {
+	n := len(closes)
+	bands := BollingerBands{
+		Width:    make([]float64, n),
+		PercentB: make([]float64, n),
+	}
+	if n < period {
+		bands.Upper = make([]float64, n)
+		bands.Middle = make([]float64, n)
+		bands.Lower = make([]float64, n)
+		return bands
+	}
+
+	bands.Upper, bands.Middle, bands.Lower = talib.BBands(closes, period, k, k, talib.SMA)
+	for i := period - 1; i < n; i++ {
+		if bands.Middle[i] != 0 {
+			bands.Width[i] = (bands.Upper[i] - bands.Lower[i]) / bands.Middle[i]
+		}
+		if spread := bands.Upper[i] - bands.Lower[i]; spread != 0 {
+			bands.PercentB[i] = (closes[i] - bands.Lower[i]) / spread
+		}
+	}
+	return bands
+}
+
+// Channel holds an upper/middle/lower price channel
+type Channel struct {
+	Upper  []float64
+	Middle []float64
+	Lower  []float64
+}
+
+// CalculateKeltner calculates Keltner Channels: EMA(emaPeriod) ± mult × ATR(atrPeriod)
+func CalculateKeltner(highs, lows, closes []float64, emaPeriod, atrPeriod int, mult float64) Channel {
+	n := len(closes)
+	channel := Channel{
+		Upper:  make([]float64, n),
+		Middle: make([]float64, n),
+		Lower:  make([]float64, n),
+	}
+	if n < emaPeriod || n <= atrPeriod {
+		return channel
+	}
+
+	ema := talib.Ema(closes, emaPeriod)
+	atr := talib.Atr(highs, lows, closes, atrPeriod)
+
+	start := emaPeriod - 1
+	if atrPeriod > start {
+		start = atrPeriod
+	}
+	for i := start; i < n; i++ {
+		channel.Middle[i] = ema[i]
+		channel.Upper[i] = ema[i] + mult*atr[i]
+		channel.Lower[i] = ema[i] - mult*atr[i]
+	}
+	return channel
+}
+
+// CalculateDonchian calculates Donchian Channels over the last period bars (including the current bar)
+func CalculateDonchian(highs, lows []float64, period int) Channel {
+	n := len(highs)
+	channel := Channel{
+		Upper:  make([]float64, n),
+		Middle: make([]float64, n),
+		Lower:  make([]float64, n),
+	}
+	if n < period {
+		return channel
+	}
+
+	upper := talib.Max(highs, period)
+	lower := talib.Min(lows, period)
+	for i := period - 1; i < n; i++ {
+		channel.Upper[i] = upper[i]
+		channel.Lower[i] = lower[i]
+		channel.Middle[i] = (upper[i] + lower[i]) / 2
+	}
+	return channel
+}
+
+func init() {
+	Register(Definition{
+		Name: "atr", MinParams: 1, MaxParams: 1, Outputs: []string{""},
+		IntParams: []int{0},
+		Lookback:  periodLookback(0, 14, 1),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 14)
+			if err != nil {
+				return nil, err
+			}
+			return map[string][]float64{"": CalculateATR(in.High, in.Low, in.Close, period)}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "bbands", MinParams: 1, MaxParams: 2, Outputs: []string{"upper", "middle", "lower", "width", "pctb"},
+		IntParams: []int{0},
+		Lookback:  periodLookback(0, 20, 0),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 20)
+			if err != nil {
+				return nil, err
+			}
+			bands := CalculateBollingerBands(in.Close, period, floatParam(params, 1, 2))
+			return map[string][]float64{
+				"upper":  bands.Upper,
+				"middle": bands.Middle,
+				"lower":  bands.Lower,
+				"width":  bands.Width,
+				"pctb":   bands.PercentB,
+			}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "keltner", MinParams: 0, MaxParams: 3, Outputs: []string{"upper", "middle", "lower"},
+		IntParams: []int{0, 1},
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			emaPeriod, err := intParam(params, 0, 20)
+			if err != nil {
+				return nil, err
+			}
+			atrPeriod, err := intParam(params, 1, 10)
+			if err != nil {
+				return nil, err
+			}
+			channel := CalculateKeltner(in.High, in.Low, in.Close, emaPeriod, atrPeriod, floatParam(params, 2, 2))
+			return map[string][]float64{"upper": channel.Upper, "middle": channel.Middle, "lower": channel.Lower}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "donchian", MinParams: 1, MaxParams: 1, Outputs: []string{"upper", "middle", "lower"},
+		IntParams: []int{0},
+		Lookback:  periodLookback(0, 20, 0),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 20)
+			if err != nil {
+				return nil, err
+			}
+			channel := CalculateDonchian(in.High, in.Low, period)
+			return map[string][]float64{"upper": channel.Upper, "middle": channel.Middle, "lower": channel.Lower}, nil
+		},
+	})
+}
@@ -0,0 +1,221 @@
+package indicators
+
+import (
+	"math"
+
+	"vagues-go/src/models"
+
+	"github.com/markcheno/go-talib"
+)
+
+// VWAP returns the spec for session-anchored (UTC day) VWAP with ±k standard deviation bands
+// (outputs: main, .upper, .lower)
+func VWAP(k float64) Spec {
+	return NewSpec("vwap", k)
+}
+
+// RollingVWAP returns the spec for VWAP over the last period bars with ±k standard deviation bands
+// (outputs: main, .upper, .lower)
+func RollingVWAP(period int, k float64) Spec {
+	return NewSpec("rvwap", float64(period), k)
+}
+
+// OBV returns the spec for on-balance volume
+func OBV() Spec {
+	return NewSpec("obv")
+}
+
+// MFI returns the spec for the money flow index
+func MFI(period int) Spec {
+	return NewSpec("mfi", float64(period))
+}
+
+// CVD returns the spec for cumulative volume delta (requires Input.Delta; 没有 Delta 时不输出)
+func CVD() Spec {
+	return NewSpec("cvd")
+}
+
+// WithDeltas returns a copy of the input carrying per-bar order flow delta values
+// deltas 需与K线一一对应；用于计算 cvd 等依赖逐笔数据的指标
+func (in Input) WithDeltas(deltas []models.Delta) Input {
+	in.Delta = make([]float64, len(deltas))
+	for i, d := range deltas {
+		in.Delta[i] = d.Value
+	}
+	return in
+}
+
+// VWAPBands holds a VWAP series and its standard deviation bands
+type VWAPBands struct {
+	VWAP  []float64
+	Upper []float64
+	Lower []float64
+}
+
+// typicalPrice returns (high + low + close) / 3 for bar i
+func typicalPrice(in Input, i int) float64 {
+	return (in.High[i] + in.Low[i] + in.Close[i]) / 3
+}
+
+// CalculateSessionVWAP calculates VWAP anchored to the start of each UTC day
+// 如果输入没有时间信息，则整个序列视为一个交易时段
+func CalculateSessionVWAP(in Input, k float64) VWAPBands {
+	n := in.Len()
+	bands := VWAPBands{
+		VWAP:  make([]float64, n),
+		Upper: make([]float64, n),
+		Lower: make([]float64, n),
+	}
+
+	var sumPV, sumPPV, sumV float64
+	for i := 0; i < n; i++ {
+		// 新的 UTC 交易日重置累计值
+		if i > 0 && len(in.Times) == n && !sameUTCDay(in, i-1, i) {
+			sumPV, sumPPV, sumV = 0, 0, 0
+		}
+
+		tp := typicalPrice(in, i)
+		sumPV += tp * in.Volume[i]
+		sumPPV += tp * tp * in.Volume[i]
+		sumV += in.Volume[i]
+
+		fillVWAP(&bands, i, sumPV, sumPPV, sumV, k, tp)
+	}
+	return bands
+}
+
+// CalculateRollingVWAP calculates VWAP over a rolling window of period bars
+func CalculateRollingVWAP(in Input, period int, k float64) VWAPBands {
+	n := in.Len()
+	bands := VWAPBands{
+		VWAP:  make([]float64, n),
+		Upper: make([]float64, n),
+		Lower: make([]float64, n),
+	}
+
+	var sumPV, sumPPV, sumV float64
+	for i := 0; i < n; i++ {
+		tp := typicalPrice(in, i)
+		sumPV += tp * in.Volume[i]
+		sumPPV += tp * tp * in.Volume[i]
+		sumV += in.Volume[i]
+
+		if i >= period {
+			oldTP := typicalPrice(in, i-period)
+			sumPV -= oldTP * in.Volume[i-period]
+			sumPPV -= oldTP * oldTP * in.Volume[i-period]
+			sumV -= in.Volume[i-period]
+		}
+
+		if i >= period-1 {
+			fillVWAP(&bands, i, sumPV, sumPPV, sumV, k, tp)
+		}
+	}
+	return bands
+}
+
+// fillVWAP stores VWAP and ±k×σ bands at index i from running sums
+func fillVWAP(bands *VWAPBands, i int, sumPV, sumPPV, sumV, k, fallback float64) {
+	if sumV <= 0 {
+		bands.VWAP[i] = fallback
+		bands.Upper[i] = fallback
+		bands.Lower[i] = fallback
+		return
+	}
+
+	vwap := sumPV / sumV
+	variance := sumPPV/sumV - vwap*vwap
+	if variance < 0 {
+		variance = 0 // 浮点误差
+	}
+	dev := math.Sqrt(variance)
+
+	bands.VWAP[i] = vwap
+	bands.Upper[i] = vwap + k*dev
+	bands.Lower[i] = vwap - k*dev
+}
+
+// sameUTCDay reports whether bars i and j start on the same UTC calendar day
+func sameUTCDay(in Input, i, j int) bool {
+	yi, mi, di := in.Times[i].UTC().Date()
+	yj, mj, dj := in.Times[j].UTC().Date()
+	return yi == yj && mi == mj && di == dj
+}
+
+// CumulativeDelta builds the cumulative volume delta (CVD) series from per-bar deltas
+func CumulativeDelta(deltas []models.Delta) []float64 {
+	cvd := make([]float64, len(deltas))
+	var sum float64
+	for i, d := range deltas {
+		sum += d.Value
+		cvd[i] = sum
+	}
+	return cvd
+}
+
+// cumulativeSum returns the running sum of values
+func cumulativeSum(values []float64) []float64 {
+	out := make([]float64, len(values))
+	var sum float64
+	for i, v := range values {
+		sum += v
+		out[i] = sum
+	}
+	return out
+}
+
+func init() {
+	Register(Definition{
+		Name: "vwap", MinParams: 0, MaxParams: 1, Outputs: []string{"", "upper", "lower"},
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			bands := CalculateSessionVWAP(in, floatParam(params, 0, 1))
+			return map[string][]float64{"": bands.VWAP, "upper": bands.Upper, "lower": bands.Lower}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "rvwap", MinParams: 1, MaxParams: 2, Outputs: []string{"", "upper", "lower"},
+		IntParams: []int{0},
+		Lookback:  periodLookback(0, 20, 0),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 20)
+			if err != nil {
+				return nil, err
+			}
+			bands := CalculateRollingVWAP(in, period, floatParam(params, 1, 1))
+			return map[string][]float64{"": bands.VWAP, "upper": bands.Upper, "lower": bands.Lower}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "obv", MinParams: 0, MaxParams: 0, Outputs: []string{""},
+		Lookback: func(params []float64) int { return 1 },
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			return map[string][]float64{"": talib.Obv(in.Close, in.Volume)}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "mfi", MinParams: 1, MaxParams: 1, Outputs: []string{""},
+		IntParams: []int{0},
+		Lookback:  periodLookback(0, 14, 1),
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			period, err := intParam(params, 0, 14)
+			if err != nil {
+				return nil, err
+			}
+			return map[string][]float64{"": talib.Mfi(in.High, in.Low, in.Close, in.Volume, period)}, nil
+		},
+	})
+
+	Register(Definition{
+		Name: "cvd", MinParams: 0, MaxParams: 0, Outputs: []string{""},
+		Compute: func(in Input, params []float64) (map[string][]float64, error) {
+			// 没有 Delta 数据时不输出，避免背离检测和 Values 读到一条平线
+			if len(in.Delta) != in.Len() {
+				return nil, nil
+			}
+			return map[string][]float64{"": cumulativeSum(in.Delta)}, nil
+		},
+	})
+}
@@ -0,0 +1,309 @@
+package marketdata
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"vagues-go/src/models"
+)
+
+// ClosedBar is a closed candle built from trades together with its order flow delta
+type ClosedBar struct {
+	KLine models.KLine
+	Delta models.Delta
+}
+
+// CandleAggregator aggregates trades into candles in its own goroutine and emits each bar once it has closed
+// K线在 结束时间+迟到宽限 后收盘；宽限期内到达的迟到成交仍计入对应K线，之后到达的计数后丢弃
+// 无成交的K线以前一根收盘价输出（成交量为0），保证K线连续
+type CandleAggregator struct {
+	interval Interval
+	grace    time.Duration
+	tracker  *DeltaTracker
+
+	in    chan models.Trade
+	out   chan ClosedBar
+	gaps  chan tradeGap
+	syncs chan chan struct{}
+
+	// 以下状态只在 Run 所在的 goroutine 中访问
+	bars      map[time.Time]*candle // 尚未收盘的K线（开始时间 -> K线）
+	closedAt  time.Time             // 已输出K线的结束时间，早于此时间的成交视为迟到
+	lastClose float64               // 最近一根已输出K线的收盘价
+	started   time.Time             // 收到第一笔成交的时间，所在K线不完整时丢弃
+
+	late    atomic.Int64 // 超过宽限期被丢弃的迟到成交
+	dropped atomic.Int64 // 输入队列已满被丢弃的成交
+}
+
+// tradeGap is a period in which trades may have been missed
+type tradeGap struct {
+	from  time.Time
+	until time.Time
+}
+
+// candle is a bar being built with the times of its first and last trades
+type candle struct {
+	kline models.KLine
+	first time.Time
+	last  time.Time
+	ids   map[int64]struct{} // 已计入的成交ID
+}
+
+// NewCandleAggregator creates an aggregator for bars of the given interval
+// grace: 收盘前等待迟到成交的时间（<=0 时默认2秒）；tracker 为空时按 DELTA_LOOKBACK_TICKS 默认值新建
+func NewCandleAggregator(interval Interval, grace time.Duration, tracker *DeltaTracker) *CandleAggregator {
+	if grace <= 0 {
+		grace = 2 * time.Second
+	}
+	if tracker == nil {
+		tracker = NewDeltaTracker(interval, 0)
+	}
+	return &CandleAggregator{
+		interval: interval,
+		grace:    grace,
+		tracker:  tracker,
+		in:       make(chan models.Trade, 4096),
+		out:      make(chan ClosedBar, 16),
+		gaps:     make(chan tradeGap, 4),
+		syncs:    make(chan chan struct{}, 1),
+		bars:     make(map[time.Time]*candle),
+	}
+}
+
+// Add queues a trade without blocking the caller (WebSocket 读取协程)
+func (a *CandleAggregator) Add(trade models.Trade) {
+	select {
+	case a.in <- trade:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+// MarkGap reports that trades between from and until may be missing (WebSocket 断线重连后调用)
+// 与缺口重叠的K线不完整，不再输出，由消费方通过 REST 补齐
+func (a *CandleAggregator) MarkGap(from, until time.Time) {
+	select {
+	case a.gaps <- tradeGap{from: from, until: until}:
+	default:
+		log.Printf("⚠️  逐笔聚合: 缺口通知队列已满，丢弃 %s ~ %s", from.Format("15:04:05"), until.Format("15:04:05"))
+	}
+}
+
+// Bars returns the channel of closed bars; it is closed when Run returns
+func (a *CandleAggregator) Bars() <-chan ClosedBar {
+	return a.out
+}
+
+// Tracker returns the delta tracker fed by the aggregator
+func (a *CandleAggregator) Tracker() *DeltaTracker {
+	return a.tracker
+}
+
+// Stats returns the number of late trades and trades dropped because the queue was full
+func (a *CandleAggregator) Stats() (late, dropped int64) {
+	return a.late.Load(), a.dropped.Load()
+}
+
+// Run aggregates trades until ctx is done
+// ticks 驱动收盘检查（为空时使用每秒一次的真实时钟，回放时可传入模拟时钟）
+func (a *CandleAggregator) Run(ctx context.Context, ticks <-chan time.Time) {
+	defer close(a.out)
+
+	if ticks == nil {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case trade := <-a.in:
+			a.handle(trade)
+		case gap := <-a.gaps:
+			a.skipGap(gap)
+		case now := <-ticks:
+			if !a.flush(ctx, now) {
+				return
+			}
+		case reply := <-a.syncs:
+			// 先处理已排队的成交，再处理已到达的时钟
+			for drained := false; !drained; {
+				select {
+				case trade := <-a.in:
+					a.handle(trade)
+				default:
+					drained = true
+				}
+			}
+			select {
+			case now := <-ticks:
+				if !a.flush(ctx, now) {
+					return
+				}
+			default:
+			}
+			close(reply)
+		}
+	}
+}
+
+// Sync returns a channel closed once the trades queued so far and a pending tick have been processed
+// 用于回放：注入成交或推进时钟后等待聚合完成，再读取 Bars（调用方需在等待期间继续接收 Bars，避免输出阻塞）
+func (a *CandleAggregator) Sync() <-chan struct{} {
+	reply := make(chan struct{})
+	a.syncs <- reply
+	return reply
+}
+
+// handle adds a trade to its bar
+func (a *CandleAggregator) handle(trade models.Trade) {
+	if trade.Time.Before(a.closedAt) {
+		a.late.Add(1)
+		return
+	}
+	// Delta 统计由 tracker 自行去重；REST 回补已记入 tracker 的成交仍需计入K线，因此K线单独去重
+	a.tracker.Add(trade)
+
+	if a.started.IsZero() {
+		a.started = trade.Time
+	}
+
+	start := a.interval.Floor(trade.Time)
+	c, ok := a.bars[start]
+	if !ok {
+		c = &candle{
+			kline: models.KLine{
+				StartTime: start,
+				EndTime:   a.interval.Next(start),
+				Open:      trade.Price,
+				High:      trade.Price,
+				Low:       trade.Price,
+				Close:     trade.Price,
+			},
+			first: trade.Time,
+			last:  trade.Time,
+			ids:   make(map[int64]struct{}),
+		}
+		a.bars[start] = c
+	}
+	if _, dup := c.ids[trade.ID]; dup {
+		return
+	}
+	c.ids[trade.ID] = struct{}{}
+
+	// 宽限期内的迟到成交可能乱序到达：开盘取最早成交、收盘取最晚成交
+	bar := &c.kline
+	if trade.Time.Before(c.first) {
+		bar.Open = trade.Price
+		c.first = trade.Time
+	}
+	if !trade.Time.Before(c.last) {
+		bar.Close = trade.Price
+		c.last = trade.Time
+	}
+	if trade.Price > bar.High {
+		bar.High = trade.Price
+	}
+	if trade.Price < bar.Low {
+		bar.Low = trade.Price
+	}
+	bar.Volume += trade.Quantity
+	bar.QuoteVolume += trade.Price * trade.Quantity
+}
+
+// skipGap discards bars overlapping a gap; the bar containing gap.until is treated like a first bar
+func (a *CandleAggregator) skipGap(gap tradeGap) {
+	for start, c := range a.bars {
+		if c.kline.EndTime.After(gap.from) && start.Before(gap.until) {
+			delete(a.bars, start)
+		}
+	}
+
+	// 缺口内的K线不补平盘K线，缺口之后到达的早于重连时间的成交视为迟到
+	if floor := a.interval.Floor(gap.until); floor.After(a.closedAt) {
+		a.closedAt = floor
+	}
+	a.started = gap.until
+	log.Printf("逐笔聚合: 跳过断线缺口 %s ~ %s", gap.from.Format("15:04:05"), gap.until.Format("15:04:05"))
+}
+
+// flush emits every bar whose end time plus grace has passed; returns false when ctx is done
+func (a *CandleAggregator) flush(ctx context.Context, now time.Time) bool {
+	cutoff := now.Add(-a.grace)
+
+	starts := make([]time.Time, 0, len(a.bars))
+	for start, c := range a.bars {
+		if !c.kline.EndTime.After(cutoff) {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	for _, start := range starts {
+		// 之前没有成交的K线按前收盘价补齐
+		if !a.emitEmpty(ctx, start) {
+			return false
+		}
+		if !a.emit(ctx, a.bars[start].kline) {
+			return false
+		}
+		delete(a.bars, start)
+	}
+
+	// 整根K线都没有成交
+	if !a.closedAt.IsZero() {
+		if !a.emitEmpty(ctx, a.interval.Floor(cutoff)) {
+			return false
+		}
+	}
+	return true
+}
+
+// emitEmpty emits flat bars from the last closed bar up to (not including) until
+func (a *CandleAggregator) emitEmpty(ctx context.Context, until time.Time) bool {
+	if a.closedAt.IsZero() {
+		return true
+	}
+	for start := a.closedAt; start.Before(until); start = a.interval.Next(start) {
+		if _, pending := a.bars[start]; pending {
+			break
+		}
+		bar := models.KLine{
+			StartTime: start,
+			EndTime:   a.interval.Next(start),
+			Open:      a.lastClose,
+			High:      a.lastClose,
+			Low:       a.lastClose,
+			Close:     a.lastClose,
+		}
+		if !a.emit(ctx, bar) {
+			return false
+		}
+	}
+	return true
+}
+
+// emit sends a closed bar with its delta; the bar containing the first trade is dropped as incomplete
+func (a *CandleAggregator) emit(ctx context.Context, bar models.KLine) bool {
+	a.closedAt = bar.EndTime
+	a.lastClose = bar.Close
+
+	if a.started.After(bar.StartTime) && a.started.Before(bar.EndTime) {
+		log.Printf("逐笔聚合: 丢弃不完整的首根K线 %s", bar.StartTime.Format("2006-01-02 15:04:05"))
+		return true
+	}
+
+	delta, _ := a.tracker.BarDelta(bar.StartTime, bar.EndTime)
+	select {
+	case a.out <- ClosedBar{KLine: bar, Delta: delta}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,373 @@
+package marketdata
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/models"
+)
+
+// TradeFetcher fetches public trades over REST (implemented by *backpack.Client)
+type TradeFetcher interface {
+	GetRecentTrades(ctx context.Context, symbol string, limit int) ([]backpack.TradeResponse, error)
+	GetHistoricalTrades(ctx context.Context, symbol string, limit, offset int) ([]backpack.TradeResponse, error)
+}
+
+// DeltaTracker accumulates taker buy/sell volume from public trades per bar and over the last N trades
+// 成交按ID去重，可同时接收 WebSocket 推送和 REST 回补
+type DeltaTracker struct {
+	mu            sync.Mutex
+	interval      Interval
+	lookbackTicks int
+	keepBars      int
+
+	bars         map[time.Time]*models.Delta // K线开始时间 -> 累计 Delta
+	trades       []models.Trade              // 最近成交（按时间排序），用于逐笔窗口
+	seen         map[int64]time.Time         // 已处理的成交ID
+	latest       time.Time                   // 最新成交时间
+	coveredFrom  time.Time                   // 成交完整区间的开始时间
+	coveredUntil time.Time                   // 成交完整区间的结束时间
+}
+
+// NewDeltaTracker creates a tracker for bars of the given interval and a DELTA_LOOKBACK_TICKS window
+func NewDeltaTracker(interval Interval, lookbackTicks int) *DeltaTracker {
+	if lookbackTicks <= 0 {
+		lookbackTicks = 40
+	}
+	return &DeltaTracker{
+		interval:      interval,
+		lookbackTicks: lookbackTicks,
+		keepBars:      100,
+		bars:          make(map[time.Time]*models.Delta),
+		seen:          make(map[int64]time.Time),
+	}
+}
+
+// LookbackTicks returns the tick window size
+func (t *DeltaTracker) LookbackTicks() int {
+	return t.lookbackTicks
+}
+
+// Add records a trade; returns false for duplicates and trades older than the retention window
+func (t *DeltaTracker) Add(trade models.Trade) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.add(trade)
+}
+
+// AddAll records trades and returns how many were new
+func (t *DeltaTracker) AddAll(trades []models.Trade) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	added := 0
+	for _, trade := range trades {
+		if t.add(trade) {
+			added++
+		}
+	}
+	return added
+}
+
+// add records a trade (caller holds the lock)
+func (t *DeltaTracker) add(trade models.Trade) bool {
+	if _, dup := t.seen[trade.ID]; dup {
+		return false
+	}
+	if trade.Time.Before(t.horizon()) {
+		return false
+	}
+	t.seen[trade.ID] = trade.Time
+
+	// 按K线累计主动买卖量
+	start := t.interval.Floor(trade.Time)
+	bar, ok := t.bars[start]
+	if !ok {
+		bar = &models.Delta{}
+		t.bars[start] = bar
+	}
+	if trade.IsTakerBuy() {
+		bar.BuyVolume += trade.Quantity
+	} else {
+		bar.SellVolume += trade.Quantity
+	}
+	bar.Value = bar.BuyVolume - bar.SellVolume
+	bar.Trades++
+
+	// 按时间顺序插入（REST 回补的成交可能晚于 WebSocket 推送到达）
+	idx := sort.Search(len(t.trades), func(i int) bool {
+		return tradeAfter(t.trades[i], trade)
+	})
+	t.trades = append(t.trades, models.Trade{})
+	copy(t.trades[idx+1:], t.trades[idx:])
+	t.trades[idx] = trade
+
+	// 逐笔窗口只需保留最近的成交（超出两倍上限时再截断，避免每笔成交都复制）
+	if maxTrades := t.maxTrades(); len(t.trades) > 2*maxTrades {
+		t.trades = append([]models.Trade(nil), t.trades[len(t.trades)-maxTrades:]...)
+	}
+
+	// 只在进入新K线时清理过期数据（保留窗口按K线推进）
+	if trade.Time.After(t.latest) {
+		newBar := t.latest.IsZero() || t.interval.Floor(trade.Time).After(t.interval.Floor(t.latest))
+		t.latest = trade.Time
+		if newBar {
+			t.prune()
+		}
+	}
+	return true
+}
+
+// MarkCovered records that every trade between from and until has been received
+// （REST 回补完成，或 WebSocket 成交推送在此期间持续在线）；与现有区间相连时合并，否则替换
+func (t *DeltaTracker) MarkCovered(from, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.coveredFrom.IsZero() || from.After(t.coveredUntil) || until.Before(t.coveredFrom) {
+		t.coveredFrom, t.coveredUntil = from, until
+		return
+	}
+	if from.Before(t.coveredFrom) {
+		t.coveredFrom = from
+	}
+	if until.After(t.coveredUntil) {
+		t.coveredUntil = until
+	}
+}
+
+// Uncover drops coverage after from (WebSocket 断线期间的成交可能缺失，需重新回补)
+func (t *DeltaTracker) Uncover(from time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.coveredFrom.IsZero() || t.coveredUntil.Before(from) {
+		return
+	}
+	if !t.coveredFrom.Before(from) {
+		t.coveredFrom, t.coveredUntil = time.Time{}, time.Time{}
+		return
+	}
+	t.coveredUntil = from
+}
+
+// Covered reports whether every trade between from and until has been received
+func (t *DeltaTracker) Covered(from, until time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.covered(from, until)
+}
+
+// covered checks the coverage window (caller holds the lock)
+func (t *DeltaTracker) covered(from, until time.Time) bool {
+	return !t.coveredFrom.IsZero() && !t.coveredFrom.After(from) && !t.coveredUntil.Before(until)
+}
+
+// BarDelta returns the delta of the bar starting at start as of asOf, with TickValue over the last
+// lookbackTicks trades before the bar end; ok is false when trades for the bar are incomplete
+func (t *DeltaTracker) BarDelta(start, asOf time.Time) (models.Delta, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	start = t.interval.Floor(start)
+	end := t.interval.Next(start)
+	if asOf.Before(end) {
+		end = asOf // 未收盘的K线只要求覆盖到 asOf
+	}
+
+	var delta models.Delta
+	if bar, exists := t.bars[start]; exists {
+		delta = *bar
+	}
+	delta.TickValue, _ = t.tickDelta(end)
+
+	return delta, t.covered(start, end)
+}
+
+// BarDeltas returns the accumulated delta of each bar starting at starts
+// 未记录成交的K线（早于保留窗口或尚未订阅成交流）Delta 为0 且标记为 Estimated，由调用方按K线估算
+func (t *DeltaTracker) BarDeltas(starts []time.Time) []models.Delta {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	deltas := make([]models.Delta, len(starts))
+	for i, start := range starts {
+		if bar, exists := t.bars[t.interval.Floor(start)]; exists {
+			deltas[i] = *bar
+		} else {
+			deltas[i].Estimated = true
+		}
+	}
+	return deltas
+}
+
+// TickDelta returns the delta over the last lookbackTicks trades before until, and the number of trades used
+func (t *DeltaTracker) TickDelta(until time.Time) (float64, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tickDelta(until)
+}
+
+// tickDelta sums signed quantity of the last lookbackTicks trades before until (caller holds the lock)
+func (t *DeltaTracker) tickDelta(until time.Time) (float64, int) {
+	end := sort.Search(len(t.trades), func(i int) bool {
+		return !t.trades[i].Time.Before(until)
+	})
+	begin := end - t.lookbackTicks
+	if begin < 0 {
+		begin = 0
+	}
+
+	var sum float64
+	for _, trade := range t.trades[begin:end] {
+		sum += trade.SignedQuantity()
+	}
+	return sum, end - begin
+}
+
+// horizon returns the oldest trade time that is still tracked
+func (t *DeltaTracker) horizon() time.Time {
+	if t.latest.IsZero() {
+		return time.Time{}
+	}
+	oldest := t.interval.Floor(t.latest)
+	for i := 0; i < t.keepBars; i++ {
+		oldest = oldest.Add(-t.interval.Duration())
+	}
+	return oldest
+}
+
+// maxTrades returns how many recent trades are kept for the tick window
+func (t *DeltaTracker) maxTrades() int {
+	return t.lookbackTicks*10 + 1000
+}
+
+// prune drops bars, trades and trade IDs older than the retention window (caller holds the lock)
+// 每根新K线开始时调用一次，遍历成交ID的开销按K线均摊
+func (t *DeltaTracker) prune() {
+	horizon := t.horizon()
+	for start := range t.bars {
+		if start.Before(horizon) {
+			delete(t.bars, start)
+		}
+	}
+	for id, ts := range t.seen {
+		if ts.Before(horizon) {
+			delete(t.seen, id)
+		}
+	}
+
+	if maxTrades := t.maxTrades(); len(t.trades) > maxTrades {
+		t.trades = append([]models.Trade(nil), t.trades[len(t.trades)-maxTrades:]...)
+	}
+	if !t.coveredFrom.IsZero() && t.coveredFrom.Before(horizon) {
+		t.coveredFrom = horizon
+	}
+}
+
+// tradeAfter orders trades by time, then by ID
+func tradeAfter(a, b models.Trade) bool {
+	if a.Time.Equal(b.Time) {
+		return a.ID > b.ID
+	}
+	return a.Time.After(b.Time)
+}
+
+// Backfill fetches trades over REST back to from and records them in the tracker
+// 先取最近成交，不足时通过 /api/v1/trades/history 按偏移分页（最多 maxPages 页）；requestedAt 为发起请求的时间，之前的成交视为完整
+func (t *DeltaTracker) Backfill(ctx context.Context, fetcher TradeFetcher, symbol string, from, requestedAt time.Time, maxPages int) error {
+	resp, err := fetcher.GetRecentTrades(ctx, symbol, backpack.MaxTradesLimit)
+	if err != nil {
+		return fmt.Errorf("获取最近成交失败: %w", err)
+	}
+
+	for page := 0; ; page++ {
+		trades, err := TradesFromREST(symbol, resp)
+		if err != nil {
+			return err
+		}
+		t.AddAll(trades)
+
+		// 不足一页说明已取到全部成交；最早成交早于 from 说明已覆盖
+		if len(trades) < backpack.MaxTradesLimit || oldestTrade(trades).Before(from) {
+			t.MarkCovered(from, requestedAt)
+			return nil
+		}
+		if page+1 >= maxPages {
+			return fmt.Errorf("回补 %d 页后仍未覆盖到 %s", maxPages, from.Format("2006-01-02 15:04:05"))
+		}
+
+		resp, err = fetcher.GetHistoricalTrades(ctx, symbol, backpack.MaxTradesLimit, (page+1)*backpack.MaxTradesLimit)
+		if err != nil {
+			return fmt.Errorf("获取历史成交失败: %w", err)
+		}
+	}
+}
+
+// oldestTrade returns the earliest trade time in trades
+func oldestTrade(trades []models.Trade) time.Time {
+	var oldest time.Time
+	for _, trade := range trades {
+		if oldest.IsZero() || trade.Time.Before(oldest) {
+			oldest = trade.Time
+		}
+	}
+	return oldest
+}
+
+// TradesFromREST converts REST trades to models.Trade
+func TradesFromREST(symbol string, resp []backpack.TradeResponse) ([]models.Trade, error) {
+	trades := make([]models.Trade, 0, len(resp))
+	for _, r := range resp {
+		price, err := strconv.ParseFloat(r.Price, 64)
+		if err != nil {
+			return nil, fmt.Errorf("解析成交价失败: %w (成交ID: %d)", err, r.ID)
+		}
+		quantity, err := strconv.ParseFloat(r.Quantity, 64)
+		if err != nil {
+			return nil, fmt.Errorf("解析成交数量失败: %w (成交ID: %d)", err, r.ID)
+		}
+		trades = append(trades, models.Trade{
+			ID:           r.ID,
+			Symbol:       symbol,
+			Price:        price,
+			Quantity:     quantity,
+			Time:         timestampToTime(r.Timestamp),
+			BuyerIsMaker: r.IsBuyerMaker,
+		})
+	}
+	return trades, nil
+}
+
+// TradeFromWS converts a trade stream message to models.Trade
+func TradeFromWS(msg backpack.WSTradeMessage) (models.Trade, error) {
+	price, err := strconv.ParseFloat(msg.Price, 64)
+	if err != nil {
+		return models.Trade{}, fmt.Errorf("解析成交价失败: %w (成交ID: %d)", err, msg.TradeID)
+	}
+	quantity, err := strconv.ParseFloat(msg.Quantity, 64)
+	if err != nil {
+		return models.Trade{}, fmt.Errorf("解析成交数量失败: %w (成交ID: %d)", err, msg.TradeID)
+	}
+	return models.Trade{
+		ID:           msg.TradeID,
+		Symbol:       msg.Symbol,
+		Price:        price,
+		Quantity:     quantity,
+		Time:         timestampToTime(msg.TradeTime),
+		BuyerIsMaker: msg.BuyerIsMaker,
+	}, nil
+}
+
+// timestampToTime converts a millisecond or microsecond timestamp to time.Time
+func timestampToTime(ts int64) time.Time {
+	if ts > 1e14 {
+		return time.UnixMicro(ts).UTC()
+	}
+	return time.UnixMilli(ts).UTC()
+}
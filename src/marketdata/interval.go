@@ -0,0 +1,186 @@
+package marketdata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntervalUnit is the base unit of a K-line interval
+type IntervalUnit string
+
+const (
+	UnitMinute IntervalUnit = "m"
+	UnitHour   IntervalUnit = "h"
+	UnitDay    IntervalUnit = "d"
+	UnitWeek   IntervalUnit = "w"
+	UnitMonth  IntervalUnit = "month"
+)
+
+// ExchangeIntervals are the K-line intervals served natively by /api/v1/klines
+var ExchangeIntervals = []string{"1m", "3m", "5m", "15m", "30m", "1h", "2h", "4h", "6h", "8h", "12h", "1d", "3d", "1w", "1month"}
+
+// Interval is a K-line interval such as 10m, 2h, 1w or 1month
+type Interval struct {
+	Count int
+	Unit  IntervalUnit
+}
+
+// ParseInterval parses an interval string: <n>m, <n>h, <n>d, <n>w or <n>month
+func ParseInterval(text string) (Interval, error) {
+	text = strings.TrimSpace(text)
+
+	var unit IntervalUnit
+	var number string
+	switch {
+	case strings.HasSuffix(text, string(UnitMonth)):
+		unit, number = UnitMonth, strings.TrimSuffix(text, string(UnitMonth))
+	case strings.HasSuffix(text, "M"):
+		unit, number = UnitMonth, strings.TrimSuffix(text, "M")
+	case strings.HasSuffix(text, string(UnitMinute)):
+		unit, number = UnitMinute, strings.TrimSuffix(text, string(UnitMinute))
+	case strings.HasSuffix(text, string(UnitHour)):
+		unit, number = UnitHour, strings.TrimSuffix(text, string(UnitHour))
+	case strings.HasSuffix(text, string(UnitDay)):
+		unit, number = UnitDay, strings.TrimSuffix(text, string(UnitDay))
+	case strings.HasSuffix(text, string(UnitWeek)):
+		unit, number = UnitWeek, strings.TrimSuffix(text, string(UnitWeek))
+	default:
+		return Interval{}, fmt.Errorf("无效的K线周期: %q", text)
+	}
+
+	count, err := strconv.Atoi(number)
+	if err != nil || count <= 0 {
+		return Interval{}, fmt.Errorf("无效的K线周期: %q", text)
+	}
+	return Interval{Count: count, Unit: unit}, nil
+}
+
+// MustParseInterval parses an interval and panics on error (for constants)
+func MustParseInterval(text string) Interval {
+	iv, err := ParseInterval(text)
+	if err != nil {
+		panic(err)
+	}
+	return iv
+}
+
+// String returns the interval in exchange notation (e.g. "10m", "1month")
+func (iv Interval) String() string {
+	return strconv.Itoa(iv.Count) + string(iv.Unit)
+}
+
+// Duration returns the interval length; months are approximated as 30 days
+func (iv Interval) Duration() time.Duration {
+	switch iv.Unit {
+	case UnitMinute:
+		return time.Duration(iv.Count) * time.Minute
+	case UnitHour:
+		return time.Duration(iv.Count) * time.Hour
+	case UnitDay:
+		return time.Duration(iv.Count) * 24 * time.Hour
+	case UnitWeek:
+		return time.Duration(iv.Count) * 7 * 24 * time.Hour
+	case UnitMonth:
+		return time.Duration(iv.Count) * 30 * 24 * time.Hour
+	}
+	return 0
+}
+
+// IsExchangeInterval reports whether the interval is served natively by the exchange
+func (iv Interval) IsExchangeInterval() bool {
+	s := iv.String()
+	for _, native := range ExchangeIntervals {
+		if s == native {
+			return true
+		}
+	}
+	return false
+}
+
+// weekEpoch is a Monday 00:00 UTC used to align weekly bars (1970-01-05)
+var weekEpoch = time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// Floor returns the UTC start of the bar containing t
+// 分钟/小时/天按 Unix 纪元对齐（UTC 零点）；周线从周一 00:00 UTC 开始；月线从每月1日开始
+func (iv Interval) Floor(t time.Time) time.Time {
+	t = t.UTC()
+	switch iv.Unit {
+	case UnitWeek:
+		d := iv.Duration()
+		offset := t.Sub(weekEpoch)
+		floored := offset - offset%d
+		if offset < 0 && offset%d != 0 {
+			floored -= d
+		}
+		return weekEpoch.Add(floored)
+	case UnitMonth:
+		months := t.Year()*12 + int(t.Month()) - 1
+		months -= months % iv.Count
+		return time.Date(months/12, time.Month(months%12+1), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		// time.Truncate 按 Go 零时间（0001-01-01）对齐，3d 等周期与 Unix 纪元不一致，需按 Unix 秒计算
+		secs := int64(iv.Duration() / time.Second)
+		unix := t.Unix()
+		floored := unix - unix%secs
+		if unix < 0 && unix%secs != 0 {
+			floored -= secs
+		}
+		return time.Unix(floored, 0).UTC()
+	}
+}
+
+// Next returns the start of the bar following the one that starts at start
+func (iv Interval) Next(start time.Time) time.Time {
+	if iv.Unit == UnitMonth {
+		return start.AddDate(0, iv.Count, 0)
+	}
+	return start.Add(iv.Duration())
+}
+
+// MultipleOf reports whether every bar of iv is made of whole bars of base
+func (iv Interval) MultipleOf(base Interval) bool {
+	if base.Duration() <= 0 {
+		return false
+	}
+	if iv.Unit == UnitMonth {
+		// 月线由整天组成，基础周期需能整除一天
+		if base.Unit == UnitMonth {
+			return iv.Count%base.Count == 0
+		}
+		return base.Unit != UnitWeek && (24*time.Hour)%base.Duration() == 0
+	}
+	if base.Unit == UnitMonth {
+		return false
+	}
+	if iv.Duration()%base.Duration() != 0 {
+		return false
+	}
+	// 基础周期的边界必须同时是目标周期的边界（如 7m 不能组成 10m）
+	if iv.Unit == UnitWeek {
+		return base.Unit == UnitWeek || (24*time.Hour)%base.Duration() == 0
+	}
+	return true
+}
+
+// SourceInterval returns the largest exchange interval that iv can be resampled from
+func (iv Interval) SourceInterval() Interval {
+	best := MustParseInterval("1m")
+	for _, native := range ExchangeIntervals {
+		candidate := MustParseInterval(native)
+		if iv.MultipleOf(candidate) && candidate.Duration() > best.Duration() {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// IntervalDuration returns the duration of an interval string, or fallback if it cannot be parsed
+func IntervalDuration(text string, fallback time.Duration) time.Duration {
+	iv, err := ParseInterval(text)
+	if err != nil {
+		return fallback
+	}
+	return iv.Duration()
+}
@@ -0,0 +1,169 @@
+package marketdata
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/models"
+)
+
+// KlineCloser turns streamed in-progress K-line updates into closed bars of the target interval
+// 推送的是同一根K线的多次更新：出现更新的K线、推送标记已收盘、或超过 结束时间+宽限 后才视为收盘；
+// 目标周期不是交易所原生周期时订阅 SourceInterval 并重采样
+type KlineCloser struct {
+	mu        sync.Mutex
+	resampler *Resampler
+	grace     time.Duration
+	pending   models.KLine // 当前未收盘的基础K线
+	open      bool
+	closedAt  time.Time // 已收盘基础K线的结束时间，早于此时间的推送视为迟到
+	out       chan models.KLine
+}
+
+// NewKlineCloser creates a closer emitting bars of the target interval
+// grace: 无新推送时，基础K线结束后等待的时间（<=0 时默认2秒）
+func NewKlineCloser(target Interval, grace time.Duration) *KlineCloser {
+	if grace <= 0 {
+		grace = 2 * time.Second
+	}
+	return &KlineCloser{
+		resampler: NewResampler(target),
+		grace:     grace,
+		out:       make(chan models.KLine, 64),
+	}
+}
+
+// StreamInterval returns the native interval to subscribe to
+func (c *KlineCloser) StreamInterval() Interval {
+	return c.resampler.Interval().SourceInterval()
+}
+
+// Bars returns the channel of closed target-interval bars
+func (c *KlineCloser) Bars() <-chan models.KLine {
+	return c.out
+}
+
+// Update applies a streamed K-line; closed marks a final update for that bar (推送中的 X 字段)
+func (c *KlineCloser) Update(k models.KLine, closed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if k.StartTime.Before(c.closedAt) {
+		return // 已收盘K线的迟到推送
+	}
+	if c.open && k.StartTime.After(c.pending.StartTime) {
+		c.closeBase()
+	}
+	c.pending, c.open = k, true
+	if closed {
+		c.closeBase()
+	}
+}
+
+// Flush closes the pending bar once its end time plus grace has passed (由收盘定时器调用)
+func (c *KlineCloser) Flush(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.open && !c.pending.EndTime.Add(c.grace).After(now) {
+		c.closeBase()
+	}
+}
+
+// closeBase closes the pending base bar and emits the target bars it completes (caller holds the lock)
+func (c *KlineCloser) closeBase() {
+	c.open = false
+	c.closedAt = c.pending.EndTime
+	for _, bar := range c.resampler.Add(c.pending) {
+		select {
+		case c.out <- bar:
+		default:
+			log.Printf("⚠️  K线推送队列已满，丢弃 %s 收盘K线", bar.StartTime.Format("2006-01-02 15:04:05"))
+		}
+	}
+}
+
+// klineTimeFormats K线推送的时间格式（ISO 8601，无时区时按 UTC）
+var klineTimeFormats = []string{
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+}
+
+// KLineFromWS converts a K-line stream message to models.KLine (推送不含成交额，QuoteVolume 为0)
+func KLineFromWS(msg backpack.WSKlineMessage) (models.KLine, error) {
+	start, err := parseKlineTime(msg.Start)
+	if err != nil {
+		return models.KLine{}, fmt.Errorf("解析开始时间失败: %w", err)
+	}
+	end, err := parseKlineTime(msg.End)
+	if err != nil {
+		return models.KLine{}, fmt.Errorf("解析结束时间失败: %w", err)
+	}
+
+	values := make([]float64, 5)
+	for i, field := range []string{msg.Open, msg.High, msg.Low, msg.Close, msg.Volume} {
+		if values[i], err = strconv.ParseFloat(field, 64); err != nil {
+			return models.KLine{}, fmt.Errorf("解析K线数值失败: %w (%s %s)", err, msg.Symbol, msg.Start)
+		}
+	}
+
+	return models.KLine{
+		StartTime: start,
+		EndTime:   end,
+		Open:      values[0],
+		High:      values[1],
+		Low:       values[2],
+		Close:     values[3],
+		Volume:    values[4],
+	}, nil
+}
+
+// KLineFromREST converts a REST K-line to models.KLine
+func KLineFromREST(resp backpack.KlineResponse) (models.KLine, error) {
+	start, err := parseKlineTime(resp.Start)
+	if err != nil {
+		return models.KLine{}, fmt.Errorf("解析开始时间失败: %w", err)
+	}
+	end, err := parseKlineTime(resp.End)
+	if err != nil {
+		return models.KLine{}, fmt.Errorf("解析结束时间失败: %w", err)
+	}
+
+	values := make([]float64, 6)
+	for i, field := range []string{resp.Open, resp.High, resp.Low, resp.Close, resp.Volume, resp.QuoteVolume} {
+		if field == "" && i == 5 {
+			continue // 部分数据源不含成交额
+		}
+		if values[i], err = strconv.ParseFloat(field, 64); err != nil {
+			return models.KLine{}, fmt.Errorf("解析K线数值失败: %w (%s)", err, resp.Start)
+		}
+	}
+
+	return models.KLine{
+		StartTime:   start,
+		EndTime:     end,
+		Open:        values[0],
+		High:        values[1],
+		Low:         values[2],
+		Close:       values[3],
+		Volume:      values[4],
+		QuoteVolume: values[5],
+	}, nil
+}
+
+// parseKlineTime parses a K-line start/end time in UTC
+func parseKlineTime(value string) (time.Time, error) {
+	var err error
+	for _, format := range klineTimeFormats {
+		var t time.Time
+		if t, err = time.Parse(format, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w (时间字符串: %s)", err, value)
+}
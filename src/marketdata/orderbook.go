@@ -0,0 +1,422 @@
+package marketdata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/models"
+)
+
+// ErrSequenceGap 增量深度的更新ID不连续，订单簿需要重新获取快照
+var ErrSequenceGap = errors.New("深度更新ID不连续")
+
+// ErrBookNotSynced 订单簿尚未应用快照
+var ErrBookNotSynced = errors.New("订单簿未同步")
+
+// Level is a price level of the order book
+type Level struct {
+	Price    float64
+	Quantity float64
+}
+
+// BookSide selects the bid or ask side of the order book
+type BookSide int
+
+const (
+	SideBid BookSide = iota // 买单
+	SideAsk                 // 卖单
+)
+
+// DepthSnapshot is a full order book snapshot from REST
+type DepthSnapshot struct {
+	LastUpdateID int64
+	Bids         []Level
+	Asks         []Level
+	Time         time.Time
+}
+
+// DepthUpdate is an incremental depth update; quantities are absolute and 0 removes the level
+type DepthUpdate struct {
+	FirstUpdateID int64
+	LastUpdateID  int64
+	Bids          []Level
+	Asks          []Level
+	Time          time.Time
+}
+
+// OrderBook is a local L2 order book maintained from a snapshot plus sequential diffs
+// 增量更新的 FirstUpdateID 必须等于上一条的 LastUpdateID+1，否则订单簿失效，需重新获取快照
+type OrderBook struct {
+	mu           sync.RWMutex
+	symbol       string
+	bids         []Level // 价格降序
+	asks         []Level // 价格升序
+	lastUpdateID int64
+	synced       bool
+	updatedAt    time.Time
+}
+
+// NewOrderBook creates an empty, unsynced order book
+func NewOrderBook(symbol string) *OrderBook {
+	return &OrderBook{symbol: symbol}
+}
+
+// Symbol returns the trading pair of the book
+func (b *OrderBook) Symbol() string {
+	return b.symbol
+}
+
+// Reset replaces the book with a snapshot and marks it synced
+func (b *OrderBook) Reset(snapshot DepthSnapshot) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bids = b.bids[:0]
+	b.asks = b.asks[:0]
+	for _, level := range snapshot.Bids {
+		b.bids = upsertLevel(b.bids, level, true)
+	}
+	for _, level := range snapshot.Asks {
+		b.asks = upsertLevel(b.asks, level, false)
+	}
+	b.lastUpdateID = snapshot.LastUpdateID
+	b.updatedAt = snapshot.Time
+	b.synced = true
+}
+
+// Apply applies an incremental update
+// 早于快照的更新被忽略；更新ID不连续时订单簿失效并返回 ErrSequenceGap
+func (b *OrderBook) Apply(update DepthUpdate) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.synced {
+		return ErrBookNotSynced
+	}
+	if update.LastUpdateID <= b.lastUpdateID {
+		return nil // 已包含在快照或之前的更新中
+	}
+	// 快照后的第一条更新可能跨越快照ID（U <= last+1 <= u），之后应严格连续
+	if update.FirstUpdateID > b.lastUpdateID+1 {
+		b.synced = false
+		return fmt.Errorf("%w: %s 期望 %d，收到 %d~%d", ErrSequenceGap, b.symbol, b.lastUpdateID+1, update.FirstUpdateID, update.LastUpdateID)
+	}
+
+	for _, level := range update.Bids {
+		b.bids = upsertLevel(b.bids, level, true)
+	}
+	for _, level := range update.Asks {
+		b.asks = upsertLevel(b.asks, level, false)
+	}
+	b.lastUpdateID = update.LastUpdateID
+	b.updatedAt = update.Time
+	return nil
+}
+
+// Invalidate marks the book unsynced (e.g. after a WebSocket reconnect)
+func (b *OrderBook) Invalidate() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.synced = false
+}
+
+// Synced reports whether the book reflects a snapshot plus all later updates
+func (b *OrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// LastUpdateID returns the ID of the last applied update
+func (b *OrderBook) LastUpdateID() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastUpdateID
+}
+
+// UpdatedAt returns the engine time of the last applied snapshot or update
+func (b *OrderBook) UpdatedAt() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.updatedAt
+}
+
+// BestBid returns the highest bid
+func (b *OrderBook) BestBid() (Level, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids) == 0 {
+		return Level{}, false
+	}
+	return b.bids[0], true
+}
+
+// BestAsk returns the lowest ask
+func (b *OrderBook) BestAsk() (Level, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.asks) == 0 {
+		return Level{}, false
+	}
+	return b.asks[0], true
+}
+
+// Mid returns the mid price between the best bid and ask
+func (b *OrderBook) Mid() (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.mid()
+}
+
+// Spread returns the best ask minus the best bid
+func (b *OrderBook) Spread() (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids) == 0 || len(b.asks) == 0 {
+		return 0, false
+	}
+	return b.asks[0].Price - b.bids[0].Price, true
+}
+
+// Levels returns up to n levels of a side, best first (n<=0 returns all)
+func (b *OrderBook) Levels(side BookSide, n int) []Level {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	levels := b.bids
+	if side == SideAsk {
+		levels = b.asks
+	}
+	if n > 0 && n < len(levels) {
+		levels = levels[:n]
+	}
+	return append([]Level(nil), levels...)
+}
+
+// DepthWithin returns the bid and ask quantity resting within bps basis points of the mid price
+func (b *OrderBook) DepthWithin(bps float64) (bidQty, askQty float64) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.depthWithin(bps)
+}
+
+// Imbalance returns (bid-ask)/(bid+ask) of the quantity within bps of the mid price, in [-1, 1]
+// 为正表示买单更厚（买方支撑强），为负表示卖单更厚
+func (b *OrderBook) Imbalance(bps float64) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bidQty, askQty := b.depthWithin(bps)
+	return imbalance(bidQty, askQty)
+}
+
+// Stats summarizes the book for strategies; false when the book is unsynced or one side is empty
+func (b *OrderBook) Stats(depthBps float64) (models.BookStats, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	mid, ok := b.mid()
+	if !b.synced || !ok {
+		return models.BookStats{}, false
+	}
+	bidQty, askQty := b.depthWithin(depthBps)
+	spread := b.asks[0].Price - b.bids[0].Price
+	return models.BookStats{
+		BestBid:   b.bids[0].Price,
+		BestAsk:   b.asks[0].Price,
+		Mid:       mid,
+		Spread:    spread,
+		SpreadBps: spread / mid * 10000,
+		DepthBps:  depthBps,
+		BidDepth:  bidQty,
+		AskDepth:  askQty,
+		Imbalance: imbalance(bidQty, askQty),
+		UpdateID:  b.lastUpdateID,
+		Time:      b.updatedAt,
+	}, true
+}
+
+// mid returns the mid price (caller holds the lock)
+func (b *OrderBook) mid() (float64, bool) {
+	if len(b.bids) == 0 || len(b.asks) == 0 {
+		return 0, false
+	}
+	return (b.bids[0].Price + b.asks[0].Price) / 2, true
+}
+
+// depthWithin sums the quantity within bps of the mid price (caller holds the lock)
+func (b *OrderBook) depthWithin(bps float64) (bidQty, askQty float64) {
+	mid, ok := b.mid()
+	if !ok {
+		return 0, 0
+	}
+	bidFloor := mid * (1 - bps/10000)
+	askCeil := mid * (1 + bps/10000)
+	for _, level := range b.bids {
+		if level.Price < bidFloor {
+			break
+		}
+		bidQty += level.Quantity
+	}
+	for _, level := range b.asks {
+		if level.Price > askCeil {
+			break
+		}
+		askQty += level.Quantity
+	}
+	return bidQty, askQty
+}
+
+// imbalance returns (bid-ask)/(bid+ask), 0 when both are empty
+func imbalance(bidQty, askQty float64) float64 {
+	if bidQty+askQty == 0 {
+		return 0
+	}
+	return (bidQty - askQty) / (bidQty + askQty)
+}
+
+// upsertLevel sets, inserts or removes (quantity 0) a level in a sorted side
+// descending 为 true 时按价格降序（买单）
+func upsertLevel(levels []Level, level Level, descending bool) []Level {
+	i := sort.Search(len(levels), func(i int) bool {
+		if descending {
+			return levels[i].Price <= level.Price
+		}
+		return levels[i].Price >= level.Price
+	})
+
+	exists := i < len(levels) && levels[i].Price == level.Price
+	switch {
+	case level.Quantity <= 0 && exists:
+		return append(levels[:i], levels[i+1:]...)
+	case level.Quantity <= 0:
+		return levels
+	case exists:
+		levels[i].Quantity = level.Quantity
+		return levels
+	default:
+		levels = append(levels, Level{})
+		copy(levels[i+1:], levels[i:])
+		levels[i] = level
+		return levels
+	}
+}
+
+// DepthSnapshotFromREST converts a REST depth snapshot
+func DepthSnapshotFromREST(resp *backpack.DepthResponse) (DepthSnapshot, error) {
+	lastUpdateID, err := strconv.ParseInt(resp.LastUpdateID, 10, 64)
+	if err != nil {
+		return DepthSnapshot{}, fmt.Errorf("解析深度快照更新ID失败: %w", err)
+	}
+	bids, err := parseLevels(resp.Bids)
+	if err != nil {
+		return DepthSnapshot{}, err
+	}
+	asks, err := parseLevels(resp.Asks)
+	if err != nil {
+		return DepthSnapshot{}, err
+	}
+	return DepthSnapshot{
+		LastUpdateID: lastUpdateID,
+		Bids:         bids,
+		Asks:         asks,
+		Time:         time.UnixMicro(resp.Timestamp),
+	}, nil
+}
+
+// DepthUpdateFromWS converts a depth stream message
+func DepthUpdateFromWS(msg backpack.WSDepthMessage) (DepthUpdate, error) {
+	bids, err := parseLevels(msg.Bids)
+	if err != nil {
+		return DepthUpdate{}, err
+	}
+	asks, err := parseLevels(msg.Asks)
+	if err != nil {
+		return DepthUpdate{}, err
+	}
+	return DepthUpdate{
+		FirstUpdateID: msg.FirstUpdateID,
+		LastUpdateID:  msg.LastUpdateID,
+		Bids:          bids,
+		Asks:          asks,
+		Time:          time.UnixMicro(msg.EngineTime),
+	}, nil
+}
+
+// parseLevels parses [price, quantity] string pairs
+func parseLevels(raw [][]string) ([]Level, error) {
+	levels := make([]Level, 0, len(raw))
+	for _, pair := range raw {
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("无效的深度档位: %v", pair)
+		}
+		price, err := strconv.ParseFloat(pair[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("解析深度价格失败: %w", err)
+		}
+		quantity, err := strconv.ParseFloat(pair[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("解析深度数量失败: %w", err)
+		}
+		levels = append(levels, Level{Price: price, Quantity: quantity})
+	}
+	return levels, nil
+}
+
+// FillEstimate is the expected result of a market order walking the book
+type FillEstimate struct {
+	Quantity    float64 // 下单数量
+	Filled      float64 // 订单簿可成交数量（深度不足时小于下单数量）
+	AvgPrice    float64 // 预计成交均价
+	WorstPrice  float64 // 吃到的最差价位
+	Mid         float64 // 下单前的中间价
+	SlippageBps float64 // 成交均价相对中间价的不利滑点（基点）
+}
+
+// Complete reports whether the book can fill the whole quantity
+func (e FillEstimate) Complete() bool {
+	return e.Filled >= e.Quantity
+}
+
+// EstimateFill walks the book for a market order of quantity; buy 为 true 时吃卖单，否则吃买单
+func (b *OrderBook) EstimateFill(buy bool, quantity float64) (FillEstimate, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	mid, ok := b.mid()
+	if !ok || quantity <= 0 {
+		return FillEstimate{}, false
+	}
+
+	levels := b.bids
+	if buy {
+		levels = b.asks
+	}
+	estimate := FillEstimate{Quantity: quantity, Mid: mid}
+	var notional float64
+	for _, level := range levels {
+		take := math.Min(level.Quantity, quantity-estimate.Filled)
+		notional += take * level.Price
+		estimate.Filled += take
+		estimate.WorstPrice = level.Price
+		if estimate.Filled >= quantity {
+			break
+		}
+	}
+	if estimate.Filled == 0 {
+		return estimate, false
+	}
+
+	estimate.AvgPrice = notional / estimate.Filled
+	if buy {
+		estimate.SlippageBps = (estimate.AvgPrice - mid) / mid * 10000
+	} else {
+		estimate.SlippageBps = (mid - estimate.AvgPrice) / mid * 10000
+	}
+	return estimate, true
+}
@@ -0,0 +1,208 @@
+package marketdata
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/clock"
+)
+
+// DepthFetcher fetches order book snapshots over REST (implemented by *backpack.Client)
+type DepthFetcher interface {
+	GetDepth(ctx context.Context, symbol string, limit int) (*backpack.DepthResponse, error)
+}
+
+// 订单簿同步参数
+const (
+	bookSnapshotLimit  = 1000 // 快照档位数量
+	bookBufferLimit    = 2000 // 同步期间最多缓存的增量更新
+	bookResyncDelay    = 500 * time.Millisecond
+	bookMaxResyncDelay = 10 * time.Second
+)
+
+// BookSync keeps an OrderBook in sync from the WebSocket depth stream and REST snapshots
+// 先订阅增量并缓存，再获取快照并回放快照之后的更新；发现更新ID不连续或断线重连时重新获取快照
+type BookSync struct {
+	book    *OrderBook
+	fetcher DepthFetcher
+
+	mu        sync.Mutex
+	ctx       context.Context
+	buffer    []DepthUpdate // 快照到达前缓存的增量更新
+	resyncing bool
+	delay     time.Duration // 快照失败后下次重试的等待时间
+
+	clock     clock.Clock    // 重试等待使用的时钟
+	attempts  sync.WaitGroup // 进行中的快照请求（见 Wait）
+	snapshots atomic.Int64   // 获取快照次数（含首次）
+}
+
+// NewBookSync creates a syncer for symbol's order book
+func NewBookSync(symbol string, fetcher DepthFetcher) *BookSync {
+	return &BookSync{
+		book:    NewOrderBook(symbol),
+		fetcher: fetcher,
+		ctx:     context.Background(),
+		clock:   clock.Real,
+	}
+}
+
+// SetClock sets the clock used to wait between snapshot retries (回放时使用模拟时钟)
+func (s *BookSync) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// Wait blocks until no snapshot request is in flight (回放同步用，重试前的等待不计入)
+func (s *BookSync) Wait() {
+	s.attempts.Wait()
+}
+
+// Book returns the synced order book (读取前应检查 Synced)
+func (s *BookSync) Book() *OrderBook {
+	return s.book
+}
+
+// Snapshots returns how many REST snapshots have been fetched
+func (s *BookSync) Snapshots() int64 {
+	return s.snapshots.Load()
+}
+
+// Start subscribes to the depth stream and fetches the initial snapshot
+// aggregation: 空为实时推送，或 200ms、600ms、1000ms 聚合推送
+func (s *BookSync) Start(ctx context.Context, ws *backpack.WSClient, aggregation string) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	ws.OnGap(func(backpack.WSGap) {
+		s.Invalidate("WebSocket 断线重连")
+	})
+	if err := ws.SubscribeDepth(s.book.Symbol(), aggregation, s.Handle); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.startResync()
+	s.mu.Unlock()
+	return nil
+}
+
+// Handle applies a depth stream message (在 WebSocket 协程中调用)
+func (s *BookSync) Handle(msg backpack.WSDepthMessage) {
+	update, err := DepthUpdateFromWS(msg)
+	if err != nil {
+		log.Printf("⚠️  %s %v", s.book.Symbol(), err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.resyncing {
+		s.bufferUpdate(update)
+		return
+	}
+	if err := s.book.Apply(update); err != nil {
+		if errors.Is(err, ErrSequenceGap) {
+			log.Printf("⚠️  %v，重新获取订单簿快照", err)
+		}
+		s.bufferUpdate(update)
+		s.startResync()
+	}
+}
+
+// Invalidate discards the book and fetches a new snapshot
+func (s *BookSync) Invalidate(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.book.Invalidate()
+	if !s.resyncing {
+		log.Printf("⚠️  %s 订单簿失效（%s），重新获取快照", s.book.Symbol(), reason)
+		s.buffer = nil
+		s.startResync()
+	}
+}
+
+// bufferUpdate caches an update until the snapshot arrives (caller holds the lock)
+func (s *BookSync) bufferUpdate(update DepthUpdate) {
+	if len(s.buffer) >= bookBufferLimit {
+		s.buffer = s.buffer[1:]
+	}
+	s.buffer = append(s.buffer, update)
+}
+
+// startResync starts fetching a snapshot unless one is in progress (caller holds the lock)
+func (s *BookSync) startResync() {
+	if s.resyncing {
+		return
+	}
+	s.resyncing = true
+	s.delay = bookResyncDelay
+	s.attempts.Add(1)
+	go s.resync(s.ctx)
+}
+
+// resync fetches a snapshot and replays the buffered updates; on failure retries later with backoff
+func (s *BookSync) resync(ctx context.Context) {
+	defer s.attempts.Done()
+
+	if ctx.Err() == nil && s.trySnapshot(ctx) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ctx.Err() != nil {
+		s.resyncing = false
+		return
+	}
+	delay := s.delay
+	s.delay = min(s.delay*2, bookMaxResyncDelay)
+	s.clock.AfterFunc(delay, func() {
+		s.attempts.Add(1)
+		go s.resync(ctx)
+	})
+}
+
+// trySnapshot fetches a snapshot and replays the buffered updates after it; false means retry
+func (s *BookSync) trySnapshot(ctx context.Context) bool {
+	symbol := s.book.Symbol()
+	resp, err := s.fetcher.GetDepth(ctx, symbol, bookSnapshotLimit)
+	s.snapshots.Add(1)
+	if err != nil {
+		log.Printf("⚠️  获取 %s 订单簿快照失败: %v", symbol, err)
+		return false
+	}
+	snapshot, err := DepthSnapshotFromREST(resp)
+	if err != nil {
+		log.Printf("⚠️  %s %v", symbol, err)
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 快照早于缓存的第一条更新时中间有缺失，稍后重新获取
+	if len(s.buffer) > 0 && s.buffer[0].FirstUpdateID > snapshot.LastUpdateID+1 {
+		return false
+	}
+
+	s.book.Reset(snapshot)
+	for _, update := range s.buffer {
+		if err := s.book.Apply(update); err != nil {
+			log.Printf("⚠️  回放 %s 增量深度失败: %v", symbol, err)
+			s.buffer = nil
+			return false
+		}
+	}
+	s.buffer = nil
+	s.resyncing = false
+	log.Printf("✅ %s 订单簿已同步（更新ID %d）", symbol, s.book.LastUpdateID())
+	return true
+}
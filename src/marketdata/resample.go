@@ -0,0 +1,118 @@
+package marketdata
+
+import (
+	"sort"
+	"time"
+
+	"vagues-go/src/models"
+)
+
+// Resampler aggregates base K-lines into bars of a larger interval
+// OHLC 取桶内首根开盘、最高、最低、末根收盘；成交量与成交额求和；桶按 UTC 边界对齐
+type Resampler struct {
+	interval Interval
+	current  models.KLine
+	lastEnd  time.Time // 当前桶内最后一根基础K线的结束时间
+	closedAt time.Time // 已输出K线的结束时间，早于此时间的基础K线视为迟到
+	open     bool
+	started  bool // 是否已开始过桶
+	partial  bool // 第一个桶从中途开始（数据窗口截断），收盘时丢弃
+}
+
+// NewResampler creates a resampler for the target interval
+func NewResampler(interval Interval) *Resampler {
+	return &Resampler{interval: interval}
+}
+
+// Interval returns the target interval
+func (r *Resampler) Interval() Interval {
+	return r.interval
+}
+
+// Add adds a base K-line (in time order) and returns the bars closed by it
+// 基础K线进入新的桶时，上一个桶即视为收盘；覆盖到桶结束时间的基础K线会立即收盘当前桶
+func (r *Resampler) Add(k models.KLine) []models.KLine {
+	var closed []models.KLine
+
+	// 迟到的K线（所属桶已输出或早于当前桶），忽略
+	bucket := r.interval.Floor(k.StartTime)
+	if bucket.Before(r.closedAt) || (r.open && bucket.Before(r.current.StartTime)) {
+		return nil
+	}
+
+	if r.open && !bucket.Equal(r.current.StartTime) {
+		closed = r.close(closed)
+	}
+
+	if !r.open {
+		r.partial = !r.started && k.StartTime.After(bucket)
+		r.started = true
+		r.current = models.KLine{
+			StartTime: bucket,
+			EndTime:   r.interval.Next(bucket),
+			Open:      k.Open,
+			High:      k.High,
+			Low:       k.Low,
+		}
+		r.open = true
+	}
+
+	if k.High > r.current.High {
+		r.current.High = k.High
+	}
+	if k.Low < r.current.Low {
+		r.current.Low = k.Low
+	}
+	r.current.Close = k.Close
+	r.current.Volume += k.Volume
+	r.current.QuoteVolume += k.QuoteVolume
+	r.lastEnd = k.EndTime
+
+	// 基础K线已覆盖到桶的结束时间（允许1秒误差，兼容 59 秒结束的K线）：立即收盘
+	if !r.lastEnd.Add(time.Second).Before(r.current.EndTime) {
+		closed = r.close(closed)
+	}
+	return closed
+}
+
+// close finishes the current bucket and appends it to closed unless it is a truncated first bucket
+func (r *Resampler) close(closed []models.KLine) []models.KLine {
+	if !r.partial {
+		closed = append(closed, r.current)
+	}
+	r.closedAt = r.current.EndTime
+	r.open = false
+	r.partial = false
+	return closed
+}
+
+// Pending returns the bar that is still being built, if any
+func (r *Resampler) Pending() (models.KLine, bool) {
+	return r.current, r.open
+}
+
+// Resample aggregates base K-lines into closed bars of the given interval
+// 第一个桶若从中途开始则丢弃；最后一个桶未到收盘时间时不返回
+func Resample(klines []models.KLine, interval Interval) []models.KLine {
+	r := NewResampler(interval)
+	var bars []models.KLine
+	for _, k := range klines {
+		bars = append(bars, r.Add(k)...)
+	}
+	return bars
+}
+
+// AlignLatestClosed returns, for each base bar, the index of the latest higher-timeframe bar
+// that had closed by the base bar's end time, or -1 if none had
+// higher 需按时间升序排列
+func AlignLatestClosed(base []models.KLine, higher []models.KLine) []int {
+	aligned := make([]int, len(base))
+	for i, k := range base {
+		// 第一个收盘时间晚于基础K线收盘时间的高周期K线之前的一根（允许1秒误差）
+		closeTime := k.EndTime.Add(time.Second)
+		aligned[i] = sort.Search(len(higher), func(j int) bool {
+			return higher[j].EndTime.After(closeTime)
+		}) - 1
+	}
+	return aligned
+}
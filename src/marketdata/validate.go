@@ -0,0 +1,207 @@
+package marketdata
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"vagues-go/src/models"
+)
+
+// IssueKind is the kind of a K-line data quality issue
+type IssueKind string
+
+const (
+	IssueInvalidPrice  IssueKind = "invalid_price"  // 价格为 0、负数、NaN 或 Inf
+	IssueInvalidVolume IssueKind = "invalid_volume" // 成交量为负数、NaN 或 Inf
+	IssueHighBelowLow  IssueKind = "high_below_low" // 最高价低于最低价
+	IssueOutOfRange    IssueKind = "out_of_range"   // 开盘价或收盘价超出 [最低价, 最高价]
+	IssueNonMonotonic  IssueKind = "non_monotonic"  // 开始时间不晚于上一根（重复或乱序）
+	IssueMisaligned    IssueKind = "misaligned"     // 开始时间未对齐周期边界，或结束时间与周期不符
+	IssueVolumeSpike   IssueKind = "volume_spike"   // 成交量远超近期中位数
+	IssueMissingBars   IssueKind = "missing_bars"   // 与上一根之间缺少K线
+)
+
+// 成交量异常检测默认参数
+const (
+	DefaultSpikeFactor   = 20.0 // 成交量超过近期中位数的倍数
+	DefaultSpikeLookback = 20   // 计算中位数使用的前序K线数量
+	minSpikeSamples      = 5    // 前序K线不足时不检测
+)
+
+// endTolerance 结束时间允许的偏差（部分数据源的结束时间为下一周期开始前 1ms 或 1s）
+const endTolerance = time.Second
+
+// Issue is a data quality problem of one bar
+type Issue struct {
+	Index  int // 在校验序列中的下标
+	Time   time.Time
+	Kind   IssueKind
+	Detail string
+}
+
+// Fatal reports whether the bar is unusable for evaluation
+// 成交量异常和缺失K线只是警告：K线本身的数值仍然可信
+func (i Issue) Fatal() bool {
+	return i.Kind != IssueVolumeSpike && i.Kind != IssueMissingBars
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s %s: %s", i.Time.UTC().Format(time.RFC3339), i.Kind, i.Detail)
+}
+
+// Validator checks K-line series for bad data: invalid prices, inconsistent OHLC,
+// unordered or misaligned timestamps, volume spikes and missing bars
+type Validator struct {
+	interval      Interval
+	spikeFactor   float64
+	spikeLookback int
+}
+
+// NewValidator creates a validator for bars of interval with the default volume spike settings
+func NewValidator(interval Interval) *Validator {
+	return &Validator{
+		interval:      interval,
+		spikeFactor:   DefaultSpikeFactor,
+		spikeLookback: DefaultSpikeLookback,
+	}
+}
+
+// SetVolumeSpike sets the volume spike threshold; factor <= 0 disables the check
+func (v *Validator) SetVolumeSpike(factor float64, lookback int) {
+	v.spikeFactor = factor
+	if lookback > 0 {
+		v.spikeLookback = lookback
+	}
+}
+
+// Validate checks every bar of klines (按开始时间升序) and returns the issues found
+func (v *Validator) Validate(klines []models.KLine) []Issue {
+	var issues []Issue
+	for i := range klines {
+		issues = append(issues, v.check(klines, i)...)
+	}
+	return issues
+}
+
+// ValidateLast checks the last bar of klines, using the preceding bars as context
+func (v *Validator) ValidateLast(klines []models.KLine) []Issue {
+	if len(klines) == 0 {
+		return nil
+	}
+	return v.check(klines, len(klines)-1)
+}
+
+// check returns the issues of klines[i]
+func (v *Validator) check(klines []models.KLine, i int) []Issue {
+	k := klines[i]
+	var issues []Issue
+	add := func(kind IssueKind, format string, args ...any) {
+		issues = append(issues, Issue{Index: i, Time: k.StartTime, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	// 价格和成交量
+	prices := []struct {
+		name  string
+		value float64
+	}{{"open", k.Open}, {"high", k.High}, {"low", k.Low}, {"close", k.Close}}
+	validPrices := true
+	for _, p := range prices {
+		if !validPrice(p.value) {
+			add(IssueInvalidPrice, "%s=%v", p.name, p.value)
+			validPrices = false
+		}
+	}
+	if math.IsNaN(k.Volume) || math.IsInf(k.Volume, 0) || k.Volume < 0 {
+		add(IssueInvalidVolume, "volume=%v", k.Volume)
+	}
+
+	// OHLC 一致性（价格无效时不再比较）
+	if validPrices {
+		if k.High < k.Low {
+			add(IssueHighBelowLow, "high=%v < low=%v", k.High, k.Low)
+		} else {
+			if k.Open < k.Low || k.Open > k.High {
+				add(IssueOutOfRange, "open=%v 不在 [%v, %v]", k.Open, k.Low, k.High)
+			}
+			if k.Close < k.Low || k.Close > k.High {
+				add(IssueOutOfRange, "close=%v 不在 [%v, %v]", k.Close, k.Low, k.High)
+			}
+		}
+	}
+
+	// 时间对齐
+	if start := v.interval.Floor(k.StartTime); !start.Equal(k.StartTime) {
+		add(IssueMisaligned, "开始时间未对齐 %s 周期", v.interval)
+	} else if end := v.interval.Next(k.StartTime); absDuration(k.EndTime.Sub(end)) > endTolerance {
+		add(IssueMisaligned, "结束时间 %s，应为 %s", k.EndTime.UTC().Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+
+	if i == 0 {
+		return issues
+	}
+
+	// 时间顺序和缺失K线
+	prev := klines[i-1]
+	if !k.StartTime.After(prev.StartTime) {
+		add(IssueNonMonotonic, "开始时间不晚于上一根 %s", prev.StartTime.UTC().Format(time.RFC3339))
+	} else if missing := v.missingBetween(prev.StartTime, k.StartTime); missing > 0 {
+		add(IssueMissingBars, "与上一根 %s 之间缺少 %d 根", prev.StartTime.UTC().Format(time.RFC3339), missing)
+	}
+
+	// 成交量异常：超过前序K线成交量中位数的 spikeFactor 倍
+	if v.spikeFactor > 0 {
+		from := max(0, i-v.spikeLookback)
+		if median, ok := medianVolume(klines[from:i]); ok && k.Volume > median*v.spikeFactor {
+			add(IssueVolumeSpike, "volume=%v 为近 %d 根中位数 %v 的 %.1f 倍", k.Volume, i-from, median, k.Volume/median)
+		}
+	}
+	return issues
+}
+
+// missingBetween returns the number of bars expected strictly between two bar starts
+func (v *Validator) missingBetween(prev, start time.Time) int {
+	prev = v.interval.Floor(prev)
+	if v.interval.Unit != UnitMonth {
+		step := v.interval.Duration()
+		return max(0, int((v.interval.Floor(start).Sub(prev)+step-1)/step)-1)
+	}
+	missing := 0
+	for t := v.interval.Next(prev); t.Before(start); t = v.interval.Next(t) {
+		missing++
+	}
+	return missing
+}
+
+// medianVolume returns the median volume of klines, ignoring invalid volumes
+// 有效样本不足或中位数为 0（长时间无成交）时返回 false
+func medianVolume(klines []models.KLine) (float64, bool) {
+	volumes := make([]float64, 0, len(klines))
+	for _, k := range klines {
+		if !math.IsNaN(k.Volume) && !math.IsInf(k.Volume, 0) && k.Volume >= 0 {
+			volumes = append(volumes, k.Volume)
+		}
+	}
+	if len(volumes) < minSpikeSamples {
+		return 0, false
+	}
+	sort.Float64s(volumes)
+	n := len(volumes)
+	median := volumes[n/2]
+	if n%2 == 0 {
+		median = (volumes[n/2-1] + volumes[n/2]) / 2
+	}
+	return median, median > 0
+}
+
+func validPrice(price float64) bool {
+	return price > 0 && !math.IsInf(price, 0) // NaN 与任何数比较都为 false
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
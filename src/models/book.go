@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// BookStats summarizes the top of a local L2 order book
+type BookStats struct {
+	BestBid   float64   // 最优买价
+	BestAsk   float64   // 最优卖价
+	Mid       float64   // 中间价
+	Spread    float64   // 买卖价差
+	SpreadBps float64   // 买卖价差（相对中间价，基点）
+	DepthBps  float64   // 统计深度的价格范围（距中间价的基点）
+	BidDepth  float64   // 范围内买单数量
+	AskDepth  float64   // 范围内卖单数量
+	Imbalance float64   // 范围内买卖失衡度 (买-卖)/(买+卖)，范围 [-1, 1]
+	UpdateID  int64     // 订单簿最后更新ID
+	Time      time.Time // 订单簿最后更新时间
+}
@@ -27,6 +27,21 @@ type Indicators struct {
 	MACDSignal    float64 // MACD信号线
 	MACDHistogram float64 // MACD柱状图
 	RSI           float64 // RSI指标
+
+	// Values 按指标键索引的全部指标值（如 "ema(21)", "macd(12,26,9).hist"）
+	// 策略通过 indicators.Spec 声明所需指标，无需修改本结构体
+	Values map[string]float64
+}
+
+// Get returns the indicator value for key, or 0 if it was not calculated
+func (ind Indicators) Get(key string) float64 {
+	return ind.Values[key]
+}
+
+// Has reports whether the indicator value for key was calculated
+func (ind Indicators) Has(key string) bool {
+	_, ok := ind.Values[key]
+	return ok
 }
 
 // Pattern represents a detected price pattern
@@ -41,12 +56,28 @@ type Delta struct {
 	Value      float64 // Delta value
 	BuyVolume  float64 // Aggressor buy volume
 	SellVolume float64 // Aggressor sell volume
+	TickValue  float64 // Delta over the last DELTA_LOOKBACK_TICKS trades up to the bar close
+	Trades     int     // Number of trades aggregated
+	Estimated  bool    // Guessed from the candle shape because no trade data was available
 }
 
 // MarketData combines K-line data with calculated indicators
 type MarketData struct {
 	KLine      KLine
 	Indicators Indicators
+
+	// Timeframes 按周期索引的高周期数据（如 "1h"、"4h"）
+	// 每项为截至当前K线已收盘的最近一根高周期K线及其指标，不含未来数据
+	Timeframes map[string]MarketData
+
+	// Book 评估时的订单簿统计（未订阅深度或订单簿未同步时为 nil）
+	Book *BookStats
+}
+
+// Timeframe returns the aligned higher-timeframe data for interval, if present
+func (m MarketData) Timeframe(interval string) (MarketData, bool) {
+	data, ok := m.Timeframes[interval]
+	return data, ok
 }
 
 // TrendDirection represents the market trend direction
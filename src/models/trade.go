@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// Trade represents a single public trade
+type Trade struct {
+	ID           int64     // 成交ID（按交易对递增）
+	Symbol       string    // 交易对
+	Price        float64   // 成交价
+	Quantity     float64   // 成交数量
+	Time         time.Time // 成交时间
+	BuyerIsMaker bool      // 买方是否为挂单方（true 表示卖方主动吃单）
+}
+
+// IsTakerBuy reports whether the aggressor (taker) was the buyer
+func (t Trade) IsTakerBuy() bool {
+	return !t.BuyerIsMaker
+}
+
+// SignedQuantity returns the quantity signed by aggressor side (+buy / -sell)
+func (t Trade) SignedQuantity() float64 {
+	if t.IsTakerBuy() {
+		return t.Quantity
+	}
+	return -t.Quantity
+}
@@ -0,0 +1,260 @@
+package parquet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	pq "github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+const (
+	julianUnixEpoch = 2440588 // 1970-01-01 的儒略日
+
+	timestampNoZoneLayout = "2006-01-02T15:04:05.999999999"
+
+	// readBatchRows 每次从文件解码的行数
+	readBatchRows = 1024
+)
+
+// Column describes a leaf column of a flat schema
+type Column struct {
+	Name     string
+	Optional bool
+
+	kind    pq.Kind
+	unit    time.Duration // 时间戳单位，0 表示不是时间戳
+	utc     bool          // 时间戳是否为 UTC
+	decimal bool
+	scale   int32
+	date    bool
+}
+
+// Reader reads a flat Parquet file row by row; values are returned as strings
+// 时间戳列转为 RFC3339（UTC），DECIMAL 转为十进制字符串，空值为 ""
+//
+// 解码由 parquet-go 完成（支持各种编码、v1/v2 数据页以及 SNAPPY/GZIP/ZSTD/LZ4/BROTLI 压缩）；不支持嵌套和重复列
+type Reader struct {
+	file    *os.File
+	reader  *pq.Reader
+	columns []Column
+
+	batch []pq.Row
+	rows  [][]string // 当前批次已格式化的行
+	row   int        // 当前批次中的下一行
+}
+
+// Open opens a Parquet file and reads its footer
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{file: file}
+	if err := r.open(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("读取 %s 失败: %w", path, err)
+	}
+	return r, nil
+}
+
+// open reads the footer and checks that the schema is flat
+func (r *Reader) open() (err error) {
+	// parquet-go 遇到部分损坏的 schema 时会 panic，这里转为错误
+	defer recoverError(&err)
+	info, err := r.file.Stat()
+	if err != nil {
+		return err
+	}
+	file, err := pq.OpenFile(r.file, info.Size())
+	if err != nil {
+		return err
+	}
+	for _, field := range file.Schema().Fields() {
+		if !field.Leaf() {
+			return fmt.Errorf("不支持嵌套列 %s", field.Name())
+		}
+		if field.Repeated() {
+			return fmt.Errorf("不支持重复列 %s", field.Name())
+		}
+		r.columns = append(r.columns, newColumn(field))
+	}
+	if len(r.columns) == 0 {
+		return fmt.Errorf("schema 为空")
+	}
+	r.reader = pq.NewReader(file)
+	return nil
+}
+
+// newColumn reads the type information of a schema field
+func newColumn(field pq.Field) Column {
+	typ := field.Type()
+	c := Column{Name: field.Name(), Optional: field.Optional(), kind: typ.Kind()}
+	if logical := typ.LogicalType(); logical != nil {
+		switch lt := logical.Value.(type) {
+		case *format.TimestampType:
+			c.utc = lt.IsAdjustedToUTC
+			if lt.Unit.Value != nil {
+				c.unit = lt.Unit.Value.Duration()
+			}
+		case *format.DecimalType:
+			c.decimal, c.scale = true, lt.Scale
+		case *format.DateType:
+			c.date = true
+		}
+	}
+	if c.kind == pq.Int96 {
+		c.unit, c.utc = time.Nanosecond, true
+	}
+	return c
+}
+
+// Columns returns the columns in schema order
+func (r *Reader) Columns() []Column {
+	return r.columns
+}
+
+// NumRows returns the total number of rows
+func (r *Reader) NumRows() int64 {
+	return r.reader.NumRows()
+}
+
+// Close closes the file
+func (r *Reader) Close() error {
+	return errors.Join(r.reader.Close(), r.file.Close())
+}
+
+// Read returns the next row, or io.EOF after the last row
+func (r *Reader) Read() ([]string, error) {
+	if r.row >= len(r.rows) {
+		if err := r.readBatch(); err != nil {
+			return nil, err
+		}
+	}
+	row := r.rows[r.row]
+	r.row++
+	return row, nil
+}
+
+// readBatch decodes and formats the next batch of rows
+func (r *Reader) readBatch() (err error) {
+	defer recoverError(&err)
+	if r.batch == nil {
+		r.batch = make([]pq.Row, readBatchRows)
+	}
+	n, err := r.reader.ReadRows(r.batch)
+	if n == 0 {
+		if err == nil || errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return err
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	r.rows, r.row = r.rows[:0], 0
+	for _, values := range r.batch[:n] {
+		if len(values) != len(r.columns) {
+			return fmt.Errorf("行有 %d 个值，schema 有 %d 列", len(values), len(r.columns))
+		}
+		row := make([]string, len(r.columns))
+		for _, v := range values {
+			i := v.Column()
+			if i < 0 || i >= len(r.columns) {
+				return fmt.Errorf("无效的列序号 %d", i)
+			}
+			row[i] = r.columns[i].format(v)
+		}
+		r.rows = append(r.rows, row)
+	}
+	return nil
+}
+
+// recoverError turns a panic from the Parquet library into an error
+func recoverError(err *error) {
+	if p := recover(); p != nil {
+		*err = fmt.Errorf("无效的 Parquet 文件: %v", p)
+	}
+}
+
+// format formats a value according to the column's physical and logical type
+func (c *Column) format(v pq.Value) string {
+	if v.IsNull() {
+		return ""
+	}
+	switch v.Kind() {
+	case pq.Boolean:
+		return strconv.FormatBool(v.Boolean())
+	case pq.Int32:
+		return c.formatInt(int64(v.Int32()))
+	case pq.Int64:
+		return c.formatInt(v.Int64())
+	case pq.Int96:
+		// 低 8 字节为当天的纳秒数，高 4 字节为儒略日
+		i := v.Int96()
+		nanos := int64(uint64(i[1])<<32 | uint64(i[0]))
+		days := int64(i[2])
+		return c.formatTime((days-julianUnixEpoch)*86400*1e9 + nanos)
+	case pq.Float:
+		return strconv.FormatFloat(float64(v.Float()), 'f', -1, 32)
+	case pq.Double:
+		return strconv.FormatFloat(v.Double(), 'f', -1, 64)
+	}
+	return c.formatBytes(v.ByteArray())
+}
+
+// formatInt formats an integer according to the column's logical type
+func (c *Column) formatInt(v int64) string {
+	switch {
+	case c.unit > 0:
+		return c.formatTime(v * int64(c.unit))
+	case c.date:
+		return time.Unix(v*86400, 0).UTC().Format("2006-01-02")
+	case c.decimal:
+		return formatDecimal(big.NewInt(v), c.scale)
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// formatTime formats nanoseconds since the epoch; 非 UTC 时间戳不带时区，由调用方按本地时区解析
+func (c *Column) formatTime(nanos int64) string {
+	t := time.Unix(0, nanos).UTC()
+	if !c.utc {
+		return t.Format(timestampNoZoneLayout)
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// formatBytes formats a byte array: DECIMAL 为大端补码，其余按字符串处理
+func (c *Column) formatBytes(b []byte) string {
+	if !c.decimal {
+		return string(b)
+	}
+	v := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return formatDecimal(v, c.scale)
+}
+
+// formatDecimal formats an unscaled decimal value
+func formatDecimal(v *big.Int, scale int32) string {
+	if scale <= 0 {
+		return v.String()
+	}
+	digits := new(big.Int).Abs(v).String()
+	for len(digits) <= int(scale) {
+		digits = "0" + digits
+	}
+	point := len(digits) - int(scale)
+	s := digits[:point] + "." + digits[point:]
+	if v.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+package parquet
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	pq "github.com/parquet-go/parquet-go"
+)
+
+// DefaultRowGroupRows 每个行组的行数
+const DefaultRowGroupRows = 100_000
+
+// FieldType is the type of a written column
+type FieldType int
+
+const (
+	Int64     FieldType = iota // int64
+	Double                     // float64
+	String                     // string（UTF8）
+	Bool                       // bool
+	Timestamp                  // time.Time，毫秒精度，UTC
+)
+
+// Field describes a written column
+type Field struct {
+	Name     string
+	Type     FieldType
+	Optional bool // 允许空值（nil）
+}
+
+// Writer writes rows to a Snappy-compressed Parquet file
+// 行数据由 parquet-go 缓存，每 DefaultRowGroupRows 行写出一个行组，Close 时写入元数据
+type Writer struct {
+	w      *pq.Writer
+	fields []Field
+	row    pq.Row
+	closed bool
+}
+
+// NewWriter returns a writer for the given columns; 列按 fields 的顺序写入
+func NewWriter(w io.Writer, fields []Field) (*Writer, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("parquet: 至少需要一列")
+	}
+	schema, err := schemaOf(fields)
+	if err != nil {
+		return nil, err
+	}
+	config, err := pq.NewWriterConfig(schema, pq.Compression(&pq.Snappy), pq.MaxRowsPerRowGroup(DefaultRowGroupRows))
+	if err != nil {
+		return nil, fmt.Errorf("parquet: %w", err)
+	}
+	return &Writer{w: pq.NewWriter(w, config), fields: fields, row: make(pq.Row, len(fields))}, nil
+}
+
+// schemaOf builds a flat schema with the columns in the given order
+// parquet.Group 按列名排序，这里用动态构造的结构体保持导出的列顺序
+func schemaOf(fields []Field) (*pq.Schema, error) {
+	types := [...]reflect.Type{
+		Int64:     reflect.TypeFor[int64](),
+		Double:    reflect.TypeFor[float64](),
+		String:    reflect.TypeFor[string](),
+		Bool:      reflect.TypeFor[bool](),
+		Timestamp: reflect.TypeFor[int64](),
+	}
+	columns := make([]reflect.StructField, len(fields))
+	for i, field := range fields {
+		if field.Type < Int64 || field.Type > Timestamp {
+			return nil, fmt.Errorf("parquet: 列 %s 的类型无效", field.Name)
+		}
+		tag := field.Name
+		if field.Type == Timestamp {
+			tag += ",timestamp(millisecond)"
+		}
+		typ := types[field.Type]
+		if field.Optional {
+			tag += ",optional"
+			typ = reflect.PointerTo(typ)
+		}
+		columns[i] = reflect.StructField{
+			Name: "F" + strconv.Itoa(i),
+			Type: typ,
+			Tag:  reflect.StructTag(`parquet:"` + tag + `"`),
+		}
+	}
+	return pq.SchemaOf(reflect.New(reflect.StructOf(columns)).Interface()), nil
+}
+
+// Write appends a row; values must match the field types (Optional 列可以为 nil)
+func (w *Writer) Write(row []any) error {
+	if w.closed {
+		return fmt.Errorf("parquet: 写入已关闭的文件")
+	}
+	if len(row) != len(w.fields) {
+		return fmt.Errorf("parquet: 行有 %d 个值，需要 %d 个", len(row), len(w.fields))
+	}
+	// 先检查整行再写入，出错时不留下半行
+	for i, value := range row {
+		v, err := w.fields[i].value(value)
+		if err != nil {
+			return err
+		}
+		w.row[i] = v.Level(0, w.fields[i].definitionLevel(value), i)
+	}
+	if _, err := w.w.WriteRows([]pq.Row{w.row}); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	return nil
+}
+
+// Close writes the remaining rows and the file footer; 不关闭底层的 io.Writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.w.Close(); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	return nil
+}
+
+// value converts a value to the column's Parquet value, checking its type
+func (f Field) value(value any) (pq.Value, error) {
+	if value == nil {
+		if f.Optional {
+			return pq.NullValue(), nil
+		}
+		return pq.Value{}, fmt.Errorf("parquet: 列 %s 不能为空", f.Name)
+	}
+	var v pq.Value
+	ok := true
+	switch f.Type {
+	case Int64:
+		var n int64
+		n, ok = value.(int64)
+		v = pq.Int64Value(n)
+	case Double:
+		var x float64
+		x, ok = value.(float64)
+		v = pq.DoubleValue(x)
+	case String:
+		var s string
+		s, ok = value.(string)
+		v = pq.ByteArrayValue([]byte(s))
+	case Bool:
+		var b bool
+		b, ok = value.(bool)
+		v = pq.BooleanValue(b)
+	case Timestamp:
+		var t time.Time
+		t, ok = value.(time.Time)
+		v = pq.Int64Value(t.UnixMilli())
+	}
+	if !ok {
+		return pq.Value{}, fmt.Errorf("parquet: 列 %s 的值类型 %T 不匹配", f.Name, value)
+	}
+	return v, nil
+}
+
+// definitionLevel returns the definition level of a value: 平面 schema 中非空的 Optional 值为 1
+func (f Field) definitionLevel(value any) int {
+	if f.Optional && value != nil {
+		return 1
+	}
+	return 0
+}
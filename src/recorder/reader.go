@@ -0,0 +1,104 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Files returns the recorded files of symbol (all symbols when empty) whose day is within [from, to]
+// from/to 为 YYYY-MM-DD，为空时不限制；按日期和分段排序
+func Files(dir, symbol, from, to string) ([]string, error) {
+	pattern := filepath.Join(dir, "*", "*"+fileSuffix)
+	if symbol != "" {
+		pattern = filepath.Join(dir, symbol, "*"+fileSuffix)
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, path := range paths {
+		day := fileDay(path)
+		if (from != "" && day < from) || (to != "" && day > to) {
+			continue
+		}
+		files = append(files, path)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if di, dj := fileDay(files[i]), fileDay(files[j]); di != dj {
+			return di < dj
+		}
+		return files[i] < files[j]
+	})
+	return files, nil
+}
+
+// fileDay returns the YYYY-MM-DD part of a recorded file name
+func fileDay(path string) string {
+	day, _, _ := strings.Cut(filepath.Base(path), ".")
+	return day
+}
+
+// ReadFile calls fn for every record in a recorded file
+// 进程异常退出时文件末尾的压缩流可能不完整，读到截断处即停止而不报错
+func ReadFile(path string, fn func(Record) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer gz.Close()
+
+	reader := bufio.NewReaderSize(gz, 1024*1024)
+	for lineNo := 1; ; lineNo++ {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var raw recordLine
+			if err := json.Unmarshal(line, &raw); err != nil {
+				return fmt.Errorf("%s 第 %d 行: %w", path, lineNo, err)
+			}
+			if err := fn(Record{Received: time.UnixMicro(raw.Received), Stream: raw.Stream, Data: raw.Data}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+}
+
+// Load reads records from files and merges them by receive time (同一时间按文件顺序)
+func Load(paths ...string) ([]Record, error) {
+	var records []Record
+	for _, path := range paths {
+		err := ReadFile(path, func(rec Record) error {
+			records = append(records, rec)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Received.Before(records[j].Received)
+	})
+	return records, nil
+}
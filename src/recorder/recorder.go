@@ -0,0 +1,247 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"vagues-go/src/backpack"
+)
+
+// 录制参数
+const (
+	queueSize     = 8192        // 待写入消息队列长度（WebSocket 协程只入队，不等待磁盘）
+	flushInterval = time.Second // 定时刷新压缩流，进程异常退出时最多丢失约1秒数据
+	dayFormat     = "2006-01-02"
+	fileSuffix    = ".jsonl.gz"
+)
+
+// Record is one recorded stream message
+type Record struct {
+	Received time.Time       // 本地接收时间
+	Stream   string          // stream 名称
+	Data     json.RawMessage // 推送的 data 字段（原样保存）
+}
+
+// recordLine 录制文件中的一行: {"t":<接收时间微秒>,"stream":"...","data":{...}}
+type recordLine struct {
+	Received int64           `json:"t"`
+	Stream   string          `json:"stream"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Recorder writes every WebSocket stream message to gzip-compressed JSONL files
+// 按交易对和接收日期（UTC）分文件: <dir>/<symbol>/<YYYY-MM-DD>.<n>.jsonl.gz；跨天自动切换文件，重启时写入新的分段
+type Recorder struct {
+	dir      string
+	queue    chan Record
+	segments map[string]*segment // 交易对 -> 当前写入的文件
+	done     chan struct{}
+
+	written atomic.Int64
+	dropped atomic.Int64
+}
+
+// segment 一个交易对当天的录制文件
+type segment struct {
+	day  string
+	file *os.File
+	buf  *bufio.Writer
+	gz   *gzip.Writer
+}
+
+// New creates a recorder writing under dir
+func New(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建录制目录失败: %w", err)
+	}
+	return &Recorder{
+		dir:      dir,
+		queue:    make(chan Record, queueSize),
+		segments: make(map[string]*segment),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Attach records every stream message received by ws
+func (r *Recorder) Attach(ws *backpack.WSClient) {
+	ws.OnMessage(func(msg backpack.WSRawMessage) {
+		r.Record(Record{Received: msg.Received, Stream: msg.Stream, Data: msg.Data})
+	})
+}
+
+// Record queues a message for writing; drops it when the queue is full (不阻塞 WebSocket 协程)
+func (r *Recorder) Record(rec Record) {
+	select {
+	case r.queue <- rec:
+	default:
+		if r.dropped.Add(1)%1000 == 1 {
+			log.Printf("⚠️  行情录制队列已满，已丢弃 %d 条消息", r.dropped.Load())
+		}
+	}
+}
+
+// Stats returns how many messages were written and dropped
+func (r *Recorder) Stats() (written, dropped int64) {
+	return r.written.Load(), r.dropped.Load()
+}
+
+// Done is closed once Run has flushed and closed all files
+func (r *Recorder) Done() <-chan struct{} {
+	return r.done
+}
+
+// Run writes queued messages until ctx is cancelled, then writes what is left and closes the files
+func (r *Recorder) Run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case rec := <-r.queue:
+			r.write(rec)
+		case <-ticker.C:
+			r.flush()
+		case <-ctx.Done():
+			r.drain()
+			r.closeAll()
+			written, dropped := r.Stats()
+			log.Printf("行情录制已停止: 写入 %d 条, 丢弃 %d 条", written, dropped)
+			return
+		}
+	}
+}
+
+// drain writes the messages still queued
+func (r *Recorder) drain() {
+	for {
+		select {
+		case rec := <-r.queue:
+			r.write(rec)
+		default:
+			return
+		}
+	}
+}
+
+// write appends a record to its symbol's file for the receive day
+func (r *Recorder) write(rec Record) {
+	if err := r.Write(rec); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+}
+
+// Write synchronously appends a record to its symbol's file for the receive day
+// 用于导入历史数据（不经过队列，不能与 Run 同时使用），写完后调用 Close
+func (r *Recorder) Write(rec Record) error {
+	seg, err := r.segmentFor(StreamSymbol(rec.Stream), rec.Received.UTC().Format(dayFormat))
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(recordLine{Received: rec.Received.UnixMicro(), Stream: rec.Stream, Data: rec.Data})
+	if err != nil {
+		return fmt.Errorf("序列化录制消息失败: %w (%s)", err, rec.Stream)
+	}
+	if _, err := seg.gz.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入录制文件失败: %w", err)
+	}
+	r.written.Add(1)
+	return nil
+}
+
+// Close closes the files opened by Write
+func (r *Recorder) Close() {
+	r.closeAll()
+}
+
+// segmentFor returns the open file of symbol for day, rotating when the day changes
+func (r *Recorder) segmentFor(symbol, day string) (*segment, error) {
+	if seg, ok := r.segments[symbol]; ok {
+		if seg.day == day {
+			return seg, nil
+		}
+		seg.close()
+		delete(r.segments, symbol)
+	}
+
+	symbolDir := filepath.Join(r.dir, symbol)
+	if err := os.MkdirAll(symbolDir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建录制目录失败: %w", err)
+	}
+
+	// 同一天已有文件（重启）时写入新的分段，不追加到可能不完整的压缩流
+	for part := 0; ; part++ {
+		path := filepath.Join(symbolDir, fmt.Sprintf("%s.%02d%s", day, part, fileSuffix))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("创建录制文件失败: %w", err)
+		}
+
+		buf := bufio.NewWriterSize(file, 64*1024)
+		seg := &segment{day: day, file: file, buf: buf, gz: gzip.NewWriter(buf)}
+		r.segments[symbol] = seg
+		log.Printf("📼 开始录制 %s 行情: %s", symbol, path)
+		return seg, nil
+	}
+}
+
+// flush flushes the compressed streams of all open files
+func (r *Recorder) flush() {
+	for symbol, seg := range r.segments {
+		if err := seg.flush(); err != nil {
+			log.Printf("⚠️  刷新 %s 录制文件失败: %v", symbol, err)
+		}
+	}
+}
+
+// closeAll closes all open files
+func (r *Recorder) closeAll() {
+	for symbol, seg := range r.segments {
+		seg.close()
+		delete(r.segments, symbol)
+	}
+}
+
+// flush writes buffered data to the file (压缩流可在此处截断读取)
+func (s *segment) flush() error {
+	if err := s.gz.Flush(); err != nil {
+		return err
+	}
+	return s.buf.Flush()
+}
+
+// close finishes the gzip stream and closes the file
+func (s *segment) close() {
+	if err := s.gz.Close(); err != nil {
+		log.Printf("⚠️  关闭录制文件失败: %v", err)
+	}
+	if err := s.buf.Flush(); err != nil {
+		log.Printf("⚠️  关闭录制文件失败: %v", err)
+	}
+	s.file.Close()
+}
+
+// StreamSymbol returns the trading pair a stream belongs to (stream 名称的最后一段)
+// 如 kline.1m.SOL_USDC、depth.200ms.SOL_USDC_PERP；不带交易对的账户 stream 归入 "account"
+func StreamSymbol(stream string) string {
+	parts := strings.Split(stream, ".")
+	last := parts[len(parts)-1]
+	if len(parts) < 2 || (parts[0] == "account" && len(parts) == 2) {
+		return parts[0]
+	}
+	return last
+}
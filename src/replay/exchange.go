@@ -0,0 +1,831 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/clock"
+	"vagues-go/src/marketdata"
+	"vagues-go/src/recorder"
+)
+
+// 模拟交易所默认参数
+const (
+	DefaultBalance  = 1000.0   // 初始计价资产余额
+	DefaultTakerFee = 0.0006   // Taker 手续费率
+	DefaultTickSize = "0.0001" // 价格精度
+	DefaultStepSize = "0.01"   // 数量精度
+)
+
+// klineTimeFormat REST K线的时间格式
+const klineTimeFormat = "2006-01-02 15:04:05"
+
+// Fill is an order executed by the simulated exchange
+type Fill struct {
+	Time       time.Time `json:"time"`
+	OrderID    string    `json:"orderId"`
+	Symbol     string    `json:"symbol"`
+	Side       string    `json:"side"`
+	Quantity   float64   `json:"quantity"`
+	Price      float64   `json:"price"`
+	Fee        float64   `json:"fee"`
+	Reason     string    `json:"reason"` // entry、close、stopLoss、takeProfit
+	StopLoss   string    `json:"stopLoss,omitempty"`
+	TakeProfit string    `json:"takeProfit,omitempty"`
+}
+
+// Exchange is a simulated exchange answering REST requests from recorded market data
+// 行情按回放进度通过 observe 更新：K线、成交和深度只包含回放时钟之前收到的数据；
+// 市价单按最新成交价（无成交时按标记价格）立即成交，止损止盈按标记价格触发，成交和持仓变化通过 WebSocket 推送
+type Exchange struct {
+	mu       sync.Mutex
+	clock    clock.Clock
+	ws       *backpack.WSClient
+	symbol   string // 策略交易对（现货或永续）
+	quote    string // 计价资产
+	balance  float64
+	takerFee float64
+	tickSize string
+	stepSize string
+	leverage string
+
+	trades    map[string][]trade   // 交易对 -> 已收到的成交（按接收顺序）
+	klines    map[string][]kline   // K线 stream -> 已收到的K线（按开始时间）
+	books     map[string]*book     // 交易对 -> 由增量深度累积的订单簿
+	marks     map[string]float64   // 交易对 -> 最新标记价格
+	positions map[string]*position // 永续交易对 -> 持仓
+	fills     []Fill               // 已成交订单
+	pending   []func()             // 待推送的账户事件（释放锁后推送）
+	nextID    int64                // 订单ID
+	tradeID   int64                // 成交ID
+}
+
+// trade is a recorded public trade
+type trade struct {
+	time       time.Time
+	id         int64
+	price      string
+	quantity   string
+	buyerMaker bool
+}
+
+// kline is the latest push of one bar from a recorded K-line stream
+type kline struct {
+	start time.Time
+	resp  backpack.KlineResponse
+}
+
+// book holds the price levels accumulated from recorded depth updates
+type book struct {
+	bids         map[string]string
+	asks         map[string]string
+	lastUpdateID int64
+	time         int64
+}
+
+// position is an open futures position
+type position struct {
+	quantity   float64 // 多为正，空为负
+	entryPrice float64
+	stopLoss   float64 // 触发价（0 表示未设置）
+	takeProfit float64
+	triggerID  string // 开仓单ID，附带的触发单ID为其加上 -sl / -tp 后缀
+}
+
+// newExchange creates a simulated exchange for symbol
+func newExchange(c clock.Clock, ws *backpack.WSClient, opts Options) *Exchange {
+	return &Exchange{
+		clock:     c,
+		ws:        ws,
+		symbol:    opts.Symbol,
+		quote:     quoteAsset(opts.Symbol),
+		balance:   opts.Balance,
+		takerFee:  opts.TakerFee,
+		tickSize:  opts.TickSize,
+		stepSize:  opts.StepSize,
+		leverage:  "1",
+		trades:    make(map[string][]trade),
+		klines:    make(map[string][]kline),
+		books:     make(map[string]*book),
+		marks:     make(map[string]float64),
+		positions: make(map[string]*position),
+	}
+}
+
+// Fills returns the orders executed so far
+func (e *Exchange) Fills() []Fill {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Fill(nil), e.fills...)
+}
+
+// observe updates the market state with a recorded message; may trigger stop loss / take profit
+func (e *Exchange) observe(rec recorder.Record) {
+	e.mu.Lock()
+	switch kind, _, _ := strings.Cut(rec.Stream, "."); kind {
+	case "trade":
+		var msg backpack.WSTradeMessage
+		if json.Unmarshal(rec.Data, &msg) == nil {
+			e.trades[msg.Symbol] = append(e.trades[msg.Symbol], trade{
+				time:       time.UnixMicro(msg.TradeTime),
+				id:         msg.TradeID,
+				price:      msg.Price,
+				quantity:   msg.Quantity,
+				buyerMaker: msg.BuyerIsMaker,
+			})
+			e.checkTriggers()
+		}
+	case "markPrice":
+		var msg backpack.WSMarkPriceMessage
+		if json.Unmarshal(rec.Data, &msg) == nil {
+			if price, err := strconv.ParseFloat(msg.MarkPrice, 64); err == nil {
+				e.marks[msg.Symbol] = price
+				e.checkTriggers()
+			}
+		}
+	case "depth":
+		var msg backpack.WSDepthMessage
+		if json.Unmarshal(rec.Data, &msg) == nil {
+			e.applyDepth(msg)
+		}
+	case "kline":
+		var msg backpack.WSKlineMessage
+		if json.Unmarshal(rec.Data, &msg) == nil {
+			e.addKline(rec.Stream, msg)
+		}
+	}
+	e.mu.Unlock()
+	e.flushEvents()
+}
+
+// applyDepth applies a depth update to the accumulated book
+func (e *Exchange) applyDepth(msg backpack.WSDepthMessage) {
+	b, ok := e.books[msg.Symbol]
+	if !ok {
+		b = &book{bids: make(map[string]string), asks: make(map[string]string)}
+		e.books[msg.Symbol] = b
+	}
+	apply := func(side map[string]string, levels [][]string) {
+		for _, level := range levels {
+			if len(level) != 2 {
+				continue
+			}
+			if quantity, err := strconv.ParseFloat(level[1], 64); err == nil && quantity == 0 {
+				delete(side, level[0])
+			} else {
+				side[level[0]] = level[1]
+			}
+		}
+	}
+	apply(b.bids, msg.Bids)
+	apply(b.asks, msg.Asks)
+	b.lastUpdateID = msg.LastUpdateID
+	b.time = msg.EngineTime
+}
+
+// addKline stores a K-line push, replacing the earlier push of the same bar
+func (e *Exchange) addKline(stream string, msg backpack.WSKlineMessage) {
+	start, err := time.Parse(time.RFC3339, msg.Start)
+	if err != nil {
+		return
+	}
+	k := kline{start: start, resp: backpack.KlineResponse{
+		Start:       msg.Start,
+		End:         msg.End,
+		Open:        msg.Open,
+		High:        msg.High,
+		Low:         msg.Low,
+		Close:       msg.Close,
+		Volume:      msg.Volume,
+		QuoteVolume: "0",
+		Trades:      strconv.FormatInt(msg.Trades, 10),
+	}}
+
+	series := e.klines[stream]
+	if n := len(series); n > 0 && !series[n-1].start.Before(start) {
+		if series[n-1].start.Equal(start) {
+			series[n-1] = k
+		}
+		return
+	}
+	e.klines[stream] = append(series, k)
+}
+
+// RoundTrip answers a REST request (实现 http.RoundTripper)
+func (e *Exchange) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	e.mu.Lock()
+	status, resp := e.route(req, body)
+	e.mu.Unlock()
+	e.flushEvents()
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}, nil
+}
+
+// apiError is an error response body
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// route dispatches a request to its endpoint (caller holds the lock)
+func (e *Exchange) route(req *http.Request, body []byte) (int, any) {
+	query := req.URL.Query()
+	switch req.Method + " " + req.URL.Path {
+	case "GET /api/v1/klines":
+		return e.getKlines(query.Get("symbol"), query.Get("interval"), query.Get("startTime"), query.Get("endTime"), query.Get("limit"))
+	case "GET /api/v1/trades":
+		return http.StatusOK, e.recentTrades(query.Get("symbol"), atoi(query.Get("limit"), 100), 0)
+	case "GET /api/v1/trades/history":
+		return http.StatusOK, e.recentTrades(query.Get("symbol"), atoi(query.Get("limit"), 100), atoi(query.Get("offset"), 0))
+	case "GET /api/v1/depth":
+		return http.StatusOK, e.depth(query.Get("symbol"), atoi(query.Get("limit"), 1000))
+	case "GET /api/v1/tickers":
+		return http.StatusOK, e.tickers()
+	case "GET /api/v1/markets":
+		return http.StatusOK, e.markets()
+	case "GET /api/v1/capital":
+		return http.StatusOK, map[string]map[string]string{
+			e.quote: {"available": strconv.FormatFloat(e.balance, 'f', -1, 64), "locked": "0", "staked": "0"},
+		}
+	case "GET /api/v1/account":
+		return http.StatusOK, backpack.AccountInfo{
+			FuturesTakerFee: strconv.FormatFloat(e.takerFee, 'f', -1, 64),
+			FuturesMakerFee: strconv.FormatFloat(e.takerFee, 'f', -1, 64),
+			LeverageLimit:   e.leverage,
+		}
+	case "PATCH /api/v1/account":
+		var update backpack.UpdateAccountRequest
+		if json.Unmarshal(body, &update) == nil && update.LeverageLimit != "" {
+			e.leverage = update.LeverageLimit
+		}
+		return http.StatusOK, struct{}{}
+	case "GET /api/v1/position":
+		return http.StatusOK, e.positionList(query.Get("symbol"))
+	case "POST /api/v1/order":
+		var order backpack.OrderRequest
+		if err := json.Unmarshal(body, &order); err != nil {
+			return http.StatusBadRequest, apiError{Code: "INVALID_ORDER", Message: err.Error()}
+		}
+		return e.placeOrder(order)
+	case "GET /api/v1/orders":
+		return http.StatusOK, e.openOrders(query.Get("symbol"))
+	case "DELETE /api/v1/order":
+		var cancel backpack.CancelOrderRequest
+		if err := json.Unmarshal(body, &cancel); err != nil {
+			return http.StatusBadRequest, apiError{Code: "INVALID_ORDER", Message: err.Error()}
+		}
+		return e.cancelTrigger(cancel)
+	}
+	return http.StatusNotFound, apiError{Code: "NOT_FOUND", Message: "回放不支持的接口: " + req.Method + " " + req.URL.Path}
+}
+
+// openOrders returns the stop loss and take profit triggers of symbol's position as resting orders
+// 回放中只有开仓单附带的触发单会挂单，市价单立即成交
+func (e *Exchange) openOrders(symbol string) []backpack.OpenOrder {
+	pos := e.positions[symbol]
+	if pos == nil {
+		return []backpack.OpenOrder{}
+	}
+	side := "Ask"
+	if pos.quantity < 0 {
+		side = "Bid"
+	}
+	orders := []backpack.OpenOrder{}
+	for _, trigger := range []struct {
+		suffix string
+		price  float64
+	}{{"-sl", pos.stopLoss}, {"-tp", pos.takeProfit}} {
+		if trigger.price > 0 {
+			orders = append(orders, backpack.OpenOrder{
+				ID:           pos.triggerID + trigger.suffix,
+				Symbol:       symbol,
+				Side:         side,
+				OrderType:    "Market",
+				Quantity:     formatFloat(math.Abs(pos.quantity)),
+				TriggerPrice: formatFloat(trigger.price),
+				ReduceOnly:   true,
+				Status:       "TriggerPending",
+			})
+		}
+	}
+	return orders
+}
+
+// cancelTrigger cancels a stop loss or take profit trigger by order ID
+func (e *Exchange) cancelTrigger(req backpack.CancelOrderRequest) (int, any) {
+	if pos := e.positions[req.Symbol]; pos != nil {
+		switch req.OrderID {
+		case pos.triggerID + "-sl":
+			if pos.stopLoss > 0 {
+				pos.stopLoss = 0
+				return http.StatusOK, backpack.OrderResponse{ID: req.OrderID, Symbol: req.Symbol, Status: "Cancelled"}
+			}
+		case pos.triggerID + "-tp":
+			if pos.takeProfit > 0 {
+				pos.takeProfit = 0
+				return http.StatusOK, backpack.OrderResponse{ID: req.OrderID, Symbol: req.Symbol, Status: "Cancelled"}
+			}
+		}
+	}
+	return http.StatusNotFound, apiError{Code: "RESOURCE_NOT_FOUND", Message: "订单不存在: " + req.OrderID}
+}
+
+// getKlines returns bars starting within [startTime, endTime] (秒)
+// 录制了对应周期的K线推送时使用推送数据，否则由已收到的成交聚合（当前未收盘的K线也会返回，与交易所一致）
+func (e *Exchange) getKlines(symbol, interval, startParam, endParam, limitParam string) (int, any) {
+	iv, err := marketdata.ParseInterval(interval)
+	if err != nil {
+		return http.StatusBadRequest, apiError{Code: "INVALID_CLIENT_REQUEST", Message: err.Error()}
+	}
+	from := time.Unix(int64(atoi(startParam, 0)), 0)
+	to := e.clock.Now()
+	if endParam != "" {
+		if end := time.Unix(int64(atoi(endParam, 0)), 0); end.Before(to) {
+			to = end
+		}
+	}
+
+	var bars []backpack.KlineResponse
+	if series := e.klines[backpack.KlineStream(symbol, interval)]; len(series) > 0 {
+		for _, k := range series {
+			if !k.start.Before(from) && !k.start.After(to) {
+				bars = append(bars, k.resp)
+			}
+		}
+	} else {
+		bars = aggregateTrades(e.trades[symbol], iv, from, to)
+	}
+
+	if limit := atoi(limitParam, 0); limit > 0 && len(bars) > limit {
+		bars = bars[len(bars)-limit:]
+	}
+	return http.StatusOK, bars
+}
+
+// aggregateTrades builds bars of iv from trades whose bar starts within [from, to]
+func aggregateTrades(trades []trade, iv marketdata.Interval, from, to time.Time) []backpack.KlineResponse {
+	type bar struct {
+		open, high, low, close float64
+		volume, quote          float64
+		count                  int
+	}
+
+	// 成交按接收顺序保存，允许少量乱序：向前扫描到早于 from 一个周期为止
+	first := len(trades)
+	for first > 0 && !trades[first-1].time.Before(from.Add(-iv.Duration())) {
+		first--
+	}
+
+	bars := make(map[time.Time]*bar)
+	for _, t := range trades[first:] {
+		start := iv.Floor(t.time)
+		if start.Before(from) || start.After(to) {
+			continue
+		}
+		price, err1 := strconv.ParseFloat(t.price, 64)
+		quantity, err2 := strconv.ParseFloat(t.quantity, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		b, ok := bars[start]
+		if !ok {
+			b = &bar{open: price, high: price, low: price}
+			bars[start] = b
+		}
+		b.high = math.Max(b.high, price)
+		b.low = math.Min(b.low, price)
+		b.close = price
+		b.volume += quantity
+		b.quote += price * quantity
+		b.count++
+	}
+
+	starts := make([]time.Time, 0, len(bars))
+	for start := range bars {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	resp := make([]backpack.KlineResponse, 0, len(starts))
+	for _, start := range starts {
+		b := bars[start]
+		resp = append(resp, backpack.KlineResponse{
+			Start:       start.UTC().Format(klineTimeFormat),
+			End:         iv.Next(start).UTC().Format(klineTimeFormat),
+			Open:        formatFloat(b.open),
+			High:        formatFloat(b.high),
+			Low:         formatFloat(b.low),
+			Close:       formatFloat(b.close),
+			Volume:      formatFloat(b.volume),
+			QuoteVolume: formatFloat(b.quote),
+			Trades:      strconv.Itoa(b.count),
+		})
+	}
+	return resp
+}
+
+// recentTrades returns up to limit trades, skipping the newest offset (按时间升序)
+func (e *Exchange) recentTrades(symbol string, limit, offset int) []backpack.TradeResponse {
+	trades := e.trades[symbol]
+	end := max(len(trades)-offset, 0)
+	start := max(end-min(limit, backpack.MaxTradesLimit), 0)
+
+	resp := make([]backpack.TradeResponse, 0, end-start)
+	for _, t := range trades[start:end] {
+		price, _ := strconv.ParseFloat(t.price, 64)
+		quantity, _ := strconv.ParseFloat(t.quantity, 64)
+		resp = append(resp, backpack.TradeResponse{
+			ID:            t.id,
+			Price:         t.price,
+			Quantity:      t.quantity,
+			QuoteQuantity: formatFloat(price * quantity),
+			Timestamp:     t.time.UnixMilli(),
+			IsBuyerMaker:  t.buyerMaker,
+		})
+	}
+	return resp
+}
+
+// depth returns the best limit levels of the accumulated book
+// 录制中没有快照，只包含录制期间变化过的档位
+func (e *Exchange) depth(symbol string, limit int) backpack.DepthResponse {
+	resp := backpack.DepthResponse{Asks: [][]string{}, Bids: [][]string{}, LastUpdateID: "0"}
+	b, ok := e.books[symbol]
+	if !ok {
+		return resp
+	}
+
+	levels := func(side map[string]string, descending bool) [][]string {
+		prices := make([]string, 0, len(side))
+		for price := range side {
+			prices = append(prices, price)
+		}
+		sort.Slice(prices, func(i, j int) bool {
+			pi, _ := strconv.ParseFloat(prices[i], 64)
+			pj, _ := strconv.ParseFloat(prices[j], 64)
+			if descending {
+				return pi > pj
+			}
+			return pi < pj
+		})
+		if len(prices) > limit {
+			prices = prices[:limit]
+		}
+		out := make([][]string, len(prices))
+		for i, price := range prices {
+			out[i] = []string{price, side[price]}
+		}
+		return out
+	}
+
+	resp.Bids = levels(b.bids, true)
+	resp.Asks = levels(b.asks, false)
+	resp.LastUpdateID = strconv.FormatInt(b.lastUpdateID, 10)
+	resp.Timestamp = b.time
+	return resp
+}
+
+// tickers returns 24h statistics of every symbol with recorded trades
+func (e *Exchange) tickers() []backpack.TickerResponse {
+	symbols := make([]string, 0, len(e.trades))
+	for symbol := range e.trades {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	since := e.clock.Now().Add(-24 * time.Hour)
+	resp := make([]backpack.TickerResponse, 0, len(symbols))
+	for _, symbol := range symbols {
+		var volume, quote float64
+		count := 0
+		trades := e.trades[symbol]
+		for i := len(trades) - 1; i >= 0 && trades[i].time.After(since); i-- {
+			price, _ := strconv.ParseFloat(trades[i].price, 64)
+			quantity, _ := strconv.ParseFloat(trades[i].quantity, 64)
+			volume += quantity
+			quote += price * quantity
+			count++
+		}
+		resp = append(resp, backpack.TickerResponse{
+			Symbol:      symbol,
+			LastPrice:   trades[len(trades)-1].price,
+			Volume:      formatFloat(volume),
+			QuoteVolume: formatFloat(quote),
+			Trades:      strconv.Itoa(count),
+		})
+	}
+	return resp
+}
+
+// markets returns the spot and perpetual markets of the replayed symbol
+func (e *Exchange) markets() []backpack.Market {
+	filters := map[string]any{
+		"priceFilter":    map[string]any{"tickSize": e.tickSize},
+		"quantityFilter": map[string]any{"minQuantity": e.stepSize, "stepSize": e.stepSize},
+	}
+	spot := strings.TrimSuffix(e.symbol, "_PERP")
+	base, _, _ := strings.Cut(spot, "_")
+	return []backpack.Market{
+		{BaseSymbol: base, QuoteSymbol: e.quote, Symbol: spot, MarketType: "SPOT", Visible: true, OrderBookState: "Open", Filters: filters},
+		{BaseSymbol: base, QuoteSymbol: e.quote, Symbol: spot + "_PERP", MarketType: "PERP", Visible: true, OrderBookState: "Open", Filters: filters},
+	}
+}
+
+// positionList returns the open positions (symbol 为空时返回全部)
+func (e *Exchange) positionList(symbol string) []backpack.PositionResponse {
+	symbols := make([]string, 0, len(e.positions))
+	for s := range e.positions {
+		if symbol == "" || s == symbol {
+			symbols = append(symbols, s)
+		}
+	}
+	sort.Strings(symbols)
+
+	resp := make([]backpack.PositionResponse, 0, len(symbols))
+	for _, s := range symbols {
+		pos := e.positions[s]
+		mark := e.markPrice(s)
+		resp = append(resp, backpack.PositionResponse{
+			Symbol:        s,
+			EntryPrice:    formatFloat(pos.entryPrice),
+			MarkPrice:     formatFloat(mark),
+			NetQuantity:   formatFloat(pos.quantity),
+			PositionSize:  formatFloat(pos.quantity),
+			UnrealizedPnl: formatFloat((mark - pos.entryPrice) * pos.quantity),
+			NetCost:       formatFloat(pos.entryPrice * pos.quantity),
+		})
+	}
+	return resp
+}
+
+// placeOrder fills a market order at the latest price and updates the position
+func (e *Exchange) placeOrder(order backpack.OrderRequest) (int, any) {
+	quantity, err := strconv.ParseFloat(order.Quantity, 64)
+	if err != nil || quantity <= 0 {
+		return http.StatusBadRequest, apiError{Code: "INVALID_ORDER", Message: "无效的数量: " + order.Quantity}
+	}
+	if order.Side != "Bid" && order.Side != "Ask" {
+		return http.StatusBadRequest, apiError{Code: "INVALID_ORDER", Message: "无效的方向: " + order.Side}
+	}
+	price := e.lastPrice(order.Symbol)
+	if price <= 0 {
+		return http.StatusBadRequest, apiError{Code: "INVALID_ORDER", Message: "回放数据中没有 " + order.Symbol + " 的成交价"}
+	}
+
+	signed := quantity
+	if order.Side == "Ask" {
+		signed = -quantity
+	}
+	pos := e.positions[order.Symbol]
+	if order.ReduceOnly {
+		if pos == nil || pos.quantity*signed >= 0 {
+			return http.StatusBadRequest, apiError{Code: "INVALID_ORDER", Message: "没有可减少的持仓"}
+		}
+		signed = math.Copysign(math.Min(quantity, math.Abs(pos.quantity)), signed)
+	}
+
+	reason := "entry"
+	if pos != nil && pos.quantity*signed < 0 {
+		reason = "close"
+	}
+	fill := e.execute(order.Symbol, order.Side, signed, price, reason, "")
+	fill.StopLoss = order.StopLossTriggerPrice
+	fill.TakeProfit = order.TakeProfitTriggerPrice
+	e.fills[len(e.fills)-1] = fill
+
+	// 开仓单附带的止损止盈触发价
+	if pos := e.positions[order.Symbol]; pos != nil && reason == "entry" {
+		pos.stopLoss, _ = strconv.ParseFloat(order.StopLossTriggerPrice, 64)
+		pos.takeProfit, _ = strconv.ParseFloat(order.TakeProfitTriggerPrice, 64)
+		pos.triggerID = fill.OrderID
+	}
+
+	return http.StatusOK, backpack.OrderResponse{
+		ID:                    fill.OrderID,
+		Symbol:                order.Symbol,
+		Side:                  order.Side,
+		OrderType:             "Market",
+		Quantity:              order.Quantity,
+		Status:                "Filled",
+		TimeInForce:           order.TimeInForce,
+		ReduceOnly:            order.ReduceOnly,
+		CreatedAt:             e.clock.Now().UnixMilli(),
+		ExecutedQuantity:      formatFloat(fill.Quantity),
+		ExecutedQuoteQuantity: formatFloat(fill.Quantity * fill.Price),
+	}
+}
+
+// execute fills signed quantity of symbol at price, updates the position and balance and queues the account pushes
+// triggerPrice 非空表示止损/止盈触发单
+func (e *Exchange) execute(symbol, side string, signed, price float64, reason, triggerPrice string) Fill {
+	now := e.clock.Now()
+	e.nextID++
+	e.tradeID++
+	quantity := math.Abs(signed)
+	fee := quantity * price * e.takerFee
+	fill := Fill{
+		Time:     now,
+		OrderID:  strconv.FormatInt(e.nextID, 10),
+		Symbol:   symbol,
+		Side:     side,
+		Quantity: quantity,
+		Price:    price,
+		Fee:      fee,
+		Reason:   reason,
+	}
+	e.fills = append(e.fills, fill)
+	e.balance -= fee
+
+	// 更新持仓：同向加仓按均价，反向先平仓并结算盈亏
+	event := backpack.PositionEventAdjusted
+	pos := e.positions[symbol]
+	switch {
+	case pos == nil:
+		pos = &position{quantity: signed, entryPrice: price}
+		e.positions[symbol] = pos
+		event = backpack.PositionEventOpened
+	case pos.quantity*signed > 0:
+		total := pos.quantity + signed
+		pos.entryPrice = (pos.entryPrice*pos.quantity + price*signed) / total
+		pos.quantity = total
+	default:
+		closed := math.Copysign(math.Min(quantity, math.Abs(pos.quantity)), pos.quantity)
+		e.balance += (price - pos.entryPrice) * closed
+		pos.quantity += signed
+		if math.Abs(pos.quantity) < 1e-12 {
+			delete(e.positions, symbol)
+			event = backpack.PositionEventClosed
+		} else if pos.quantity*closed < 0 {
+			pos.entryPrice = price // 反手
+		}
+	}
+
+	micros := now.UnixMicro()
+	update := backpack.WSOrderUpdate{
+		EventType:             backpack.OrderEventFill,
+		EventTime:             micros,
+		Symbol:                symbol,
+		Side:                  side,
+		OrderType:             "MARKET",
+		Quantity:              formatFloat(quantity),
+		TriggerPrice:          triggerPrice,
+		Status:                "Filled",
+		OrderID:               fill.OrderID,
+		TradeID:               e.tradeID,
+		FillQuantity:          formatFloat(quantity),
+		ExecutedQuantity:      formatFloat(quantity),
+		ExecutedQuoteQuantity: formatFloat(quantity * price),
+		FillPrice:             formatFloat(price),
+		Fee:                   formatFloat(fee),
+		FeeSymbol:             e.quote,
+		EngineTime:            micros,
+		Origin:                "USER",
+	}
+	positionUpdate := backpack.WSPositionUpdate{
+		EventType:   event,
+		EventTime:   micros,
+		Symbol:      symbol,
+		MarkPrice:   backpack.FlexFloat(e.markPrice(symbol)),
+		NetQuantity: 0,
+		EngineTime:  micros,
+	}
+	if pos := e.positions[symbol]; pos != nil {
+		positionUpdate.EntryPrice = backpack.FlexFloat(pos.entryPrice)
+		positionUpdate.NetQuantity = backpack.FlexFloat(pos.quantity)
+	}
+	e.push(now, backpack.OrderUpdateStream(symbol), update)
+	e.push(now, backpack.PositionUpdateStream(symbol), positionUpdate)
+	return fill
+}
+
+// checkTriggers executes stop loss / take profit of positions whose mark price crossed a trigger (caller holds the lock)
+func (e *Exchange) checkTriggers() {
+	symbols := make([]string, 0, len(e.positions))
+	for symbol := range e.positions {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		pos := e.positions[symbol]
+		mark := e.markPrice(symbol)
+		if mark <= 0 {
+			continue
+		}
+
+		long := pos.quantity > 0
+		var reason string
+		var trigger float64
+		switch {
+		case pos.stopLoss > 0 && ((long && mark <= pos.stopLoss) || (!long && mark >= pos.stopLoss)):
+			reason, trigger = "stopLoss", pos.stopLoss
+		case pos.takeProfit > 0 && ((long && mark >= pos.takeProfit) || (!long && mark <= pos.takeProfit)):
+			reason, trigger = "takeProfit", pos.takeProfit
+		default:
+			continue
+		}
+
+		side := "Ask"
+		if !long {
+			side = "Bid"
+		}
+		e.execute(symbol, side, -pos.quantity, e.lastPrice(symbol), reason, formatFloat(trigger))
+	}
+}
+
+// lastPrice returns the latest trade price of symbol, falling back to its mark price or the spot symbol's trades
+func (e *Exchange) lastPrice(symbol string) float64 {
+	for _, s := range []string{symbol, strings.TrimSuffix(symbol, "_PERP")} {
+		if trades := e.trades[s]; len(trades) > 0 {
+			if price, err := strconv.ParseFloat(trades[len(trades)-1].price, 64); err == nil {
+				return price
+			}
+		}
+		if mark := e.marks[s]; mark > 0 && s == symbol {
+			return mark
+		}
+	}
+	return 0
+}
+
+// markPrice returns the latest mark price of symbol (未录制标记价格时使用最新成交价)
+func (e *Exchange) markPrice(symbol string) float64 {
+	if mark := e.marks[symbol]; mark > 0 {
+		return mark
+	}
+	return e.lastPrice(symbol)
+}
+
+// push queues an account stream push; it is delivered once the lock is released
+func (e *Exchange) push(at time.Time, stream string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	e.pending = append(e.pending, func() {
+		e.ws.Deliver(at, stream, data)
+	})
+}
+
+// flushEvents delivers the queued account pushes
+func (e *Exchange) flushEvents() {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+
+	for _, deliver := range pending {
+		deliver()
+	}
+}
+
+// quoteAsset returns the quote asset of symbol (SOL_USDC、SOL_USDC_PERP -> USDC)
+func quoteAsset(symbol string) string {
+	parts := strings.Split(strings.TrimSuffix(symbol, "_PERP"), "_")
+	if len(parts) < 2 {
+		return "USDC"
+	}
+	return parts[len(parts)-1]
+}
+
+// formatFloat formats a number with the fewest digits that parse back to the same value
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// atoi parses a query parameter, returning def when empty or invalid
+func atoi(value string, def int) int {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return def
+}
@@ -0,0 +1,234 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/clock"
+	"vagues-go/src/recorder"
+	"vagues-go/src/trading"
+)
+
+// DefaultWarmup 未指定开始时间时，录制开始后仅用于积累行情（不驱动交易系统）的时长
+const DefaultWarmup = 3 * time.Hour
+
+// Options configures a replay session
+type Options struct {
+	Dir      string        // 录制目录（MARKET_DATA_RECORD_DIR）
+	Symbol   string        // 交易对（同时加载对应的现货/永续录制）
+	From     time.Time     // 交易系统启动时刻（为零时为首条记录 + Warmup）
+	To       time.Time     // 回放结束时刻（为零时回放到最后一条记录）
+	Warmup   time.Duration // 未指定 From 时的预热时长
+	Balance  float64       // 初始余额
+	TakerFee float64       // Taker 手续费率
+	TickSize string        // 价格精度
+	StepSize string        // 数量精度
+}
+
+// Session replays recorded market data through a TradingSystem
+// 模拟时钟、回放 WebSocket 客户端和模拟交易所（REST）共同替代真实环境：
+// 每条记录按接收时间注入，注入前先触发到期的定时器，注入后等待交易系统处理完毕，
+// 因此相同的录制数据和配置总是产生相同的信号、日志和订单
+type Session struct {
+	opts     Options
+	records  []recorder.Record
+	clock    *clock.Fake
+	ws       *backpack.WSClient
+	client   *backpack.Client
+	exchange *Exchange
+}
+
+// NewSession loads the recorded data of opts.Symbol and creates the simulated environment
+func NewSession(opts Options) (*Session, error) {
+	if opts.Symbol == "" {
+		return nil, fmt.Errorf("未指定回放交易对")
+	}
+	if opts.Warmup <= 0 {
+		opts.Warmup = DefaultWarmup
+	}
+	if opts.Balance <= 0 {
+		opts.Balance = DefaultBalance
+	}
+	if opts.TakerFee <= 0 {
+		opts.TakerFee = DefaultTakerFee
+	}
+	if opts.TickSize == "" {
+		opts.TickSize = DefaultTickSize
+	}
+	if opts.StepSize == "" {
+		opts.StepSize = DefaultStepSize
+	}
+
+	// 加载现货和永续两个交易对的录制（逐笔成交可能订阅在现货上）
+	toDay := ""
+	if !opts.To.IsZero() {
+		toDay = opts.To.UTC().Format("2006-01-02")
+	}
+	spot := strings.TrimSuffix(opts.Symbol, "_PERP")
+	var paths []string
+	for _, symbol := range []string{spot, spot + "_PERP"} {
+		files, err := recorder.Files(opts.Dir, symbol, "", toDay)
+		if err != nil {
+			return nil, fmt.Errorf("查找 %s 录制文件失败: %w", symbol, err)
+		}
+		paths = append(paths, files...)
+	}
+	records, err := recorder.Load(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("读取录制文件失败: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s 中没有 %s 的录制数据", opts.Dir, opts.Symbol)
+	}
+	if opts.From.IsZero() {
+		opts.From = records[0].Received.Add(opts.Warmup)
+	}
+
+	client, err := backpack.NewClient("replay", base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	if err != nil {
+		return nil, err
+	}
+	c := clock.NewFake(opts.From)
+	ws := backpack.NewReplayWSClient()
+	exchange := newExchange(c, ws, opts)
+	client.SetTransport(exchange)
+	client.SetRateLimiter(nil) // 模拟交易所立即应答，不需要限流
+
+	return &Session{
+		opts:     opts,
+		records:  records,
+		clock:    c,
+		ws:       ws,
+		client:   client,
+		exchange: exchange,
+	}, nil
+}
+
+// Clock returns the fake clock to set as Config.Clock
+func (s *Session) Clock() clock.Clock {
+	return s.clock
+}
+
+// WSClient returns the replay WebSocket client to set as Config.WSClient
+func (s *Session) WSClient() *backpack.WSClient {
+	return s.ws
+}
+
+// Client returns the REST client answered by the simulated exchange
+func (s *Session) Client() *backpack.Client {
+	return s.client
+}
+
+// Fills returns the orders executed by the simulated exchange
+func (s *Session) Fills() []Fill {
+	return s.exchange.Fills()
+}
+
+// From returns the time the trading system starts at
+func (s *Session) From() time.Time {
+	return s.opts.From
+}
+
+// Run replays the records through ts until the data (or opts.To) is exhausted or ctx is cancelled
+// ts 必须由 Client()、WSClient() 和 Clock() 创建；返回交易系统 Run 的错误
+func (s *Session) Run(ctx context.Context, ts *trading.TradingSystem) error {
+	// 开始时刻之前的记录只用于积累交易所行情（历史K线、成交和深度）
+	i := 0
+	for ; i < len(s.records) && s.records[i].Received.Before(s.opts.From); i++ {
+		s.exchange.observe(s.records[i])
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- ts.Run(runCtx)
+		cancel()
+	}()
+
+	// 等待交易系统完成启动（拉取历史、订阅）
+	err := ts.Sync(runCtx)
+	for ; err == nil && i < len(s.records); i++ {
+		rec := s.records[i]
+		if !s.opts.To.IsZero() && rec.Received.After(s.opts.To) {
+			break
+		}
+		if err = s.advance(runCtx, ts, rec.Received); err != nil {
+			break
+		}
+
+		// 先更新交易所（可能触发止损止盈并推送账户事件），再注入推送；录制中的账户推送属于实盘账户，不回放
+		s.exchange.observe(rec)
+		if err = ts.Sync(runCtx); err != nil {
+			break
+		}
+		if strings.HasPrefix(rec.Stream, "account.") {
+			continue
+		}
+		s.ws.Deliver(rec.Received, rec.Stream, rec.Data)
+		err = ts.Sync(runCtx)
+	}
+	if err == nil && !s.opts.To.IsZero() {
+		err = s.advance(runCtx, ts, s.opts.To)
+	}
+
+	cancel()
+	runErr := <-done
+	s.ws.Disconnect()
+	return runErr
+}
+
+// advance moves the clock to t, letting the trading system handle every timer due on the way
+func (s *Session) advance(ctx context.Context, ts *trading.TradingSystem, t time.Time) error {
+	for {
+		next, ok := s.clock.Next()
+		if !ok || next.After(t) {
+			break
+		}
+		s.clock.Set(next)
+		if err := ts.Sync(ctx); err != nil {
+			return err
+		}
+	}
+	s.clock.Set(t)
+	return nil
+}
+
+// LogWriter prefixes every log line with the fake time (配合 log.SetFlags(0) 使用，使日志可逐字节比较)
+type LogWriter struct {
+	mu    sync.Mutex
+	clock clock.Clock
+	w     io.Writer
+}
+
+// NewLogWriter creates a LogWriter writing to w
+func NewLogWriter(c clock.Clock, w io.Writer) *LogWriter {
+	return &LogWriter{clock: c, w: w}
+}
+
+// Write writes p, prefixing each line with the current fake time
+func (lw *LogWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	prefix := lw.clock.Now().UTC().Format("2006/01/02 15:04:05 ")
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		buf.WriteString(prefix)
+		buf.Write(line)
+	}
+	if _, err := lw.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -14,7 +14,7 @@ type EMA_MACD_Strategy struct {
 // NewEMA_MACD_Strategy creates a new strategy instance
 func NewEMA_MACD_Strategy() *EMA_MACD_Strategy {
 	return &EMA_MACD_Strategy{
-		calculator: indicators.NewCalculator(200), // Keep 200 periods for calculations
+		calculator: indicators.NewCalculator(),
 		history:    make([]models.MarketData, 0),
 	}
 }
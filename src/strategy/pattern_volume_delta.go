@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"time"
+	"vagues-go/src/indicators"
 	"vagues-go/src/models"
 )
 
@@ -14,6 +16,10 @@ type PatternVolumeDeltaStrategy struct {
 	bLookback int     // Breakout lookback periods (default 5)
 	mRatio    float64 // Momentum candle ratio (default 0.7)
 
+	// Volatility parameters
+	atrPeriod       int     // ATR period (default 14)
+	breakoutATRMult float64 // Breakout must clear the range by this many ATRs (0 = disabled)
+
 	// Volume parameters
 	vLookback int     // Volume lookback window (default 20)
 	vMult     float64 // Volume multiplier (default 1.25)
@@ -23,14 +29,28 @@ type PatternVolumeDeltaStrategy struct {
 	deltaThreshMode    string  // "dynamic" or "absolute"
 	deltaThreshAbs     float64 // Absolute delta threshold
 	deltaDynMult       float64 // Dynamic threshold multiplier (default 0.8)
+	deltaWindow        string  // "bar" (whole candle) or "ticks" (last deltaLookbackTicks trades)
+
+	// Order book confirmation
+	bookImbalanceMin float64 // Minimum book imbalance in the signal direction (0 = disabled)
 
 	// Trend filter
-	useTrendFilter bool // Whether to use trend filter
-	emaLong        int  // Long EMA period (default 30)
+	useTrendFilter bool          // Whether to use trend filter
+	trendFilters   []TrendFilter // Trend filters that must all agree (default: EMA(30))
+
+	// Divergence filter / exit
+	useDivergenceFilter bool                        // Reject entries against a recent regular divergence
+	divergenceSources   []string                    // Oscillators checked for divergence (macd, rsi, cvd)
+	divergenceConfig    indicators.DivergenceConfig // Pivot and pairing parameters
+	divergenceWindow    int                         // Divergence must be confirmed within this many bars (default 5)
+
+	// Indicators declared by this strategy (in addition to indicators.DefaultSpecs)
+	indicatorSpecs []indicators.Spec
 
 	// History
 	history      []models.MarketData
 	deltaHistory []float64 // History of delta values for dynamic threshold
+	barDeltas    []float64 // History of whole-bar delta values (for CVD divergence)
 
 	// Logging control
 	verboseLogging bool // Whether to output verbose filter logs (default false)
@@ -45,14 +65,20 @@ func NewPatternVolumeDeltaStrategy() *PatternVolumeDeltaStrategy {
 		hRatio:             2.0,
 		bLookback:          5,
 		mRatio:             0.7,
+		atrPeriod:          14,
+		breakoutATRMult:    0,
 		vLookback:          20,
 		vMult:              1.25,
 		deltaLookbackTicks: 40,
 		deltaThreshMode:    "dynamic",
 		deltaThreshAbs:     100.0,
 		deltaDynMult:       0.8,
+		deltaWindow:        "bar",
 		useTrendFilter:     true,
-		emaLong:            30,
+		trendFilters:       []TrendFilter{EMATrendFilter{Period: 30}},
+		divergenceSources:  []string{indicators.DivergenceSourceMACD, indicators.DivergenceSourceRSI},
+		divergenceConfig:   indicators.DefaultDivergenceConfig(),
+		divergenceWindow:   5,
 		history:            make([]models.MarketData, 0),
 		deltaHistory:       make([]float64, 0),
 		verboseLogging:     false, // 默认关闭详细日志
@@ -64,6 +90,117 @@ func (s *PatternVolumeDeltaStrategy) SetVerboseLogging(enabled bool) {
 	s.verboseLogging = enabled
 }
 
+// AddIndicators 声明策略额外需要的指标（如 indicators.RSI(7)、indicators.NewSpec("bbands", 20, 2)）
+func (s *PatternVolumeDeltaStrategy) AddIndicators(specs ...indicators.Spec) {
+	s.indicatorSpecs = append(s.indicatorSpecs, specs...)
+}
+
+// SetBreakoutATRFilter 设置波动率归一化突破：收盘价需超出此前 bLookback 根K线区间 mult × ATR(period) 才视为突破（mult 为 0 时关闭）
+func (s *PatternVolumeDeltaStrategy) SetBreakoutATRFilter(period int, mult float64) {
+	if period > 0 {
+		s.atrPeriod = period
+	}
+	s.breakoutATRMult = mult
+}
+
+// SetTrendFilters 设置趋势过滤器（所有过滤器都通过才允许进场），如 ADX > 20 且 Supertrend 方向一致
+func (s *PatternVolumeDeltaStrategy) SetTrendFilters(filters ...TrendFilter) {
+	s.trendFilters = filters
+	s.useTrendFilter = len(filters) > 0
+}
+
+// SetDeltaWindow 设置 Delta 判断窗口："bar" 使用整根K线的主动买卖差，"ticks" 使用收盘前最近 lookbackTicks 笔成交
+// 估算的 Delta（无逐笔数据）始终按整根K线判断
+func (s *PatternVolumeDeltaStrategy) SetDeltaWindow(window string, lookbackTicks int) {
+	if window == "bar" || window == "ticks" {
+		s.deltaWindow = window
+	}
+	if lookbackTicks > 0 {
+		s.deltaLookbackTicks = lookbackTicks
+	}
+}
+
+// DeltaLookbackTicks returns the DELTA_LOOKBACK_TICKS window size
+func (s *PatternVolumeDeltaStrategy) DeltaLookbackTicks() int {
+	return s.deltaLookbackTicks
+}
+
+// deltaValue returns the delta measure used by the Delta filter
+func (s *PatternVolumeDeltaStrategy) deltaValue(delta models.Delta) float64 {
+	if s.deltaWindow == "ticks" && !delta.Estimated {
+		return delta.TickValue
+	}
+	return delta.Value
+}
+
+// SetBookImbalanceFilter 设置订单簿失衡确认：做多要求失衡度 >= min，做空要求 <= -min（min 为 0 时关闭）
+// 数据中没有订单簿统计（未订阅深度或未同步）时不过滤
+func (s *PatternVolumeDeltaStrategy) SetBookImbalanceFilter(min float64) {
+	s.bookImbalanceMin = math.Max(min, 0)
+}
+
+// AddTrendFilters 在现有趋势过滤器之后追加过滤器（如多周期确认）
+func (s *PatternVolumeDeltaStrategy) AddTrendFilters(filters ...TrendFilter) {
+	s.SetTrendFilters(append(s.trendFilters, filters...)...)
+}
+
+// SetDivergenceFilter 设置背离过滤：近期出现反向常规背离时拒绝进场（如做多时出现看跌背离）
+// sources 为空时沿用默认的 macd、rsi
+func (s *PatternVolumeDeltaStrategy) SetDivergenceFilter(enabled bool, sources ...string) {
+	s.useDivergenceFilter = enabled
+	if len(sources) > 0 {
+		s.divergenceSources = sources
+	}
+}
+
+// SetDivergenceConfig 设置背离检测参数；window 为背离确认后仍视为有效的K线数
+func (s *PatternVolumeDeltaStrategy) SetDivergenceConfig(cfg indicators.DivergenceConfig, window int) {
+	s.divergenceConfig = cfg
+	if window > 0 {
+		s.divergenceWindow = window
+	}
+}
+
+// RecentDivergences returns divergences confirmed within the divergence window on the strategy history
+func (s *PatternVolumeDeltaStrategy) RecentDivergences() []indicators.Divergence {
+	divergences := indicators.DetectMarketDivergences(s.history, s.barDeltas, s.divergenceConfig, s.divergenceSources...)
+	return indicators.RecentDivergences(divergences, len(s.history), s.divergenceWindow)
+}
+
+// CheckDivergenceExit reports whether an open position should be closed because of an opposing regular divergence
+// position 为持仓方向（SignalLongEntry / SignalShortEntry），只考虑确认K线在 after 之后收盘的背离，
+// 避免开仓时已存在或已处理过的背离在窗口内每根K线重复触发；返回平仓信号、原因和确认K线的收盘时间
+func (s *PatternVolumeDeltaStrategy) CheckDivergenceExit(position models.SignalType, after time.Time) (models.SignalType, string, time.Time) {
+	for _, div := range s.RecentDivergences() {
+		if !div.IsRegular() || div.Confirmed < 0 || div.Confirmed >= len(s.history) {
+			continue
+		}
+		confirmedAt := s.history[div.Confirmed].KLine.EndTime
+		if !confirmedAt.After(after) {
+			continue
+		}
+		if position == models.SignalLongEntry && !div.IsBullish() {
+			return models.SignalLongExit, div.String(), confirmedAt
+		}
+		if position == models.SignalShortEntry && div.IsBullish() {
+			return models.SignalShortExit, div.String(), confirmedAt
+		}
+	}
+	return models.SignalNone, "", time.Time{}
+}
+
+// RequiredIndicators returns the indicator specs this strategy reads from MarketData
+func (s *PatternVolumeDeltaStrategy) RequiredIndicators() []indicators.Spec {
+	var specs []indicators.Spec
+	for _, filter := range s.trendFilters {
+		specs = append(specs, filter.RequiredIndicators()...)
+	}
+	if s.breakoutATRMult > 0 {
+		specs = append(specs, indicators.ATR(s.atrPeriod))
+	}
+	return append(specs, s.indicatorSpecs...)
+}
+
 // GetLastFilterFailure 获取最后一次过滤失败的原因
 func (s *PatternVolumeDeltaStrategy) GetLastFilterFailure() string {
 	return s.lastFilterFailure
@@ -78,10 +215,14 @@ func (s *PatternVolumeDeltaStrategy) Analyze(data models.MarketData, delta model
 	}
 
 	// Add delta to history
-	s.deltaHistory = append(s.deltaHistory, delta.Value)
+	s.deltaHistory = append(s.deltaHistory, s.deltaValue(delta))
 	if len(s.deltaHistory) > 100 {
 		s.deltaHistory = s.deltaHistory[1:]
 	}
+	s.barDeltas = append(s.barDeltas, delta.Value)
+	if len(s.barDeltas) > len(s.history) {
+		s.barDeltas = s.barDeltas[len(s.barDeltas)-len(s.history):]
+	}
 
 	// Need at least 2 periods for pattern detection
 	if len(s.history) < 2 {
@@ -120,28 +261,58 @@ func (s *PatternVolumeDeltaStrategy) Analyze(data models.MarketData, delta model
 	// 3. Delta filter
 	deltaOk := s.checkDelta(delta, pattern.Direction)
 	if !deltaOk {
-		s.lastFilterFailure = fmt.Sprintf("Delta过滤未通过 (Delta值: %.2f, 方向: %s)", delta.Value, getPatternDirectionName(pattern.Direction))
+		s.lastFilterFailure = fmt.Sprintf("Delta过滤未通过 (Delta值: %.2f, 方向: %s)", s.deltaValue(delta), getPatternDirectionName(pattern.Direction))
 		if s.verboseLogging {
-			log.Printf("策略过滤: Delta过滤未通过 (Delta值: %.2f, 方向: %s)", delta.Value, getPatternDirectionName(pattern.Direction))
+			log.Printf("策略过滤: Delta过滤未通过 (Delta值: %.2f, 方向: %s)", s.deltaValue(delta), getPatternDirectionName(pattern.Direction))
 		}
 		return models.SignalNone
 	}
 	if s.verboseLogging {
-		log.Printf("策略过滤: Delta过滤通过 (Delta值: %.2f)", delta.Value)
+		log.Printf("策略过滤: Delta过滤通过 (Delta值: %.2f)", s.deltaValue(delta))
+	}
+
+	// 3b. Order book imbalance confirmation (optional)
+	if s.bookImbalanceMin > 0 {
+		bookOk, detail := s.checkBookImbalance(current, pattern.Direction)
+		if !bookOk {
+			s.lastFilterFailure = fmt.Sprintf("订单簿失衡过滤未通过 (%s, 方向: %s)", detail, getPatternDirectionName(pattern.Direction))
+			if s.verboseLogging {
+				log.Printf("策略过滤: 订单簿失衡过滤未通过 (%s, 方向: %s)", detail, getPatternDirectionName(pattern.Direction))
+			}
+			return models.SignalNone
+		}
+		if s.verboseLogging {
+			log.Printf("策略过滤: 订单簿失衡过滤通过 (%s)", detail)
+		}
 	}
 
 	// 4. Trend filter (optional)
 	if s.useTrendFilter {
-		trendOk := s.checkTrend(current, pattern.Direction)
+		trendOk, filterName, detail := s.checkTrend(current, pattern.Direction)
 		if !trendOk {
-			s.lastFilterFailure = fmt.Sprintf("Trend过滤未通过 (价格: %.4f, EMA30: %.4f, 方向: %s)", current.KLine.Close, current.Indicators.EMA30, getPatternDirectionName(pattern.Direction))
+			s.lastFilterFailure = fmt.Sprintf("Trend过滤未通过 [%s] (%s, 方向: %s)", filterName, detail, getPatternDirectionName(pattern.Direction))
+			if s.verboseLogging {
+				log.Printf("策略过滤: Trend过滤未通过 [%s] (%s, 方向: %s)", filterName, detail, getPatternDirectionName(pattern.Direction))
+			}
+			return models.SignalNone
+		}
+		if s.verboseLogging {
+			log.Printf("策略过滤: Trend过滤通过 (%d 个过滤器)", len(s.trendFilters))
+		}
+	}
+
+	// 5. Divergence filter (optional)
+	if s.useDivergenceFilter {
+		divOk, detail := s.checkDivergence(pattern.Direction)
+		if !divOk {
+			s.lastFilterFailure = fmt.Sprintf("背离过滤未通过 (%s, 方向: %s)", detail, getPatternDirectionName(pattern.Direction))
 			if s.verboseLogging {
-				log.Printf("策略过滤: Trend过滤未通过 (价格: %.4f, EMA30: %.4f, 方向: %s)", current.KLine.Close, current.Indicators.EMA30, getPatternDirectionName(pattern.Direction))
+				log.Printf("策略过滤: 背离过滤未通过 (%s, 方向: %s)", detail, getPatternDirectionName(pattern.Direction))
 			}
 			return models.SignalNone
 		}
 		if s.verboseLogging {
-			log.Printf("策略过滤: Trend过滤通过 (价格: %.4f, EMA30: %.4f)", current.KLine.Close, current.Indicators.EMA30)
+			log.Printf("策略过滤: 背离过滤通过")
 		}
 	}
 
@@ -263,8 +434,13 @@ func (s *PatternVolumeDeltaStrategy) detectBreakout(current models.MarketData) m
 	}
 
 	// Find highest high and lowest low in lookback period
-	high := current.KLine.High
-	low := current.KLine.Low
+	// ATR 突破过滤开启时区间不含当前K线（否则收盘价不可能超出区间 ATR 倍数）
+	rangeEnd := current
+	if s.breakoutATRMult > 0 {
+		rangeEnd = s.history[len(s.history)-2]
+	}
+	high := rangeEnd.KLine.High
+	low := rangeEnd.KLine.Low
 	for i := len(s.history) - 2; i >= len(s.history)-s.bLookback-1 && i >= 0; i-- {
 		if s.history[i].KLine.High > high {
 			high = s.history[i].KLine.High
@@ -274,8 +450,18 @@ func (s *PatternVolumeDeltaStrategy) detectBreakout(current models.MarketData) m
 		}
 	}
 
+	// Volatility-normalized breakout: require the close to clear the range by breakoutATRMult × ATR
+	var minExcess float64
+	if s.breakoutATRMult > 0 {
+		atr := current.Indicators.Get(indicators.ATR(s.atrPeriod).Key())
+		if atr <= 0 {
+			return models.Pattern{Direction: models.SignalNone, Confidence: 0.0, Name: "None"}
+		}
+		minExcess = atr * s.breakoutATRMult
+	}
+
 	// Bullish breakout: close breaks above recent high
-	if current.KLine.Close > high && current.KLine.Volume > 0 {
+	if current.KLine.Close > high+minExcess && current.KLine.Volume > 0 {
 		return models.Pattern{
 			Direction:  models.SignalLongEntry,
 			Confidence: 0.75,
@@ -284,7 +470,7 @@ func (s *PatternVolumeDeltaStrategy) detectBreakout(current models.MarketData) m
 	}
 
 	// Bearish breakout: close breaks below recent low
-	if current.KLine.Close < low && current.KLine.Volume > 0 {
+	if current.KLine.Close < low-minExcess && current.KLine.Volume > 0 {
 		return models.Pattern{
 			Direction:  models.SignalShortEntry,
 			Confidence: 0.75,
@@ -370,6 +556,7 @@ func (s *PatternVolumeDeltaStrategy) checkVolume(candle models.MarketData) bool
 // checkDelta checks if delta meets the threshold
 func (s *PatternVolumeDeltaStrategy) checkDelta(delta models.Delta, patternDirection models.SignalType) bool {
 	var threshold float64
+	value := s.deltaValue(delta)
 
 	if s.deltaThreshMode == "dynamic" {
 		// Calculate dynamic threshold based on recent delta history
@@ -399,17 +586,17 @@ func (s *PatternVolumeDeltaStrategy) checkDelta(delta models.Delta, patternDirec
 	// Check delta direction matches pattern direction
 	var result bool
 	if patternDirection == models.SignalLongEntry {
-		result = delta.Value >= threshold
+		result = value >= threshold
 		if !result {
 			if s.verboseLogging {
-				log.Printf("策略过滤: Delta过滤 - LONG方向: Delta值 %.2f < 阈值 %.2f", delta.Value, threshold)
+				log.Printf("策略过滤: Delta过滤 - LONG方向: Delta值 %.2f < 阈值 %.2f", value, threshold)
 			}
 		}
 	} else if patternDirection == models.SignalShortEntry {
-		result = delta.Value <= -threshold
+		result = value <= -threshold
 		if !result {
 			if s.verboseLogging {
-				log.Printf("策略过滤: Delta过滤 - SHORT方向: Delta值 %.2f > -阈值 %.2f (需要 <= %.2f)", delta.Value, threshold, -threshold)
+				log.Printf("策略过滤: Delta过滤 - SHORT方向: Delta值 %.2f > -阈值 %.2f (需要 <= %.2f)", value, threshold, -threshold)
 			}
 		}
 	} else {
@@ -419,23 +606,51 @@ func (s *PatternVolumeDeltaStrategy) checkDelta(delta models.Delta, patternDirec
 	return result
 }
 
-// checkTrend checks if trend filter is satisfied
-func (s *PatternVolumeDeltaStrategy) checkTrend(candle models.MarketData, patternDirection models.SignalType) bool {
-	// Use EMA30 to determine trend
-	ema30 := candle.Indicators.EMA30
-	if ema30 == 0 {
-		return false
+// checkBookImbalance checks that the order book leans in the signal direction
+func (s *PatternVolumeDeltaStrategy) checkBookImbalance(candle models.MarketData, patternDirection models.SignalType) (bool, string) {
+	book := candle.Book
+	if book == nil {
+		return true, "无订单簿数据"
 	}
 
-	if patternDirection == models.SignalLongEntry {
-		// For long, price should be above EMA30
-		return candle.KLine.Close > ema30
-	} else if patternDirection == models.SignalShortEntry {
-		// For short, price should be below EMA30
-		return candle.KLine.Close < ema30
+	detail := fmt.Sprintf("失衡度 %.2f, 阈值 %.2f", book.Imbalance, s.bookImbalanceMin)
+	switch patternDirection {
+	case models.SignalLongEntry:
+		return book.Imbalance >= s.bookImbalanceMin, detail
+	case models.SignalShortEntry:
+		return book.Imbalance <= -s.bookImbalanceMin, detail
+	default:
+		return false, detail
 	}
+}
+
+// checkTrend checks if every trend filter is satisfied
+// Returns the name and detail of the first failing filter
+func (s *PatternVolumeDeltaStrategy) checkTrend(candle models.MarketData, patternDirection models.SignalType) (bool, string, string) {
+	for _, filter := range s.trendFilters {
+		ok, detail := filter.Check(candle, patternDirection)
+		if !ok {
+			return false, filter.Name(), detail
+		}
+		if s.verboseLogging {
+			log.Printf("策略过滤: Trend过滤 [%s] 通过 (%s)", filter.Name(), detail)
+		}
+	}
+	return true, "", ""
+}
 
-	return false
+// checkDivergence rejects an entry when a recent regular divergence points the other way
+func (s *PatternVolumeDeltaStrategy) checkDivergence(patternDirection models.SignalType) (bool, string) {
+	for _, div := range s.RecentDivergences() {
+		if !div.IsRegular() {
+			continue
+		}
+		if (patternDirection == models.SignalLongEntry && !div.IsBullish()) ||
+			(patternDirection == models.SignalShortEntry && div.IsBullish()) {
+			return false, div.String()
+		}
+	}
+	return true, ""
 }
 
 // GetCurrentPattern returns the most recent detected pattern
@@ -0,0 +1,277 @@
+package strategy
+
+import (
+	"fmt"
+	"strings"
+
+	"vagues-go/src/indicators"
+	"vagues-go/src/models"
+)
+
+// TrendFilter decides whether the market trend agrees with a pattern direction
+type TrendFilter interface {
+	// Name returns a short name used in filter logs
+	Name() string
+	// RequiredIndicators returns the indicator specs the filter reads
+	RequiredIndicators() []indicators.Spec
+	// Check reports whether the candle's trend agrees with direction, plus a detail string for logs
+	Check(candle models.MarketData, direction models.SignalType) (bool, string)
+}
+
+// EMATrendFilter requires price above EMA(Period) for longs and below it for shorts
+type EMATrendFilter struct {
+	Period int
+}
+
+// Name implements TrendFilter
+func (f EMATrendFilter) Name() string {
+	return fmt.Sprintf("EMA%d", f.Period)
+}
+
+// RequiredIndicators implements TrendFilter
+func (f EMATrendFilter) RequiredIndicators() []indicators.Spec {
+	return []indicators.Spec{indicators.EMA(f.Period)}
+}
+
+// Check implements TrendFilter
+func (f EMATrendFilter) Check(candle models.MarketData, direction models.SignalType) (bool, string) {
+	ema := candle.Indicators.Get(indicators.EMA(f.Period).Key())
+	if ema == 0 && f.Period == 30 {
+		ema = candle.Indicators.EMA30 // 兼容固定字段
+	}
+	detail := fmt.Sprintf("价格: %.4f, EMA%d: %.4f", candle.KLine.Close, f.Period, ema)
+	if ema == 0 {
+		return false, detail
+	}
+
+	switch direction {
+	case models.SignalLongEntry:
+		// For long, price should be above EMA
+		return candle.KLine.Close > ema, detail
+	case models.SignalShortEntry:
+		// For short, price should be below EMA
+		return candle.KLine.Close < ema, detail
+	}
+	return false, detail
+}
+
+// ADXTrendFilter requires ADX(Period) >= MinADX and +DI/-DI agreeing with the direction
+type ADXTrendFilter struct {
+	Period int
+	MinADX float64 // 最小趋势强度（如 20）
+}
+
+// Name implements TrendFilter
+func (f ADXTrendFilter) Name() string {
+	return fmt.Sprintf("ADX%d", f.Period)
+}
+
+// RequiredIndicators implements TrendFilter
+func (f ADXTrendFilter) RequiredIndicators() []indicators.Spec {
+	return []indicators.Spec{indicators.ADX(f.Period)}
+}
+
+// Check implements TrendFilter
+func (f ADXTrendFilter) Check(candle models.MarketData, direction models.SignalType) (bool, string) {
+	spec := indicators.ADX(f.Period)
+	adx := candle.Indicators.Get(spec.Key())
+	plusDI := candle.Indicators.Get(spec.Output("plusdi"))
+	minusDI := candle.Indicators.Get(spec.Output("minusdi"))
+	detail := fmt.Sprintf("ADX: %.2f (最小: %.2f), +DI: %.2f, -DI: %.2f", adx, f.MinADX, plusDI, minusDI)
+
+	if adx == 0 || adx < f.MinADX {
+		return false, detail
+	}
+
+	switch direction {
+	case models.SignalLongEntry:
+		return plusDI > minusDI, detail
+	case models.SignalShortEntry:
+		return minusDI > plusDI, detail
+	}
+	return false, detail
+}
+
+// SupertrendFilter requires the Supertrend direction to agree with the pattern direction
+type SupertrendFilter struct {
+	Period int
+	Mult   float64
+}
+
+// Name implements TrendFilter
+func (f SupertrendFilter) Name() string {
+	return fmt.Sprintf("Supertrend(%d,%.1f)", f.Period, f.Mult)
+}
+
+// RequiredIndicators implements TrendFilter
+func (f SupertrendFilter) RequiredIndicators() []indicators.Spec {
+	return []indicators.Spec{indicators.Supertrend(f.Period, f.Mult)}
+}
+
+// Check implements TrendFilter
+func (f SupertrendFilter) Check(candle models.MarketData, direction models.SignalType) (bool, string) {
+	spec := indicators.Supertrend(f.Period, f.Mult)
+	line := candle.Indicators.Get(spec.Key())
+	trend := candle.Indicators.Get(spec.Output("direction"))
+	detail := fmt.Sprintf("Supertrend: %.4f, 方向: %+.0f", line, trend)
+
+	switch direction {
+	case models.SignalLongEntry:
+		return trend > 0, detail
+	case models.SignalShortEntry:
+		return trend < 0, detail
+	}
+	return false, detail
+}
+
+// IchimokuFilter requires price above the cloud and tenkan > kijun for longs (mirrored for shorts)
+type IchimokuFilter struct {
+	Tenkan  int
+	Kijun   int
+	SenkouB int
+}
+
+// Name implements TrendFilter
+func (f IchimokuFilter) Name() string {
+	return fmt.Sprintf("Ichimoku(%d,%d,%d)", f.Tenkan, f.Kijun, f.SenkouB)
+}
+
+// RequiredIndicators implements TrendFilter
+func (f IchimokuFilter) RequiredIndicators() []indicators.Spec {
+	return []indicators.Spec{indicators.Ichimoku(f.Tenkan, f.Kijun, f.SenkouB)}
+}
+
+// Check implements TrendFilter
+func (f IchimokuFilter) Check(candle models.MarketData, direction models.SignalType) (bool, string) {
+	spec := indicators.Ichimoku(f.Tenkan, f.Kijun, f.SenkouB)
+	tenkan := candle.Indicators.Get(spec.Output("tenkan"))
+	kijun := candle.Indicators.Get(spec.Output("kijun"))
+	spanA := candle.Indicators.Get(spec.Output("spana"))
+	spanB := candle.Indicators.Get(spec.Output("spanb"))
+	detail := fmt.Sprintf("价格: %.4f, 转换线: %.4f, 基准线: %.4f, 云层: %.4f/%.4f",
+		candle.KLine.Close, tenkan, kijun, spanA, spanB)
+
+	if spanA == 0 || spanB == 0 {
+		return false, detail
+	}
+
+	cloudTop := spanA
+	cloudBottom := spanB
+	if cloudBottom > cloudTop {
+		cloudTop, cloudBottom = cloudBottom, cloudTop
+	}
+
+	switch direction {
+	case models.SignalLongEntry:
+		return candle.KLine.Close > cloudTop && tenkan > kijun, detail
+	case models.SignalShortEntry:
+		return candle.KLine.Close < cloudBottom && tenkan < kijun, detail
+	}
+	return false, detail
+}
+
+// TimeframeTrendFilter requires indicators.GetTrendDirection to agree with the direction on every configured
+// higher timeframe (MarketData.Timeframes); missing timeframe data fails the check
+type TimeframeTrendFilter struct {
+	Intervals []string
+}
+
+// Name implements TrendFilter
+func (f TimeframeTrendFilter) Name() string {
+	return fmt.Sprintf("MTF%v", f.Intervals)
+}
+
+// RequiredIndicators implements TrendFilter
+// GetTrendDirection 只读取默认指标，高周期指标由交易系统单独计算
+func (f TimeframeTrendFilter) RequiredIndicators() []indicators.Spec {
+	return nil
+}
+
+// Check implements TrendFilter
+func (f TimeframeTrendFilter) Check(candle models.MarketData, direction models.SignalType) (bool, string) {
+	want := models.TrendBullish
+	if direction == models.SignalShortEntry {
+		want = models.TrendBearish
+	}
+
+	parts := make([]string, 0, len(f.Intervals))
+	for _, interval := range f.Intervals {
+		data, ok := candle.Timeframe(interval)
+		if !ok {
+			parts = append(parts, fmt.Sprintf("%s: 缺少数据", interval))
+			return false, strings.Join(parts, ", ")
+		}
+		trend := indicators.GetTrendDirection(data)
+		parts = append(parts, fmt.Sprintf("%s: %s", interval, getTrendName(trend)))
+		if trend != want {
+			return false, strings.Join(parts, ", ")
+		}
+	}
+	return true, strings.Join(parts, ", ")
+}
+
+// getTrendName converts TrendDirection to string for logging
+func getTrendName(trend models.TrendDirection) string {
+	switch trend {
+	case models.TrendBullish:
+		return "多头"
+	case models.TrendBearish:
+		return "空头"
+	default:
+		return "震荡"
+	}
+}
+
+// ParseTrendFilters builds trend filters from a spec list such as "ema(30), adx(14,20), supertrend(10,3)"
+// adx 的第二个参数为最小 ADX 值；ichimoku 参数为 (tenkan, kijun, senkouB)
+func ParseTrendFilters(text string) ([]TrendFilter, error) {
+	specs, err := indicators.ParseSpecs(text)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := make([]TrendFilter, 0, len(specs))
+	for _, spec := range specs {
+		var paramErr error
+		param := func(i int, fallback float64) float64 {
+			if i < len(spec.Params) {
+				return spec.Params[i]
+			}
+			return fallback
+		}
+		// period 读取周期参数，非正整数（如 ema(21.5)）记为错误而不是截断
+		period := func(i int, fallback int) int {
+			if i >= len(spec.Params) {
+				return fallback
+			}
+			value := int(spec.Params[i])
+			if (float64(value) != spec.Params[i] || value <= 0) && paramErr == nil {
+				paramErr = fmt.Errorf("参数 %v 必须为正整数", spec.Params[i])
+			}
+			return value
+		}
+
+		var filter TrendFilter
+		switch spec.Name {
+		case "ema":
+			filter = EMATrendFilter{Period: period(0, 30)}
+		case "adx":
+			filter = ADXTrendFilter{Period: period(0, 14), MinADX: param(1, 20)}
+		case "supertrend":
+			filter = SupertrendFilter{Period: period(0, 10), Mult: param(1, 3)}
+		case "ichimoku":
+			filter = IchimokuFilter{
+				Tenkan:  period(0, 9),
+				Kijun:   period(1, 26),
+				SenkouB: period(2, 52),
+			}
+		default:
+			return nil, fmt.Errorf("不支持的趋势过滤器: %s", spec.Key())
+		}
+		if paramErr != nil {
+			return nil, fmt.Errorf("趋势过滤器 %s: %w", spec.Key(), paramErr)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
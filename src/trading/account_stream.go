@@ -0,0 +1,237 @@
+package trading
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"vagues-go/src/backpack"
+)
+
+// accountEventBuffer 账户事件队列长度（WebSocket 协程写入，主循环处理）
+const accountEventBuffer = 256
+
+// accountEvent is an update from the private account streams, or a request to reconcile after a gap
+// 订单管理器不是线程安全的，推送统一通过队列交给主循环处理
+type accountEvent struct {
+	order     *backpack.WSOrderUpdate
+	position  *backpack.WSPositionUpdate
+	reconcile bool // 断线重连后通过 REST 核对持仓（断线期间的平仓推送可能丢失）
+}
+
+// startAccountStream subscribes to the signed order and position update streams of the futures symbol
+// 用于实时获取开仓成交、止损止盈触发单执行和平仓；WebSocket 不可用时不订阅
+func (ts *TradingSystem) startAccountStream() {
+	if ts.wsClient == nil || !ts.wsClient.IsConnected() {
+		return
+	}
+
+	futuresSymbol := ts.getFuturesSymbol()
+	events := make(chan accountEvent, accountEventBuffer)
+	push := func(event accountEvent) {
+		select {
+		case events <- event:
+		default:
+			log.Printf("⚠️  %s 账户事件队列已满，丢弃推送", futuresSymbol)
+		}
+	}
+
+	err := ts.wsClient.SubscribeOrderUpdates(futuresSymbol, func(update backpack.WSOrderUpdate) {
+		push(accountEvent{order: &update})
+	})
+	if err != nil {
+		log.Printf("⚠️  订阅 %s 订单更新失败: %v", futuresSymbol, err)
+		return
+	}
+	err = ts.wsClient.SubscribePositionUpdates(futuresSymbol, func(update backpack.WSPositionUpdate) {
+		push(accountEvent{position: &update})
+	})
+	if err != nil {
+		log.Printf("⚠️  订阅 %s 持仓更新失败: %v", futuresSymbol, err)
+	}
+
+	ts.wsClient.OnGap(func(backpack.WSGap) {
+		push(accountEvent{reconcile: true})
+	})
+
+	ts.accountEvents = events
+	log.Printf("✅ 已订阅 %s 订单和持仓更新", futuresSymbol)
+}
+
+// handleAccountEvent applies an account stream event to the local orders (在主循环中调用)
+func (ts *TradingSystem) handleAccountEvent(ctx context.Context, event accountEvent) {
+	switch {
+	case event.order != nil:
+		ts.handleOrderUpdate(*event.order)
+	case event.position != nil:
+		ts.handlePositionUpdate(*event.position)
+	case event.reconcile:
+		if err := ts.reconcilePosition(ctx); err != nil {
+			log.Printf("⚠️  核对 %s 持仓失败: %v", ts.getFuturesSymbol(), err)
+		}
+	}
+}
+
+// handleOrderUpdate records entry fills, exit fills from trigger orders and entry orders that never filled
+func (ts *TradingSystem) handleOrderUpdate(update backpack.WSOrderUpdate) {
+	switch update.EventType {
+	case backpack.OrderEventFill:
+		price, quantity, fee, ok := update.Fill()
+		if !ok {
+			log.Printf("⚠️  无法解析成交推送: 订单 %s", update.OrderID)
+			return
+		}
+
+		// 开仓订单成交：用实际成交均价和累计成交数量更新本地订单
+		if order := ts.orderManager.GetOrder(update.OrderID); order != nil && order.Status == OrderStatusOpen {
+			avgPrice, ok := update.AveragePrice()
+			if !ok {
+				avgPrice = price
+			}
+			executed, err := strconv.ParseFloat(update.ExecutedQuantity, 64)
+			if err != nil || executed <= 0 {
+				executed = quantity
+			}
+			if err := ts.orderManager.RecordEntryFill(order.ID, avgPrice, executed, fee); err != nil {
+				log.Printf("⚠️  更新开仓成交失败: %v", err)
+				return
+			}
+			log.Printf("📥 开仓成交 - 订单ID: %s, 成交价: %.4f, 数量: %.4f, 均价: %.4f, 手续费: %.6f",
+				order.ID, price, quantity, avgPrice, fee)
+			return
+		}
+
+		// 平仓成交（止损/止盈触发单或手动平仓）：方向与持仓相反，手续费计入持仓订单
+		order := ts.openOrderClosedBy(update.Side)
+		if order == nil {
+			log.Printf("⚠️  %s 收到未跟踪订单的成交: 订单ID %s, %s %.4f @ %.4f",
+				update.Symbol, update.OrderID, update.Side, quantity, price)
+			return
+		}
+		ts.orderManager.AddFee(order.ID, fee)
+		if avgPrice, ok := update.AveragePrice(); ok {
+			ts.lastExitPrice = avgPrice
+		} else {
+			ts.lastExitPrice = price
+		}
+
+		if update.IsTrigger() {
+			log.Printf("🎯 %s触发单成交 - 持仓订单: %s, 触发价: %s, 成交价: %.4f, 数量: %.4f",
+				ts.triggerKind(order, update), order.ID, update.TriggerPrice, price, quantity)
+		} else {
+			log.Printf("📤 平仓成交 - 持仓订单: %s, 成交价: %.4f, 数量: %.4f", order.ID, price, quantity)
+		}
+
+	case backpack.OrderEventCancelled, backpack.OrderEventExpired:
+		// IOC 市价开仓单未成交即取消：移除本地订单，避免一直阻塞后续开仓
+		order := ts.orderManager.GetOrder(update.OrderID)
+		if order == nil || order.Status != OrderStatusOpen {
+			return
+		}
+		if executed, _ := strconv.ParseFloat(update.ExecutedQuantity, 64); executed > 0 {
+			return
+		}
+		if err := ts.orderManager.CancelOrder(order.ID); err == nil {
+			log.Printf("⚠️  开仓订单 %s 未成交已%s（%s），已移除本地订单", order.ID, update.EventType, update.ExpiryReason)
+		}
+
+	case backpack.OrderEventTriggerFailed:
+		log.Printf("❌ %s 触发单执行失败 - 订单ID: %s, 触发价: %s", update.Symbol, update.OrderID, update.TriggerPrice)
+		if ts.notifier != nil {
+			_ = ts.notifier.SendErrorNotification("触发单执行失败",
+				fmt.Sprintf("%s 止损/止盈触发单 %s 执行失败（触发价 %s），请检查持仓", update.Symbol, update.OrderID, update.TriggerPrice))
+		}
+	}
+}
+
+// handlePositionUpdate closes local orders once the exchange reports the position closed
+func (ts *TradingSystem) handlePositionUpdate(update backpack.WSPositionUpdate) {
+	switch update.EventType {
+	case backpack.PositionEventClosed:
+		exitPrice := ts.lastExitPrice
+		if exitPrice <= 0 {
+			exitPrice = float64(update.MarkPrice) // 未收到平仓成交（如强平），按标记价格估算
+		}
+		ts.closeLocalOrders(exitPrice, "持仓已平仓")
+
+	case backpack.PositionEventOpened:
+		if len(ts.orderManager.GetOpenOrders()) == 0 {
+			log.Printf("⚠️  %s 出现未跟踪的持仓: 数量 %.4f, 开仓价 %.4f", update.Symbol, float64(update.NetQuantity), float64(update.EntryPrice))
+		}
+	}
+}
+
+// reconcilePosition closes local orders whose position no longer exists on the exchange
+func (ts *TradingSystem) reconcilePosition(ctx context.Context) error {
+	if len(ts.orderManager.GetOpenOrders()) == 0 {
+		return nil
+	}
+
+	futuresSymbol := ts.getFuturesSymbol()
+	positions, err := ts.client.GetPositions(ctx, futuresSymbol)
+	if err != nil {
+		return err
+	}
+	for _, position := range positions {
+		if quantity, _ := strconv.ParseFloat(position.NetQuantity, 64); position.Symbol == futuresSymbol && quantity != 0 {
+			return nil
+		}
+	}
+
+	exitPrice := ts.lastExitPrice
+	if exitPrice <= 0 && len(ts.klines) > 0 {
+		exitPrice = ts.klines[len(ts.klines)-1].Close
+	}
+	ts.closeLocalOrders(exitPrice, "断线期间持仓已平仓")
+	return nil
+}
+
+// closeLocalOrders closes all open local orders at exitPrice using the fees recorded from fills
+func (ts *TradingSystem) closeLocalOrders(exitPrice float64, reason string) {
+	futuresSymbol := ts.getFuturesSymbol()
+	for _, order := range ts.orderManager.GetOpenOrders() {
+		if err := ts.orderManager.CloseOrder(order.ID, exitPrice, order.TradingFee, 0); err != nil {
+			log.Printf("⚠️  关闭本地订单失败: %v", err)
+			continue
+		}
+
+		log.Printf("✅ %s - 订单ID: %s, 平仓价: %.4f, 手续费: %.6f, 盈亏: %.4f (%.2f%%)",
+			reason, order.ID, order.ExitPrice, order.TradingFee, order.PnL, order.PnLPercent)
+
+		if ts.notifier != nil {
+			_ = ts.notifier.SendCloseNotification(
+				futuresSymbol,
+				fmt.Sprintf("%.4f", order.Quantity),
+				fmt.Sprintf("%.4f", order.ExitPrice),
+				fmt.Sprintf("%.4f", order.PnL),
+				fmt.Sprintf("%.2f%%", order.PnLPercent),
+				order.ID,
+			)
+		}
+	}
+	ts.lastExitPrice = 0
+}
+
+// openOrderClosedBy returns the open order that a fill on side reduces (多仓由卖单平仓，空仓由买单平仓)
+func (ts *TradingSystem) openOrderClosedBy(side string) *LocalOrder {
+	for _, order := range ts.orderManager.GetOpenOrders() {
+		if (order.OrderType == OrderTypeLong && side == "Ask") || (order.OrderType == OrderTypeShort && side == "Bid") {
+			return order
+		}
+	}
+	return nil
+}
+
+// triggerKind names a trigger order by whichever of the order's stop loss or take profit its trigger price is closer to
+func (ts *TradingSystem) triggerKind(order *LocalOrder, update backpack.WSOrderUpdate) string {
+	triggerPrice, err := strconv.ParseFloat(update.TriggerPrice, 64)
+	if err != nil {
+		return "条件"
+	}
+	if math.Abs(triggerPrice-order.StopLoss) <= math.Abs(triggerPrice-order.TakeProfit) {
+		return "止损"
+	}
+	return "止盈"
+}
@@ -0,0 +1,336 @@
+package trading
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/marketdata"
+	"vagues-go/src/models"
+)
+
+// K线来源
+const (
+	BarSourceTrades = "trades" // 逐笔成交聚合K线（同时得到真实 Delta）
+	BarSourceKlines = "klines" // 交易所K线推送
+	BarSourcePoll   = "poll"   // 按周期轮询 REST
+)
+
+// barCloseDelay 收盘定时器在K线结束后等待的时间（逐笔聚合的迟到宽限为2秒）
+const barCloseDelay = 3 * time.Second
+
+// resolveBarSource returns the configured bar source, choosing one when unset
+// 未配置时：有逐笔 Delta 统计用 trades，否则用 klines；WebSocket 不可用时用 poll
+func (ts *TradingSystem) resolveBarSource() string {
+	if ts.wsClient == nil || !ts.wsClient.IsConnected() {
+		return BarSourcePoll
+	}
+
+	switch ts.barSource {
+	case BarSourceTrades:
+		if ts.deltaTracker == nil {
+			log.Printf("⚠️  按K线估算 Delta 时无法使用逐笔聚合，改用 K线推送")
+			return BarSourceKlines
+		}
+		return BarSourceTrades
+	case BarSourceKlines, BarSourcePoll:
+		return ts.barSource
+	case "":
+		if ts.deltaTracker != nil {
+			return BarSourceTrades
+		}
+		return BarSourceKlines
+	default:
+		log.Printf("⚠️  未知的K线来源: %s，自动选择", ts.barSource)
+		ts.barSource = ""
+		return ts.resolveBarSource()
+	}
+}
+
+// startKlineStream subscribes to the K-line stream of the trading interval
+// 非交易所原生周期订阅可整除的原生周期并重采样
+func (ts *TradingSystem) startKlineStream() {
+	iv, err := marketdata.ParseInterval(ts.interval)
+	if err != nil {
+		log.Printf("⚠️  %v，将使用 REST 轮询", err)
+		return
+	}
+
+	closer := marketdata.NewKlineCloser(iv, 0)
+	streamInterval := closer.StreamInterval().String()
+	err = ts.wsClient.SubscribeKlines(ts.symbol, streamInterval, func(msg backpack.WSKlineMessage) {
+		kline, err := marketdata.KLineFromWS(msg)
+		if err != nil {
+			log.Printf("⚠️  解析 %s K线推送失败: %v", ts.symbol, err)
+			return
+		}
+		closer.Update(kline, msg.Closed)
+	})
+	if err != nil {
+		log.Printf("⚠️  订阅 %s K线推送失败: %v，将使用 REST 轮询", ts.symbol, err)
+		return
+	}
+
+	ts.klineCloser = closer
+	log.Printf("✅ 已订阅 %s %s K线推送", ts.symbol, streamInterval)
+}
+
+// runStreaming evaluates the strategy on every closed bar pushed over WebSocket
+// 取代按固定周期轮询 REST 的主循环，避免评估尚未收盘的K线；WebSocket 断开期间在K线收盘后改用 REST 获取
+func (ts *TradingSystem) runStreaming(ctx context.Context, klines []models.KLine) error {
+	ts.klines = closedKlines(klines, ts.clock.Now())
+
+	var aggregated <-chan marketdata.ClosedBar
+	if ts.aggregator != nil {
+		ticker := ts.clock.NewTicker(time.Second)
+		defer ticker.Stop()
+		go ts.aggregator.Run(ctx, ticker.C())
+		aggregated = ts.aggregator.Bars()
+		log.Printf("✅ %s 使用逐笔成交聚合 %s K线，收盘后立即评估", ts.symbol, ts.interval)
+	}
+	var pushed <-chan models.KLine
+	if ts.klineCloser != nil {
+		pushed = ts.klineCloser.Bars()
+		log.Printf("✅ %s 使用 %s K线推送，收盘后立即评估", ts.symbol, ts.interval)
+	}
+
+	timer := ts.clock.NewTimer(ts.untilBarClose(ts.clock.Now()))
+	defer timer.Stop()
+
+	handleBar := func(bar marketdata.ClosedBar) {
+		// 断线期间聚合的K线缺少成交，以 REST 数据为准
+		if !ts.streamLive() {
+			return
+		}
+		if err := ts.processAggregatedBar(ctx, bar); err != nil {
+			log.Printf("处理新数据失败: %v", err)
+		}
+	}
+	handleKline := func(kline models.KLine) {
+		if !ts.streamLive() {
+			return
+		}
+		if err := ts.processClosedKline(ctx, kline); err != nil {
+			log.Printf("处理新数据失败: %v", err)
+		}
+	}
+	polling := false
+	handleTimer := func(now time.Time) {
+		if ts.klineCloser != nil {
+			ts.klineCloser.Flush(now)
+		}
+		if live := ts.streamLive(); live == polling {
+			polling = !live
+			if polling {
+				log.Printf("⚠️  %s WebSocket 已断开，改用 REST 轮询", ts.symbol)
+			} else {
+				log.Printf("✅ %s WebSocket 已恢复，改用推送", ts.symbol)
+			}
+		}
+		if polling {
+			if err := ts.pollClosedBars(ctx); err != nil {
+				log.Printf("处理新数据失败: %v", err)
+			}
+		}
+		timer.Reset(ts.untilBarClose(ts.clock.Now()))
+	}
+
+	// settle 处理所有已排队的事件（回放同步）：先等聚合协程处理完已收到的成交和时钟，再按固定顺序处理
+	settle := func() {
+		if ts.bookSync != nil {
+			ts.bookSync.Wait()
+		}
+		if aggregated != nil {
+			done := ts.aggregator.Sync()
+			for waiting := true; waiting; {
+				select {
+				case bar, ok := <-aggregated:
+					if !ok {
+						aggregated, waiting = nil, false
+						continue
+					}
+					handleBar(bar) // 聚合协程输出K线时可能阻塞，等待期间继续接收
+				case <-done:
+					waiting = false
+				}
+			}
+		}
+		for progressed := true; progressed; {
+			progressed = false
+			select {
+			case bar, ok := <-aggregated:
+				if ok {
+					handleBar(bar)
+					progressed = true
+				}
+			default:
+			}
+			select {
+			case kline := <-pushed:
+				handleKline(kline)
+				progressed = true
+			default:
+			}
+			select {
+			case event := <-ts.accountEvents:
+				ts.handleAccountEvent(ctx, event)
+				progressed = true
+			default:
+			}
+			select {
+			case now := <-timer.C():
+				handleTimer(now)
+				progressed = true
+			default:
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if ts.aggregator != nil {
+				if late, dropped := ts.aggregator.Stats(); late > 0 || dropped > 0 {
+					log.Printf("逐笔聚合统计: 迟到丢弃 %d 笔, 队列溢出丢弃 %d 笔", late, dropped)
+				}
+			}
+			log.Println("交易系统停止")
+			return nil
+
+		case bar, ok := <-aggregated:
+			if !ok {
+				aggregated = nil
+				continue
+			}
+			handleBar(bar)
+
+		case kline := <-pushed:
+			handleKline(kline)
+
+		case event := <-ts.accountEvents:
+			ts.handleAccountEvent(ctx, event)
+
+		case now := <-timer.C():
+			handleTimer(now)
+
+		case reply := <-ts.syncs:
+			settle()
+			close(reply)
+		}
+	}
+}
+
+// streamLive reports whether bars are currently being pushed over WebSocket
+func (ts *TradingSystem) streamLive() bool {
+	return ts.wsClient != nil && ts.wsClient.IsConnected()
+}
+
+// untilBarClose returns the wait until the current bar closes plus barCloseDelay
+func (ts *TradingSystem) untilBarClose(now time.Time) time.Duration {
+	iv, err := marketdata.ParseInterval(ts.interval)
+	if err != nil {
+		return ts.getIntervalDuration()
+	}
+	return iv.Next(iv.Floor(now)).Add(barCloseDelay).Sub(now)
+}
+
+// pollClosedBars fetches closed bars over REST and evaluates the ones not yet processed
+func (ts *TradingSystem) pollClosedBars(ctx context.Context) error {
+	fetched, err := ts.fetchHistoricalKlines(ctx, ts.historyLimit())
+	if err != nil {
+		return fmt.Errorf("获取历史K线数据失败: %w", err)
+	}
+
+	for _, kline := range closedKlines(fetched, ts.clock.Now()) {
+		if ts.processed(kline) {
+			continue
+		}
+		if err := ts.processClosedKline(ctx, kline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processAggregatedBar evaluates a bar aggregated from trades, using its trade delta
+func (ts *TradingSystem) processAggregatedBar(ctx context.Context, bar marketdata.ClosedBar) error {
+	if ts.processed(bar.KLine) {
+		return nil
+	}
+	ts.appendKline(ctx, bar.KLine)
+	ts.recordDelta(bar.Delta)
+	return ts.evaluateClosedBar(ctx, bar.KLine, bar.Delta)
+}
+
+// processClosedKline evaluates a closed exchange bar; delta comes from public trades or is estimated
+func (ts *TradingSystem) processClosedKline(ctx context.Context, kline models.KLine) error {
+	if ts.processed(kline) {
+		return nil
+	}
+	ts.appendKline(ctx, kline)
+	delta := ts.orderFlowDelta(ctx, kline, ts.klines)
+	return ts.evaluateClosedBar(ctx, kline, delta)
+}
+
+// evaluateClosedBar calculates indicators over the local history and evaluates the strategy on the bar
+func (ts *TradingSystem) evaluateClosedBar(ctx context.Context, kline models.KLine, delta models.Delta) error {
+	// 数据质量校验（本地历史的最后一根即当前K线）
+	if !ts.checkBar(ts.klines) {
+		return nil
+	}
+
+	// 计算技术指标
+	calculatedIndicators, err := ts.calculator.CalculateIndicatorsInput(ts.indicatorInput(ts.klines), ts.strategy.RequiredIndicators()...)
+	if err != nil {
+		return fmt.Errorf("计算技术指标失败: %w", err)
+	}
+	if len(calculatedIndicators) == 0 {
+		return fmt.Errorf("无法计算技术指标")
+	}
+
+	currentData := models.MarketData{
+		KLine:      kline,
+		Indicators: calculatedIndicators[len(calculatedIndicators)-1],
+	}
+
+	// 附加已对齐的高周期数据（多周期确认）
+	ts.refreshTimeframes(ctx)
+	ts.attachTimeframes(&currentData)
+
+	return ts.evaluate(ctx, currentData, delta)
+}
+
+// processed reports whether a bar at or after kline's start has already been evaluated
+func (ts *TradingSystem) processed(kline models.KLine) bool {
+	n := len(ts.klines)
+	return n > 0 && !kline.StartTime.After(ts.klines[n-1].StartTime)
+}
+
+// appendKline appends a closed bar to the local history, refetching over REST when bars are missing
+// （首根不完整K线被丢弃，或 WebSocket 中断期间没有输出K线）
+func (ts *TradingSystem) appendKline(ctx context.Context, k models.KLine) {
+	if n := len(ts.klines); n == 0 || ts.klines[n-1].EndTime.Add(time.Second).Before(k.StartTime) {
+		fetched, err := ts.fetchHistoricalKlines(ctx, ts.historyLimit())
+		if err != nil {
+			log.Printf("⚠️  补齐 %s 缺失K线失败: %v", ts.symbol, err)
+		} else {
+			ts.klines = closedKlines(fetched, k.StartTime)
+		}
+	}
+
+	ts.klines = append(ts.klines, k)
+	if limit := ts.historyLimit(); len(ts.klines) > limit {
+		ts.klines = append([]models.KLine(nil), ts.klines[len(ts.klines)-limit:]...)
+	}
+}
+
+// closedKlines returns the bars that had closed by t (K线按时间升序)
+func closedKlines(klines []models.KLine, t time.Time) []models.KLine {
+	end := len(klines)
+	for end > 0 && klines[end-1].EndTime.After(t) {
+		end--
+	}
+	return klines[:end]
+}
@@ -0,0 +1,129 @@
+package trading
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/marketdata"
+)
+
+// liquidityDepthLimit 未维护本地订单簿时，估算滑点获取的快照档位数量
+const liquidityDepthLimit = 100
+
+// checkEntryLiquidity estimates the fill of a market entry from the order book and rejects excessive slippage
+// buy 为 true 表示开多（吃卖单）；未配置滑点上限时不检查。无本地订单簿时通过 REST 获取快照
+func (ts *TradingSystem) checkEntryLiquidity(ctx context.Context, buy bool, quantity float64) (bool, string) {
+	if ts.maxSlippageBps <= 0 {
+		return true, ""
+	}
+
+	book := ts.orderBook()
+	if book == nil || !book.Synced() {
+		snapshot, err := ts.fetchBookSnapshot(ctx)
+		if err != nil {
+			return false, fmt.Sprintf("无法获取订单簿: %v", err)
+		}
+		book = snapshot
+	}
+
+	estimate, ok := book.EstimateFill(buy, quantity)
+	if !ok {
+		return false, "订单簿为空"
+	}
+	if !estimate.Complete() {
+		return false, fmt.Sprintf("深度不足: 可成交 %.4f / %.4f", estimate.Filled, estimate.Quantity)
+	}
+	if estimate.SlippageBps > ts.maxSlippageBps {
+		return false, fmt.Sprintf("预计滑点 %.2fbps 超过上限 %.2fbps（中间价 %.4f, 预计均价 %.4f, 最差价 %.4f）",
+			estimate.SlippageBps, ts.maxSlippageBps, estimate.Mid, estimate.AvgPrice, estimate.WorstPrice)
+	}
+
+	log.Printf("📊 流动性检查通过 - 数量: %.4f, 中间价: %.4f, 预计均价: %.4f, 预计滑点: %.2fbps (上限 %.2fbps)",
+		quantity, estimate.Mid, estimate.AvgPrice, estimate.SlippageBps, ts.maxSlippageBps)
+	return true, ""
+}
+
+// fetchBookSnapshot fetches a one-off order book of the futures symbol over REST
+func (ts *TradingSystem) fetchBookSnapshot(ctx context.Context) (*marketdata.OrderBook, error) {
+	futuresSymbol := ts.getFuturesSymbol()
+	resp, err := ts.client.GetDepth(ctx, futuresSymbol, liquidityDepthLimit)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := marketdata.DepthSnapshotFromREST(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	book := marketdata.NewOrderBook(futuresSymbol)
+	book.Reset(snapshot)
+	return book, nil
+}
+
+// selectLiquidMarkets returns up to limit markets ordered by 24h quote volume, highest first
+// maxSlippageBps > 0 时剔除半个买卖价差已超过滑点上限的交易对（最小的市价单也无法开仓）
+func (m *MultiSymbolMonitor) selectLiquidMarkets(ctx context.Context, markets []backpack.Market, limit int, maxSlippageBps float64) []backpack.Market {
+	tickers, err := m.client.GetTickers(ctx)
+	if err != nil {
+		log.Printf("⚠️  获取24小时行情失败: %v，按交易所返回顺序选取交易对", err)
+		return markets[:min(limit, len(markets))]
+	}
+
+	quoteVolume := make(map[string]float64, len(tickers))
+	for _, ticker := range tickers {
+		if volume, err := strconv.ParseFloat(ticker.QuoteVolume, 64); err == nil {
+			quoteVolume[ticker.Symbol] = volume
+		}
+	}
+
+	ranked := append([]backpack.Market(nil), markets...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return quoteVolume[ranked[i].Symbol] > quoteVolume[ranked[j].Symbol]
+	})
+	if maxSlippageBps <= 0 {
+		return ranked[:min(limit, len(ranked))]
+	}
+
+	liquid := make([]backpack.Market, 0, limit)
+	for _, market := range ranked {
+		if len(liquid) >= limit {
+			break
+		}
+		spreadBps, err := m.spreadBps(ctx, market.Symbol)
+		if err != nil {
+			log.Printf("⚠️  获取 %s 订单簿失败: %v", market.Symbol, err)
+			continue
+		}
+		if spreadBps/2 > maxSlippageBps {
+			log.Printf("跳过 %s: 买卖价差 %.2fbps 的一半超过滑点上限 %.2fbps（24h成交额 %.0f）",
+				market.Symbol, spreadBps, maxSlippageBps, quoteVolume[market.Symbol])
+			continue
+		}
+		liquid = append(liquid, market)
+	}
+	return liquid
+}
+
+// spreadBps returns the current bid-ask spread of symbol in basis points
+func (m *MultiSymbolMonitor) spreadBps(ctx context.Context, symbol string) (float64, error) {
+	resp, err := m.client.GetDepth(ctx, symbol, 5)
+	if err != nil {
+		return 0, err
+	}
+	snapshot, err := marketdata.DepthSnapshotFromREST(resp)
+	if err != nil {
+		return 0, err
+	}
+
+	book := marketdata.NewOrderBook(symbol)
+	book.Reset(snapshot)
+	stats, ok := book.Stats(0)
+	if !ok {
+		return 0, fmt.Errorf("订单簿为空")
+	}
+	return stats.SpreadBps, nil
+}
@@ -59,11 +59,14 @@ func (m *MultiSymbolMonitor) Run(ctx context.Context) error {
 		maxSymbols = m.config.MaxTradingSymbols
 	}
 
-	// 只取前 N 个交易对
+	// 按24小时成交额选取流动性最好的 N 个交易对（配置了滑点上限时剔除价差过大的交易对）
 	originalCount := len(perpMarkets)
-	if len(perpMarkets) > maxSymbols {
-		perpMarkets = perpMarkets[:maxSymbols]
-		log.Printf("限制监控数量为 %d 个交易对（从 %d 个 PERP 交易对中选取前 %d 个）", maxSymbols, originalCount, maxSymbols)
+	perpMarkets = m.selectLiquidMarkets(ctx, perpMarkets, maxSymbols, m.config.MaxSlippageBps)
+	if len(perpMarkets) < originalCount {
+		log.Printf("限制监控数量为 %d 个交易对（从 %d 个 PERP 交易对中按24小时成交额选取 %d 个）", maxSymbols, originalCount, len(perpMarkets))
+	}
+	if len(perpMarkets) == 0 {
+		return fmt.Errorf("没有满足流动性要求的 PERP 交易对")
 	}
 
 	log.Printf("开始监控 %d 个交易对...", len(perpMarkets))
@@ -83,7 +86,10 @@ func (m *MultiSymbolMonitor) Run(ctx context.Context) error {
 		symbolConfig := m.config
 		symbolConfig.Symbol = market.Symbol
 
-		ts := NewTradingSystem(m.client, symbolConfig)
+		ts, err := NewTradingSystem(m.client, symbolConfig)
+		if err != nil {
+			return fmt.Errorf("创建交易对 %s 的交易系统失败: %w", market.Symbol, err)
+		}
 		m.mu.Lock()
 		m.tradingSystems[market.Symbol] = ts
 		m.mu.Unlock()
@@ -0,0 +1,53 @@
+package trading
+
+import (
+	"context"
+	"log"
+
+	"vagues-go/src/marketdata"
+	"vagues-go/src/models"
+)
+
+// 订单簿默认参数
+const (
+	defaultBookDepthBps   = 25      // 深度/失衡统计范围（距中间价基点）
+	bookStreamAggregation = "200ms" // 深度推送聚合周期（多交易对同时订阅时减少流量）
+)
+
+// startOrderBook subscribes to the futures symbol's depth stream and keeps a local order book
+// 订单簿在 WebSocket 协程中更新，评估时读取统计；WebSocket 不可用时不启用
+func (ts *TradingSystem) startOrderBook(ctx context.Context) {
+	if !ts.orderBookEnabled || ts.wsClient == nil || !ts.wsClient.IsConnected() {
+		return
+	}
+
+	futuresSymbol := ts.getFuturesSymbol()
+	bookSync := marketdata.NewBookSync(futuresSymbol, ts.client)
+	bookSync.SetClock(ts.clock)
+	if err := bookSync.Start(ctx, ts.wsClient, bookStreamAggregation); err != nil {
+		log.Printf("⚠️  订阅 %s 深度失败: %v", futuresSymbol, err)
+		return
+	}
+
+	ts.bookSync = bookSync
+	log.Printf("✅ 已订阅 %s 深度（%s 聚合），维护本地订单簿", futuresSymbol, bookStreamAggregation)
+}
+
+// orderBook returns the local order book, or nil when not subscribed
+func (ts *TradingSystem) orderBook() *marketdata.OrderBook {
+	if ts.bookSync == nil {
+		return nil
+	}
+	return ts.bookSync.Book()
+}
+
+// attachBook adds the current order book statistics to data (订单簿未同步时不附加)
+func (ts *TradingSystem) attachBook(data *models.MarketData) {
+	book := ts.orderBook()
+	if book == nil {
+		return
+	}
+	if stats, ok := book.Stats(ts.bookDepthBps); ok {
+		data.Book = &stats
+	}
+}
@@ -0,0 +1,136 @@
+package trading
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"vagues-go/src/backpack"
+	"vagues-go/src/indicators"
+	"vagues-go/src/marketdata"
+	"vagues-go/src/models"
+)
+
+// maxTradeBackfillPages 每根K线最多回补的成交页数（每页1000笔）
+const maxTradeBackfillPages = 5
+
+// startTradeStream subscribes to the public trade stream and feeds the delta tracker
+// aggregate 为 true 时成交同时聚合为K线（收盘即评估）；WebSocket 不可用时只依赖 REST 回补
+func (ts *TradingSystem) startTradeStream(aggregate bool) {
+	if ts.deltaTracker == nil || ts.wsClient == nil || !ts.wsClient.IsConnected() {
+		return
+	}
+
+	if aggregate {
+		if iv, err := marketdata.ParseInterval(ts.interval); err == nil {
+			ts.aggregator = marketdata.NewCandleAggregator(iv, 0, ts.deltaTracker)
+		}
+	}
+
+	err := ts.wsClient.SubscribeTrades(ts.symbol, func(msg backpack.WSTradeMessage) {
+		trade, err := marketdata.TradeFromWS(msg)
+		if err != nil {
+			log.Printf("⚠️  %v", err)
+			return
+		}
+		if ts.aggregator != nil {
+			ts.aggregator.Add(trade) // 由聚合协程写入 deltaTracker
+			return
+		}
+		ts.deltaTracker.Add(trade)
+	})
+	if err != nil {
+		log.Printf("⚠️  订阅 %s 逐笔成交失败: %v，将使用 REST 回补", ts.symbol, err)
+		ts.aggregator = nil
+		return
+	}
+
+	ts.setTradeStreamSince(ts.clock.Now())
+	log.Printf("✅ 已订阅 %s 逐笔成交", ts.symbol)
+}
+
+// tradeStreamStart returns when the trade stream last became continuous (zero if not subscribed)
+func (ts *TradingSystem) tradeStreamStart() time.Time {
+	ts.streamMu.Lock()
+	defer ts.streamMu.Unlock()
+	return ts.tradeStreamSince
+}
+
+// setTradeStreamSince records when the trade stream became continuous
+func (ts *TradingSystem) setTradeStreamSince(t time.Time) {
+	ts.streamMu.Lock()
+	defer ts.streamMu.Unlock()
+	ts.tradeStreamSince = t
+}
+
+// handleStreamGap invalidates data that may have been missed while the WebSocket was down
+// 断线期间的成交重新通过 REST 回补，聚合K线跳过缺口（缺失的K线由 appendKline 从 REST 补齐）
+func (ts *TradingSystem) handleStreamGap(gap backpack.WSGap) {
+	if ts.tradeStreamStart().IsZero() {
+		return
+	}
+	log.Printf("⚠️  %s WebSocket 断线缺口 %s ~ %s，缺失成交将通过 REST 回补",
+		ts.symbol, gap.From.Format("15:04:05"), gap.Until.Format("15:04:05"))
+
+	ts.deltaTracker.Uncover(gap.From)
+	ts.setTradeStreamSince(gap.Until)
+	if ts.aggregator != nil {
+		ts.aggregator.MarkGap(gap.From, gap.Until)
+	}
+}
+
+// orderFlowDelta returns the real taker buy/sell delta of the bar from public trades
+// 逐笔数据不完整（未订阅成交流且回补失败）时退回按K线形态估算
+func (ts *TradingSystem) orderFlowDelta(ctx context.Context, kline models.KLine, historicalKlines []models.KLine) models.Delta {
+	if ts.deltaTracker == nil {
+		return ts.calculateDelta(kline, historicalKlines)
+	}
+
+	now := ts.clock.Now()
+
+	// 成交流在线期间的成交视为完整
+	if since := ts.tradeStreamStart(); !since.IsZero() && ts.wsClient != nil && ts.wsClient.IsConnected() {
+		ts.deltaTracker.MarkCovered(since, now)
+	}
+
+	delta, ok := ts.deltaTracker.BarDelta(kline.StartTime, now)
+	if !ok {
+		// 回补本根K线开始以来的成交（同时覆盖逐笔窗口）
+		if err := ts.deltaTracker.Backfill(ctx, ts.client, ts.symbol, kline.StartTime, now, maxTradeBackfillPages); err != nil {
+			log.Printf("⚠️  回补 %s 成交失败: %v", ts.symbol, err)
+		}
+		delta, ok = ts.deltaTracker.BarDelta(kline.StartTime, now)
+	}
+
+	if !ok {
+		log.Printf("⚠️  %s 逐笔成交不完整，Delta 按K线估算", ts.symbol)
+		return ts.calculateDelta(kline, historicalKlines)
+	}
+
+	ts.recordDelta(delta)
+	return delta
+}
+
+// indicatorInput builds the indicator input for klines, attaching per-bar deltas for cvd
+// 优先使用逐笔成交统计的 Delta；未订阅成交流或K线超出保留窗口时按K线形态估算
+func (ts *TradingSystem) indicatorInput(klines []models.KLine) indicators.Input {
+	in := indicators.NewInput(klines)
+	deltas := make([]models.Delta, len(klines))
+	if ts.deltaTracker != nil {
+		deltas = ts.deltaTracker.BarDeltas(in.Times)
+	}
+	for i, delta := range deltas {
+		if ts.deltaTracker == nil || delta.Estimated {
+			deltas[i] = estimateDelta(klines[i])
+		}
+	}
+	return in.WithDeltas(deltas)
+}
+
+// recordDelta appends a bar delta to the history (最多保留100根)
+func (ts *TradingSystem) recordDelta(delta models.Delta) {
+	ts.deltaHistory = append(ts.deltaHistory, delta)
+	if len(ts.deltaHistory) > 100 {
+		ts.deltaHistory = ts.deltaHistory[1:]
+	}
+}
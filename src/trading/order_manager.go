@@ -3,6 +3,8 @@ package trading
 import (
 	"fmt"
 	"time"
+
+	"vagues-go/src/clock"
 )
 
 // OrderStatus represents the status of an order
@@ -54,6 +56,7 @@ type OrderManager struct {
 	orders     map[string]*LocalOrder // 订单ID到订单的映射
 	openOrders []string               // 当前开仓订单ID列表
 	totalPnL   float64                // 总盈亏
+	clock      clock.Clock            // 开仓/平仓时间和本地订单ID使用的时钟
 }
 
 // NewOrderManager creates a new order manager
@@ -62,12 +65,18 @@ func NewOrderManager() *OrderManager {
 		orders:     make(map[string]*LocalOrder),
 		openOrders: make([]string, 0),
 		totalPnL:   0,
+		clock:      clock.Real,
 	}
 }
 
+// SetClock sets the clock used for entry/exit times and local order IDs (回放时使用模拟时钟)
+func (om *OrderManager) SetClock(c clock.Clock) {
+	om.clock = c
+}
+
 // OpenLong opens a long position
 func (om *OrderManager) OpenLong(symbol string, entryPrice, quantity float64, stopLoss, takeProfit float64) string {
-	orderID := generateOrderID()
+	orderID := generateOrderID(om.clock.Now())
 	order := &LocalOrder{
 		ID:               orderID,
 		Symbol:           symbol,
@@ -75,7 +84,7 @@ func (om *OrderManager) OpenLong(symbol string, entryPrice, quantity float64, st
 		EntryPrice:       entryPrice,
 		Quantity:         quantity,
 		Status:           OrderStatusOpen,
-		EntryTime:        time.Now(),
+		EntryTime:        om.clock.Now(),
 		StopLoss:         stopLoss,
 		TakeProfit:       takeProfit,
 		TrailingStopLoss: stopLoss, // Initialize to regular stop loss
@@ -95,7 +104,7 @@ func (om *OrderManager) OpenLong(symbol string, entryPrice, quantity float64, st
 
 // OpenShort opens a short position
 func (om *OrderManager) OpenShort(symbol string, entryPrice, quantity float64, stopLoss, takeProfit float64) string {
-	orderID := generateOrderID()
+	orderID := generateOrderID(om.clock.Now())
 	order := &LocalOrder{
 		ID:               orderID,
 		Symbol:           symbol,
@@ -103,7 +112,7 @@ func (om *OrderManager) OpenShort(symbol string, entryPrice, quantity float64, s
 		EntryPrice:       entryPrice,
 		Quantity:         quantity,
 		Status:           OrderStatusOpen,
-		EntryTime:        time.Now(),
+		EntryTime:        om.clock.Now(),
 		StopLoss:         stopLoss,
 		TakeProfit:       takeProfit,
 		TrailingStopLoss: stopLoss, // Initialize to regular stop loss
@@ -135,7 +144,7 @@ func (om *OrderManager) CloseOrder(orderID string, exitPrice float64, tradingFee
 	}
 
 	order.ExitPrice = exitPrice
-	order.ExitTime = time.Now()
+	order.ExitTime = om.clock.Now()
 	order.Status = OrderStatusClosed
 	order.TradingFee = tradingFee
 	order.FundingFee = fundingFee
@@ -353,6 +362,48 @@ func (om *OrderManager) UpdateOrderID(oldID, newID string) {
 }
 
 // generateOrderID generates a unique order ID
-func generateOrderID() string {
-	return fmt.Sprintf("LOCAL_%d", time.Now().UnixNano())
+func generateOrderID(now time.Time) string {
+	return fmt.Sprintf("LOCAL_%d", now.UnixNano())
+}
+
+// RecordEntryFill updates an open order with the actual average fill price and quantity
+// fee: 本次开仓成交的手续费（累加到 TradingFee）
+func (om *OrderManager) RecordEntryFill(orderID string, avgPrice, quantity, fee float64) error {
+	order, exists := om.orders[orderID]
+	if !exists {
+		return fmt.Errorf("订单不存在: %s", orderID)
+	}
+	if order.Status != OrderStatusOpen {
+		return fmt.Errorf("订单状态不是开仓状态: %s", orderID)
+	}
+
+	order.EntryPrice = avgPrice
+	order.Quantity = quantity
+	order.TradingFee += fee
+	order.HighestPrice = avgPrice
+	order.LowestPrice = avgPrice
+	return nil
+}
+
+// AddFee adds a trading fee (e.g. an exit fill) to an open order
+func (om *OrderManager) AddFee(orderID string, fee float64) {
+	if order, exists := om.orders[orderID]; exists && order.Status == OrderStatusOpen {
+		order.TradingFee += fee
+	}
+}
+
+// CancelOrder marks an open order that never filled as canceled
+func (om *OrderManager) CancelOrder(orderID string) error {
+	order, exists := om.orders[orderID]
+	if !exists {
+		return fmt.Errorf("订单不存在: %s", orderID)
+	}
+	if order.Status != OrderStatusOpen {
+		return fmt.Errorf("订单状态不是开仓状态: %s", orderID)
+	}
+
+	order.Status = OrderStatusCanceled
+	order.ExitTime = om.clock.Now()
+	om.removeOpenOrder(orderID)
+	return nil
 }
@@ -0,0 +1,66 @@
+package trading
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"vagues-go/src/marketdata"
+	"vagues-go/src/models"
+)
+
+// maxLoggedIssues 启动时校验历史K线最多逐条输出的问题数
+const maxLoggedIssues = 5
+
+// checkBar validates the last bar of window against the preceding bars and reports whether it may be evaluated
+// 问题均输出告警；开启 SkipInvalidBars 时，价格无效、OHLC 不一致、时间乱序或未对齐的K线不评估
+func (ts *TradingSystem) checkBar(window []models.KLine) bool {
+	issues := ts.validator.ValidateLast(window)
+	fatal := false
+	for _, issue := range issues {
+		log.Printf("⚠️  %s K线数据异常: %s", ts.symbol, issue)
+		fatal = fatal || issue.Fatal()
+	}
+	if fatal && ts.skipInvalidBars {
+		log.Printf("⚠️  %s K线 %s 未通过数据校验，跳过本次策略评估",
+			ts.symbol, window[len(window)-1].StartTime.Format("2006-01-02 15:04:05"))
+		return false
+	}
+	return true
+}
+
+// reportHistory validates the historical bars loaded at startup and logs a summary
+func (ts *TradingSystem) reportHistory(klines []models.KLine) {
+	issues := ts.validator.Validate(klines)
+	if len(issues) == 0 {
+		return
+	}
+
+	counts := make(map[marketdata.IssueKind]int)
+	var kinds []marketdata.IssueKind
+	for _, issue := range issues {
+		if counts[issue.Kind] == 0 {
+			kinds = append(kinds, issue.Kind)
+		}
+		counts[issue.Kind]++
+	}
+	summary := make([]string, len(kinds))
+	for i, kind := range kinds {
+		summary[i] = fmt.Sprintf("%s×%d", kind, counts[kind])
+	}
+	log.Printf("⚠️  %s 历史K线 %d 根中发现 %d 个数据问题 (%s)", ts.symbol, len(klines), len(issues), strings.Join(summary, ", "))
+	for _, issue := range issues[:min(len(issues), maxLoggedIssues)] {
+		log.Printf("   %s", issue)
+	}
+}
+
+// pollWindow returns the history ending with the polled bar (最新K线可能已包含在历史数据中)
+func pollWindow(history []models.KLine, latest models.KLine) []models.KLine {
+	end := len(history)
+	if end > 0 && history[end-1].StartTime.Equal(latest.StartTime) {
+		end--
+	}
+	window := make([]models.KLine, end, end+1)
+	copy(window, history[:end])
+	return append(window, latest)
+}
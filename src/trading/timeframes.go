@@ -0,0 +1,105 @@
+package trading
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"vagues-go/src/indicators"
+	"vagues-go/src/marketdata"
+	"vagues-go/src/models"
+)
+
+// timeframeSeries caches closed higher-timeframe bars with indicators for multi-timeframe confirmation
+type timeframeSeries struct {
+	interval  string
+	klines    []models.KLine      // 已收盘的高周期K线（升序）
+	data      []models.MarketData // 与 klines 一一对应的K线及指标
+	nextClose time.Time           // 下一根高周期K线的收盘时间，到达后刷新
+}
+
+// newTimeframeSeries creates empty caches for the configured higher timeframes
+func newTimeframeSeries(intervals []string) []*timeframeSeries {
+	series := make([]*timeframeSeries, 0, len(intervals))
+	for _, interval := range intervals {
+		series = append(series, &timeframeSeries{interval: interval})
+	}
+	return series
+}
+
+// timeframeLimit returns how many higher-timeframe bars are needed for GetTrendDirection (EMA169 等默认指标)
+func timeframeLimit() int {
+	return indicators.RequiredBars(indicators.DefaultSpecs...) + 10
+}
+
+// refreshTimeframes reloads higher-timeframe bars once a new one has closed
+// 通过 REST 获取对应周期K线；获取失败或K线不足以计算趋势指标时清空该周期数据，趋势过滤按缺少数据拒绝信号
+// （不再用基础周期重采样：基础周期历史通常只有几十根，重采样后远不够 EMA169 预热）
+func (ts *TradingSystem) refreshTimeframes(ctx context.Context) {
+	now := ts.clock.Now()
+	for _, tf := range ts.timeframes {
+		if now.Before(tf.nextClose) {
+			continue
+		}
+
+		iv, err := marketdata.ParseInterval(tf.interval)
+		if err != nil {
+			log.Printf("⚠️  多周期确认: %v", err)
+			tf.nextClose = now.Add(24 * time.Hour)
+			continue
+		}
+		limit := timeframeLimit()
+
+		klines, err := ts.fetchKlines(ctx, tf.interval, limit)
+		if err != nil {
+			log.Printf("❌ 获取 %s 周期K线失败: %v，暂无法进行多周期确认，下一根 %s K线收盘后重试", tf.interval, err, ts.interval)
+			tf.klines, tf.data = nil, nil
+			tf.nextClose = now.Add(ts.getIntervalDuration())
+			continue
+		}
+
+		// 只保留已收盘的K线，避免使用未完成的高周期数据
+		for len(klines) > 0 && klines[len(klines)-1].EndTime.After(now) {
+			klines = klines[:len(klines)-1]
+		}
+
+		// K线不足时 EMA144/169 等指标无法预热，趋势会一直判定为震荡
+		if required := indicators.RequiredBars(indicators.DefaultSpecs...); len(klines) < required {
+			log.Printf("⚠️  %s 周期K线不足 (当前: %d, 需要: %d)，暂无法进行多周期确认", tf.interval, len(klines), required)
+			tf.klines, tf.data = nil, nil
+			tf.nextClose = now.Add(ts.getIntervalDuration())
+			continue
+		}
+
+		calculated, err := ts.calculator.CalculateIndicators(klines)
+		if err != nil || len(calculated) == 0 {
+			log.Printf("⚠️  %s 周期计算技术指标失败: %v", tf.interval, err)
+			tf.klines, tf.data = nil, nil
+			tf.nextClose = now.Add(ts.getIntervalDuration())
+			continue
+		}
+
+		tf.klines = klines
+		tf.data = make([]models.MarketData, len(klines))
+		for i := range klines {
+			tf.data[i] = models.MarketData{KLine: klines[i], Indicators: calculated[i]}
+		}
+		// 最后一根已收盘K线之后的下一根收盘时再刷新
+		tf.nextClose = iv.Next(iv.Next(klines[len(klines)-1].StartTime))
+	}
+}
+
+// attachTimeframes sets data.Timeframes to the latest higher-timeframe bars closed by data's bar
+func (ts *TradingSystem) attachTimeframes(data *models.MarketData) {
+	if len(ts.timeframes) == 0 {
+		return
+	}
+
+	data.Timeframes = make(map[string]models.MarketData, len(ts.timeframes))
+	for _, tf := range ts.timeframes {
+		idx := marketdata.AlignLatestClosed([]models.KLine{data.KLine}, tf.klines)[0]
+		if idx >= 0 {
+			data.Timeframes[tf.interval] = tf.data[idx]
+		}
+	}
+}
@@ -2,16 +2,19 @@ package trading
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"vagues-go/src/backpack"
+	"vagues-go/src/clock"
 	"vagues-go/src/indicators"
+	"vagues-go/src/marketdata"
 	"vagues-go/src/models"
 	"vagues-go/src/notify"
 	"vagues-go/src/strategy"
@@ -19,20 +22,45 @@ import (
 
 // TradingSystem represents the main trading system
 type TradingSystem struct {
-	client        *backpack.Client
-	strategy      *strategy.PatternVolumeDeltaStrategy
-	orderManager  *OrderManager
-	calculator    *indicators.Calculator
-	symbol        string
-	interval      string
-	quantity      float64 // 保留用于兼容
-	stopLossPct   float64
-	takeProfitPct float64
-	leverage      int                      // 杠杆倍数
-	maxPosPct     float64                  // 单笔最大仓位占总权益比例
-	notifier      *notify.TelegramNotifier // Telegram 通知器
+	client         *backpack.Client
+	strategy       *strategy.PatternVolumeDeltaStrategy
+	orderManager   *OrderManager
+	calculator     *indicators.Calculator
+	symbol         string
+	interval       string
+	quantity       float64 // 保留用于兼容
+	stopLossPct    float64
+	takeProfitPct  float64
+	leverage       int                      // 杠杆倍数
+	maxPosPct      float64                  // 单笔最大仓位占总权益比例
+	atrPeriod      int                      // ATR 周期（用于 ATR 倍数止损止盈）
+	stopLossATR    float64                  // 止损距离 = ATR × 倍数（0 表示使用固定百分比）
+	takeProfitATR  float64                  // 止盈距离 = ATR × 倍数（0 表示使用固定百分比）
+	notifier       *notify.TelegramNotifier // Telegram 通知器
+	divergenceExit bool                     // 出现反向常规背离时主动平仓
+	divergenceSeen time.Time                // 最近一次触发平仓的背离的确认K线收盘时间
+	timeframes     []*timeframeSeries       // 多周期确认使用的高周期数据
 	// Delta tracking
-	deltaHistory []models.Delta // History of delta values
+	deltaHistory     []models.Delta               // History of delta values
+	deltaTracker     *marketdata.DeltaTracker     // 逐笔成交 Delta 统计（nil 表示按K线估算）
+	wsClient         *backpack.WSClient           // WebSocket 客户端（可选，用于订阅逐笔成交）
+	tradeStreamSince time.Time                    // 逐笔成交流连续在线的开始时间（订阅或重连）
+	streamMu         sync.Mutex                   // 保护 tradeStreamSince（断线回调在 WebSocket 协程中执行）
+	barSource        string                       // K线来源：trades、klines 或 poll（为空时自动选择）
+	aggregator       *marketdata.CandleAggregator // 逐笔成交聚合K线（nil 表示未使用）
+	klineCloser      *marketdata.KlineCloser      // K线推送收盘检测（nil 表示未使用）
+	klines           []models.KLine               // 推送模式下本地维护的已收盘K线
+	accountEvents    chan accountEvent            // 私有 stream 推送的订单/持仓事件（nil 表示未订阅）
+	lastExitPrice    float64                      // 最近一次平仓成交均价（持仓平仓时作为出场价）
+	orderBookEnabled bool                         // 是否订阅深度并维护本地订单簿
+	bookDepthBps     float64                      // 订单簿深度/失衡统计范围（距中间价基点）
+	bookSync         *marketdata.BookSync         // 本地订单簿同步（nil 表示未订阅）
+	maxSlippageBps   float64                      // 市价开仓预计滑点上限（基点，0 表示不检查）
+	recordMarkPrice  bool                         // 是否订阅标记价格（仅用于录制）
+	validator        *marketdata.Validator        // K线数据质量校验
+	skipInvalidBars  bool                         // K线未通过校验时跳过策略评估
+	clock            clock.Clock                  // 时钟（回放时为模拟时钟）
+	syncs            chan chan struct{}           // 回放同步请求（见 Sync）
 }
 
 // Config holds trading system configuration
@@ -47,10 +75,45 @@ type Config struct {
 	MaxTradingSymbols int     // 最大监控交易对数量（默认20，0表示不限制）
 	TelegramBotToken  string  // Telegram Bot Token
 	TelegramChatID    string  // Telegram Chat ID
+
+	Indicators []indicators.Spec // 策略额外需要的指标（如 rsi(7), bbands(20,2)）
+
+	ATRPeriod         int     // ATR 周期（默认14）
+	StopLossATRMult   float64 // ATR 倍数止损（0 表示使用 StopLossPct）
+	TakeProfitATRMult float64 // ATR 倍数止盈（0 表示使用 TakeProfitPct）
+	BreakoutATRMult   float64 // 突破形态需超出区间的 ATR 倍数（0 表示关闭）
+
+	TrendFilters []strategy.TrendFilter // 趋势过滤器（为空时使用默认 EMA30 过滤）
+
+	DivergenceFilter  bool     // 近期出现反向背离时拒绝进场
+	DivergenceExit    bool     // 持仓出现反向常规背离时主动平仓
+	DivergenceSources []string // 背离检测使用的指标（macd, rsi, cvd；为空时使用 macd, rsi）
+
+	ConfirmTimeframes []string // 多周期确认：这些高周期的趋势方向需与信号一致（如 "15m", "1h"）
+
+	WSClient           *backpack.WSClient // 已连接的 WebSocket 客户端（可选，多交易对共享）
+	EstimateDelta      bool               // 不使用逐笔成交，按K线形态估算 Delta（旧行为）
+	DeltaLookbackTicks int                // 逐笔 Delta 窗口（DELTA_LOOKBACK_TICKS，默认40）
+	DeltaWindow        string             // Delta 过滤使用的窗口："bar"（整根K线，默认）或 "ticks"
+	BarSource          string             // K线来源：trades（逐笔聚合）、klines（K线推送）、poll（REST 轮询）；为空时自动选择
+	OrderBook          bool               // 订阅深度并维护本地 L2 订单簿（需要 WebSocket）
+	BookDepthBps       float64            // 订单簿深度/失衡统计范围（距中间价基点，默认25）
+	MaxSlippageBps     float64            // 市价开仓预计滑点上限（基点，0 表示不检查）；多交易对模式下同时剔除价差过大的交易对
+	BookImbalanceMin   float64            // 订单簿失衡确认阈值（0~1，0 表示关闭），与 Delta 一起确认方向
+	RecordMarkPrice    bool               // 订阅标记价格推送（录制行情时使用）
+	Clock              clock.Clock        // 时钟（为空时使用系统时钟，回放时传入模拟时钟）
+	SkipInvalidBars    bool               // K线未通过数据质量校验（价格无效、OHLC 不一致、时间乱序或未对齐）时跳过策略评估
+	VolumeSpikeFactor  float64            // 成交量超过近期中位数的倍数时告警（0 表示默认20，负数关闭）
 }
 
 // NewTradingSystem creates a new trading system
-func NewTradingSystem(client *backpack.Client, config Config) *TradingSystem {
+// K线周期、策略声明的指标或趋势过滤器无效时返回错误
+func NewTradingSystem(client *backpack.Client, config Config) (*TradingSystem, error) {
+	iv, err := marketdata.ParseInterval(config.Interval)
+	if err != nil {
+		return nil, err
+	}
+
 	// 默认杠杆为1（无杠杆）
 	leverage := config.Leverage
 	if leverage <= 0 {
@@ -66,21 +129,97 @@ func NewTradingSystem(client *backpack.Client, config Config) *TradingSystem {
 	// 初始化 Telegram 通知器
 	telegramNotifier := notify.NewTelegramNotifier(config.TelegramBotToken, config.TelegramChatID)
 
-	return &TradingSystem{
-		client:        client,
-		strategy:      strategy.NewPatternVolumeDeltaStrategy(),
-		orderManager:  NewOrderManager(),
-		calculator:    indicators.NewCalculator(30),
-		symbol:        config.Symbol,
-		interval:      config.Interval,
-		quantity:      config.Quantity,
-		stopLossPct:   config.StopLossPct,
-		takeProfitPct: config.TakeProfitPct,
-		leverage:      leverage,
-		maxPosPct:     maxPosPct,
-		notifier:      telegramNotifier,
-		deltaHistory:  make([]models.Delta, 0),
+	// 默认ATR周期为14
+	atrPeriod := config.ATRPeriod
+	if atrPeriod <= 0 {
+		atrPeriod = 14
+	}
+
+	// 初始化策略及其声明的指标
+	strat := strategy.NewPatternVolumeDeltaStrategy()
+	if len(config.Indicators) > 0 {
+		strat.AddIndicators(config.Indicators...)
+	}
+	if len(config.TrendFilters) > 0 {
+		strat.SetTrendFilters(config.TrendFilters...)
+	}
+	if config.BreakoutATRMult > 0 {
+		strat.SetBreakoutATRFilter(atrPeriod, config.BreakoutATRMult)
+	}
+	if config.DivergenceFilter || config.DivergenceExit {
+		strat.SetDivergenceFilter(config.DivergenceFilter, config.DivergenceSources...)
+	}
+	if config.DeltaWindow != "" || config.DeltaLookbackTicks > 0 {
+		strat.SetDeltaWindow(config.DeltaWindow, config.DeltaLookbackTicks)
+	}
+	if config.BookImbalanceMin > 0 {
+		strat.SetBookImbalanceFilter(config.BookImbalanceMin)
+	}
+	if len(config.ConfirmTimeframes) > 0 {
+		strat.AddTrendFilters(strategy.TimeframeTrendFilter{Intervals: config.ConfirmTimeframes})
+	}
+	if config.StopLossATRMult > 0 || config.TakeProfitATRMult > 0 {
+		strat.AddIndicators(indicators.ATR(atrPeriod))
 	}
+	// K线数据质量校验
+	validator := marketdata.NewValidator(iv)
+	if config.VolumeSpikeFactor != 0 {
+		validator.SetVolumeSpike(config.VolumeSpikeFactor, 0)
+	}
+	if err := indicators.Validate(strat.RequiredIndicators()...); err != nil {
+		return nil, fmt.Errorf("策略指标声明无效: %w", err)
+	}
+
+	// 逐笔成交 Delta 统计
+	var deltaTracker *marketdata.DeltaTracker
+	if !config.EstimateDelta {
+		deltaTracker = marketdata.NewDeltaTracker(iv, strat.DeltaLookbackTicks())
+	}
+
+	// 订单簿深度统计范围默认25个基点
+	bookDepthBps := config.BookDepthBps
+	if bookDepthBps <= 0 {
+		bookDepthBps = defaultBookDepthBps
+	}
+
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real
+	}
+	orderManager := NewOrderManager()
+	orderManager.SetClock(clk)
+
+	return &TradingSystem{
+		client:           client,
+		strategy:         strat,
+		orderManager:     orderManager,
+		calculator:       indicators.NewCalculator(),
+		symbol:           config.Symbol,
+		interval:         config.Interval,
+		quantity:         config.Quantity,
+		stopLossPct:      config.StopLossPct,
+		takeProfitPct:    config.TakeProfitPct,
+		leverage:         leverage,
+		maxPosPct:        maxPosPct,
+		atrPeriod:        atrPeriod,
+		stopLossATR:      config.StopLossATRMult,
+		takeProfitATR:    config.TakeProfitATRMult,
+		notifier:         telegramNotifier,
+		divergenceExit:   config.DivergenceExit,
+		timeframes:       newTimeframeSeries(config.ConfirmTimeframes),
+		deltaHistory:     make([]models.Delta, 0),
+		deltaTracker:     deltaTracker,
+		wsClient:         config.WSClient,
+		barSource:        config.BarSource,
+		orderBookEnabled: config.OrderBook,
+		bookDepthBps:     bookDepthBps,
+		maxSlippageBps:   config.MaxSlippageBps,
+		recordMarkPrice:  config.RecordMarkPrice,
+		validator:        validator,
+		skipInvalidBars:  config.SkipInvalidBars,
+		clock:            clk,
+		syncs:            make(chan chan struct{}),
+	}, nil
 }
 
 // Run starts the trading system
@@ -103,13 +242,17 @@ func (ts *TradingSystem) Run(ctx context.Context) error {
 	}
 
 	// 获取历史K线数据（至少需要满足Volume过滤的20根+缓冲）
-	klines, err := ts.fetchHistoricalKlines(ctx, 50)
+	klines, err := ts.fetchHistoricalKlines(ctx, ts.historyLimit())
 	if err != nil {
 		return fmt.Errorf("获取历史K线数据失败: %w", err)
 	}
+	ts.reportHistory(klines)
 
 	// 计算技术指标
-	calculatedIndicators := ts.calculator.CalculateIndicators(klines)
+	calculatedIndicators, err := ts.calculator.CalculateIndicatorsInput(ts.indicatorInput(klines), ts.strategy.RequiredIndicators()...)
+	if err != nil {
+		return fmt.Errorf("计算技术指标失败: %w", err)
+	}
 	if len(calculatedIndicators) == 0 {
 		return fmt.Errorf("无法计算技术指标，数据不足")
 	}
@@ -123,6 +266,32 @@ func (ts *TradingSystem) Run(ctx context.Context) error {
 		}
 	}
 
+	// 加载多周期确认所需的高周期数据
+	ts.refreshTimeframes(ctx)
+
+	// 订阅逐笔成交（用于真实 Delta），按K线来源订阅K线推送
+	source := ts.resolveBarSource()
+	ts.startTradeStream(source == BarSourceTrades)
+	if source == BarSourceKlines {
+		ts.startKlineStream()
+	}
+	if ts.wsClient != nil {
+		ts.wsClient.OnGap(ts.handleStreamGap)
+	}
+
+	// 订阅订单和持仓更新（实时获取成交、止损止盈触发和平仓）
+	ts.startAccountStream()
+
+	// 订阅深度，维护本地订单簿（可选）
+	ts.startOrderBook(ctx)
+
+	// 录制行情时额外订阅标记价格（推送由录制器保存，交易逻辑不使用）
+	if ts.recordMarkPrice && ts.streamLive() {
+		if err := ts.wsClient.SubscribeMarkPrice(ts.getFuturesSymbol(), func(backpack.WSMarkPriceMessage) {}); err != nil {
+			log.Printf("⚠️  订阅 %s 标记价格失败: %v", ts.getFuturesSymbol(), err)
+		}
+	}
+
 	// 输出初始状态和指标
 	if len(marketData) > 0 {
 		delta := ts.calculateDelta(marketData[len(marketData)-1].KLine, klines)
@@ -131,26 +300,71 @@ func (ts *TradingSystem) Run(ctx context.Context) error {
 		ts.printStatus(ctx, marketData[len(marketData)-1], models.SignalNone, pattern, delta, accountBalance, quoteAsset)
 	}
 
-	// 主交易循环
-	ticker := time.NewTicker(ts.getIntervalDuration())
+	// WebSocket 可用时由收盘K线驱动（断线期间自动改为 REST 轮询）
+	if ts.aggregator != nil || ts.klineCloser != nil {
+		return ts.runStreaming(ctx, klines)
+	}
+
+	// 主交易循环（未使用 WebSocket 时轮询 REST）
+	ticker := ts.clock.NewTicker(ts.getIntervalDuration())
 	defer ticker.Stop()
 
 	// 注意：已禁用自动平仓功能，只保留开仓功能
 	// 止损止盈已通过API在开仓时设置，由交易所自动执行
 
+	poll := func() {
+		if err := ts.processNewData(ctx); err != nil {
+			log.Printf("处理新数据失败: %v", err)
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("交易系统停止")
 			return nil
-		case <-ticker.C:
-			if err := ts.processNewData(ctx); err != nil {
-				log.Printf("处理新数据失败: %v", err)
+		case <-ticker.C():
+			poll()
+		case event := <-ts.accountEvents:
+			ts.handleAccountEvent(ctx, event)
+		case reply := <-ts.syncs:
+			for progressed := true; progressed; {
+				progressed = false
+				select {
+				case event := <-ts.accountEvents:
+					ts.handleAccountEvent(ctx, event)
+					progressed = true
+				default:
+				}
+				select {
+				case <-ticker.C():
+					poll()
+					progressed = true
+				default:
+				}
 			}
+			close(reply)
 		}
 	}
 }
 
+// Sync waits until the main loop has handled everything already queued: bars, account events and due timers
+// 回放时每注入一条推送或推进一次时钟后调用，使处理顺序只取决于录制数据（实盘不需要调用）；主循环启动前调用会等待启动完成
+func (ts *TradingSystem) Sync(ctx context.Context) error {
+	reply := make(chan struct{})
+	select {
+	case ts.syncs <- reply:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-reply:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // processNewData processes new market data
 func (ts *TradingSystem) processNewData(ctx context.Context) error {
 	// 获取最新的K线数据
@@ -166,13 +380,24 @@ func (ts *TradingSystem) processNewData(ctx context.Context) error {
 	latestKline := klines[0]
 
 	// 获取历史数据来计算指标（至少需要满足Volume过滤的20根+缓冲）
-	historicalKlines, err := ts.fetchHistoricalKlines(ctx, 50)
+	historicalKlines, err := ts.fetchHistoricalKlines(ctx, ts.historyLimit())
 	if err != nil {
 		return fmt.Errorf("获取历史K线数据失败: %w", err)
 	}
 
+	// 数据质量校验
+	if !ts.checkBar(pollWindow(historicalKlines, latestKline)) {
+		return nil
+	}
+
+	// 计算Delta（逐笔成交的主动买卖差，数据不完整时按K线估算）；先于指标计算，使 cvd 包含当前K线的回补成交
+	delta := ts.orderFlowDelta(ctx, latestKline, historicalKlines)
+
 	// 计算技术指标
-	calculatedIndicators := ts.calculator.CalculateIndicators(historicalKlines)
+	calculatedIndicators, err := ts.calculator.CalculateIndicatorsInput(ts.indicatorInput(historicalKlines), ts.strategy.RequiredIndicators()...)
+	if err != nil {
+		return fmt.Errorf("计算技术指标失败: %w", err)
+	}
 	if len(calculatedIndicators) == 0 {
 		return fmt.Errorf("无法计算技术指标")
 	}
@@ -183,9 +408,17 @@ func (ts *TradingSystem) processNewData(ctx context.Context) error {
 		Indicators: calculatedIndicators[len(calculatedIndicators)-1],
 	}
 
-	// 计算Delta（简化版本：基于K线数据估算）
-	// 注意：真实实现需要逐笔交易数据，这里使用K线数据估算
-	delta := ts.calculateDelta(latestKline, historicalKlines)
+	// 附加已对齐的高周期数据（多周期确认）
+	ts.refreshTimeframes(ctx)
+	ts.attachTimeframes(&currentData)
+
+	return ts.evaluate(ctx, currentData, delta)
+}
+
+// evaluate runs the strategy on the current bar and handles the resulting signal
+func (ts *TradingSystem) evaluate(ctx context.Context, currentData models.MarketData, delta models.Delta) error {
+	// 附加当前订单簿统计（价差、深度、失衡）
+	ts.attachBook(&currentData)
 
 	// 分析市场信号
 	signal := ts.strategy.Analyze(currentData, delta)
@@ -199,6 +432,13 @@ func (ts *TradingSystem) processNewData(ctx context.Context) error {
 	// 输出当前状态和指标
 	ts.printStatus(ctx, currentData, signal, pattern, delta, accountBalance, quoteAsset)
 
+	// 背离平仓（可选）：持仓方向出现反向常规背离时主动平仓
+	if ts.divergenceExit {
+		if err := ts.checkDivergenceExit(ctx, currentData); err != nil {
+			log.Printf("⚠️  背离平仓失败: %v", err)
+		}
+	}
+
 	// 处理交易信号（只处理开仓信号，不处理平仓信号）
 	switch signal {
 	case models.SignalLongEntry:
@@ -233,9 +473,10 @@ func (ts *TradingSystem) handleLongEntry(ctx context.Context, data models.Market
 		return nil
 	}
 
-	// 计算止损止盈价格
-	stopLoss := data.KLine.Close * (1 - ts.stopLossPct/100)
-	takeProfit := data.KLine.Close * (1 + ts.takeProfitPct/100)
+	// 计算止损止盈价格（固定百分比或ATR倍数）
+	stopDistance, takeDistance := ts.exitDistances(data)
+	stopLoss := data.KLine.Close - stopDistance
+	takeProfit := data.KLine.Close + takeDistance
 
 	// 计算开仓数量：账户余额 * 杠杆 * 最大仓位比例 / 入场价格
 	quantity, err := ts.calculatePositionSize(ctx, data.KLine.Close, stopLoss)
@@ -248,6 +489,12 @@ func (ts *TradingSystem) handleLongEntry(ctx context.Context, data models.Market
 		return nil
 	}
 
+	// 按订单簿估算市价成交滑点，超过上限时跳过开仓
+	if ok, reason := ts.checkEntryLiquidity(ctx, true, quantity); !ok {
+		log.Printf("⚠️  流动性过滤未通过，跳过开多: %s", reason)
+		return nil
+	}
+
 	// 转换symbol为期货格式
 	futuresSymbol := ts.getFuturesSymbol()
 
@@ -281,11 +528,17 @@ func (ts *TradingSystem) handleLongEntry(ctx context.Context, data models.Market
 		TakeProfitTriggerBy:    "MarkPrice",   // 使用标记价格触发
 	}
 
+	// 下单前按交易对限制检查数量、价格和名义价值
+	if err := ts.checkOrderLimits(ctx, futuresSymbol, data.KLine.Close, quantityStr); err != nil {
+		log.Printf("⚠️  订单不满足交易对限制，跳过开多: %v", err)
+		return nil
+	}
+
 	log.Printf("正在通过API开多仓 - 交易对: %s, 数量: %s, 止损: %s, 止盈: %s (基于账户余额和杠杆计算)",
 		futuresSymbol, quantityStr, stopLossStr, takeProfitStr)
 	orderResp, err := ts.client.PlaceOrder(ctx, orderReq)
 	if err != nil {
-		return fmt.Errorf("API开多仓失败: %w", err)
+		return ts.entryFailed("开多", err)
 	}
 
 	// 获取账户信息以计算手续费
@@ -341,9 +594,10 @@ func (ts *TradingSystem) handleShortEntry(ctx context.Context, data models.Marke
 		return nil
 	}
 
-	// 计算止损止盈价格
-	stopLoss := data.KLine.Close * (1 + ts.stopLossPct/100)
-	takeProfit := data.KLine.Close * (1 - ts.takeProfitPct/100)
+	// 计算止损止盈价格（固定百分比或ATR倍数）
+	stopDistance, takeDistance := ts.exitDistances(data)
+	stopLoss := data.KLine.Close + stopDistance
+	takeProfit := data.KLine.Close - takeDistance
 
 	// 计算开仓数量：账户余额 * 杠杆 * 最大仓位比例 / 入场价格
 	quantity, err := ts.calculatePositionSize(ctx, data.KLine.Close, stopLoss)
@@ -356,6 +610,12 @@ func (ts *TradingSystem) handleShortEntry(ctx context.Context, data models.Marke
 		return nil
 	}
 
+	// 按订单簿估算市价成交滑点，超过上限时跳过开仓
+	if ok, reason := ts.checkEntryLiquidity(ctx, false, quantity); !ok {
+		log.Printf("⚠️  流动性过滤未通过，跳过开空: %s", reason)
+		return nil
+	}
+
 	// 转换symbol为期货格式
 	futuresSymbol := ts.getFuturesSymbol()
 
@@ -389,11 +649,17 @@ func (ts *TradingSystem) handleShortEntry(ctx context.Context, data models.Marke
 		TakeProfitTriggerBy:    "MarkPrice",   // 使用标记价格触发
 	}
 
+	// 下单前按交易对限制检查数量、价格和名义价值
+	if err := ts.checkOrderLimits(ctx, futuresSymbol, data.KLine.Close, quantityStr); err != nil {
+		log.Printf("⚠️  订单不满足交易对限制，跳过开空: %v", err)
+		return nil
+	}
+
 	log.Printf("正在通过API开空仓 - 交易对: %s, 数量: %s, 止损: %s, 止盈: %s (基于账户余额和杠杆计算)",
 		futuresSymbol, quantityStr, stopLossStr, takeProfitStr)
 	orderResp, err := ts.client.PlaceOrder(ctx, orderReq)
 	if err != nil {
-		return fmt.Errorf("API开空仓失败: %w", err)
+		return ts.entryFailed("开空", err)
 	}
 
 	// 获取账户信息以计算手续费
@@ -441,6 +707,47 @@ func (ts *TradingSystem) handleShortEntry(ctx context.Context, data models.Marke
 	return nil
 }
 
+// entryFailed handles a rejected entry order according to the API error category
+// 保证金不足和限频时跳过本次信号（不视为错误）；认证失败和订单无效需要人工处理，返回错误
+func (ts *TradingSystem) entryFailed(action string, err error) error {
+	switch {
+	case errors.Is(err, backpack.ErrInsufficientMargin):
+		log.Printf("⚠️  %s %s 保证金不足，跳过本次信号: %v", ts.symbol, action, err)
+		return nil
+	case errors.Is(err, backpack.ErrRateLimited):
+		log.Printf("⚠️  %s %s 触发交易所限频，跳过本次信号: %v", ts.symbol, action, err)
+		return nil
+	case errors.Is(err, backpack.ErrAuth):
+		return fmt.Errorf("API%s仓失败（请检查 API 密钥和系统时间）: %w", action, err)
+	case errors.Is(err, backpack.ErrInvalidOrder):
+		return fmt.Errorf("API%s仓失败（订单参数被拒绝，请检查数量和价格精度）: %w", action, err)
+	}
+	return fmt.Errorf("API%s仓失败: %w", action, err)
+}
+
+// exitDistances returns the stop loss and take profit distances from the entry price
+// 配置了ATR倍数且ATR可用时使用 ATR × 倍数，否则使用固定百分比
+func (ts *TradingSystem) exitDistances(data models.MarketData) (float64, float64) {
+	stopDistance := data.KLine.Close * ts.stopLossPct / 100
+	takeDistance := data.KLine.Close * ts.takeProfitPct / 100
+
+	atr := data.Indicators.Get(indicators.ATR(ts.atrPeriod).Key())
+	if atr <= 0 {
+		if ts.stopLossATR > 0 || ts.takeProfitATR > 0 {
+			log.Printf("⚠️  ATR(%d) 不可用，使用固定百分比止损止盈", ts.atrPeriod)
+		}
+		return stopDistance, takeDistance
+	}
+
+	if ts.stopLossATR > 0 {
+		stopDistance = atr * ts.stopLossATR
+	}
+	if ts.takeProfitATR > 0 {
+		takeDistance = atr * ts.takeProfitATR
+	}
+	return stopDistance, takeDistance
+}
+
 // handleLongExit handles long exit signal
 // 注意：此函数已禁用，不再自动平仓
 // 止损止盈已通过API在开仓时设置，由交易所自动执行
@@ -457,23 +764,153 @@ func (ts *TradingSystem) handleShortExit(ctx context.Context, data models.Market
 	return nil
 }
 
-// fetchHistoricalKlines fetches historical K-line data
-func (ts *TradingSystem) fetchHistoricalKlines(ctx context.Context, limit int) ([]models.KLine, error) {
-	endTime := time.Now().Unix()
-	startTime := endTime - int64(limit)*ts.getIntervalSeconds()
+// checkDivergenceExit closes open positions that an opposing regular divergence invalidates
+// 仅在启用 DivergenceExit 时调用；整个持仓通过 API 市价平仓一次，并取消附带的止损止盈触发单
+func (ts *TradingSystem) checkDivergenceExit(ctx context.Context, data models.MarketData) error {
+	openOrders := ts.orderManager.GetOpenOrders()
+	if len(openOrders) == 0 {
+		return nil
+	}
+
+	// 本地订单对应交易所同一交易对的同一持仓，按持仓方向检查
+	position := models.SignalLongEntry
+	if openOrders[0].OrderType == OrderTypeShort {
+		position = models.SignalShortEntry
+	}
+	// 只考虑开仓之后确认、且尚未处理过的背离
+	after := ts.divergenceSeen
+	for _, order := range openOrders {
+		if order.EntryTime.After(after) {
+			after = order.EntryTime
+		}
+	}
+	exitSignal, reason, confirmedAt := ts.strategy.CheckDivergenceExit(position, after)
+	if exitSignal == models.SignalNone {
+		return nil
+	}
 
-	klineResponses, err := ts.client.GetKlines(ctx, ts.symbol, ts.interval, &startTime, &endTime, &limit)
+	// 整个持仓只平仓一次
+	futuresSymbol := ts.getFuturesSymbol()
+	log.Printf("📉 检测到反向背离，准备平仓 %s (%d 笔本地订单): %s", futuresSymbol, len(openOrders), reason)
+	resp, err := ts.client.ClosePosition(ctx, futuresSymbol)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("平仓 %s 失败: %w", futuresSymbol, err)
 	}
+	ts.divergenceSeen = confirmedAt
 
-	klines := make([]models.KLine, len(klineResponses))
-	for i, resp := range klineResponses {
-		kline, err := ts.convertKlineResponse(resp)
-		if err != nil {
-			return nil, err
+	// 取消开仓时附带的止损止盈触发单，避免持仓平掉后触发开出新仓（不影响该交易对的其他挂单）
+	if err := ts.cancelAttachedTriggers(ctx, futuresSymbol, openOrders); err != nil {
+		log.Printf("⚠️  取消 %s 止损/止盈触发单失败: %v", futuresSymbol, err)
+		if ts.notifier != nil {
+			_ = ts.notifier.SendErrorNotification("取消触发单失败",
+				fmt.Sprintf("%s 背离平仓后取消止损/止盈触发单失败: %v，请手动检查挂单", futuresSymbol, err))
+		}
+	}
+
+	// 平仓成交均价（响应中没有成交信息时按收盘价估算）
+	exitPrice := data.KLine.Close
+	if resp != nil {
+		if price, ok := resp.AveragePrice(); ok {
+			exitPrice = price
+		}
+	}
+
+	// 按入场和出场金额估算手续费
+	var takerFeeRate float64 = 0.0006 // 默认 0.06% (如果获取失败)
+	if accountInfo, err := ts.client.GetAccount(ctx); err == nil && accountInfo != nil {
+		if fee, err := strconv.ParseFloat(accountInfo.FuturesTakerFee, 64); err == nil {
+			takerFeeRate = fee
+		}
+	}
+
+	for _, order := range openOrders {
+		tradingFee := (order.EntryPrice + exitPrice) * order.Quantity * takerFeeRate
+		if err := ts.orderManager.CloseOrder(order.ID, exitPrice, tradingFee, 0); err != nil {
+			log.Printf("⚠️  关闭本地订单失败: %v", err)
+			continue
+		}
+
+		log.Printf("✅ 背离平仓成功 - 订单ID: %s, 平仓价: %.4f, 盈亏: %.4f (%.2f%%)",
+			order.ID, order.ExitPrice, order.PnL, order.PnLPercent)
+
+		if ts.notifier != nil {
+			_ = ts.notifier.SendCloseNotification(
+				futuresSymbol,
+				fmt.Sprintf("%.4f", order.Quantity),
+				fmt.Sprintf("%.4f", order.ExitPrice),
+				fmt.Sprintf("%.4f", order.PnL),
+				fmt.Sprintf("%.2f%%", order.PnLPercent),
+				order.ID,
+			)
 		}
-		klines[i] = kline
+	}
+	ts.lastExitPrice = 0
+	return nil
+}
+
+// cancelAttachedTriggers cancels the stop loss / take profit triggers attached to the given local orders
+// 按平仓方向和触发价匹配交易所挂单，只取消这些触发单
+func (ts *TradingSystem) cancelAttachedTriggers(ctx context.Context, symbol string, orders []*LocalOrder) error {
+	resting, err := ts.client.GetOpenOrders(ctx, symbol)
+	if err != nil {
+		return fmt.Errorf("查询挂单失败: %w", err)
+	}
+	var errs []error
+	for _, trigger := range resting {
+		if !ts.isAttachedTrigger(ctx, symbol, trigger, orders) {
+			continue
+		}
+		if err := ts.client.CancelOrder(ctx, trigger.ID, symbol); err != nil {
+			errs = append(errs, fmt.Errorf("取消触发单 %s 失败: %w", trigger.ID, err))
+			continue
+		}
+		log.Printf("✅ 已取消触发单 %s (触发价 %s)", trigger.ID, trigger.TriggerPrice)
+	}
+	return errors.Join(errs...)
+}
+
+// isAttachedTrigger reports whether a resting order is the stop loss or take profit trigger placed with one of orders
+func (ts *TradingSystem) isAttachedTrigger(ctx context.Context, symbol string, resting backpack.OpenOrder, orders []*LocalOrder) bool {
+	triggerPrice, err := strconv.ParseFloat(resting.TriggerPrice, 64)
+	if err != nil {
+		return false
+	}
+	for _, order := range orders {
+		closingSide := "Ask"
+		if order.OrderType == OrderTypeShort {
+			closingSide = "Bid"
+		}
+		if resting.Side != closingSide {
+			continue
+		}
+		// 开仓时触发价按 tickSize 格式化后提交，这里同样格式化后比较
+		for _, price := range []float64{order.StopLoss, order.TakeProfit} {
+			if price <= 0 {
+				continue
+			}
+			if submitted, err := strconv.ParseFloat(ts.formatPriceByTickSize(ctx, price, symbol), 64); err == nil && submitted == triggerPrice {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// historyLimit returns how many K-lines to fetch for indicator calculation
+// 至少50根（Volume过滤的20根+缓冲），并满足策略声明指标的预热需求
+func (ts *TradingSystem) historyLimit() int {
+	limit := 50
+	if required := indicators.RequiredBars(ts.strategy.RequiredIndicators()...) + 10; required > limit {
+		limit = required
+	}
+	return limit
+}
+
+// fetchHistoricalKlines fetches historical K-line data
+func (ts *TradingSystem) fetchHistoricalKlines(ctx context.Context, limit int) ([]models.KLine, error) {
+	klines, err := ts.fetchKlines(ctx, ts.interval, limit)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(klines) > 0 {
@@ -486,129 +923,80 @@ func (ts *TradingSystem) fetchHistoricalKlines(ctx context.Context, limit int) (
 	return klines, nil
 }
 
-// fetchLatestKlines fetches the latest K-line data
-func (ts *TradingSystem) fetchLatestKlines(ctx context.Context, limit int) ([]models.KLine, error) {
-	// 使用当前时间作为结束时间，根据limit计算开始时间
-	endTime := time.Now().Unix()
-	startTime := endTime - int64(limit)*ts.getIntervalSeconds()
+// fetchKlines fetches the most recent limit K-lines of the given interval
+// 交易所不直接提供的周期（如 10m、2d）由可整除的最大原生周期获取后重采样
+func (ts *TradingSystem) fetchKlines(ctx context.Context, interval string, limit int) ([]models.KLine, error) {
+	iv, err := marketdata.ParseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	source := iv
+	if !iv.IsExchangeInterval() {
+		source = iv.SourceInterval()
+	}
+
+	// 重采样时多取一个目标周期的数据，以补齐第一个不完整的桶
+	count := limit
+	if source != iv {
+		factor := int(iv.Duration() / source.Duration())
+		count = (limit + 1) * factor
+	}
+
+	endTime := ts.clock.Now().Unix()
+	startTime := endTime - int64(count)*int64(source.Duration()/time.Second)
 
-	klineResponses, err := ts.client.GetKlines(ctx, ts.symbol, ts.interval, &startTime, &endTime, &limit)
+	klineResponses, err := ts.client.GetKlines(ctx, ts.symbol, source.String(), &startTime, &endTime, &count)
 	if err != nil {
 		return nil, err
 	}
 
 	klines := make([]models.KLine, len(klineResponses))
 	for i, resp := range klineResponses {
-		kline, err := ts.convertKlineResponse(resp)
+		kline, err := marketdata.KLineFromREST(resp)
 		if err != nil {
 			return nil, err
 		}
 		klines[i] = kline
 	}
 
-	if len(klines) > 0 {
-		log.Printf("成功获取 %d 条最新K线数据 (最新时间: %s)",
-			len(klines),
-			klines[0].EndTime.Format("2006-01-02 15:04:05"))
+	if source != iv {
+		klines = marketdata.Resample(klines, iv)
+		if len(klines) > limit {
+			klines = klines[len(klines)-limit:]
+		}
 	}
-
 	return klines, nil
 }
 
-// convertKlineResponse converts backpack KlineResponse to models.KLine
-func (ts *TradingSystem) convertKlineResponse(resp backpack.KlineResponse) (models.KLine, error) {
-	// Parse time - try multiple formats
-	// Format 1: "2006-01-02 15:04:05" (space-separated)
-	// Format 2: "2006-01-02T15:04:05Z" (RFC3339)
-	timeFormats := []string{
-		"2006-01-02 15:04:05",
-		time.RFC3339,
-		"2006-01-02T15:04:05",
-	}
-
-	var startTime, endTime time.Time
-	var err error
-
-	// Parse start time
-	for _, format := range timeFormats {
-		startTime, err = time.Parse(format, resp.Start)
-		if err == nil {
-			break
-		}
-	}
+// fetchLatestKlines fetches the latest K-line data
+func (ts *TradingSystem) fetchLatestKlines(ctx context.Context, limit int) ([]models.KLine, error) {
+	klines, err := ts.fetchKlines(ctx, ts.interval, limit)
 	if err != nil {
-		return models.KLine{}, fmt.Errorf("解析开始时间失败: %w (时间字符串: %s)", err, resp.Start)
+		return nil, err
 	}
 
-	// Parse end time
-	for _, format := range timeFormats {
-		endTime, err = time.Parse(format, resp.End)
-		if err == nil {
-			break
-		}
+	if len(klines) > 0 {
+		log.Printf("成功获取 %d 条最新K线数据 (最新时间: %s)",
+			len(klines),
+			klines[0].EndTime.Format("2006-01-02 15:04:05"))
 	}
-	if err != nil {
-		return models.KLine{}, fmt.Errorf("解析结束时间失败: %w (时间字符串: %s)", err, resp.End)
-	}
-
-	// Parse prices and volumes
-	open, _ := strconv.ParseFloat(resp.Open, 64)
-	high, _ := strconv.ParseFloat(resp.High, 64)
-	low, _ := strconv.ParseFloat(resp.Low, 64)
-	close, _ := strconv.ParseFloat(resp.Close, 64)
-	volume, _ := strconv.ParseFloat(resp.Volume, 64)
-	quoteVolume, _ := strconv.ParseFloat(resp.QuoteVolume, 64)
-
-	return models.KLine{
-		StartTime:   startTime,
-		EndTime:     endTime,
-		Open:        open,
-		High:        high,
-		Low:         low,
-		Close:       close,
-		Volume:      volume,
-		QuoteVolume: quoteVolume,
-	}, nil
+
+	return klines, nil
 }
 
-// getIntervalDuration returns the duration for the trading interval
+// getIntervalDuration returns the duration of the trading interval
 func (ts *TradingSystem) getIntervalDuration() time.Duration {
-	switch ts.interval {
-	case "1m":
-		return time.Minute
-	case "5m":
-		return 5 * time.Minute
-	case "15m":
-		return 15 * time.Minute
-	case "1h":
-		return time.Hour
-	case "4h":
-		return 4 * time.Hour
-	case "1d":
-		return 24 * time.Hour
-	default:
-		return 5 * time.Minute // Default to 5 minutes
-	}
+	return intervalDuration(ts.interval)
 }
 
 // getIntervalSeconds returns the interval in seconds
 func (ts *TradingSystem) getIntervalSeconds() int64 {
-	switch ts.interval {
-	case "1m":
-		return 60
-	case "5m":
-		return 300
-	case "15m":
-		return 900
-	case "1h":
-		return 3600
-	case "4h":
-		return 14400
-	case "1d":
-		return 86400
-	default:
-		return 300 // Default to 5 minutes
-	}
+	return int64(ts.getIntervalDuration() / time.Second)
+}
+
+// intervalDuration returns the duration of a K-line interval (any multiple such as 3m, 10m, 2h, 12h, 1w)
+func intervalDuration(interval string) time.Duration {
+	return marketdata.IntervalDuration(interval, 5*time.Minute) // 无法解析时默认5分钟
 }
 
 // getFuturesSymbol converts spot symbol to futures symbol format
@@ -658,40 +1046,13 @@ func (ts *TradingSystem) calculatePositionSize(ctx context.Context, entryPrice,
 	return quantity, nil
 }
 
-// formatQuantityByStepSize 根据交易对的 stepSize 格式化数量
+// formatQuantityByStepSize 根据交易对的 stepSize 格式化数量（向下取整）
+// 不会上调到最小数量（否则会超出按风险计算的仓位），小于最小数量的订单由 checkOrderLimits 拒绝
 func (ts *TradingSystem) formatQuantityByStepSize(ctx context.Context, quantity float64, symbol string) string {
-	// 尝试从市场信息获取 stepSize
-	markets, err := ts.client.GetMarkets(ctx)
-	if err == nil {
-		for _, market := range markets {
-			if market.Symbol == symbol {
-				qf, err := market.GetQuantityFilter()
-				if err == nil && qf.StepSize != "" {
-					// 解析 stepSize
-					stepSize, err := strconv.ParseFloat(qf.StepSize, 64)
-					if err == nil && stepSize > 0 {
-						// 将数量对齐到 stepSize 的倍数（向下取整）
-						alignedQuantity := math.Floor(quantity/stepSize) * stepSize
-						// 确保不小于最小数量
-						if qf.MinQuantity != "" {
-							minQty, err := strconv.ParseFloat(qf.MinQuantity, 64)
-							if err == nil && alignedQuantity < minQty {
-								alignedQuantity = minQty
-							}
-						}
-						// 根据 stepSize 计算小数位数
-						decimals := ts.countDecimals(stepSize)
-						// 格式化
-						quantityStr := fmt.Sprintf("%."+fmt.Sprintf("%d", decimals)+"f", alignedQuantity)
-						// 移除尾部的0和小数点
-						quantityStr = strings.TrimRight(quantityStr, "0")
-						quantityStr = strings.TrimSuffix(quantityStr, ".")
-						return quantityStr
-					}
-				}
-				break
-			}
-		}
+	info, err := ts.client.GetMarketInfo(ctx, symbol)
+	if err == nil && !info.StepSize.IsZero() {
+		aligned := info.RoundQuantity(quantity, backpack.RoundDown)
+		return info.FormatQuantity(aligned, backpack.RoundNearest) // aligned 已对齐，只消除浮点误差
 	}
 
 	// 如果无法获取 stepSize，使用保守的2位小数
@@ -701,56 +1062,11 @@ func (ts *TradingSystem) formatQuantityByStepSize(ctx context.Context, quantity
 	return quantityStr
 }
 
-// countDecimals 计算小数位数
-func (ts *TradingSystem) countDecimals(value float64) int {
-	str := fmt.Sprintf("%g", value)
-	if !strings.Contains(str, ".") {
-		return 0
-	}
-	parts := strings.Split(str, ".")
-	if len(parts) != 2 {
-		return 0
-	}
-	// 移除尾部的0
-	decimals := strings.TrimRight(parts[1], "0")
-	return len(decimals)
-}
-
-// formatPriceByTickSize 根据交易对的 tickSize 格式化价格
+// formatPriceByTickSize 根据交易对的 tickSize 格式化价格（向下取整）
 func (ts *TradingSystem) formatPriceByTickSize(ctx context.Context, price float64, symbol string) string {
-	// 尝试从市场信息获取 tickSize
-	markets, err := ts.client.GetMarkets(ctx)
-	if err == nil {
-		for _, market := range markets {
-			if market.Symbol == symbol {
-				// 尝试获取 priceFilter
-				if priceFilterData, ok := market.Filters["priceFilter"]; ok {
-					// 将 priceFilterData 转换为 JSON 再解析
-					filterJSON, err := json.Marshal(priceFilterData)
-					if err == nil {
-						var priceFilter struct {
-							TickSize string `json:"tickSize"`
-						}
-						if err := json.Unmarshal(filterJSON, &priceFilter); err == nil && priceFilter.TickSize != "" {
-							tickSize, err := strconv.ParseFloat(priceFilter.TickSize, 64)
-							if err == nil && tickSize > 0 {
-								// 将价格对齐到 tickSize 的倍数（向下取整）
-								alignedPrice := math.Floor(price/tickSize) * tickSize
-								// 计算小数位数
-								decimals := ts.countDecimals(tickSize)
-								// 格式化
-								priceStr := fmt.Sprintf("%."+fmt.Sprintf("%d", decimals)+"f", alignedPrice)
-								// 移除尾部的0和小数点
-								priceStr = strings.TrimRight(priceStr, "0")
-								priceStr = strings.TrimSuffix(priceStr, ".")
-								return priceStr
-							}
-						}
-					}
-				}
-				break
-			}
-		}
+	info, err := ts.client.GetMarketInfo(ctx, symbol)
+	if err == nil && !info.TickSize.IsZero() {
+		return info.FormatPrice(price, backpack.RoundDown)
 	}
 
 	// 如果无法获取 tickSize，使用4位小数（大多数交易对的合理精度）
@@ -760,6 +1076,20 @@ func (ts *TradingSystem) formatPriceByTickSize(ctx context.Context, price float6
 	return priceStr
 }
 
+// checkOrderLimits checks the formatted order against the market's quantity, price and notional limits
+// 无法获取市场信息时不检查（由交易所校验）
+func (ts *TradingSystem) checkOrderLimits(ctx context.Context, symbol string, price float64, quantityStr string) error {
+	info, err := ts.client.GetMarketInfo(ctx, symbol)
+	if err != nil {
+		return nil
+	}
+	quantity, err := strconv.ParseFloat(quantityStr, 64)
+	if err != nil {
+		return fmt.Errorf("无效的数量: %s", quantityStr)
+	}
+	return info.CheckOrder(price, quantity)
+}
+
 // getAccountBalance gets account balance for the quote asset using backpack client
 func (ts *TradingSystem) getAccountBalance(ctx context.Context) (float64, string) {
 	// 通过backpack客户端获取账户余额
@@ -873,6 +1203,19 @@ func (ts *TradingSystem) getAccountBalance(ctx context.Context) (float64, string
 // calculateDelta calculates order flow delta from K-line data
 // Note: This is a simplified version. Real implementation requires tick-by-tick trade data
 func (ts *TradingSystem) calculateDelta(currentKline models.KLine, historicalKlines []models.KLine) models.Delta {
+	delta := estimateDelta(currentKline)
+
+	// Store in history
+	ts.deltaHistory = append(ts.deltaHistory, delta)
+	if len(ts.deltaHistory) > 100 {
+		ts.deltaHistory = ts.deltaHistory[1:]
+	}
+
+	return delta
+}
+
+// estimateDelta guesses a bar's taker buy/sell split from its candle shape
+func estimateDelta(currentKline models.KLine) models.Delta {
 	// Simplified delta calculation based on price movement and volume
 	// If close > open, assume more buy pressure; if close < open, assume more sell pressure
 	// This is an approximation - real delta requires aggressor side information from order book
@@ -901,19 +1244,17 @@ func (ts *TradingSystem) calculateDelta(currentKline models.KLine, historicalKli
 		sellVolume = currentKline.Volume * 0.5
 	}
 
-	delta := models.Delta{
+	return models.Delta{
 		Value:      buyVolume - sellVolume,
 		BuyVolume:  buyVolume,
 		SellVolume: sellVolume,
+		Estimated:  true,
 	}
+}
 
-	// Store in history
-	ts.deltaHistory = append(ts.deltaHistory, delta)
-	if len(ts.deltaHistory) > 100 {
-		ts.deltaHistory = ts.deltaHistory[1:]
-	}
-
-	return delta
+// GetClosedOrders returns the closed local orders (用于导出回测/实盘成交记录)
+func (ts *TradingSystem) GetClosedOrders() []*LocalOrder {
+	return ts.orderManager.GetClosedOrders()
 }
 
 // GetPerformance returns trading performance statistics
@@ -1045,6 +1386,10 @@ func (ts *TradingSystem) printStatus(ctx context.Context, data models.MarketData
 		pattern.Name, getSignalName(pattern.Direction), pattern.Confidence)
 	log.Printf("Delta: 值=%.2f | 买量=%.2f | 卖量=%.2f",
 		delta.Value, delta.BuyVolume, delta.SellVolume)
+	if book := data.Book; book != nil {
+		log.Printf("订单簿: 买一=%.4f | 卖一=%.4f | 价差=%.2fbps | %.0fbps 内买量=%.2f 卖量=%.2f | 失衡=%.2f",
+			book.BestBid, book.BestAsk, book.SpreadBps, book.DepthBps, book.BidDepth, book.AskDepth, book.Imbalance)
+	}
 	log.Println("--- 技术指标 ---")
 	log.Printf("EMA30: %.4f (趋势过滤)", ind.EMA30)
 	log.Printf("交易信号: %s", signalName)